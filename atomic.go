@@ -0,0 +1,66 @@
+package retrieve
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// partSuffix is appended to the output path while an atomic download is in progress.
+const partSuffix = ".part"
+
+// Atomic sets whether the download is written to "<output>.part" and
+// renamed to the output path only once it completes and passes any
+// checksum verification. Atomic writes are enabled by default, so an
+// interrupted download never leaves a truncated file at the output path.
+//
+// If the output path is a symlink, its target is written and replaced
+// instead, so the link is kept. Outputs that are not regular files, such
+// as /dev/null or a device, are written in place.
+func (b *Builder) Atomic(enabled bool) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.atomic = enabled
+	return b
+}
+
+// IsAtomic returns whether the download is written atomically.
+func (b *Builder) IsAtomic() bool {
+	return b.atomic
+}
+
+// writePath returns the path the download to outputPath is written to.
+func (b *Builder) writePath(outputPath string) string {
+	if !b.atomic {
+		return outputPath
+	}
+	target := finalPath(outputPath)
+	if info, err := os.Stat(target); err == nil && !info.Mode().IsRegular() {
+		return outputPath
+	}
+	return target + partSuffix
+}
+
+// finalPath returns the path an atomic download to outputPath is renamed
+// to: outputPath itself or, if it is a symlink, the file it points to.
+func finalPath(outputPath string) string {
+	if resolved, err := filepath.EvalSymlinks(outputPath); err == nil {
+		return resolved
+	}
+	// A dangling symlink is kept and its target created.
+	if target, err := os.Readlink(outputPath); err == nil {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(outputPath), target)
+		}
+		return target
+	}
+	return outputPath
+}
+
+// removeIncomplete removes the partially written file at path, unless it
+// is not a regular file, such as /dev/null or a symlink.
+func removeIncomplete(path string) {
+	if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,117 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomic(t *testing.T) {
+	assert.True(t, retrieve.New("http://example.com").IsAtomic())
+	assert.False(t, retrieve.New("http://example.com").Atomic(false).IsAtomic())
+}
+
+func TestExec_Atomic(t *testing.T) {
+	for _, atomic := range []bool{true, false} {
+		written := make(chan struct{})
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "10")
+			w.Write([]byte("01234"))
+			w.(http.Flusher).Flush()
+			close(written)
+			<-release
+			w.Write([]byte("56789"))
+		}))
+
+		output := filepath.Join(t.TempDir(), "out.txt")
+		done := make(chan error)
+		go func() {
+			done <- retrieve.New(server.URL).SetOutput(output).Atomic(atomic).Exec()
+		}()
+
+		<-written
+		if atomic {
+			assert.EventuallyWithT(t, func(c *assert.CollectT) {
+				assert.FileExists(c, output+".part")
+			}, time.Second, time.Millisecond)
+			assert.NoFileExists(t, output)
+		} else {
+			assert.EventuallyWithT(t, func(c *assert.CollectT) {
+				assert.FileExists(c, output)
+			}, time.Second, time.Millisecond)
+		}
+		close(release)
+
+		assert.NoError(t, <-done)
+		data, _ := os.ReadFile(output)
+		assert.Equal(t, "0123456789", string(data))
+		assert.NoFileExists(t, output+".part")
+		server.Close()
+	}
+}
+
+func TestExec_AtomicInterrupted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte("01234"))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.txt")
+	assert.Error(t, retrieve.New(server.URL).SetOutput(output).Exec())
+	assert.NoFileExists(t, output)
+
+	data, _ := os.ReadFile(output + ".part")
+	assert.Equal(t, "01234", string(data))
+}
+
+func TestExec_AtomicSymlink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	link := filepath.Join(dir, "link.txt")
+	assert.NoError(t, os.WriteFile(target, []byte("old"), 0o644))
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks are not supported:", err)
+	}
+
+	assert.NoError(t, retrieve.New(server.URL).SetOutput(link).Exec())
+
+	info, err := os.Lstat(link)
+	assert.NoError(t, err)
+	assert.True(t, info.Mode()&os.ModeSymlink != 0)
+	data, _ := os.ReadFile(target)
+	assert.Equal(t, "new", string(data))
+	assert.NoFileExists(t, target+".part")
+	assert.NoFileExists(t, link+".part")
+}
+
+func TestExec_AtomicDevice(t *testing.T) {
+	if _, err := os.Stat("/dev/null"); err != nil {
+		t.Skip("no /dev/null")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("discarded"))
+	}))
+	defer server.Close()
+
+	assert.NoError(t, retrieve.New(server.URL).SetOutput("/dev/null").Exec())
+	info, err := os.Stat("/dev/null")
+	assert.NoError(t, err)
+	assert.True(t, info.Mode()&os.ModeDevice != 0)
+	assert.NoFileExists(t, "/dev/null.part")
+}
@@ -0,0 +1,128 @@
+package retrieve
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// AuditEntry records a network destination contacted by a request, as
+// written by SetAuditLog.
+type AuditEntry struct {
+	// Time is when the request was sent.
+	Time time.Time `json:"time"`
+
+	// Download is the URL the download was started with, before rewrite
+	// rules, Resolvers, mirrors and redirects.
+	Download string `json:"download"`
+
+	// Method and URL are those of the request sent.
+	Method string `json:"method"`
+	URL    string `json:"url"`
+
+	// Host is the host and port requested.
+	Host string `json:"host"`
+
+	// Addr is the IP address and port connected to, which is the proxy's
+	// when one is used. It is empty if the connection was not made with
+	// net/http, as for FTP.
+	Addr string `json:"addr,omitempty"`
+
+	// Reused reports whether the connection was reused from an earlier
+	// request.
+	Reused bool `json:"reused,omitempty"`
+}
+
+// auditMu serializes the entries written to audit logs, which may be
+// shared by concurrent downloads.
+var auditMu sync.Mutex
+
+// SetAuditLog writes an AuditEntry to w, as a line of JSON, for every
+// request sent over the network, including those to mirrors and
+// redirects, so the hosts and addresses a download contacted can be
+// reviewed. Responses served from a cache set with SetCache are not
+// logged. w may be shared by several builders.
+func (b *Builder) SetAuditLog(w io.Writer) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.auditLog = w
+	return b
+}
+
+// GetAuditLog returns the writer set with SetAuditLog, if any.
+func (b *Builder) GetAuditLog() io.Writer {
+	return b.auditLog
+}
+
+// auditTransport logs the destination of every request.
+type auditTransport struct {
+	base http.RoundTripper
+	b    *Builder
+}
+
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "file" || req.URL.Scheme == "data" {
+		return t.base.RoundTrip(req)
+	}
+
+	entry := AuditEntry{
+		Time:     time.Now(),
+		Download: redactURL(t.b.auditURL),
+		Method:   req.Method,
+		URL:      req.URL.Redacted(),
+		Host:     hostPort(req.URL),
+	}
+	var mu sync.Mutex
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			entry.Addr = info.Conn.RemoteAddr().String()
+			entry.Reused = info.Reused
+		},
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+
+	mu.Lock()
+	defer mu.Unlock()
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	json.NewEncoder(t.b.auditLog).Encode(entry)
+	return resp, err
+}
+
+// hostPort returns the host and port of u, with the default port of its
+// scheme if it has none.
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(u.Scheme)
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// defaultPort returns the port used for scheme when a URL has none.
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "https":
+		return "443"
+	case "ftp", "ftps":
+		return "21"
+	}
+	return "80"
+}
+
+// redactURL returns rawURL with any password replaced, as url.URL.Redacted does.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
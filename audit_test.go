@@ -0,0 +1,93 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readAuditLog(t *testing.T, log *bytes.Buffer) []retrieve.AuditEntry {
+	var entries []retrieve.AuditEntry
+	for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+		var entry retrieve.AuditEntry
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestSetAuditLog(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer target.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/file", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	var log bytes.Buffer
+	b := retrieve.New(origin.URL + "/file").SetAuditLog(&log)
+	assert.Same(t, &log, b.GetAuditLog())
+	_, err := b.ExecBytes()
+	assert.NoError(t, err)
+
+	entries := readAuditLog(t, &log)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, origin.URL+"/file", entries[0].Download)
+		assert.Equal(t, origin.URL+"/file", entries[0].URL)
+		assert.Equal(t, http.MethodGet, entries[0].Method)
+		assert.Equal(t, origin.Listener.Addr().String(), entries[0].Host)
+		assert.Equal(t, origin.Listener.Addr().String(), entries[0].Addr)
+		assert.False(t, entries[0].Time.IsZero())
+
+		assert.Equal(t, origin.URL+"/file", entries[1].Download)
+		assert.Equal(t, target.URL+"/file", entries[1].URL)
+		assert.Equal(t, target.Listener.Addr().String(), entries[1].Addr)
+	}
+}
+
+func TestSetAuditLog_Mirrors(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer broken.Close()
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer mirror.Close()
+
+	var log bytes.Buffer
+	_, err := retrieve.New(broken.URL + "/file").
+		SetMirrors([]string{mirror.URL + "/file"}).
+		SetAuditLog(&log).
+		ExecBytes()
+	assert.NoError(t, err)
+
+	var addrs []string
+	for _, entry := range readAuditLog(t, &log) {
+		assert.Equal(t, broken.URL+"/file", entry.Download)
+		addrs = append(addrs, entry.Addr)
+	}
+	assert.Contains(t, addrs, broken.Listener.Addr().String())
+	assert.Contains(t, addrs, mirror.Listener.Addr().String())
+}
+
+func TestSetAuditLog_RedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	var log bytes.Buffer
+	_, err := retrieve.New(strings.Replace(server.URL, "http://", "http://user:secret@", 1) + "/file").SetAuditLog(&log).ExecBytes()
+	assert.NoError(t, err)
+	assert.NotContains(t, log.String(), "secret")
+}
@@ -0,0 +1,272 @@
+package retrieve
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultBatchWorkers = 4
+
+// Batch downloads many Builders concurrently using a pool of workers.
+type Batch struct {
+	builders   []*Builder
+	workers    int
+	ctx        context.Context
+	comparator BatchComparator
+	onProgress func(BatchProgress)
+
+	signals      []os.Signal
+	shutdownMode ShutdownMode
+
+	results     []BatchResult
+	followHints func(parent *Builder, hint Hint) *Builder
+}
+
+// BatchComparator orders queued items in a Batch. It returns a negative
+// number when a should be downloaded before b, a positive number when
+// after, and zero to keep their insertion order.
+type BatchComparator func(a, b *Builder) int
+
+// BatchResult is the outcome of a single item in a Batch.
+type BatchResult struct {
+	Builder      *Builder
+	Err          error
+	Duration     time.Duration
+	BytesWritten int64
+}
+
+// NewBatch initializes a new, empty Batch.
+func NewBatch() *Batch {
+	return &Batch{
+		workers: defaultBatchWorkers,
+		ctx:     context.Background(),
+	}
+}
+
+// Add queues one or more Builders for download.
+func (b *Batch) Add(builders ...*Builder) *Batch {
+	b.builders = append(b.builders, builders...)
+	return b
+}
+
+// AddURL queues a download of url to the given output path.
+func (b *Batch) AddURL(url, output string) *Batch {
+	return b.Add(New(url).SetOutput(output))
+}
+
+// GetBuilders returns the Builders queued in the batch.
+func (b *Batch) GetBuilders() []*Builder {
+	return b.builders
+}
+
+// SetWorkers sets how many downloads run concurrently.
+func (b *Batch) SetWorkers(n int) *Batch {
+	if n < 1 {
+		n = 1
+	}
+	b.workers = n
+	return b
+}
+
+// GetWorkers returns the number of concurrent downloads.
+func (b *Batch) GetWorkers() int {
+	return b.workers
+}
+
+// SetContext sets a context that cancels the whole batch.
+//
+// Cancelling it aborts in-flight downloads and skips items that have not started.
+func (b *Batch) SetContext(ctx context.Context) *Batch {
+	b.ctx = ctx
+	return b
+}
+
+// SetComparator sets the order in which queued items are started.
+//
+// See SmallestFirst, ExtensionsFirst and HighestPriorityFirst for built-in comparators.
+func (b *Batch) SetComparator(cmp BatchComparator) *Batch {
+	b.comparator = cmp
+	return b
+}
+
+// OnProgress registers a callback invoked whenever any item makes progress,
+// with totals and an estimated completion time for the whole batch.
+func (b *Batch) OnProgress(fn func(BatchProgress)) *Batch {
+	b.onProgress = fn
+	return b
+}
+
+// Exec downloads every queued item and returns one result per item,
+// in the order they were added.
+func (b *Batch) Exec() []BatchResult {
+	b.results = make([]BatchResult, len(b.builders))
+	items := make([]int, len(b.builders))
+	for i := range items {
+		items[i] = i
+	}
+	b.exec(b.ctx, items)
+	for b.followHints != nil && b.ctx.Err() == nil {
+		items = b.queueHints(items)
+		if len(items) == 0 {
+			break
+		}
+		b.results = append(b.results, make([]BatchResult, len(items))...)
+		b.exec(b.ctx, items)
+	}
+	return slices.Clone(b.results)
+}
+
+// RetryFailed downloads again only the items that failed or did not
+// complete in the previous Exec or RetryFailed, and those added since, so
+// a large sync can be re-run without re-checking the files it already
+// has. Downloads that failed while reading the response are resumed from
+// where they stopped. Items skipped with ErrNotModified are up to date and
+// are not retried.
+//
+// ctx cancels this run instead of the context set with SetContext. It
+// returns one result per item, in the order they were added, keeping the
+// previous results of the items that are not retried.
+func (b *Batch) RetryFailed(ctx context.Context) []BatchResult {
+	b.results = append(b.results, make([]BatchResult, len(b.builders)-len(b.results))...)
+	var items []int
+	for i, result := range b.results {
+		if result.Builder == nil || result.Err != nil && !errors.Is(result.Err, ErrNotModified) {
+			b.builders[i].keepPartial = true
+			items = append(items, i)
+		}
+	}
+	b.exec(ctx, items)
+	return slices.Clone(b.results)
+}
+
+// exec downloads the items at the given indices, storing their results.
+func (b *Batch) exec(ctx context.Context, items []int) {
+	builders := make([]*Builder, len(items))
+	for k, i := range items {
+		builders[k] = b.builders[i]
+	}
+	jobs := make(chan int)
+	tracker := newBatchTracker(builders, b.onProgress)
+
+	dispatchCtx, runCtx, interrupted, stop := b.shutdownContexts(ctx)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for range min(b.workers, len(items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range jobs {
+				i := items[k]
+				b.results[i] = b.run(b.builders[i], runCtx, tracker.item(k))
+				tracker.finish(k, b.results[i].Err)
+			}
+		}()
+	}
+
+	for _, k := range order(builders, b.comparator) {
+		i := items[k]
+		if dispatchCtx.Err() != nil {
+			b.results[i] = BatchResult{Builder: b.builders[i], Err: dispatchCtx.Err()}
+			tracker.finish(k, b.results[i].Err)
+			continue
+		}
+		select {
+		case jobs <- k:
+		case <-dispatchCtx.Done():
+			b.results[i] = BatchResult{Builder: b.builders[i], Err: dispatchCtx.Err()}
+			tracker.finish(k, b.results[i].Err)
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if interrupted() {
+		b.interrupt(items)
+	}
+}
+
+// order returns the indices of builders in the order they should start.
+func order(builders []*Builder, comparator BatchComparator) []int {
+	order := make([]int, len(builders))
+	for i := range order {
+		order[i] = i
+	}
+	if comparator != nil {
+		slices.SortStableFunc(order, func(i, j int) int {
+			return comparator(builders[i], builders[j])
+		})
+	}
+	return order
+}
+
+// run executes a single builder, tying its context to batchCtx and
+// reporting its progress to onProgress.
+func (b *Batch) run(builder *Builder, batchCtx context.Context, onProgress func(Progress)) BatchResult {
+	parent := builder.ctx
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(batchCtx, cancel)
+	builder.ctx = ctx
+
+	userProgress := builder.onProgress
+	builder.onProgress = func(p Progress) {
+		if userProgress != nil {
+			userProgress(p)
+		}
+		onProgress(p)
+	}
+
+	defer func() {
+		stop()
+		cancel()
+		builder.ctx = parent
+		builder.onProgress = userProgress
+	}()
+
+	start := time.Now()
+	err := builder.Exec()
+	return BatchResult{Builder: builder, Err: err, Duration: time.Since(start), BytesWritten: builder.progress.BytesWritten}
+}
+
+// SmallestFirst starts items with the smallest size hint first.
+// Items without a size hint start last.
+func SmallestFirst(a, b *Builder) int {
+	switch {
+	case a.sizeHint < 0 && b.sizeHint < 0:
+		return 0
+	case a.sizeHint < 0:
+		return 1
+	case b.sizeHint < 0:
+		return -1
+	}
+	return cmp.Compare(a.sizeHint, b.sizeHint)
+}
+
+// HighestPriorityFirst starts items with the highest priority hint first.
+func HighestPriorityFirst(a, b *Builder) int {
+	return cmp.Compare(b.priority, a.priority)
+}
+
+// ExtensionsFirst returns a comparator that starts items whose URL ends
+// with one of the given extensions first, in the order the extensions are listed.
+func ExtensionsFirst(exts ...string) BatchComparator {
+	rank := func(builder *Builder) int {
+		ext := strings.ToLower(path.Ext(builder.urlPath()))
+		for i, e := range exts {
+			if strings.EqualFold("."+strings.TrimPrefix(e, "."), ext) {
+				return i
+			}
+		}
+		return len(exts)
+	}
+	return func(a, b *Builder) int {
+		return cmp.Compare(rank(a), rank(b))
+	}
+}
@@ -0,0 +1,156 @@
+package retrieve
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// BatchProgress describes the overall state of a Batch.
+type BatchProgress struct {
+	// Items is the number of items in the batch.
+	Items int
+
+	// Completed is the number of items that finished, successfully or not.
+	Completed int
+
+	// Failed is the number of completed items that returned an error.
+	// Items skipped with ErrNotModified are up to date and not counted.
+	Failed int
+
+	// Active is the number of items currently downloading.
+	Active int
+
+	// BytesWritten is the number of bytes written across all items.
+	BytesWritten int64
+
+	// BytesPerSecond is the average throughput of the batch so far.
+	BytesPerSecond float64
+
+	// Elapsed is the time since the batch started.
+	Elapsed time.Duration
+
+	// ETA is the estimated time until the batch completes, or -1 if it cannot be estimated yet.
+	ETA time.Duration
+}
+
+type batchItemStats struct {
+	written int64
+	total   int64
+	active  bool
+	done    bool
+	failed  bool
+}
+
+// batchTracker aggregates per-item progress into BatchProgress events.
+type batchTracker struct {
+	mu    sync.Mutex
+	fn    func(BatchProgress)
+	start time.Time
+	items []batchItemStats
+}
+
+func newBatchTracker(builders []*Builder, fn func(BatchProgress)) *batchTracker {
+	t := &batchTracker{
+		fn:    fn,
+		start: time.Now(),
+		items: make([]batchItemStats, len(builders)),
+	}
+	for i, builder := range builders {
+		t.items[i].total = builder.sizeHint
+	}
+	return t
+}
+
+// item returns a progress callback for the item at index i.
+func (t *batchTracker) item(i int) func(Progress) {
+	return func(p Progress) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		item := &t.items[i]
+		item.active = true
+		item.written = p.BytesWritten
+		if p.TotalBytes >= 0 {
+			item.total = p.TotalBytes
+		}
+		t.emit()
+	}
+}
+
+// finish records that the item at index i completed with err.
+func (t *batchTracker) finish(i int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	item := &t.items[i]
+	item.active = false
+	item.done = true
+	item.failed = err != nil && !errors.Is(err, ErrNotModified)
+	t.emit()
+}
+
+// emit sends the current progress to the callback. Callers must hold t.mu.
+func (t *batchTracker) emit() {
+	if t.fn != nil {
+		t.fn(t.progress())
+	}
+}
+
+// progress computes the current BatchProgress. Callers must hold t.mu.
+//
+// The ETA is the number of bytes still expected divided by the average
+// throughput so far. Items of unknown size are assumed to be as large as
+// the average successfully completed item.
+func (t *batchTracker) progress() BatchProgress {
+	p := BatchProgress{
+		Items:   len(t.items),
+		Elapsed: time.Since(t.start),
+		ETA:     -1,
+	}
+
+	var completedBytes int64
+	var succeeded int
+	for _, item := range t.items {
+		p.BytesWritten += item.written
+		switch {
+		case item.done:
+			p.Completed++
+			if item.failed {
+				p.Failed++
+			} else {
+				succeeded++
+				completedBytes += item.written
+			}
+		case item.active:
+			p.Active++
+		}
+	}
+
+	if p.Elapsed > 0 {
+		p.BytesPerSecond = float64(p.BytesWritten) / p.Elapsed.Seconds()
+	}
+
+	if p.Completed == p.Items {
+		p.ETA = 0
+		return p
+	}
+	if p.BytesPerSecond <= 0 {
+		return p
+	}
+
+	var remaining int64
+	for _, item := range t.items {
+		if item.done {
+			continue
+		}
+		total := item.total
+		if total < 0 {
+			if succeeded == 0 {
+				return p
+			}
+			total = completedBytes / int64(succeeded)
+		}
+		remaining += max(total-item.written, 0)
+	}
+	p.ETA = time.Duration(float64(remaining) / p.BytesPerSecond * float64(time.Second))
+	return p
+}
@@ -0,0 +1,291 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBatch(t *testing.T) {
+	b := retrieve.NewBatch().SetWorkers(8).AddURL("http://example.com", "out.txt")
+	assert.Equal(t, 8, b.GetWorkers())
+	assert.Len(t, b.GetBuilders(), 1)
+}
+
+func TestBatch_Exec(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	dir := t.TempDir()
+	batch := retrieve.NewBatch().SetWorkers(2)
+	for i := range 5 {
+		batch.AddURL(fmt.Sprintf("%s/file%d", server.URL, i), filepath.Join(dir, fmt.Sprintf("file%d", i)))
+	}
+	batch.AddURL(server.URL+"/missing", filepath.Join(dir, "missing"))
+
+	results := batch.Exec()
+	assert.Len(t, results, 6)
+	assert.LessOrEqual(t, peak.Load(), int32(2))
+
+	for i, result := range results[:5] {
+		assert.NoError(t, result.Err)
+		data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("file%d", i)))
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("/file%d", i), string(data))
+	}
+	assert.Error(t, results[5].Err)
+}
+
+func TestBatch_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := retrieve.NewBatch().
+		SetContext(ctx).
+		AddURL("http://example.com/a", filepath.Join(t.TempDir(), "a")).
+		Exec()
+	assert.ErrorIs(t, results[0].Err, context.Canceled)
+}
+
+func TestBatch_Comparators(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		order = append(order, r.URL.Path)
+		mu.Unlock()
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	dir := t.TempDir()
+	newItem := func(name string) *retrieve.Builder {
+		return retrieve.New(server.URL + "/" + name).SetOutput(filepath.Join(dir, name))
+	}
+
+	tests := []struct {
+		name     string
+		cmp      retrieve.BatchComparator
+		builders []*retrieve.Builder
+		want     []string
+	}{
+		{
+			name: "smallest first",
+			cmp:  retrieve.SmallestFirst,
+			builders: []*retrieve.Builder{
+				newItem("unknown"),
+				newItem("large").SetSizeHint(1000),
+				newItem("small").SetSizeHint(10),
+			},
+			want: []string{"/small", "/large", "/unknown"},
+		},
+		{
+			name: "extensions first",
+			cmp:  retrieve.ExtensionsFirst("json", ".txt"),
+			builders: []*retrieve.Builder{
+				newItem("a.bin"),
+				newItem("b.txt"),
+				newItem("c.JSON"),
+			},
+			want: []string{"/c.JSON", "/b.txt", "/a.bin"},
+		},
+		{
+			name: "highest priority first",
+			cmp:  retrieve.HighestPriorityFirst,
+			builders: []*retrieve.Builder{
+				newItem("low").SetPriority(1),
+				newItem("high").SetPriority(5),
+				newItem("default"),
+			},
+			want: []string{"/high", "/low", "/default"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order = nil
+			results := retrieve.NewBatch().
+				SetWorkers(1).
+				SetComparator(tt.cmp).
+				Add(tt.builders...).
+				Exec()
+			for _, result := range results {
+				assert.NoError(t, result.Err)
+			}
+			assert.Equal(t, tt.want, order)
+			assert.Same(t, tt.builders[0], results[0].Builder)
+		})
+	}
+}
+
+func TestBatch_Progress(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 1024))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	dir := t.TempDir()
+	var events []retrieve.BatchProgress
+	var itemEvents atomic.Int32
+	results := retrieve.NewBatch().
+		SetWorkers(1).
+		AddURL(server.URL+"/a", filepath.Join(dir, "a")).
+		Add(retrieve.New(server.URL+"/b").
+			SetOutput(filepath.Join(dir, "b")).
+			OnProgress(func(p retrieve.Progress) { itemEvents.Add(1) })).
+		AddURL(server.URL+"/c", filepath.Join(dir, "c")).
+		OnProgress(func(p retrieve.BatchProgress) {
+			events = append(events, p)
+		}).
+		Exec()
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+
+	assert.NotEmpty(t, events)
+	assert.Positive(t, itemEvents.Load())
+
+	// After the first item completes, the remaining items are estimated from its size.
+	for _, p := range events {
+		if p.Completed == 1 && p.Active == 0 {
+			assert.GreaterOrEqual(t, p.ETA, time.Duration(0))
+		}
+	}
+
+	last := events[len(events)-1]
+	assert.Equal(t, 3, last.Items)
+	assert.Equal(t, 3, last.Completed)
+	assert.Equal(t, 0, last.Failed)
+	assert.Equal(t, int64(3*1024), last.BytesWritten)
+	assert.Equal(t, time.Duration(0), last.ETA)
+}
+
+func TestBatch_RetryFailed(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	var mu sync.Mutex
+	var requests []string
+	var broken atomic.Bool
+	broken.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Path+" "+r.Header.Get("Range"))
+		mu.Unlock()
+		if r.URL.Path == "/large" && broken.Load() {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data[:4000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	batch := retrieve.NewBatch().
+		AddURL(server.URL+"/small", filepath.Join(dir, "small")).
+		AddURL(server.URL+"/large", filepath.Join(dir, "large"))
+	results := batch.Exec()
+	assert.NoError(t, results[0].Err)
+	assert.Error(t, results[1].Err)
+
+	broken.Store(false)
+	batch.AddURL(server.URL+"/added", filepath.Join(dir, "added"))
+	mu.Lock()
+	requests = nil
+	mu.Unlock()
+
+	results = batch.RetryFailed(context.Background())
+	assert.Len(t, results, 3)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "large"))
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.ElementsMatch(t, []string{"/large bytes=4000-", "/added "}, requests)
+
+	// Nothing is left to retry.
+	requests = nil
+	assert.Len(t, batch.RetryFailed(context.Background()), 3)
+	assert.Empty(t, requests)
+}
+
+func TestBatch_NotModified(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "skipped"), []byte("content"), 0o644))
+
+	var last retrieve.BatchProgress
+	batch := retrieve.NewBatch().
+		Add(retrieve.New(server.URL+"/skipped").
+			SetOutput(filepath.Join(dir, "skipped")).
+			SkipIfSameSize()).
+		AddURL(server.URL+"/new", filepath.Join(dir, "new")).
+		OnProgress(func(p retrieve.BatchProgress) { last = p })
+	results := batch.Exec()
+	assert.ErrorIs(t, results[0].Err, retrieve.ErrNotModified)
+	assert.NoError(t, results[1].Err)
+	assert.Equal(t, 2, last.Completed)
+	assert.Equal(t, 0, last.Failed)
+
+	// The skipped item is up to date and not retried.
+	requests = nil
+	results = batch.RetryFailed(context.Background())
+	assert.ErrorIs(t, results[0].Err, retrieve.ErrNotModified)
+	assert.Empty(t, requests)
+}
+
+func TestBatch_RetryFailedContext(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	batch := retrieve.NewBatch().AddURL(server.URL+"/missing", filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, batch.Exec()[0].Err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := batch.RetryFailed(ctx)
+	assert.ErrorIs(t, results[0].Err, context.Canceled)
+}
@@ -0,0 +1,104 @@
+package retrieve
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// MirrorBenchmark holds the measurements taken for a single mirror by BenchmarkMirrors.
+type MirrorBenchmark struct {
+	URL string
+
+	// Latency is the time until the response headers were received.
+	Latency time.Duration
+
+	// Throughput is the transfer rate of the sample body in bytes per second.
+	Throughput float64
+
+	// Bytes is the number of sample bytes received.
+	Bytes int64
+
+	// Err is set if the mirror could not be benchmarked.
+	Err error
+}
+
+// Score returns the estimated time to fetch n bytes from the mirror.
+func (m MirrorBenchmark) Score(n int64) time.Duration {
+	if m.Err != nil || m.Throughput <= 0 {
+		return time.Duration(1<<63 - 1)
+	}
+	return m.Latency + time.Duration(float64(n)/m.Throughput*float64(time.Second))
+}
+
+// BenchmarkMirrors downloads a ranged sample of sampleBytes from each URL in
+// turn, measures its latency and throughput, and returns the results ranked
+// from fastest to slowest. Mirrors that fail are ranked last.
+func BenchmarkMirrors(urls []string, sampleBytes int64) []MirrorBenchmark {
+	return New("").benchmarkMirrors(urls, sampleBytes)
+}
+
+// benchmarkMirrors benchmarks urls using the builder's client configuration.
+func (b *Builder) benchmarkMirrors(urls []string, sampleBytes int64) []MirrorBenchmark {
+	client, release := b.httpClient()
+	defer release()
+
+	results := make([]MirrorBenchmark, len(urls))
+	for i, rawURL := range urls {
+		results[i] = b.benchmark(client, rawURL, sampleBytes)
+	}
+
+	slices.SortStableFunc(results, func(x, y MirrorBenchmark) int {
+		return cmp.Compare(x.Score(sampleBytes), y.Score(sampleBytes))
+	})
+	return results
+}
+
+// benchmark measures a single mirror.
+func (b *Builder) benchmark(client *http.Client, rawURL string, sampleBytes int64) MirrorBenchmark {
+	result := MirrorBenchmark{URL: rawURL}
+
+	if !isValidURL(rawURL) {
+		result.Err = fmt.Errorf("invalid URL: %s", rawURL)
+		return result
+	}
+
+	req, err := http.NewRequestWithContext(b.ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	for key, value := range b.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", sampleBytes-1))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+	result.Latency = time.Since(start)
+
+	if resp.StatusCode > 399 {
+		result.Err = newStatusError(resp)
+		return result
+	}
+
+	transferStart := time.Now()
+	result.Bytes, err = io.Copy(io.Discard, io.LimitReader(resp.Body, sampleBytes))
+	elapsed := time.Since(transferStart)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if elapsed > 0 {
+		result.Throughput = float64(result.Bytes) / elapsed.Seconds()
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBenchmarkMirrors(t *testing.T) {
+	newMirror := func(delay time.Duration) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			assert.Equal(t, "bytes=0-1023", r.Header.Get("Range"))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(make([]byte, 1024))
+		}))
+	}
+
+	slow := newMirror(50 * time.Millisecond)
+	defer slow.Close()
+	fast := newMirror(0)
+	defer fast.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	results := retrieve.BenchmarkMirrors([]string{broken.URL, slow.URL, fast.URL}, 1024)
+	assert.Len(t, results, 3)
+
+	assert.Equal(t, fast.URL, results[0].URL)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, int64(1024), results[0].Bytes)
+
+	assert.Equal(t, slow.URL, results[1].URL)
+	assert.GreaterOrEqual(t, results[1].Latency, 50*time.Millisecond)
+
+	assert.Equal(t, broken.URL, results[2].URL)
+	assert.Error(t, results[2].Err)
+}
@@ -0,0 +1,118 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ciathefed/retrieve/cache"
+)
+
+// ErrNotCached is returned when OnlyIfCached is set and no fresh response
+// is cached.
+var ErrNotCached = errors.New("response not cached")
+
+// Cache-Control request directives set with NoCache, ForceRevalidate and OnlyIfCached.
+const (
+	directiveNoCache      = "no-cache"
+	directiveRevalidate   = "max-age=0"
+	directiveOnlyIfCached = "only-if-cached"
+)
+
+// WithCache stores responses in an HTTP cache in dir, so repeated downloads
+// of the same resource are served from disk while fresh and revalidated
+// with the server once stale. Builders using the same dir share the cache.
+//
+// See the cache package for the caching rules that apply.
+func (b *Builder) WithCache(dir string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	c, err := cache.New(dir)
+	if err != nil {
+		b.err = fmt.Errorf("failed to open cache: %w", err)
+		return b
+	}
+	b.responseCache = c
+	return b
+}
+
+// SetCache sets the HTTP cache used for the request.
+func (b *Builder) SetCache(c *cache.Cache) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.responseCache = c
+	return b
+}
+
+// GetCache returns the HTTP cache used for the request, if any.
+func (b *Builder) GetCache() *cache.Cache {
+	return b.responseCache
+}
+
+// NoCache sends the request with "Cache-Control: no-cache", so a full
+// response is fetched from the server instead of a cached one, and stored
+// in the cache if allowed. Proxies on the way honor it too.
+//
+// Only one of NoCache, ForceRevalidate and OnlyIfCached applies; the last
+// one set wins.
+func (b *Builder) NoCache() *Builder {
+	return b.setCacheDirective(directiveNoCache)
+}
+
+// IsNoCache returns whether the request bypasses cached responses.
+func (b *Builder) IsNoCache() bool {
+	return b.cacheDirective == directiveNoCache
+}
+
+// ForceRevalidate sends the request with "Cache-Control: max-age=0", so a
+// cached response is revalidated with the server even while fresh. See
+// NoCache.
+func (b *Builder) ForceRevalidate() *Builder {
+	return b.setCacheDirective(directiveRevalidate)
+}
+
+// IsForceRevalidate returns whether cached responses are always revalidated.
+func (b *Builder) IsForceRevalidate() bool {
+	return b.cacheDirective == directiveRevalidate
+}
+
+// OnlyIfCached sends the request with "Cache-Control: only-if-cached", so
+// it is served from the cache without contacting the server. If no fresh
+// response is cached, Exec fails with ErrNotCached. See NoCache.
+func (b *Builder) OnlyIfCached() *Builder {
+	return b.setCacheDirective(directiveOnlyIfCached)
+}
+
+// IsOnlyIfCached returns whether the request is only served from the cache.
+func (b *Builder) IsOnlyIfCached() bool {
+	return b.cacheDirective == directiveOnlyIfCached
+}
+
+func (b *Builder) setCacheDirective(directive string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.cacheDirective = directive
+	b.headers["Cache-Control"] = directive
+	return b
+}
+
+// checkCached fails for the response to an only-if-cached request that
+// could not be served from a cache.
+func (b *Builder) checkCached(resp *http.Response) error {
+	if b.cacheDirective == directiveOnlyIfCached && resp.StatusCode == http.StatusGatewayTimeout {
+		return ErrNotCached
+	}
+	return nil
+}
+
+// fromCache reports whether resp was served from the builder's cache.
+func (b *Builder) fromCache(resp *http.Response) bool {
+	if b.responseCache == nil {
+		return false
+	}
+	status := resp.Header.Get(cache.StatusHeader)
+	return status == cache.StatusHit || status == cache.StatusRevalidated || status == cache.StatusStale
+}
@@ -0,0 +1,175 @@
+package cache
+
+import (
+	"archive/tar"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const archiveBodyExt = ".body"
+
+// Export writes every cached response to w as a tar archive, which Import
+// reads back into another Cache. This lets a machine with internet access
+// seed the cache of one without: responses in the archive are served
+// offline while fresh, and for as long as SetStaleIfError allows once stale.
+//
+// Each response is stored as its metadata, named after its key with a
+// .json extension, followed by its body. The archive is never encrypted:
+// data encrypted with SetEncryptionKey is decrypted, and Import encrypts
+// it with the key of the importing Cache.
+func (c *Cache) Export(w io.Writer) error {
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		if err := c.exportEntry(tw, e.key, e.entry); err != nil {
+			// The entry may have been replaced or evicted since it was listed.
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func (c *Cache) exportEntry(tw *tar.Writer, key string, e *entry) error {
+	body, err := c.openBlob(e.Body)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	archived := *e
+	archived.Body = key + archiveBodyExt
+	meta, err := json.Marshal(&archived)
+	if err != nil {
+		return err
+	}
+	if err := writeArchiveFile(tw, key+metaExt, meta, e.ResponseTime); err != nil {
+		return err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    archived.Body,
+		Mode:    0o644,
+		Size:    body.size,
+		ModTime: e.ResponseTime,
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, body)
+	return err
+}
+
+func writeArchiveFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// Import adds the responses of an archive written by Export to the cache,
+// replacing those stored for the same requests. It returns the number of
+// responses imported.
+func (c *Cache) Import(r io.Reader) (int, error) {
+	tr := tar.NewReader(r)
+	n := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		key, ok := strings.CutSuffix(hdr.Name, metaExt)
+		if !ok || !validKey(key) {
+			return n, fmt.Errorf("invalid cache archive entry: %s", hdr.Name)
+		}
+		if err := c.importEntry(tr, key); err != nil {
+			return n, err
+		}
+		n++
+	}
+	c.evict()
+	return n, nil
+}
+
+func (c *Cache) importEntry(tr *tar.Reader, key string) error {
+	var e entry
+	if err := json.NewDecoder(tr).Decode(&e); err != nil {
+		return fmt.Errorf("invalid cache archive entry: %s%s: %w", key, metaExt, err)
+	}
+	hdr, err := tr.Next()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	if hdr.Name != key+archiveBodyExt {
+		return fmt.Errorf("invalid cache archive entry: %s, want %s%s", hdr.Name, key, archiveBodyExt)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, key+".tmp*")
+	if err != nil {
+		return err
+	}
+	if err := c.writeBody(tmp, tr); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	e.Body = newBodyName(key)
+	if err := os.Rename(tmp.Name(), c.path(e.Body)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := c.saveEntry(key, &e); err != nil {
+		os.Remove(c.path(e.Body))
+		return err
+	}
+	return nil
+}
+
+// writeBody copies a body to f, encrypting it if the cache has a key.
+func (c *Cache) writeBody(f *os.File, r io.Reader) error {
+	if c.key == nil {
+		_, err := io.Copy(f, r)
+		return err
+	}
+	sw, err := c.newSealWriter(f)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(sw, r); err != nil {
+		return err
+	}
+	return sw.Close()
+}
+
+// validKey reports whether key is a key returned by cacheKey.
+func validKey(key string) bool {
+	b, err := hex.DecodeString(key)
+	return err == nil && len(b) == 32
+}
@@ -0,0 +1,86 @@
+package cache_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ciathefed/retrieve/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_ExportImport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("content of " + r.URL.Path))
+	}))
+	client, c := newClient(t)
+	get(t, client, server.URL+"/a")
+	get(t, client, server.URL+"/b")
+
+	var archive bytes.Buffer
+	assert.NoError(t, c.Export(&archive))
+	// The server is gone: the imported cache has to serve everything.
+	server.Close()
+
+	offline, err := cache.New(t.TempDir())
+	assert.NoError(t, err)
+	n, err := offline.Import(bytes.NewReader(archive.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	offlineClient := &http.Client{Transport: offline.Transport(nil)}
+	for _, path := range []string{"/a", "/b"} {
+		body, status := get(t, offlineClient, server.URL+path)
+		assert.Equal(t, cache.StatusHit, status)
+		assert.Equal(t, "content of "+path, body)
+	}
+}
+
+func TestCache_ExportImportEncrypted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	c, err := cache.New(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetEncryptionKey(testKey))
+	get(t, &http.Client{Transport: c.Transport(nil)}, server.URL)
+
+	var archive bytes.Buffer
+	assert.NoError(t, c.Export(&archive))
+	assert.Contains(t, archive.String(), "secret")
+
+	other, err := cache.New(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, other.SetEncryptionKey(bytes.Repeat([]byte{9}, 32)))
+	_, err = other.Import(&archive)
+	assert.NoError(t, err)
+
+	body, status := get(t, &http.Client{Transport: other.Transport(nil)}, server.URL)
+	assert.Equal(t, cache.StatusHit, status)
+	assert.Equal(t, "secret", body)
+}
+
+func TestCache_ImportInvalid(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	data := "{}"
+	tw.WriteHeader(&tar.Header{Name: "../escape.json", Mode: 0o644, Size: int64(len(data))})
+	tw.Write([]byte(data))
+	tw.Close()
+
+	_, c := newClient(t)
+	n, err := c.Import(&archive)
+	assert.ErrorContains(t, err, "invalid cache archive entry")
+	assert.Zero(t, n)
+
+	_, err = c.Import(strings.NewReader("not a tar archive"))
+	assert.Error(t, err)
+}
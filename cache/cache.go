@@ -0,0 +1,310 @@
+// Package cache implements an HTTP response cache stored on disk.
+//
+// The cache follows the caching rules of RFC 9111 (formerly RFC 7234) for a
+// private cache: responses are stored according to their Cache-Control,
+// Expires and Vary headers, served while fresh, and revalidated with
+// If-None-Match or If-Modified-Since once stale. The stale-while-revalidate
+// and stale-if-error extensions of RFC 5861 are supported, both as response
+// directives and as defaults set on the Cache. The size of the cache can be
+// capped with LRU or LFU eviction, and Stats reports how it performs.
+// Cached data can be encrypted at rest with SetEncryptionKey, and moved
+// between machines with Export and Import.
+//
+// A Cache is safe for concurrent use, and any number of Caches may share a
+// directory.
+package cache
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StatusHeader is set on every response that passes through the cache to
+// describe how it was served: StatusHit, StatusRevalidated or StatusMiss.
+const StatusHeader = "X-Cache"
+
+const (
+	// StatusHit means the response was served from the cache without contacting the server.
+	StatusHit = "HIT"
+	// StatusRevalidated means the server confirmed the cached response is still valid.
+	StatusRevalidated = "REVALIDATED"
+	// StatusMiss means the response came from the server.
+	StatusMiss = "MISS"
+	// StatusStale means a stale response was served from the cache, either
+	// while it is revalidated in the background or because the server failed.
+	StatusStale = "STALE"
+)
+
+const metaExt = ".json"
+
+// Cache is an HTTP response cache stored in a directory.
+type Cache struct {
+	dir string
+	now func() time.Time
+	key []byte
+
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+
+	maxSize int64
+	policy  EvictionPolicy
+	evictMu sync.Mutex
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+
+	hits, revalidations, stale, misses, evictions atomic.Int64
+}
+
+// New initializes a Cache that stores responses in dir, creating it if needed.
+func New(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, now: time.Now, refreshing: make(map[string]bool)}, nil
+}
+
+// SetStaleWhileRevalidate lets a response that has been stale for less
+// than d be served right away while it is revalidated in the background,
+// so callers never wait on the server for content they already have. A
+// stale-while-revalidate directive in the response takes precedence.
+//
+// It should be set before the Cache is used.
+func (c *Cache) SetStaleWhileRevalidate(d time.Duration) {
+	c.staleWhileRevalidate = d
+}
+
+// SetStaleIfError lets a response that has been stale for less than d be
+// served when the server cannot be reached or answers 500, 502, 503 or
+// 504, so a download keeps working while the origin is down. A
+// stale-if-error directive in the response takes precedence.
+//
+// It should be set before the Cache is used.
+func (c *Cache) SetStaleIfError(d time.Duration) {
+	c.staleIfError = d
+}
+
+// Dir returns the directory the cache stores responses in.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Delete removes the cached response for rawURL, if any.
+func (c *Cache) Delete(rawURL string) error {
+	key := cacheKey(http.MethodGet, rawURL)
+	e, err := c.loadEntry(key)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.remove(key, e)
+}
+
+// Clear removes every cached response.
+func (c *Cache) Clear() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			errs = append(errs, removeIfExists(c.path(entry.Name())))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// entry is the metadata of a cached response.
+type entry struct {
+	URL          string            `json:"url"`
+	StatusCode   int               `json:"status_code"`
+	Header       http.Header       `json:"header"`
+	Vary         map[string]string `json:"vary,omitempty"`
+	RequestTime  time.Time         `json:"request_time"`
+	ResponseTime time.Time         `json:"response_time"`
+	Body         string            `json:"body"`
+	LastAccess   time.Time         `json:"last_access"`
+	Hits         int64             `json:"hits,omitempty"`
+}
+
+// matches reports whether the entry was stored for a request with the same
+// values of the headers its response varies on.
+func (e *entry) matches(req *http.Request) bool {
+	for name, value := range e.Vary {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheKey returns the file name stem of the entry for a request.
+func cacheKey(method, rawURL string) string {
+	sum := sha256.Sum256([]byte(method + " " + rawURL))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) path(name string) string {
+	return filepath.Join(c.dir, name)
+}
+
+// loadEntry reads the metadata stored under key.
+func (c *Cache) loadEntry(key string) (*entry, error) {
+	data, err := os.ReadFile(c.path(key + metaExt))
+	if err != nil {
+		return nil, err
+	}
+	if data, err = c.unseal(data); err != nil {
+		return nil, err
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// load returns the entry stored under key and its opened body.
+func (c *Cache) load(key string) (*entry, *blob, error) {
+	e, err := c.loadEntry(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := c.openBlob(e.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, body, nil
+}
+
+// saveEntry atomically writes the metadata of e under key and removes the
+// body it replaces, if any.
+func (c *Cache) saveEntry(key string, e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if data, err = c.seal(data); err != nil {
+		return err
+	}
+
+	old, _ := c.loadEntry(key)
+	if err := c.writeFile(key+metaExt, data); err != nil {
+		return err
+	}
+	if old != nil && old.Body != e.Body {
+		removeIfExists(c.path(old.Body))
+	}
+	return nil
+}
+
+// writeFile atomically replaces the file name in the cache directory.
+func (c *Cache) writeFile(name string, data []byte) error {
+	tmp, err := os.CreateTemp(c.dir, name+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path(name)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// newBodyName returns a unique file name for a new body stored under key.
+func newBodyName(key string) string {
+	var b [8]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%s-%s.body", key, hex.EncodeToString(b[:]))
+}
+
+// bodyWriter copies a response body into the cache as it is read and
+// commits the entry once the body has been read to the end.
+type bodyWriter struct {
+	body   io.ReadCloser
+	tmp    *os.File
+	seal   *sealWriter // encrypts into tmp if the cache has a key
+	commit func(tmpName string) error
+}
+
+func (bw *bodyWriter) Read(p []byte) (int, error) {
+	n, err := bw.body.Read(p)
+	if bw.tmp != nil && n > 0 {
+		var w io.Writer = bw.tmp
+		if bw.seal != nil {
+			w = bw.seal
+		}
+		if _, werr := w.Write(p[:n]); werr != nil {
+			bw.abort()
+		}
+	}
+	if err == io.EOF && bw.tmp != nil {
+		bw.finish()
+	}
+	return n, err
+}
+
+func (bw *bodyWriter) Close() error {
+	bw.abort()
+	return bw.body.Close()
+}
+
+// finish commits the cached copy of a fully read body.
+func (bw *bodyWriter) finish() {
+	name := bw.tmp.Name()
+	var err error
+	if bw.seal != nil {
+		err = bw.seal.Close()
+	}
+	if closeErr := bw.tmp.Close(); err == nil {
+		err = closeErr
+	}
+	bw.tmp = nil
+	if err == nil {
+		err = bw.commit(name)
+	}
+	if err != nil {
+		os.Remove(name)
+	}
+}
+
+// abort discards the cached copy of a body that was not read to the end.
+func (bw *bodyWriter) abort() {
+	if bw.tmp == nil {
+		return
+	}
+	bw.tmp.Close()
+	os.Remove(bw.tmp.Name())
+	bw.tmp = nil
+}
+
+func removeIfExists(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,348 @@
+package cache_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newClient(t *testing.T) (*http.Client, *cache.Cache) {
+	c, err := cache.New(t.TempDir())
+	assert.NoError(t, err)
+	return &http.Client{Transport: c.Transport(nil)}, c
+}
+
+func get(t *testing.T, client *http.Client, url string, header ...string) (string, string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	assert.NoError(t, err)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := client.Do(req)
+	if !assert.NoError(t, err) {
+		return "", ""
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return string(body), resp.Header.Get(cache.StatusHeader)
+}
+
+func TestCache_Fresh(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	client, _ := newClient(t)
+
+	body, status := get(t, client, server.URL)
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, cache.StatusMiss, status)
+
+	body, status = get(t, client, server.URL)
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, cache.StatusHit, status)
+	assert.Equal(t, int32(1), requests.Load())
+
+	_, status = get(t, client, server.URL, "Cache-Control", "no-cache")
+	assert.Equal(t, cache.StatusMiss, status)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestCache_Heuristics(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/expires":
+			w.Header().Set("Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		case "/last-modified":
+			w.Header().Set("Last-Modified", time.Now().Add(-240*time.Hour).UTC().Format(http.TimeFormat))
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	client, _ := newClient(t)
+	for _, path := range []string{"/expires", "/last-modified"} {
+		get(t, client, server.URL+path)
+		_, status := get(t, client, server.URL+path)
+		assert.Equal(t, cache.StatusHit, status, path)
+	}
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestCache_NotStored(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/vary-star":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "*")
+		case "/not-found":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	client, _ := newClient(t)
+	for _, path := range []string{"/no-store", "/vary-star", "/not-found", "/no-validators"} {
+		get(t, client, server.URL+path)
+		_, status := get(t, client, server.URL+path)
+		assert.Equal(t, cache.StatusMiss, status, path)
+	}
+	assert.Equal(t, int32(8), requests.Load())
+}
+
+func TestCache_Revalidate(t *testing.T) {
+	content := "version 1"
+	etag := `"v1"`
+	var notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	client, _ := newClient(t)
+
+	body, status := get(t, client, server.URL)
+	assert.Equal(t, "version 1", body)
+	assert.Equal(t, cache.StatusMiss, status)
+
+	body, status = get(t, client, server.URL)
+	assert.Equal(t, "version 1", body)
+	assert.Equal(t, cache.StatusRevalidated, status)
+	assert.Equal(t, int32(1), notModified.Load())
+
+	content, etag = "version 2", `"v2"`
+	body, status = get(t, client, server.URL)
+	assert.Equal(t, "version 2", body)
+	assert.Equal(t, cache.StatusMiss, status)
+
+	body, status = get(t, client, server.URL)
+	assert.Equal(t, "version 2", body)
+	assert.Equal(t, cache.StatusRevalidated, status)
+}
+
+func TestCache_Vary(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	}))
+	defer server.Close()
+
+	client, _ := newClient(t)
+
+	get(t, client, server.URL, "Accept-Language", "en")
+	body, status := get(t, client, server.URL, "Accept-Language", "en")
+	assert.Equal(t, "en", body)
+	assert.Equal(t, cache.StatusHit, status)
+
+	body, status = get(t, client, server.URL, "Accept-Language", "fr")
+	assert.Equal(t, "fr", body)
+	assert.Equal(t, cache.StatusMiss, status)
+}
+
+func TestCache_Bypass(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader("hello world"))
+	}))
+	defer server.Close()
+
+	client, _ := newClient(t)
+	get(t, client, server.URL)
+
+	body, status := get(t, client, server.URL, "Range", "bytes=0-4")
+	assert.Equal(t, "hello", body)
+	assert.Empty(t, status)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestCache_PartialBodyNotStored(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello world"))
+	}))
+	defer server.Close()
+
+	client, _ := newClient(t)
+
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	buf := make([]byte, 5)
+	io.ReadFull(resp.Body, buf)
+	resp.Body.Close()
+
+	_, status := get(t, client, server.URL)
+	assert.Equal(t, cache.StatusMiss, status)
+}
+
+func TestCache_DeleteAndClear(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	client, c := newClient(t)
+
+	get(t, client, server.URL+"/a")
+	get(t, client, server.URL+"/b")
+
+	assert.NoError(t, c.Delete(server.URL+"/a"))
+	_, status := get(t, client, server.URL+"/a")
+	assert.Equal(t, cache.StatusMiss, status)
+	_, status = get(t, client, server.URL+"/b")
+	assert.Equal(t, cache.StatusHit, status)
+
+	assert.NoError(t, c.Clear())
+	entries, _ := os.ReadDir(c.Dir())
+	assert.Empty(t, entries)
+}
+
+func TestNew_InvalidDir(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(file, nil, 0o644))
+	_, err := cache.New(filepath.Join(file, "cache"))
+	assert.Error(t, err)
+}
+
+func TestCache_RequestDirectives(t *testing.T) {
+	var requests, conditional atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	client, _ := newClient(t)
+
+	body, status := get(t, client, server.URL, "Cache-Control", "only-if-cached")
+	assert.Equal(t, "", body)
+	assert.Equal(t, cache.StatusMiss, status)
+	assert.Equal(t, int32(0), requests.Load())
+
+	_, status = get(t, client, server.URL)
+	assert.Equal(t, cache.StatusMiss, status)
+
+	body, status = get(t, client, server.URL, "Cache-Control", "only-if-cached")
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, cache.StatusHit, status)
+
+	body, status = get(t, client, server.URL, "Cache-Control", "max-age=0")
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, cache.StatusRevalidated, status)
+	assert.Equal(t, int32(1), conditional.Load())
+
+	body, status = get(t, client, server.URL, "Cache-Control", "no-cache")
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, cache.StatusMiss, status)
+	assert.Equal(t, int32(1), conditional.Load(), "no-cache fetches a full response")
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+		fmt.Fprintf(w, "version %d", n)
+	}))
+	defer server.Close()
+
+	client, _ := newClient(t)
+
+	body, status := get(t, client, server.URL)
+	assert.Equal(t, "version 1", body)
+	assert.Equal(t, cache.StatusMiss, status)
+
+	body, status = get(t, client, server.URL)
+	assert.Equal(t, "version 1", body)
+	assert.Equal(t, cache.StatusStale, status)
+
+	// The background refresh stores the next version.
+	assert.Eventually(t, func() bool {
+		body, status = get(t, client, server.URL)
+		return body != "version 1"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, cache.StatusStale, status)
+
+	// A request asking for fresh content waits for the server.
+	_, status = get(t, client, server.URL, "Cache-Control", "max-age=0")
+	assert.Equal(t, cache.StatusMiss, status)
+}
+
+func TestCache_StaleIfError(t *testing.T) {
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Path == "/must-revalidate" {
+			w.Header().Set("Cache-Control", "max-age=0, must-revalidate")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=0")
+		}
+		w.Write([]byte("hello"))
+	}))
+
+	client, c := newClient(t)
+	c.SetStaleIfError(time.Minute)
+
+	get(t, client, server.URL)
+	get(t, client, server.URL+"/must-revalidate")
+	failing.Store(true)
+
+	body, status := get(t, client, server.URL)
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, cache.StatusStale, status)
+
+	body, status = get(t, client, server.URL+"/must-revalidate")
+	assert.Equal(t, "", body)
+	assert.Equal(t, cache.StatusMiss, status)
+
+	server.Close()
+	body, status = get(t, client, server.URL)
+	assert.Equal(t, "hello", body)
+	assert.Equal(t, cache.StatusStale, status)
+}
@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// directives are the parsed directives of a Cache-Control header.
+type directives map[string]string
+
+// parseCacheControl parses every Cache-Control header in h.
+func parseCacheControl(h http.Header) directives {
+	d := make(directives)
+	for _, value := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name == "" {
+				continue
+			}
+			d[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return d
+}
+
+func (d directives) has(name string) bool {
+	_, ok := d[name]
+	return ok
+}
+
+// seconds returns the value of a delta-seconds directive.
+func (d directives) seconds(name string) (time.Duration, bool) {
+	arg, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// date returns the Date header of e, or the time the response was received.
+func (e *entry) date() time.Time {
+	if t, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return t
+	}
+	return e.ResponseTime
+}
+
+// freshnessLifetime returns how long the response stays fresh after it was
+// generated, from max-age, Expires or, failing both, 10% of the time since
+// it was last modified.
+func (e *entry) freshnessLifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if maxAge, ok := cc.seconds("max-age"); ok {
+		return maxAge
+	}
+	if expires := e.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return max(t.Sub(e.date()), 0)
+	}
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && lastModified.Before(e.date()) {
+		return e.date().Sub(lastModified) / 10
+	}
+	return 0
+}
+
+// age returns the current age of the response.
+func (e *entry) age(now time.Time) time.Duration {
+	apparent := max(e.ResponseTime.Sub(e.date()), 0)
+	var ageValue time.Duration
+	if n, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && n > 0 {
+		ageValue = time.Duration(n) * time.Second
+	}
+	corrected := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	return max(apparent, corrected) + now.Sub(e.ResponseTime)
+}
+
+// fresh reports whether the entry may be served without revalidation for a
+// request with the given Cache-Control directives.
+func (e *entry) fresh(reqCC directives, now time.Time) bool {
+	if parseCacheControl(e.Header).has("no-cache") {
+		return false
+	}
+	age := e.age(now)
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	lifetime := e.freshnessLifetime()
+	if minFresh, ok := reqCC.seconds("min-fresh"); ok {
+		lifetime -= minFresh
+	}
+	return age < lifetime
+}
+
+// hasValidators reports whether the response can be revalidated.
+func hasValidators(h http.Header) bool {
+	return h.Get("ETag") != "" || h.Get("Last-Modified") != ""
+}
+
+// storable reports whether resp to a request with the given Cache-Control
+// directives may be stored and reused, fresh, after revalidation or stale.
+func (c *Cache) storable(resp *http.Response, reqCC directives) bool {
+	if resp.StatusCode != http.StatusOK || reqCC.has("no-store") {
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") || strings.TrimSpace(resp.Header.Get("Vary")) == "*" {
+		return false
+	}
+	e := &entry{Header: resp.Header, ResponseTime: c.now()}
+	return e.freshnessLifetime() > 0 || hasValidators(resp.Header) ||
+		e.staleWindow("stale-while-revalidate", c.staleWhileRevalidate) > 0 ||
+		e.staleWindow("stale-if-error", c.staleIfError) > 0
+}
+
+// varyValues returns the values of the request headers the response varies on.
+func varyValues(req *http.Request, resp *http.Response) map[string]string {
+	var values map[string]string
+	for _, value := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if values == nil {
+				values = make(map[string]string)
+			}
+			values[name] = req.Header.Get(name)
+		}
+	}
+	return values
+}
+
+// servableStale reports whether the entry, once stale, may still be served
+// under the stale directive name, such as "stale-if-error", falling back
+// to the window def when the response does not set it.
+func (e *entry) servableStale(name string, def time.Duration, now time.Time) bool {
+	window := e.staleWindow(name, def)
+	return window > 0 && e.age(now)-e.freshnessLifetime() < window
+}
+
+// staleWindow returns how long past its freshness lifetime the entry may
+// be served under the stale directive name.
+func (e *entry) staleWindow(name string, def time.Duration) time.Duration {
+	cc := parseCacheControl(e.Header)
+	if cc.has("must-revalidate") || cc.has("no-cache") {
+		return 0
+	}
+	if d, ok := cc.seconds(name); ok {
+		return d
+	}
+	return def
+}
+
+// serverError reports whether a response with the given status code allows
+// a stale response to be served under stale-if-error.
+func serverError(code int) bool {
+	switch code {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Encrypted files start with a version byte and a random salt, from which
+// the key of the file is derived, followed by the content sealed with
+// AES-256-GCM in chunks. The nonce of each chunk holds its index and marks
+// the last one, so chunks cannot be reordered, dropped or truncated.
+const (
+	sealVersion   = 1
+	saltSize      = 32
+	sealChunkSize = 64 << 10
+	sealTagSize   = 16
+	sealHeaderLen = 1 + saltSize
+)
+
+var errDecrypt = errors.New("cache: cannot decrypt cached data")
+
+// SetEncryptionKey encrypts the responses stored from now on, both bodies
+// and metadata, with AES-256-GCM under key, which must be 32 bytes long.
+// Responses stored without the key, or with another one, are treated as
+// missing and replaced.
+//
+// It should be set before the Cache is used.
+func (c *Cache) SetEncryptionKey(key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("invalid encryption key length: %d bytes, want 32", len(key))
+	}
+	c.key = bytes.Clone(key)
+	return nil
+}
+
+// fileAEAD returns the cipher of a file with the given salt.
+func (c *Cache) fileAEAD(salt []byte) cipher.AEAD {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		panic(err) // the derived key is always 32 bytes
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+func sealNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], index)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// sealWriter encrypts what is written to it into w. Close seals the last
+// chunk without closing w.
+type sealWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	index uint64
+	buf   []byte
+}
+
+func (c *Cache) newSealWriter(w io.Writer) (*sealWriter, error) {
+	header := make([]byte, sealHeaderLen)
+	header[0] = sealVersion
+	rand.Read(header[1:])
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &sealWriter{w: w, aead: c.fileAEAD(header[1:])}, nil
+}
+
+func (sw *sealWriter) Write(p []byte) (int, error) {
+	sw.buf = append(sw.buf, p...)
+	// A full chunk is only sealed once more data follows, as the last
+	// chunk is sealed differently.
+	for len(sw.buf) > sealChunkSize {
+		if err := sw.seal(sw.buf[:sealChunkSize], false); err != nil {
+			return 0, err
+		}
+		sw.buf = sw.buf[:copy(sw.buf, sw.buf[sealChunkSize:])]
+	}
+	return len(p), nil
+}
+
+func (sw *sealWriter) Close() error {
+	return sw.seal(sw.buf, true)
+}
+
+func (sw *sealWriter) seal(chunk []byte, last bool) error {
+	sealed := sw.aead.Seal(nil, sealNonce(sw.index, last), chunk, nil)
+	sw.index++
+	_, err := sw.w.Write(sealed)
+	return err
+}
+
+// openReader decrypts what sealWriter wrote.
+type openReader struct {
+	r     *bufio.Reader
+	aead  cipher.AEAD
+	index uint64
+	buf   []byte
+	done  bool
+}
+
+func (or *openReader) Read(p []byte) (int, error) {
+	for len(or.buf) == 0 {
+		if or.done {
+			return 0, io.EOF
+		}
+		if err := or.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, or.buf)
+	or.buf = or.buf[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk.
+func (or *openReader) next() error {
+	chunk := make([]byte, sealChunkSize+sealTagSize)
+	n, err := io.ReadFull(or.r, chunk)
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		or.done = true
+	case err == io.EOF:
+		return errDecrypt
+	case err != nil:
+		return err
+	default:
+		_, err := or.r.Peek(1)
+		or.done = err == io.EOF
+	}
+	plain, err := or.aead.Open(chunk[:0], sealNonce(or.index, or.done), chunk[:n], nil)
+	if err != nil {
+		return errDecrypt
+	}
+	or.index++
+	or.buf = plain
+	return nil
+}
+
+// openSealed returns a reader decrypting r.
+func (c *Cache) openSealed(r io.Reader) (*openReader, error) {
+	br := bufio.NewReaderSize(r, sealChunkSize+sealTagSize)
+	header := make([]byte, sealHeaderLen)
+	if _, err := io.ReadFull(br, header); err != nil || header[0] != sealVersion {
+		return nil, errDecrypt
+	}
+	return &openReader{r: br, aead: c.fileAEAD(header[1:])}, nil
+}
+
+// sealedSize returns the size of the content of an encrypted file of n bytes.
+func sealedSize(n int64) int64 {
+	payload := n - sealHeaderLen
+	chunks := (payload + sealChunkSize + sealTagSize - 1) / (sealChunkSize + sealTagSize)
+	return payload - chunks*sealTagSize
+}
+
+// seal encrypts data, if the cache has a key.
+func (c *Cache) seal(data []byte) ([]byte, error) {
+	if c.key == nil {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	sw, err := c.newSealWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	sw.Write(data)
+	if err := sw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unseal decrypts data, if the cache has a key.
+func (c *Cache) unseal(data []byte) ([]byte, error) {
+	if c.key == nil {
+		return data, nil
+	}
+	or, err := c.openSealed(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(or)
+}
+
+// blob is the opened body of a cached response.
+type blob struct {
+	io.Reader
+	f    *os.File
+	size int64
+}
+
+func (b *blob) Close() error {
+	return b.f.Close()
+}
+
+// openBlob opens the body stored in the file name.
+func (c *Cache) openBlob(name string) (*blob, error) {
+	f, err := os.Open(c.path(name))
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if c.key == nil {
+		return &blob{Reader: f, f: f, size: info.Size()}, nil
+	}
+	or, err := c.openSealed(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	size := sealedSize(info.Size())
+	if size < 0 {
+		f.Close()
+		return nil, errDecrypt
+	}
+	return &blob{Reader: or, f: f, size: size}, nil
+}
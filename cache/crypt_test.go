@@ -0,0 +1,94 @@
+package cache_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ciathefed/retrieve/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testKey = bytes.Repeat([]byte{7}, 32)
+
+func newEncryptedClient(t *testing.T, dir string, key []byte) *http.Client {
+	c, err := cache.New(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, c.SetEncryptionKey(key))
+	return &http.Client{Transport: c.Transport(nil)}
+}
+
+func TestCache_Encrypted(t *testing.T) {
+	sizes := map[string]int{"/empty": 0, "/small": 11, "/chunk": 64 << 10, "/large": 200<<10 + 3}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("X-Secret", "classified")
+		w.Write([]byte(strings.Repeat("secret ", sizes[r.URL.Path]/7+1)[:sizes[r.URL.Path]]))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	client := newEncryptedClient(t, dir, testKey)
+	for path, size := range sizes {
+		want, status := get(t, client, server.URL+path)
+		assert.Equal(t, cache.StatusMiss, status)
+		assert.Len(t, want, size)
+
+		body, status := get(t, client, server.URL+path)
+		assert.Equal(t, cache.StatusHit, status, path)
+		assert.Equal(t, want, body, path)
+	}
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "secret")
+		assert.NotContains(t, string(data), "classified")
+	}
+
+	// Another key cannot read the cached responses.
+	other := newEncryptedClient(t, dir, bytes.Repeat([]byte{8}, 32))
+	_, status := get(t, other, server.URL+"/small")
+	assert.Equal(t, cache.StatusMiss, status)
+}
+
+func TestCache_EncryptedTampered(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("hello world"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	client := newEncryptedClient(t, dir, testKey)
+	get(t, client, server.URL)
+
+	bodies, _ := filepath.Glob(filepath.Join(dir, "*.body"))
+	if !assert.Len(t, bodies, 1) {
+		return
+	}
+	data, _ := os.ReadFile(bodies[0])
+	data[len(data)-1] ^= 1
+	assert.NoError(t, os.WriteFile(bodies[0], data, 0o644))
+
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+	assert.Error(t, err)
+}
+
+func TestCache_SetEncryptionKey(t *testing.T) {
+	c, err := cache.New(t.TempDir())
+	assert.NoError(t, err)
+	assert.Error(t, c.SetEncryptionKey([]byte("short")))
+}
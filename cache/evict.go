@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EvictionPolicy selects the responses removed first when the cache grows
+// past the size set with SetMaxSize.
+type EvictionPolicy int
+
+const (
+	// LRU evicts the least recently used responses first.
+	LRU EvictionPolicy = iota
+	// LFU evicts the least frequently used responses first, and the least
+	// recently used among those used as often.
+	LFU
+)
+
+func (p EvictionPolicy) String() string {
+	switch p {
+	case LRU:
+		return "LRU"
+	case LFU:
+		return "LFU"
+	}
+	return fmt.Sprintf("EvictionPolicy(%d)", int(p))
+}
+
+// SetMaxSize caps the size of the cache directory at n bytes. Whenever a
+// response is stored, cached responses are evicted according to policy
+// until the cache fits again. A size of 0 removes the cap.
+//
+// It should be set before the Cache is used.
+func (c *Cache) SetMaxSize(n int64, policy EvictionPolicy) error {
+	if n < 0 {
+		return fmt.Errorf("invalid cache size: %d", n)
+	}
+	if policy != LRU && policy != LFU {
+		return fmt.Errorf("invalid eviction policy: %v", policy)
+	}
+	c.maxSize = n
+	c.policy = policy
+	return nil
+}
+
+// Size returns the number of bytes used by the cached responses.
+func (c *Cache) Size() (int64, error) {
+	entries, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, e := range entries {
+		size += e.size
+	}
+	return size, nil
+}
+
+// Prune removes the cached responses that have not been used for longer
+// than olderThan and returns how many were removed.
+func (c *Cache) Prune(olderThan time.Duration) (int, error) {
+	c.evictMu.Lock()
+	defer c.evictMu.Unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := c.now().Add(-olderThan)
+	removed := 0
+	for _, e := range entries {
+		if e.lastUsed().Before(cutoff) {
+			if err := c.remove(e.key, e.entry); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// evict removes cached responses until the cache fits its maximum size.
+func (c *Cache) evict() {
+	if c.maxSize <= 0 {
+		return
+	}
+	c.evictMu.Lock()
+	defer c.evictMu.Unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return
+	}
+	var size int64
+	for _, e := range entries {
+		size += e.size
+	}
+	if size <= c.maxSize {
+		return
+	}
+
+	slices.SortFunc(entries, func(a, b sizedEntry) int {
+		if c.policy == LFU {
+			if n := cmp.Compare(a.Hits, b.Hits); n != 0 {
+				return n
+			}
+		}
+		return a.lastUsed().Compare(b.lastUsed())
+	})
+	for _, e := range entries {
+		if size <= c.maxSize {
+			break
+		}
+		if c.remove(e.key, e.entry) == nil {
+			size -= e.size
+			c.evictions.Add(1)
+		}
+	}
+}
+
+// sizedEntry is a stored entry with its key and size on disk.
+type sizedEntry struct {
+	*entry
+	key  string
+	size int64
+}
+
+// lastUsed returns when the entry was last served or stored.
+func (e *entry) lastUsed() time.Time {
+	if e.LastAccess.IsZero() {
+		return e.ResponseTime
+	}
+	return e.LastAccess
+}
+
+// entries returns every entry stored in the cache.
+func (c *Cache) entries() ([]sizedEntry, error) {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []sizedEntry
+	for _, file := range files {
+		key, ok := strings.CutSuffix(file.Name(), metaExt)
+		if !ok || file.IsDir() || strings.Contains(key, ".") {
+			continue
+		}
+		e, err := c.loadEntry(key)
+		if err != nil {
+			continue
+		}
+		size := fileSize(c.path(file.Name()))
+		if e.Body != "" {
+			size += fileSize(c.path(e.Body))
+		}
+		entries = append(entries, sizedEntry{entry: e, key: key, size: size})
+	}
+	return entries, nil
+}
+
+// remove deletes the entry e stored under key and its body.
+func (c *Cache) remove(key string, e *entry) error {
+	if err := removeIfExists(c.path(key + metaExt)); err != nil {
+		return err
+	}
+	return removeIfExists(c.path(e.Body))
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
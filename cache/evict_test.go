@@ -0,0 +1,108 @@
+package cache_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newSizedServer serves 1000 bytes at every path, fresh for a minute.
+func newSizedServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// entrySize returns the size on disk of a response cached from server.
+func entrySize(t *testing.T, server *httptest.Server) int64 {
+	client, c := newClient(t)
+	get(t, client, server.URL+"/x")
+	size, err := c.Size()
+	assert.NoError(t, err)
+	return size
+}
+
+func TestCache_EvictLRU(t *testing.T) {
+	server := newSizedServer(t)
+	maxSize := 3*entrySize(t, server) + 100
+	client, c := newClient(t)
+	assert.NoError(t, c.SetMaxSize(maxSize, cache.LRU))
+
+	for _, path := range []string{"/a", "/b", "/c", "/a"} {
+		get(t, client, server.URL+path)
+	}
+	get(t, client, server.URL+"/d")
+
+	size, err := c.Size()
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, size, maxSize)
+	assert.Equal(t, int64(1), c.Stats().Evictions)
+
+	_, status := get(t, client, server.URL+"/a")
+	assert.Equal(t, cache.StatusHit, status)
+	_, status = get(t, client, server.URL+"/d")
+	assert.Equal(t, cache.StatusHit, status)
+	_, status = get(t, client, server.URL+"/b")
+	assert.Equal(t, cache.StatusMiss, status)
+}
+
+func TestCache_EvictLFU(t *testing.T) {
+	server := newSizedServer(t)
+	client, c := newClient(t)
+	assert.NoError(t, c.SetMaxSize(3*entrySize(t, server)+100, cache.LFU))
+
+	for _, path := range []string{"/a", "/a", "/a", "/b", "/b", "/c"} {
+		get(t, client, server.URL+path)
+	}
+	get(t, client, server.URL+"/d")
+
+	_, status := get(t, client, server.URL+"/c")
+	assert.Equal(t, cache.StatusMiss, status)
+	_, status = get(t, client, server.URL+"/a")
+	assert.Equal(t, cache.StatusHit, status)
+
+	assert.Error(t, c.SetMaxSize(-1, cache.LRU))
+	assert.Error(t, c.SetMaxSize(100, cache.EvictionPolicy(7)))
+}
+
+func TestCache_Prune(t *testing.T) {
+	server := newSizedServer(t)
+	client, c := newClient(t)
+
+	get(t, client, server.URL+"/old")
+	time.Sleep(50 * time.Millisecond)
+	get(t, client, server.URL+"/new")
+
+	removed, err := c.Prune(25 * time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, status := get(t, client, server.URL+"/old")
+	assert.Equal(t, cache.StatusMiss, status)
+	_, status = get(t, client, server.URL+"/new")
+	assert.Equal(t, cache.StatusHit, status)
+}
+
+func TestCache_Stats(t *testing.T) {
+	server := newSizedServer(t)
+	client, c := newClient(t)
+	assert.Equal(t, 0.0, c.Stats().HitRatio())
+
+	for i := range 4 {
+		get(t, client, fmt.Sprintf("%s/%d", server.URL, i%2))
+	}
+	stats := c.Stats()
+	assert.Equal(t, int64(2), stats.Hits)
+	assert.Equal(t, int64(2), stats.Misses)
+	assert.Equal(t, 0.5, stats.HitRatio())
+}
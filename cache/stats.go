@@ -0,0 +1,46 @@
+package cache
+
+// Stats counts how the responses passing through a Cache were served
+// since it was created.
+type Stats struct {
+	Hits          int64 // served from the cache without contacting the server
+	Revalidations int64 // confirmed by the server to still be valid
+	Stale         int64 // served stale, while revalidating or because the server failed
+	Misses        int64 // fetched from the server
+	Evictions     int64 // removed to keep the cache under its maximum size
+}
+
+// HitRatio returns the share of responses served from the cache, including
+// revalidated and stale ones, or 0 if there were none.
+func (s Stats) HitRatio() float64 {
+	served := s.Hits + s.Revalidations + s.Stale
+	if total := served + s.Misses; total > 0 {
+		return float64(served) / float64(total)
+	}
+	return 0
+}
+
+// Stats returns the counts of responses served by the cache.
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Hits:          c.hits.Load(),
+		Revalidations: c.revalidations.Load(),
+		Stale:         c.stale.Load(),
+		Misses:        c.misses.Load(),
+		Evictions:     c.evictions.Load(),
+	}
+}
+
+// count records how a response was served.
+func (c *Cache) count(status string) {
+	switch status {
+	case StatusHit:
+		c.hits.Add(1)
+	case StatusRevalidated:
+		c.revalidations.Add(1)
+	case StatusStale:
+		c.stale.Add(1)
+	case StatusMiss:
+		c.misses.Add(1)
+	}
+}
@@ -0,0 +1,275 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Transport returns an http.RoundTripper that serves GET requests from the
+// cache when possible and stores cacheable responses from base.
+//
+// Requests with a Range header or their own conditional headers are passed
+// to base unchanged. Only 200 OK responses are stored, and a response is
+// only stored once its body has been read to the end.
+//
+// The Cache-Control request directives "no-cache", which fetches a full
+// response from base without using the stored one, "max-age=0", which
+// revalidates the stored response, and "only-if-cached", which answers 504
+// Gateway Timeout instead of contacting base when no fresh response is
+// stored, give callers control over freshness per request.
+func (c *Cache) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{cache: c, base: base}
+}
+
+type transport struct {
+	cache *Cache
+	base  http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !cacheableRequest(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	c := t.cache
+	key := cacheKey(req.Method, req.URL.String())
+	reqCC := parseCacheControl(req.Header)
+
+	var cached *entry
+	var body *blob
+	if !reqCC.has("no-cache") {
+		cached, body = c.lookup(key, req)
+	}
+	if cached != nil && cached.fresh(reqCC, c.now()) {
+		return c.serve(key, req, cached, body, StatusHit)
+	}
+	if reqCC.has("only-if-cached") {
+		if body != nil {
+			body.Close()
+		}
+		c.count(StatusMiss)
+		return gatewayTimeout(req), nil
+	}
+	// A request asking for fresher content is not served stale content.
+	if cached != nil && !reqCC.has("max-age") && !reqCC.has("min-fresh") &&
+		cached.servableStale("stale-while-revalidate", c.staleWhileRevalidate, c.now()) {
+		t.refresh(key, req)
+		return c.serve(key, req, cached, body, StatusStale)
+	}
+
+	outReq := req
+	if cached != nil && hasValidators(cached.Header) {
+		outReq = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			outReq.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			outReq.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	requestTime := c.now()
+	resp, err := t.base.RoundTrip(outReq)
+	if cached != nil {
+		if err == nil && resp.StatusCode == http.StatusNotModified && outReq != req {
+			resp.Body.Close()
+			cached.update(resp.Header, requestTime, c.now())
+			return c.serve(key, req, cached, body, StatusRevalidated)
+		}
+		if (err != nil || serverError(resp.StatusCode)) && req.Context().Err() == nil &&
+			cached.servableStale("stale-if-error", c.staleIfError, c.now()) {
+			if err == nil {
+				resp.Body.Close()
+			}
+			return c.serve(key, req, cached, body, StatusStale)
+		}
+		body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Header.Set(StatusHeader, StatusMiss)
+	c.count(StatusMiss)
+	if c.storable(resp, reqCC) {
+		c.store(key, req, resp, requestTime)
+	}
+	return resp, nil
+}
+
+// refresh revalidates the entry under key in the background, unless that
+// is already under way.
+func (t *transport) refresh(key string, req *http.Request) {
+	c := t.cache
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	// The refresh outlives the request, and max-age=0 makes it go to the server.
+	req = req.Clone(context.WithoutCancel(req.Context()))
+	req.Header.Set("Cache-Control", "max-age=0")
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+		resp, err := t.RoundTrip(req)
+		if err != nil {
+			return
+		}
+		// Reading the body to the end stores it.
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+}
+
+// lookup returns the entry stored under key and its opened body if it
+// matches req, or nil.
+func (c *Cache) lookup(key string, req *http.Request) (*entry, *blob) {
+	e, body, err := c.load(key)
+	if err != nil {
+		return nil, nil
+	}
+	if !e.matches(req) {
+		body.Close()
+		return nil, nil
+	}
+	return e, body
+}
+
+// cacheableRequest reports whether req may be served from the cache.
+func cacheableRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	for _, name := range []string{"Range", "If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range"} {
+		if req.Header.Get(name) != "" {
+			return false
+		}
+	}
+	return !parseCacheControl(req.Header).has("no-store")
+}
+
+// update merges the headers of a 304 Not Modified response into the entry.
+func (e *entry) update(h http.Header, requestTime, responseTime time.Time) {
+	for name, values := range h {
+		switch name {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding", StatusHeader:
+			continue
+		}
+		e.Header[name] = values
+	}
+	e.RequestTime = requestTime
+	e.ResponseTime = responseTime
+}
+
+// store arranges for resp to be cached once its body has been read.
+func (c *Cache) store(key string, req *http.Request, resp *http.Response, requestTime time.Time) {
+	tmp, err := os.CreateTemp(c.dir, key+".tmp*")
+	if err != nil {
+		return
+	}
+	var seal *sealWriter
+	if c.key != nil {
+		if seal, err = c.newSealWriter(tmp); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return
+		}
+	}
+
+	header := resp.Header.Clone()
+	header.Del(StatusHeader)
+	e := &entry{
+		URL:          req.URL.String(),
+		StatusCode:   resp.StatusCode,
+		Header:       header,
+		Vary:         varyValues(req, resp),
+		RequestTime:  requestTime,
+		ResponseTime: c.now(),
+		LastAccess:   c.now(),
+	}
+	resp.Body = &bodyWriter{
+		body: resp.Body,
+		tmp:  tmp,
+		seal: seal,
+		commit: func(tmpName string) error {
+			e.Body = newBodyName(key)
+			if err := os.Rename(tmpName, c.path(e.Body)); err != nil {
+				return err
+			}
+			if err := c.saveEntry(key, e); err != nil {
+				os.Remove(c.path(e.Body))
+				return err
+			}
+			c.evict()
+			return nil
+		},
+	}
+}
+
+// gatewayTimeout returns the response to an only-if-cached request that
+// cannot be served from the cache.
+func gatewayTimeout(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "504 " + http.StatusText(http.StatusGatewayTimeout),
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{StatusHeader: {StatusMiss}},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// serve records the use of the entry stored under key and builds the
+// response to req from it.
+func (c *Cache) serve(key string, req *http.Request, e *entry, body *blob, status string) (*http.Response, error) {
+	e.LastAccess = c.now()
+	e.Hits++
+	if status == StatusRevalidated {
+		if err := c.saveEntry(key, e); err != nil {
+			body.Close()
+			return nil, err
+		}
+	} else if current, err := c.loadEntry(key); err == nil && current.Body == e.Body {
+		// Unless the entry was replaced in the meantime.
+		c.saveEntry(key, e)
+	}
+	c.count(status)
+	return c.response(req, e, body, status)
+}
+
+// response builds a response to req from a cached entry and its body.
+func (c *Cache) response(req *http.Request, e *entry, body *blob, status string) (*http.Response, error) {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(e.age(c.now()).Seconds()), 10))
+	header.Set("Content-Length", strconv.FormatInt(body.size, 10))
+	header.Set(StatusHeader, status)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: body.size,
+		Request:       req,
+	}, nil
+}
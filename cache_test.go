@@ -0,0 +1,107 @@
+package retrieve_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+	"github.com/ciathefed/retrieve/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCache(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("cached content"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	for _, name := range []string{"a.txt", "b.txt"} {
+		output := filepath.Join(dir, name)
+		b := retrieve.New(server.URL).SetOutput(output).WithCache(cacheDir)
+		assert.NotNil(t, b.GetCache())
+		assert.NoError(t, b.Exec())
+
+		data, _ := os.ReadFile(output)
+		assert.Equal(t, "cached content", string(data))
+	}
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestWithCache_InvalidDir(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(file, nil, 0o644))
+	assert.Error(t, retrieve.New("http://example.com").WithCache(filepath.Join(file, "cache")).Exec())
+}
+
+func TestCacheDirectives(t *testing.T) {
+	b := retrieve.New("http://example.com")
+	assert.False(t, b.IsNoCache() || b.IsForceRevalidate() || b.IsOnlyIfCached())
+	assert.True(t, b.NoCache().IsNoCache())
+	assert.Equal(t, "no-cache", b.GetHeaders()["Cache-Control"])
+	assert.True(t, b.ForceRevalidate().IsForceRevalidate())
+	assert.False(t, b.IsNoCache())
+	assert.True(t, b.OnlyIfCached().IsOnlyIfCached())
+	assert.Equal(t, "only-if-cached", b.GetHeaders()["Cache-Control"])
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("cached content"))
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	_, err := retrieve.New(server.URL).WithCache(cacheDir).SetRetries(2).OnlyIfCached().ExecString()
+	assert.ErrorIs(t, err, retrieve.ErrNotCached)
+	assert.Equal(t, int32(0), requests.Load())
+
+	for range 2 {
+		data, err := retrieve.New(server.URL).WithCache(cacheDir).NoCache().ExecString()
+		assert.NoError(t, err)
+		assert.Equal(t, "cached content", data)
+	}
+	assert.Equal(t, int32(2), requests.Load())
+
+	data, err := retrieve.New(server.URL).WithCache(cacheDir).OnlyIfCached().ExecString()
+	assert.NoError(t, err)
+	assert.Equal(t, "cached content", data)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestSetCache_StaleIfError(t *testing.T) {
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=0")
+		w.Write([]byte("installer"))
+	}))
+	defer server.Close()
+
+	c, err := cache.New(t.TempDir())
+	assert.NoError(t, err)
+	c.SetStaleIfError(time.Hour)
+
+	_, err = retrieve.New(server.URL).SetCache(c).ExecString()
+	assert.NoError(t, err)
+
+	failing.Store(true)
+	result, err := retrieve.New(server.URL).SetCache(c).SetWriter(io.Discard).ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, cache.StatusStale, result.Header.Get(cache.StatusHeader))
+}
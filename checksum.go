@@ -0,0 +1,59 @@
+package retrieve
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+var hashFuncs = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// VerifyChecksum hashes the response while it is written and fails Exec if
+// the digest does not match the expected hex-encoded value.
+//
+// Supported algorithms: "md5", "sha256", "sha512".
+//
+// When the checksum does not match, the downloaded file is deleted.
+func (b *Builder) VerifyChecksum(algo, expected string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	algo = strings.ToLower(algo)
+	if _, ok := hashFuncs[algo]; !ok {
+		b.err = fmt.Errorf("unsupported checksum algorithm: %s", algo)
+		return b
+	}
+	b.checksumAlgo = algo
+	b.checksum = strings.ToLower(strings.TrimSpace(expected))
+	return b
+}
+
+// GetChecksum returns the checksum algorithm and expected digest, if set.
+func (b *Builder) GetChecksum() (string, string) {
+	return b.checksumAlgo, b.checksum
+}
+
+// newHash returns a hash for the configured checksum, or nil if none is set.
+func (b *Builder) newHash() hash.Hash {
+	if b.checksumAlgo == "" {
+		return nil
+	}
+	return hashFuncs[b.checksumAlgo]()
+}
+
+// verifyHash compares the digest of h against the expected checksum.
+func (b *Builder) verifyHash(h hash.Hash) error {
+	actual := hex.EncodeToString(h.Sum(nil))
+	if actual != b.checksum {
+		return fmt.Errorf("%w: expected %s %s, got %s", ErrChecksumMismatch, b.checksumAlgo, b.checksum, actual)
+	}
+	return nil
+}
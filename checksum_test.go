@@ -0,0 +1,65 @@
+package retrieve_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	b := retrieve.New("http://example.com").VerifyChecksum("SHA256", "ABCDEF")
+	algo, expected := b.GetChecksum()
+	assert.Equal(t, "sha256", algo)
+	assert.Equal(t, "abcdef", expected)
+}
+
+func TestVerifyChecksum_UnsupportedAlgorithm(t *testing.T) {
+	err := retrieve.New("http://example.com").VerifyChecksum("crc32", "abc").Exec()
+	assert.Error(t, err)
+}
+
+func TestExec_ChecksumMatch(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	sum := sha256.Sum256([]byte("success"))
+	output := filepath.Join(t.TempDir(), "out.txt")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		VerifyChecksum("sha256", hex.EncodeToString(sum[:])).
+		Exec()
+	assert.NoError(t, err)
+	assert.FileExists(t, output)
+}
+
+func TestExec_ChecksumMismatch(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	sum := sha256.Sum256([]byte("success"))
+	output := filepath.Join(t.TempDir(), "out.txt")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		VerifyChecksum("sha256", hex.EncodeToString(sum[:])).
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrChecksumMismatch)
+
+	_, err = os.Stat(output)
+	assert.True(t, os.IsNotExist(err))
+}
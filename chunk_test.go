@@ -0,0 +1,141 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetChunkSize(t *testing.T) {
+	assert.Equal(t, int64(0), retrieve.New("http://example.com").GetChunkSize())
+	assert.Equal(t, int64(1<<20), retrieve.New("http://example.com").SetChunkSize(1<<20).GetChunkSize())
+	assert.Error(t, retrieve.New("http://example.com").SetChunkSize(-1).Exec())
+}
+
+// newChunkServer serves data with Range support and records the Range
+// header of each request.
+func newChunkServer(data []byte) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return ranges
+	}
+}
+
+func TestExec_Chunks(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 2500)
+	server, ranges := newChunkServer(data)
+	defer server.Close()
+
+	sum := sha256.Sum256(data)
+	var last retrieve.Progress
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetChunkSize(10000).
+		VerifyChecksum("sha256", hex.EncodeToString(sum[:])).
+		OnProgress(func(p retrieve.Progress) { last = p }).
+		Exec()
+	assert.NoError(t, err)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+	assert.Equal(t, []string{"bytes=0-9999", "bytes=10000-19999", "bytes=20000-24999"}, ranges())
+	assert.Equal(t, int64(len(data)), last.TotalBytes)
+}
+
+func TestExec_ChunksLowercaseMethod(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 2500)
+	server, ranges := newChunkServer(data)
+	defer server.Close()
+
+	got, err := retrieve.New(server.URL).
+		SetMethod("get").
+		SetChunkSize(10000).
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, []string{"bytes=0-9999", "bytes=10000-19999", "bytes=20000-24999"}, ranges())
+}
+
+func TestExec_ChunksWriter(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+	server, ranges := newChunkServer(data)
+	defer server.Close()
+
+	got, err := retrieve.New(server.URL).SetChunkSize(300).ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Len(t, ranges(), 4)
+}
+
+func TestExec_ChunksUnsupported(t *testing.T) {
+	data := bytes.Repeat([]byte("y"), 1000)
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	got, err := retrieve.New(server.URL).SetChunkSize(100).ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, 1, requests)
+}
+
+func TestExec_ChunksResume(t *testing.T) {
+	data := bytes.Repeat([]byte("abcdefghij"), 100)
+	var mu sync.Mutex
+	var ranges []string
+	failed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		fail := !failed && r.Header.Get("Range") == "bytes=400-799"
+		failed = failed || fail
+		mu.Unlock()
+		if fail {
+			// Declare the full chunk but cut the connection halfway.
+			w.Header().Set("Content-Range", "bytes 400-799/1000")
+			w.Header().Set("Content-Length", "400")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[400:600])
+			return
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetChunkSize(400).
+		SetRetries(1).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		Exec()
+	assert.NoError(t, err)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+	assert.Equal(t, []string{"bytes=0-399", "bytes=400-799", "bytes=600-999"}, ranges)
+}
@@ -0,0 +1,98 @@
+package retrieve
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CleanupError is returned when a download succeeded but the cleanup
+// request set with ThenDelete or ThenRequest failed. The output is complete.
+type CleanupError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *CleanupError) Error() string {
+	return fmt.Sprintf("download succeeded but cleanup %s %s failed: %v", e.Method, e.URL, e.Err)
+}
+
+func (e *CleanupError) Unwrap() error {
+	return e.Err
+}
+
+// ThenDelete sends a DELETE request for the downloaded URL once the
+// download has succeeded and been verified, to pick up and remove files
+// from an inbox. See ThenRequest.
+func (b *Builder) ThenDelete() *Builder {
+	return b.ThenRequest(http.MethodDelete, "")
+}
+
+// ThenRequest sends a cleanup request with the given method to rawURL once
+// the download has succeeded and been verified. An empty rawURL means the
+// URL the file was downloaded from, which may be a mirror.
+//
+// The request carries the same headers as the download and is retried
+// like it. If it fails, Exec returns a *CleanupError.
+func (b *Builder) ThenRequest(method, rawURL string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	method = strings.ToUpper(method)
+	if !isValidMethod(method) && method != http.MethodDelete {
+		b.err = fmt.Errorf("invalid cleanup method: %s", method)
+		return b
+	}
+	if rawURL != "" && !isValidURL(rawURL) {
+		b.err = fmt.Errorf("invalid cleanup URL: %s", rawURL)
+		return b
+	}
+	b.cleanupMethod = method
+	b.cleanupURL = rawURL
+	return b
+}
+
+// cleanupSource sends the cleanup request, if any, for a download of rawURL.
+func (b *Builder) cleanupSource(client *http.Client, rawURL string) error {
+	if b.cleanupMethod == "" {
+		return nil
+	}
+	if b.cleanupURL != "" {
+		rawURL = b.cleanupURL
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := b.sendCleanup(client, rawURL)
+		if err == nil {
+			return nil
+		}
+		if attempt >= b.retries || !b.isRetryable(err) {
+			return &CleanupError{Method: b.cleanupMethod, URL: rawURL, Err: err}
+		}
+		if err := sleepContext(b.ctx, b.retryDelay(err, attempt)); err != nil {
+			return &CleanupError{Method: b.cleanupMethod, URL: rawURL, Err: err}
+		}
+	}
+}
+
+func (b *Builder) sendCleanup(client *http.Client, rawURL string) error {
+	req, err := http.NewRequestWithContext(b.ctx, b.cleanupMethod, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	for key, value := range b.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode > 399 {
+		return newStatusError(resp)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newInboxServer serves files that can be fetched with GET and removed
+// with DELETE, and records each request as "METHOD /path".
+func newInboxServer(files map[string]string) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var log []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		log = append(log, r.Method+" "+r.URL.Path)
+		content, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(content))
+		case http.MethodDelete:
+			delete(files, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return log
+	}
+}
+
+func TestThenRequest_Invalid(t *testing.T) {
+	assert.Error(t, retrieve.New("http://example.com").ThenRequest("NOPE", "").Exec())
+	assert.Error(t, retrieve.New("http://example.com").ThenRequest(http.MethodPost, "not a url").Exec())
+}
+
+func TestExec_ThenDelete(t *testing.T) {
+	files := map[string]string{"/inbox/a.txt": "a"}
+	server, log := newInboxServer(files)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "a.txt")
+	err := retrieve.New(server.URL+"/inbox/a.txt").
+		SetOutput(output).
+		SetHeader("Authorization", "Bearer token").
+		ThenDelete().
+		Exec()
+	assert.NoError(t, err)
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, "a", string(data))
+	assert.Equal(t, []string{"GET /inbox/a.txt", "DELETE /inbox/a.txt"}, log())
+	assert.Empty(t, files)
+}
+
+func TestExec_ThenDelete_NotAfterFailure(t *testing.T) {
+	files := map[string]string{"/a.txt": "a"}
+	server, log := newInboxServer(files)
+	defer server.Close()
+
+	err := retrieve.New(server.URL+"/a.txt").
+		SetOutput(filepath.Join(t.TempDir(), "a.txt")).
+		VerifyChecksum("sha256", "0000000000000000000000000000000000000000000000000000000000000000").
+		ThenDelete().
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrChecksumMismatch)
+	assert.Equal(t, []string{"GET /a.txt"}, log())
+	assert.Len(t, files, 1)
+}
+
+func TestExec_ThenRequest(t *testing.T) {
+	files := map[string]string{"/a.txt": "a"}
+	server, log := newInboxServer(files)
+	defer server.Close()
+
+	err := retrieve.New(server.URL+"/a.txt").
+		SetOutput(filepath.Join(t.TempDir(), "a.txt")).
+		SetRetries(2).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		ThenRequest(http.MethodPost, server.URL+"/ack").
+		Exec()
+	var cleanupErr *retrieve.CleanupError
+	if assert.ErrorAs(t, err, &cleanupErr) {
+		assert.Equal(t, http.MethodPost, cleanupErr.Method)
+		assert.Equal(t, server.URL+"/ack", cleanupErr.URL)
+	}
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, []string{"GET /a.txt", "POST /ack"}, log())
+}
@@ -0,0 +1,118 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnlyIfModified(t *testing.T) {
+	assert.False(t, retrieve.New("http://example.com").IsOnlyIfModified())
+	assert.True(t, retrieve.New("http://example.com").OnlyIfModified().IsOnlyIfModified())
+}
+
+func TestExec_OnlyIfModified_ETag(t *testing.T) {
+	content := []byte("version 1")
+	etag := `"v1"`
+	var lastIfNoneMatch string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIfNoneMatch = r.Header.Get("If-None-Match")
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.txt")
+	b := retrieve.New(server.URL).SetOutput(output).OnlyIfModified()
+
+	assert.NoError(t, b.Exec())
+	assert.Empty(t, lastIfNoneMatch)
+	assert.FileExists(t, output+".meta")
+
+	// Touch the file so an overwrite would be detectable.
+	assert.NoError(t, os.WriteFile(output, []byte("local"), 0o644))
+	assert.ErrorIs(t, b.Exec(), retrieve.ErrNotModified)
+	assert.Equal(t, etag, lastIfNoneMatch)
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, "local", string(data))
+
+	content = []byte("version 2")
+	etag = `"v2"`
+	assert.NoError(t, b.Exec())
+	data, _ = os.ReadFile(output)
+	assert.Equal(t, "version 2", string(data))
+}
+
+func TestExec_OnlyIfModified_ModTime(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", modified, bytes.NewReader([]byte("remote")))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "file.txt")
+	assert.NoError(t, os.WriteFile(output, []byte("local"), 0o644))
+	assert.NoError(t, os.Chtimes(output, modified, modified.Add(time.Hour)))
+
+	err := retrieve.New(server.URL + "/file.txt").SetOutput(dir).OnlyIfModified().Exec()
+	assert.ErrorIs(t, err, retrieve.ErrNotModified)
+
+	assert.NoError(t, os.Chtimes(output, modified, modified.Add(-time.Hour)))
+	err = retrieve.New(server.URL + "/file.txt").SetOutput(dir).OnlyIfModified().Exec()
+	assert.NoError(t, err)
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, "remote", string(data))
+}
+
+func TestSkipIfSameSize(t *testing.T) {
+	assert.False(t, retrieve.New("http://example.com").IsSkipIfSameSize())
+	assert.True(t, retrieve.New("http://example.com").SkipIfSameSize().IsSkipIfSameSize())
+}
+
+func TestExec_SkipIfSameSize(t *testing.T) {
+	content := []byte("remote")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "file.txt")
+	b := retrieve.New(server.URL + "/file.txt").SetOutput(dir).SkipIfSameSize()
+
+	assert.NoError(t, b.Exec())
+
+	// Same size, different content: the local file is kept.
+	assert.NoError(t, os.WriteFile(output, []byte("REMOTE"), 0o644))
+	assert.ErrorIs(t, b.Exec(), retrieve.ErrNotModified)
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, "REMOTE", string(data))
+
+	content = []byte("remote, longer")
+	assert.NoError(t, b.Exec())
+	data, _ = os.ReadFile(output)
+	assert.Equal(t, "remote, longer", string(data))
+}
+
+func TestExec_SkipIfSameSize_NoContentLength(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Transfer-Encoding", "chunked")
+		w.Write([]byte("remote"))
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.txt")
+	assert.NoError(t, os.WriteFile(output, []byte("REMOTE"), 0o644))
+	assert.NoError(t, retrieve.New(server.URL).SetOutput(output).SkipIfSameSize().Exec())
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, "remote", string(data))
+}
@@ -0,0 +1,36 @@
+package retrieve
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrDeclined is returned when the callback set with OnConfirm declines a
+// download.
+var ErrDeclined = errors.New("download declined")
+
+// OnConfirm registers a callback invoked once the response headers arrive
+// and before anything is written, with the size, type and URL of the file,
+// so interactive tools can ask "This file is 8.4 GB, continue?". Returning
+// false aborts Exec with ErrDeclined, without trying mirrors. The callback
+// is invoked once per Exec, even if the download is retried or resumed.
+func (b *Builder) OnConfirm(fn func(info FileInfo) bool) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.onConfirm = fn
+	return b
+}
+
+// confirm asks the callback set with OnConfirm whether to download the
+// file of resp, of the given total size.
+func (b *Builder) confirm(resp *http.Response, total int64) error {
+	if b.onConfirm == nil || b.confirmed {
+		return nil
+	}
+	if !b.onConfirm(*newFileInfo(resp, total)) {
+		return ErrDeclined
+	}
+	b.confirmed = true
+	return nil
+}
@@ -0,0 +1,79 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnConfirm_Declined(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(bytes.Repeat([]byte("x"), 1000))
+	}))
+	defer server.Close()
+	var mirrorHits atomic.Int32
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits.Add(1)
+	}))
+	defer mirror.Close()
+
+	var got retrieve.FileInfo
+	output := filepath.Join(t.TempDir(), "large.bin")
+	err := retrieve.New(server.URL + "/large.bin").
+		SetOutput(output).
+		SetMirrors([]string{mirror.URL}).
+		OnConfirm(func(info retrieve.FileInfo) bool {
+			got = info
+			return false
+		}).
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrDeclined)
+	assert.EqualValues(t, 1000, got.Size)
+	assert.Equal(t, "application/octet-stream", got.ContentType)
+	assert.Equal(t, server.URL+"/large.bin", got.URL)
+	assert.NoFileExists(t, output)
+	assert.NoFileExists(t, output+".part")
+	assert.Zero(t, mirrorHits.Load())
+}
+
+func TestOnConfirm_OncePerExec(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Length", "10000")
+			w.Write(data[:4000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	var sizes []int64
+	output := filepath.Join(t.TempDir(), "file.bin")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetRetries(1).
+		OnConfirm(func(info retrieve.FileInfo) bool {
+			sizes = append(sizes, info.Size)
+			return true
+		}).
+		Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{10000}, sizes)
+
+	got, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+}
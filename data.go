@@ -0,0 +1,70 @@
+package retrieve
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// dataFilename is the file name of the content of a data: URL saved to a
+// directory.
+const dataFilename = "data"
+
+// isDataURL reports whether rawURL is a data: URL.
+func isDataURL(rawURL string) bool {
+	scheme, rest, ok := strings.Cut(rawURL, ":")
+	return ok && strings.EqualFold(scheme, "data") && strings.Contains(rest, ",")
+}
+
+// parseDataURL decodes a data: URL as defined by RFC 2397:
+// data:[<mediatype>][;base64],<data>
+func parseDataURL(rawURL string) (mediaType string, data []byte, ok bool) {
+	_, rest, _ := strings.Cut(rawURL, ":")
+	params, encoded, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", nil, false
+	}
+	params, isBase64 := strings.CutSuffix(params, ";base64")
+	if !isBase64 {
+		params, isBase64 = strings.CutSuffix(params, ";BASE64")
+	}
+	mediaType, err := url.PathUnescape(params)
+	if err != nil {
+		return "", nil, false
+	}
+	if mediaType == "" || strings.HasPrefix(mediaType, ";") {
+		mediaType = "text/plain;charset=US-ASCII" + mediaType
+	}
+
+	decoded, err := url.PathUnescape(encoded)
+	if err != nil {
+		return "", nil, false
+	}
+	if !isBase64 {
+		return mediaType, []byte(decoded), true
+	}
+	decoded = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, decoded)
+	data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(decoded, "="))
+	if err != nil {
+		return "", nil, false
+	}
+	return mediaType, data, true
+}
+
+// serveDataURL returns the response to a request for a data: URL.
+func serveDataURL(req *http.Request) *http.Response {
+	mediaType, data, ok := parseDataURL(req.URL.String())
+	if !ok {
+		return localResponse(req, http.StatusBadRequest, nil)
+	}
+	resp := localResponse(req, http.StatusOK, data)
+	resp.Header.Set("Content-Type", mediaType)
+	resp.Header.Set("Content-Disposition", `attachment; filename="`+dataFilename+`"`)
+	return resp
+}
@@ -0,0 +1,53 @@
+package retrieve_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_DataURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		want     string
+		wantType string
+	}{
+		{"base64", "data:text/plain;base64,SGVsbG8sIFdvcmxkIQ==", "Hello, World!", "text/plain"},
+		{"base64 without padding", "data:;base64,SGVsbG8", "Hello", "text/plain;charset=US-ASCII"},
+		{"percent-encoded", "data:,Hello%2C%20World%21", "Hello, World!", "text/plain;charset=US-ASCII"},
+		{"media type parameters", "data:application/json;charset=utf-8,%7B%7D", "{}", "application/json;charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "out")
+			result, err := retrieve.New(tt.url).SetOutput(output).ExecWithResult()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantType, result.Header.Get("Content-Type"))
+
+			data, err := os.ReadFile(output)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+		})
+	}
+}
+
+func TestExec_DataURLToDirectory(t *testing.T) {
+	dir := t.TempDir()
+	result, err := retrieve.New("data:,inline").SetOutput(dir + string(filepath.Separator)).ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "data"), result.Output)
+}
+
+func TestExec_DataURLInvalid(t *testing.T) {
+	_, err := retrieve.New("data:;base64,!!!").ExecBytes()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+
+	_, err = retrieve.New("data:no-comma").ExecBytes()
+	assert.ErrorContains(t, err, "invalid URL")
+}
@@ -0,0 +1,152 @@
+package retrieve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SetAttemptTimeout limits each attempt, from sending the request to
+// writing the last byte, to d. An attempt that times out is retried like
+// other transient failures, with a fresh budget of d. Unlike SetTimeout,
+// which applies to each HTTP request, it covers every request of an
+// attempt, such as segments and chunks.
+//
+// Setting it removes the default overall timeout, as SetConnectTimeout does.
+func (b *Builder) SetAttemptTimeout(d time.Duration) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if d <= 0 {
+		b.err = fmt.Errorf("invalid attempt timeout: %v", d)
+		return b
+	}
+	b.attemptTimeout = d
+	return b
+}
+
+// GetAttemptTimeout returns the timeout of each attempt, or 0 if there is none.
+func (b *Builder) GetAttemptTimeout() time.Duration {
+	return b.attemptTimeout
+}
+
+// SetTotalDeadline limits the whole of Exec, including every attempt,
+// the backoff between them and any polling, to d from the time Exec is
+// called. Once it passes, Exec fails with context.DeadlineExceeded and no
+// more retries are made. It applies on top of the context's own deadline.
+//
+// Setting it removes the default overall timeout, as SetConnectTimeout does.
+func (b *Builder) SetTotalDeadline(d time.Duration) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if d <= 0 {
+		b.err = fmt.Errorf("invalid total deadline: %v", d)
+		return b
+	}
+	b.totalDeadline = d
+	return b
+}
+
+// GetTotalDeadline returns the deadline of the whole request, or 0 if
+// there is none.
+func (b *Builder) GetTotalDeadline() time.Duration {
+	return b.totalDeadline
+}
+
+// sizeDeadlineGrace is added to the transfer time allowed by
+// DeadlineFromSize, for connecting and waiting for the response.
+const sizeDeadlineGrace = 30 * time.Second
+
+// DeadlineFromSize limits the whole of Exec to the time the file takes to
+// download at minBytesPerSec, plus 30 seconds, so large files get the time
+// they need while a transfer that slows to a crawl fails instead of
+// lingering. The size is taken from SetSizeHint or else from a HEAD
+// request; if it is unknown, no deadline applies. Once the deadline
+// passes, Exec fails with context.DeadlineExceeded, as with
+// SetTotalDeadline, which still applies if set.
+//
+// Setting it removes the default overall timeout, as SetConnectTimeout does.
+func (b *Builder) DeadlineFromSize(minBytesPerSec int64) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if minBytesPerSec <= 0 {
+		b.err = fmt.Errorf("invalid minimum speed: %d bytes per second", minBytesPerSec)
+		return b
+	}
+	b.minBytesPerSec = minBytesPerSec
+	return b
+}
+
+// GetDeadlineFromSize returns the minimum speed, in bytes per second, the
+// deadline is derived from, or 0 if DeadlineFromSize is not set.
+func (b *Builder) GetDeadlineFromSize() int64 {
+	return b.minBytesPerSec
+}
+
+// sizeDeadline returns the deadline for downloading size bytes at the
+// minimum speed.
+func (b *Builder) sizeDeadline(size int64) time.Duration {
+	return time.Duration(float64(size)/float64(b.minBytesPerSec)*float64(time.Second)) + sizeDeadlineGrace
+}
+
+// applySizeDeadline sets the deadline derived from the size of rawURL, if
+// DeadlineFromSize is set and the size is known, and returns a function
+// that removes it.
+func (b *Builder) applySizeDeadline(client *http.Client, rawURL string) func() {
+	if b.minBytesPerSec <= 0 {
+		return func() {}
+	}
+	size := b.sizeHint
+	if size < 0 {
+		info, err := b.probe(client, rawURL)
+		if err != nil || info.Size < 0 {
+			return func() {}
+		}
+		size = info.Size
+	}
+
+	parent := b.ctx
+	ctx, cancel := context.WithTimeout(parent, b.sizeDeadline(size))
+	b.ctx = ctx
+	return func() {
+		cancel()
+		b.ctx = parent
+	}
+}
+
+// withContext runs fn with the builder's context replaced by ctx.
+func (b *Builder) withContext(ctx context.Context, fn func() error) error {
+	parent := b.ctx
+	b.ctx = ctx
+	defer func() { b.ctx = parent }()
+	return fn()
+}
+
+// withTotalDeadline runs fn under the total deadline, if any.
+func (b *Builder) withTotalDeadline(fn func() error) error {
+	if b.totalDeadline <= 0 {
+		return fn()
+	}
+	ctx, cancel := context.WithTimeout(b.ctx, b.totalDeadline)
+	defer cancel()
+	return b.withContext(ctx, fn)
+}
+
+// timedAttempt performs an attempt under the attempt timeout, if any.
+func (b *Builder) timedAttempt(client *http.Client, rawURL string) error {
+	if b.attemptTimeout <= 0 {
+		return b.attempt(client, rawURL)
+	}
+	ctx, cancel := context.WithTimeout(b.ctx, b.attemptTimeout)
+	defer cancel()
+	err := b.withContext(ctx, func() error {
+		return b.attempt(client, rawURL)
+	})
+	if err != nil && ctx.Err() != nil && b.ctx.Err() == nil {
+		err = fmt.Errorf("attempt timed out after %v: %w", b.attemptTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+package retrieve_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newSlowServer serves "done", taking delay on the first n requests.
+func newSlowServer(t *testing.T, n int32, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= n {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+		w.Write([]byte("done"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestSetAttemptTimeout(t *testing.T) {
+	assert.Equal(t, time.Duration(0), retrieve.New("http://example.com").GetAttemptTimeout())
+	assert.Equal(t, time.Second, retrieve.New("http://example.com").SetAttemptTimeout(time.Second).GetAttemptTimeout())
+
+	server, requests := newSlowServer(t, 2, time.Second)
+
+	data, err := retrieve.New(server.URL).
+		SetAttemptTimeout(50*time.Millisecond).
+		SetRetries(2).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		ExecString()
+	assert.NoError(t, err)
+	assert.Equal(t, "done", data)
+	assert.Equal(t, int32(3), requests.Load())
+
+	server, _ = newSlowServer(t, 1, time.Second)
+	_, err = retrieve.New(server.URL).SetAttemptTimeout(50 * time.Millisecond).ExecString()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "attempt timed out")
+}
+
+func TestSetTotalDeadline(t *testing.T) {
+	assert.Equal(t, time.Duration(0), retrieve.New("http://example.com").GetTotalDeadline())
+
+	err := retrieve.New("http://example.com").SetTotalDeadline(-time.Second).Exec()
+	assert.ErrorContains(t, err, "invalid total deadline")
+
+	server, requests := newSlowServer(t, 100, time.Second)
+
+	start := time.Now()
+	_, err = retrieve.New(server.URL).
+		SetAttemptTimeout(50*time.Millisecond).
+		SetTotalDeadline(200*time.Millisecond).
+		SetRetries(100).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		ExecString()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Less(t, requests.Load(), int32(10))
+}
+
+// deadlineTransport records the deadline of each request's context by method.
+type deadlineTransport struct {
+	mu        sync.Mutex
+	deadlines map[string]time.Time
+}
+
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	if t.deadlines == nil {
+		t.deadlines = make(map[string]time.Time)
+	}
+	t.deadlines[req.Method], _ = req.Context().Deadline()
+	t.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDeadlineFromSize(t *testing.T) {
+	assert.Equal(t, int64(0), retrieve.New("http://example.com").GetDeadlineFromSize())
+	assert.ErrorContains(t, retrieve.New("http://example.com").DeadlineFromSize(0).Exec(), "invalid minimum speed")
+
+	var heads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+		}
+		w.Header().Set("Content-Length", "1000000")
+		if r.Method == http.MethodGet {
+			w.Write(make([]byte, 1000000))
+		}
+	}))
+	defer server.Close()
+
+	// The size is probed with a HEAD request: 10 seconds at 100 kB/s, plus 30.
+	transport := &deadlineTransport{}
+	start := time.Now()
+	b := retrieve.New(server.URL).DeadlineFromSize(100_000).SetTransport(transport)
+	assert.Equal(t, int64(100_000), b.GetDeadlineFromSize())
+	_, err := b.ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), heads.Load())
+	assert.WithinDuration(t, start.Add(40*time.Second), transport.deadlines[http.MethodGet], time.Second)
+
+	// A size hint saves the HEAD request.
+	transport = &deadlineTransport{}
+	start = time.Now()
+	_, err = retrieve.New(server.URL).DeadlineFromSize(10_000).SetSizeHint(1000000).SetTransport(transport).ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), heads.Load())
+	assert.WithinDuration(t, start.Add(130*time.Second), transport.deadlines[http.MethodGet], time.Second)
+}
+
+func TestDeadlineFromSize_UnknownSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	transport := &deadlineTransport{}
+	data, err := retrieve.New(server.URL).DeadlineFromSize(1000).SetTransport(transport).ExecString()
+	assert.NoError(t, err)
+	assert.Equal(t, "done", data)
+	assert.True(t, transport.deadlines[http.MethodGet].IsZero())
+}
@@ -0,0 +1,254 @@
+package retrieve
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ciathefed/retrieve/internal/brotli"
+	"github.com/ciathefed/retrieve/internal/zstd"
+)
+
+// ErrDecompressionLimit is returned when a compressed response expands
+// beyond the limits set with SetDecompressionLimits.
+var ErrDecompressionLimit = errors.New("decompression limit exceeded")
+
+// ratioGrace is how much output a decompressor may produce before the
+// compression ratio limit is enforced, so small, highly compressible
+// responses are not rejected.
+const ratioGrace = 1 << 20
+
+// SetDecompressionLimits guards against decompression bombs by capping the
+// decompressed size of a response at maxSize bytes and its compression
+// ratio at maxRatio. Zero disables either limit. Exceeding a limit fails
+// the download with ErrDecompressionLimit, and it is not retried.
+//
+// By default the HTTP transport transparently decompresses gzip responses
+// with no limits. When limits are set, gzip is requested and decompressed
+// by the Builder instead, so the limits can be enforced.
+func (b *Builder) SetDecompressionLimits(maxSize int64, maxRatio float64) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if maxSize < 0 || maxRatio < 0 {
+		b.err = fmt.Errorf("invalid decompression limits: size %d, ratio %g", maxSize, maxRatio)
+		return b
+	}
+	b.maxDecompressedSize = maxSize
+	b.maxCompressionRatio = maxRatio
+	return b
+}
+
+// GetDecompressionLimits returns the maximum decompressed size and compression ratio.
+func (b *Builder) GetDecompressionLimits() (int64, float64) {
+	return b.maxDecompressedSize, b.maxCompressionRatio
+}
+
+func (b *Builder) hasDecompressionLimits() bool {
+	return b.maxDecompressedSize > 0 || b.maxCompressionRatio > 0
+}
+
+// AutoDecompress asks the server for a compressed response, sending
+// "Accept-Encoding: gzip, br, zstd", and decompresses it before it is
+// written. Responses the server compressed without being asked are
+// decompressed too, so the output holds the content rather than, say, a
+// gzip stream named data.json. Codings whose decompressor would not fit in
+// the memory budget are not requested.
+//
+// Like the HTTP transport, it does not ask for compression when the caller
+// set Accept-Encoding or a range is requested, and ranged responses are
+// never decompressed.
+func (b *Builder) AutoDecompress() *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.autoDecompress = true
+	return b
+}
+
+// IsAutoDecompress returns whether responses are decompressed automatically.
+func (b *Builder) IsAutoDecompress() bool {
+	return b.autoDecompress
+}
+
+// codingMemory is the memory held by the decompressor of each supported
+// content coding.
+var codingMemory = map[string]int64{
+	"gzip":    gzipMemory,
+	"deflate": gzipMemory,
+	"br":      brotliMemory,
+	"zstd":    zstdMemory,
+}
+
+// autoCodings are the codings AutoDecompress asks for.
+var autoCodings = []string{"gzip", "br", "zstd"}
+
+// requestEncoding asks for a compressed response on req, to be decompressed
+// by decodeResponse, if AutoDecompress or decompression limits are set.
+// Like the transport, it does not ask when the caller set Accept-Encoding
+// or requests a range.
+func (b *Builder) requestEncoding(req *http.Request) bool {
+	if !b.autoDecompress && !b.hasDecompressionLimits() || req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" {
+		return false
+	}
+	if !b.autoDecompress {
+		req.Header.Set("Accept-Encoding", "gzip")
+		return true
+	}
+	var codings []string
+	for _, c := range autoCodings {
+		if b.memoryBudget == 0 || codingMemory[c]+copyBufferSize <= b.memoryBudget {
+			codings = append(codings, c)
+		}
+	}
+	if len(codings) == 0 {
+		return false
+	}
+	req.Header.Set("Accept-Encoding", strings.Join(codings, ", "))
+	return true
+}
+
+// decodeResponse replaces the body of a compressed response with its
+// decompressed content, guarded by the decompression limits. Responses
+// with a coding it cannot decode are left as they are.
+func (b *Builder) decodeResponse(resp *http.Response) {
+	var codings []string
+	for _, c := range strings.Split(resp.Header.Get("Content-Encoding"), ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c == "" || c == "identity" {
+			continue
+		}
+		if c == "x-gzip" {
+			c = "gzip"
+		}
+		if _, ok := codingMemory[c]; !ok {
+			return
+		}
+		codings = append(codings, c)
+	}
+	if len(codings) == 0 {
+		return
+	}
+
+	compressed := &countingReader{r: resp.Body}
+	db := &decodedBody{body: resp.Body}
+	var r io.Reader = compressed
+	// Codings are listed in the order they were applied.
+	for _, c := range slices.Backward(codings) {
+		lr := &lazyDecoder{r: r, coding: c, memory: b.memory}
+		db.decoders = append(db.decoders, lr)
+		r = lr
+	}
+	db.Reader = &bombGuard{
+		r:          r,
+		compressed: compressed,
+		maxSize:    b.maxDecompressedSize,
+		maxRatio:   b.maxCompressionRatio,
+	}
+	resp.Body = db
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decodedBody reads decompressed data and closes the underlying body.
+type decodedBody struct {
+	io.Reader
+	body     io.Closer
+	decoders []*lazyDecoder
+}
+
+func (db *decodedBody) Close() error {
+	for _, d := range db.decoders {
+		d.release()
+	}
+	return db.body.Close()
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// lazyDecoder defers starting the decompressor, which reads the stream
+// header, and reserving memory for it until the first Read.
+type lazyDecoder struct {
+	r      io.Reader
+	coding string
+	dec    io.Reader
+	size   int64 // memory reserved
+	memory *memoryBudget
+}
+
+func (lr *lazyDecoder) Read(p []byte) (int, error) {
+	if lr.dec == nil {
+		size := codingMemory[lr.coding]
+		if err := lr.memory.reserve(size, "decompression window"); err != nil {
+			return 0, err
+		}
+		dec, err := newDecoder(lr.coding, lr.r)
+		if err != nil {
+			lr.memory.release(size)
+			return 0, err
+		}
+		lr.dec, lr.size = dec, size
+	}
+	return lr.dec.Read(p)
+}
+
+// release returns the decompressor's memory to the budget.
+func (lr *lazyDecoder) release() {
+	if lr.dec != nil {
+		lr.memory.release(lr.size)
+		lr.dec = nil
+	}
+}
+
+// newDecoder returns a reader decompressing r, compressed with coding.
+func newDecoder(coding string, r io.Reader) (io.Reader, error) {
+	switch coding {
+	case "br":
+		return brotli.NewReader(r), nil
+	case "zstd":
+		return zstd.NewReader(r), nil
+	case "deflate":
+		return zlib.NewReader(r)
+	default:
+		return gzip.NewReader(r)
+	}
+}
+
+// bombGuard fails once the decompressed output of r exceeds maxSize bytes
+// or maxRatio times the compressed input.
+type bombGuard struct {
+	r          io.Reader
+	compressed *countingReader
+	maxSize    int64
+	maxRatio   float64
+	n          int64
+}
+
+func (g *bombGuard) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	g.n += int64(n)
+	if g.maxSize > 0 && g.n > g.maxSize {
+		return n, fmt.Errorf("%w: more than %d bytes", ErrDecompressionLimit, g.maxSize)
+	}
+	if g.maxRatio > 0 && g.n > ratioGrace && float64(g.n) > g.maxRatio*float64(g.compressed.n) {
+		return n, fmt.Errorf("%w: ratio above %g", ErrDecompressionLimit, g.maxRatio)
+	}
+	return n, err
+}
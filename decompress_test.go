@@ -0,0 +1,198 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func newGzipServer(body []byte, requests *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Accept-Encoding") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		w.Write(body)
+	}))
+}
+
+func TestSetDecompressionLimits(t *testing.T) {
+	size, ratio := retrieve.New("http://example.com").SetDecompressionLimits(1<<20, 50).GetDecompressionLimits()
+	assert.Equal(t, int64(1<<20), size)
+	assert.Equal(t, 50.0, ratio)
+	assert.Error(t, retrieve.New("http://example.com").SetDecompressionLimits(-1, 0).Exec())
+}
+
+func TestExec_DecompressionWithinLimits(t *testing.T) {
+	var requests atomic.Int32
+	data := bytes.Repeat([]byte("retrieve "), 1000)
+	server := newGzipServer(gzipBytes(t, data), &requests)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out")
+	err := retrieve.New(server.URL).SetOutput(output).SetDecompressionLimits(1<<20, 0).Exec()
+	assert.NoError(t, err)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+}
+
+func TestExec_DecompressionBomb(t *testing.T) {
+	bomb := make([]byte, 16<<20)
+	tests := []struct {
+		name     string
+		maxSize  int64
+		maxRatio float64
+	}{
+		{name: "size", maxSize: 4 << 20},
+		{name: "ratio", maxRatio: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := newGzipServer(gzipBytes(t, bomb), &requests)
+			defer server.Close()
+
+			err := retrieve.New(server.URL).
+				SetOutput(filepath.Join(t.TempDir(), "out")).
+				SetRetries(2).
+				SetRetryBackoff(time.Millisecond, time.Millisecond).
+				SetDecompressionLimits(tt.maxSize, tt.maxRatio).
+				Exec()
+			assert.ErrorIs(t, err, retrieve.ErrDecompressionLimit)
+			assert.Equal(t, int32(1), requests.Load())
+		})
+	}
+}
+
+// newEncodedServer serves body with the given Content-Encoding, whatever
+// the request asked for, and records the Accept-Encoding it was sent.
+func newEncodedServer(body []byte, encoding string, accept *atomic.Value) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept.Store(r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(body)
+	}))
+}
+
+func readTestdata(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return data
+}
+
+func TestAutoDecompress(t *testing.T) {
+	assert.False(t, retrieve.New("http://example.com").IsAutoDecompress())
+	assert.True(t, retrieve.New("http://example.com").AutoDecompress().IsAutoDecompress())
+
+	hello := []byte("hello, world\n")
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+	}{
+		{"gzip", "gzip", gzipBytes(t, hello)},
+		{"brotli", "br", readTestdata(t, "internal/brotli/testdata/hello.br")},
+		{"zstd", "zstd", readTestdata(t, "internal/zstd/testdata/hello.zst")},
+		{"layered", "br, gzip", gzipBytes(t, readTestdata(t, "internal/brotli/testdata/hello.br"))},
+		{"identity", "identity", hello},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var accept atomic.Value
+			server := newEncodedServer(tt.body, tt.encoding, &accept)
+			defer server.Close()
+
+			output := filepath.Join(t.TempDir(), "hello.txt")
+			err := retrieve.New(server.URL).SetOutput(output).AutoDecompress().Exec()
+			assert.NoError(t, err)
+			assert.Equal(t, "gzip, br, zstd", accept.Load())
+			data, _ := os.ReadFile(output)
+			assert.Equal(t, hello, data)
+		})
+	}
+}
+
+func TestAutoDecompress_Unrequested(t *testing.T) {
+	// The caller's Accept-Encoding is kept, and a server that compresses
+	// anyway is still decoded.
+	var accept atomic.Value
+	data := []byte(`{"ok": true}`)
+	server := newEncodedServer(gzipBytes(t, data), "gzip", &accept)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "data.json")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetHeader("Accept-Encoding", "identity").
+		AutoDecompress().
+		Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, "identity", accept.Load())
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+}
+
+func TestAutoDecompress_MemoryBudget(t *testing.T) {
+	// Only gzip fits in a 1 MiB budget.
+	var accept atomic.Value
+	data := []byte("hello, world\n")
+	server := newEncodedServer(gzipBytes(t, data), "gzip", &accept)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "hello.txt")
+	err := retrieve.New(server.URL).SetOutput(output).SetMemoryBudget(1 << 20).AutoDecompress().Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip", accept.Load())
+
+	// A server sending zstd regardless fails the budget.
+	zstdServer := newEncodedServer(readTestdata(t, "internal/zstd/testdata/hello.zst"), "zstd", &accept)
+	defer zstdServer.Close()
+	err = retrieve.New(zstdServer.URL).SetOutput(output).SetMemoryBudget(1 << 20).AutoDecompress().Exec()
+	assert.ErrorIs(t, err, retrieve.ErrMemoryBudget)
+}
+
+func TestAutoDecompress_Limits(t *testing.T) {
+	var accept atomic.Value
+	server := newEncodedServer(readTestdata(t, "internal/zstd/testdata/rle.zst"), "zstd", &accept)
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "rle")).
+		SetDecompressionLimits(1<<10, 0).
+		AutoDecompress().
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrDecompressionLimit)
+}
+
+func TestAutoDecompress_UnknownEncoding(t *testing.T) {
+	var accept atomic.Value
+	server := newEncodedServer([]byte("opaque"), "compress", &accept)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "data")
+	err := retrieve.New(server.URL).SetOutput(output).AutoDecompress().Exec()
+	assert.NoError(t, err)
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, "opaque", string(got))
+}
@@ -0,0 +1,228 @@
+package retrieve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const defaultDialTimeout = 30 * time.Second
+
+// SetTCPKeepAlive configures TCP keep-alive probes on the connections used
+// by the request.
+//
+// Aggressive settings keep idle connections from being dropped by NATs and
+// proxies during multi-hour transfers, for example:
+//
+//	SetTCPKeepAlive(net.KeepAliveConfig{Enable: true, Idle: 15 * time.Second, Interval: 15 * time.Second, Count: 4})
+func (b *Builder) SetTCPKeepAlive(config net.KeepAliveConfig) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.keepAlive = &config
+	return b
+}
+
+// GetTCPKeepAlive returns the TCP keep-alive configuration set for the request, if any.
+func (b *Builder) GetTCPKeepAlive() *net.KeepAliveConfig {
+	return b.keepAlive
+}
+
+// SetUnixSocket sends the request over the unix domain socket at path, such
+// as a local daemon like Docker exposing HTTP on a socket. The URL is still
+// used for the request line and the Host header, so
+//
+//	New("http://docker/v1.43/images/json").SetUnixSocket("/var/run/docker.sock")
+//
+// requests /v1.43/images/json with "Host: docker". Proxies are not used.
+func (b *Builder) SetUnixSocket(path string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if path == "" {
+		b.err = fmt.Errorf("invalid unix socket path: %q", path)
+		return b
+	}
+	b.unixSocket = path
+	return b
+}
+
+// GetUnixSocket returns the path of the unix domain socket set for the request, if any.
+func (b *Builder) GetUnixSocket() string {
+	return b.unixSocket
+}
+
+// ResolveTo connects to the given IP addresses instead of looking up host,
+// like curl's --resolve, while the URL still sets the Host header and the
+// TLS server name. This helps testing a staging server or pinning a CDN
+// edge. host may include a port, such as "example.com:443", to only
+// override connections to that port. Addresses are tried in order.
+//
+// Connections through a proxy are not affected, as the proxy looks up the
+// host.
+func (b *Builder) ResolveTo(host string, addrs ...string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if host == "" || len(addrs) == 0 {
+		b.err = fmt.Errorf("invalid host override: %q to %q", host, addrs)
+		return b
+	}
+	ips := make([]string, len(addrs))
+	for i, addr := range addrs {
+		ip := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"))
+		if ip == nil {
+			b.err = fmt.Errorf("invalid address for %s: %q", host, addr)
+			return b
+		}
+		ips[i] = ip.String()
+	}
+	if b.resolveTo == nil {
+		b.resolveTo = make(map[string][]string)
+	}
+	b.resolveTo[strings.ToLower(host)] = ips
+	return b
+}
+
+// GetResolveTo returns the addresses set with ResolveTo, by host.
+func (b *Builder) GetResolveTo() map[string][]string {
+	return b.resolveTo
+}
+
+// PreferIPv4 connects over IPv4 first and only falls back to IPv6 if that
+// fails, instead of racing both families. On networks with broken IPv6,
+// this avoids waiting for IPv6 connections to time out.
+func (b *Builder) PreferIPv4() *Builder {
+	return b.setIPFamily("4", false)
+}
+
+// PreferIPv6 connects over IPv6 first and only falls back to IPv4 if that
+// fails.
+func (b *Builder) PreferIPv6() *Builder {
+	return b.setIPFamily("6", false)
+}
+
+// IPv4Only only connects over IPv4.
+func (b *Builder) IPv4Only() *Builder {
+	return b.setIPFamily("4", true)
+}
+
+func (b *Builder) setIPFamily(family string, only bool) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.ipFamily = family
+	b.ipFamilyOnly = only
+	return b
+}
+
+// GetIPFamily returns the network preferred with PreferIPv4, PreferIPv6 or
+// IPv4Only, "tcp4" or "tcp6", and whether it is the only one used. The
+// network is empty if both families are used alike.
+func (b *Builder) GetIPFamily() (network string, only bool) {
+	if b.ipFamily == "" {
+		return "", false
+	}
+	return "tcp" + b.ipFamily, b.ipFamilyOnly
+}
+
+// needsDialer reports whether any option requires a custom dialer.
+func (b *Builder) needsDialer() bool {
+	return b.keepAlive != nil || b.connectTimeout > 0 || b.unixSocket != "" || b.dnsResolver != nil ||
+		len(b.resolveTo) > 0 || b.ipFamily != "" || b.blockPrivate
+}
+
+// newDialer returns a dialer configured from the builder's options.
+func (b *Builder) newDialer() *net.Dialer {
+	dialer := &net.Dialer{
+		Timeout:   defaultDialTimeout,
+		KeepAlive: 30 * time.Second,
+		Resolver:  b.dnsResolver,
+	}
+	if b.keepAlive != nil {
+		dialer.KeepAliveConfig = *b.keepAlive
+	}
+	if b.connectTimeout > 0 {
+		dialer.Timeout = b.connectTimeout
+	}
+	if b.blockPrivate {
+		dialer.Control = controlBlocked
+	}
+	return dialer
+}
+
+// dialContext dials connections for the transport.
+func (b *Builder) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if b.unixSocket != "" {
+		return b.newDialer().DialContext(ctx, "unix", b.unixSocket)
+	}
+	if ips, port, ok := b.overriddenAddr(addr); ok {
+		var errs []error
+		for _, ip := range b.sortByFamily(ips) {
+			conn, err := b.newDialer().DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("no IPv%s address for %s", b.ipFamily, addr)
+		}
+		return nil, errors.Join(errs...)
+	}
+	if b.ipFamily == "" || network != "tcp" {
+		return b.newDialer().DialContext(ctx, network, addr)
+	}
+
+	conn, err := b.newDialer().DialContext(ctx, "tcp"+b.ipFamily, addr)
+	if err == nil || b.ipFamilyOnly || ctx.Err() != nil {
+		return conn, err
+	}
+	other := "tcp6"
+	if b.ipFamily == "6" {
+		other = "tcp4"
+	}
+	if conn, otherErr := b.newDialer().DialContext(ctx, other, addr); otherErr == nil {
+		return conn, nil
+	}
+	return nil, err
+}
+
+// sortByFamily returns ips with the addresses of the preferred IP family
+// first, or only those if no other family is allowed.
+func (b *Builder) sortByFamily(ips []string) []string {
+	if b.ipFamily == "" {
+		return ips
+	}
+	var preferred, others []string
+	for _, ip := range ips {
+		isV4 := net.ParseIP(ip).To4() != nil
+		if isV4 == (b.ipFamily == "4") {
+			preferred = append(preferred, ip)
+		} else if !b.ipFamilyOnly {
+			others = append(others, ip)
+		}
+	}
+	return append(preferred, others...)
+}
+
+// overriddenAddr returns the addresses set with ResolveTo for the host of
+// addr, and its port.
+func (b *Builder) overriddenAddr(addr string) ([]string, string, bool) {
+	if len(b.resolveTo) == 0 {
+		return nil, "", false
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, "", false
+	}
+	host = strings.ToLower(host)
+	if ips, ok := b.resolveTo[net.JoinHostPort(host, port)]; ok {
+		return ips, port, true
+	}
+	ips, ok := b.resolveTo[host]
+	return ips, port, ok
+}
@@ -0,0 +1,131 @@
+package retrieve_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetTCPKeepAlive(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success"))
+	}))
+	defer server.Close()
+
+	config := net.KeepAliveConfig{Enable: true, Idle: 15 * time.Second, Interval: 5 * time.Second, Count: 4}
+	b := retrieve.New(server.URL).
+		SetTCPKeepAlive(config).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt"))
+	assert.Equal(t, &config, b.GetTCPKeepAlive())
+	assert.NoError(t, b.Exec())
+}
+
+func TestExec_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "http.sock")
+	listener, err := net.Listen("unix", socket)
+	if !assert.NoError(t, err) {
+		return
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + r.URL.Path))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	b := retrieve.New("http://docker/v1.43/images/json").SetUnixSocket(socket)
+	assert.Equal(t, socket, b.GetUnixSocket())
+	data, err := b.ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "docker/v1.43/images/json", string(data))
+}
+
+func TestSetUnixSocket_Empty(t *testing.T) {
+	err := retrieve.New("http://docker/").SetUnixSocket("").Exec()
+	assert.ErrorContains(t, err, "invalid unix socket path")
+}
+
+func TestResolveTo(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.TLS.ServerName))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	rootCAs := server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	// The server only listens on IPv4, so the second address is used.
+	b := retrieve.New("https://example.com:"+port+"/file").
+		SetRootCAs(rootCAs).
+		ResolveTo("EXAMPLE.com", "[::1]", "127.0.0.1")
+	assert.Equal(t, map[string][]string{"example.com": {"::1", "127.0.0.1"}}, b.GetResolveTo())
+	data, err := b.ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com:"+port+" example.com", string(data))
+
+	data, err = retrieve.New("https://example.com:"+port+"/file").
+		SetRootCAs(rootCAs).
+		ResolveTo("example.com:"+port, "127.0.0.1").
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com:"+port+" example.com", string(data))
+}
+
+func TestResolveTo_Invalid(t *testing.T) {
+	assert.ErrorContains(t, retrieve.New("http://example.com").ResolveTo("example.com", "not-an-ip").Exec(), "invalid address")
+	assert.ErrorContains(t, retrieve.New("http://example.com").ResolveTo("example.com").Exec(), "invalid host override")
+}
+
+func TestIPFamily(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		w.Write([]byte(host))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	url := "http://localhost:" + port
+
+	b := retrieve.New(url).IPv4Only()
+	network, only := b.GetIPFamily()
+	assert.Equal(t, "tcp4", network)
+	assert.True(t, only)
+	data, err := b.ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", string(data))
+
+	// The server only listens on IPv4, so the connection falls back to it.
+	b = retrieve.New(url).PreferIPv6()
+	network, only = b.GetIPFamily()
+	assert.Equal(t, "tcp6", network)
+	assert.False(t, only)
+	data, err = b.ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", string(data))
+
+	data, err = retrieve.New(url).PreferIPv4().ResolveTo("localhost", "::1", "127.0.0.1").ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", string(data))
+
+	_, err = retrieve.New(url).IPv4Only().ResolveTo("localhost", "::1").ExecBytes()
+	assert.ErrorContains(t, err, "no IPv4 address")
+}
+
+func TestIPv4Only_IPv6Server(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 is not available:", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	assert.NoError(t, retrieve.New(server.URL).SetOutput(filepath.Join(t.TempDir(), "out")).Exec())
+	assert.Error(t, retrieve.New(server.URL).IPv4Only().SetOutput(filepath.Join(t.TempDir(), "out")).Exec())
+}
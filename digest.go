@@ -0,0 +1,311 @@
+package retrieve
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// SetDigestAuth enables HTTP Digest authentication (RFC 7616).
+//
+// When the server responds with 401 and a Digest challenge, the request is
+// answered and resent automatically. Subsequent requests made by the same
+// Exec to the host that issued the challenge, such as retries and
+// redirects, are authenticated up front. Requests to other hosts are not.
+//
+// The request body is streamed, not buffered, so resending it after a
+// challenge requires a body that can be rewound, such as a string, a byte
+// slice or an io.Seeker.
+func (b *Builder) SetDigestAuth(username, password string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.digestAuth = &digestCredentials{username: username, password: password}
+	return b
+}
+
+type digestCredentials struct {
+	username string
+	password string
+}
+
+// digestAlgorithms lists the supported algorithms, strongest first.
+var digestAlgorithms = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{"SHA-512-256", sha512.New512_256},
+	{"SHA-256", sha256.New},
+	{"MD5", md5.New},
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	userhash  bool
+	hash      func() hash.Hash
+	sess      bool
+}
+
+// parseDigestChallenges returns the strongest supported Digest challenge in
+// the WWW-Authenticate headers, or nil if there is none.
+func parseDigestChallenges(headers []string) *digestChallenge {
+	var best *digestChallenge
+	bestRank := len(digestAlgorithms)
+	for _, header := range headers {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := parseAuthParams(rest)
+
+		algorithm := params["algorithm"]
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+		base, sess := strings.CutSuffix(strings.ToUpper(algorithm), "-SESS")
+
+		for rank, alg := range digestAlgorithms {
+			if alg.name != base || rank >= bestRank {
+				continue
+			}
+			qop := ""
+			for _, q := range strings.Split(params["qop"], ",") {
+				q = strings.TrimSpace(q)
+				if q == "auth" || (q == "auth-int" && qop == "") {
+					qop = q
+				}
+			}
+			if params["qop"] != "" && qop == "" {
+				continue
+			}
+			best = &digestChallenge{
+				realm:     params["realm"],
+				nonce:     params["nonce"],
+				opaque:    params["opaque"],
+				algorithm: algorithm,
+				qop:       qop,
+				userhash:  strings.EqualFold(params["userhash"], "true"),
+				hash:      alg.hash,
+				sess:      sess,
+			}
+			bestRank = rank
+		}
+	}
+	return best
+}
+
+// parseAuthParams parses a comma-separated list of auth-params, where values
+// may be tokens or quoted strings.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			return params
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[key] = value.String()
+	}
+}
+
+// authorize returns the Authorization header answering c for req. With
+// qop=auth-int, the body of req is hashed through req.GetBody.
+func (c *digestChallenge) authorize(creds *digestCredentials, req *http.Request, nc int) (string, error) {
+	h := func(s string) string {
+		hh := c.hash()
+		io.WriteString(hh, s)
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	cnonce := newCnonce()
+	uri := req.URL.RequestURI()
+	ncValue := fmt.Sprintf("%08x", nc)
+
+	ha1 := h(creds.username + ":" + c.realm + ":" + creds.password)
+	if c.sess {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+
+	ha2 := h(req.Method + ":" + uri)
+	if c.qop == "auth-int" {
+		bodyHash, err := c.hashBody(req)
+		if err != nil {
+			return "", err
+		}
+		ha2 = h(req.Method + ":" + uri + ":" + bodyHash)
+	}
+
+	var response string
+	if c.qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + ncValue + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	}
+
+	username := creds.username
+	if c.userhash {
+		username = h(creds.username + ":" + c.realm)
+	}
+
+	params := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", c.realm),
+		fmt.Sprintf("nonce=%q", c.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("algorithm=%s", c.algorithm),
+		fmt.Sprintf("response=%q", response),
+	}
+	if c.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%q", c.opaque))
+	}
+	if c.qop != "" {
+		params = append(params, "qop="+c.qop, "nc="+ncValue, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	if c.userhash {
+		params = append(params, "userhash=true")
+	}
+	return "Digest " + strings.Join(params, ", "), nil
+}
+
+// hashBody returns the hex-encoded hash of the body of req, read through
+// req.GetBody so that req.Body can still be sent.
+func (c *digestChallenge) hashBody(req *http.Request) (string, error) {
+	hh := c.hash()
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return "", fmt.Errorf("cannot hash the %s body for Digest auth-int", req.Method)
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(hh, body)
+		body.Close()
+		if err != nil {
+			return "", err
+		}
+		if body, err = req.GetBody(); err != nil {
+			return "", err
+		}
+		req.Body.Close()
+		req.Body = body
+	}
+	return hex.EncodeToString(hh.Sum(nil)), nil
+}
+
+func newCnonce() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// digestTransport answers Digest challenges on behalf of the wrapped
+// transport, on the host that issued them only.
+type digestTransport struct {
+	base  http.RoundTripper
+	creds *digestCredentials
+
+	mu         sync.Mutex
+	challenges map[string]*digestNonce
+}
+
+// digestNonce is the last challenge received from a host and the number of
+// requests answered with its nonce.
+type digestNonce struct {
+	challenge *digestChallenge
+	nc        int
+}
+
+func (t *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if auth, err := t.authorization(req); err != nil {
+		return nil, err
+	} else if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenge := parseDigestChallenges(resp.Header.Values("WWW-Authenticate"))
+	if challenge == nil {
+		return resp, nil
+	}
+
+	t.mu.Lock()
+	if t.challenges == nil {
+		t.challenges = make(map[string]*digestNonce)
+	}
+	t.challenges[req.URL.Host] = &digestNonce{challenge: challenge}
+	t.mu.Unlock()
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("cannot resend the %s body to %s", req.Method, req.URL)
+		}
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	auth, err := t.authorization(retry)
+	if err != nil {
+		return nil, err
+	}
+	retry.Header.Set("Authorization", auth)
+	return t.base.RoundTrip(retry)
+}
+
+// authorization returns the Authorization header for req using the last
+// challenge received from its host, or an empty string if there is none.
+func (t *digestTransport) authorization(req *http.Request) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := t.challenges[req.URL.Host]
+	if n == nil {
+		return "", nil
+	}
+	n.nc++
+	return n.challenge.authorize(t.creds, req, n.nc)
+}
@@ -0,0 +1,168 @@
+package retrieve_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newDigestServer returns a server requiring Digest authentication with the
+// given algorithm for user "alice" and password "secret".
+func newDigestServer(t *testing.T, algorithm string, newHash func() hash.Hash, challenges *atomic.Int32) *httptest.Server {
+	const realm, nonce = "downloads@example.com", "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+
+	h := func(s string) string {
+		hh := newHash()
+		io.WriteString(hh, s)
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			challenges.Add(1)
+			w.Header().Add("WWW-Authenticate", `Basic realm="legacy"`)
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Digest realm=%q, qop="auth, auth-int", algorithm=%s, nonce=%q, opaque="5ccc069c403ebaf9f0171e9517f40e41"`, realm, algorithm, nonce))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		params := map[string]string{}
+		for _, part := range strings.Split(strings.TrimPrefix(auth, "Digest "), ", ") {
+			key, value, _ := strings.Cut(part, "=")
+			params[key] = strings.Trim(value, `"`)
+		}
+		assert.Equal(t, algorithm, params["algorithm"])
+		assert.Equal(t, "5ccc069c403ebaf9f0171e9517f40e41", params["opaque"])
+
+		ha1 := h("alice:" + realm + ":secret")
+		ha2 := h(r.Method + ":" + params["uri"])
+		expected := h(ha1 + ":" + nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":" + params["qop"] + ":" + ha2)
+		if params["username"] != "alice" || params["response"] != expected {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("protected"))
+	}))
+}
+
+func TestExec_DigestAuth(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		hash      func() hash.Hash
+	}{
+		{"MD5", md5.New},
+		{"SHA-256", sha256.New},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			var challenges atomic.Int32
+			server := newDigestServer(t, tt.algorithm, tt.hash, &challenges)
+			defer server.Close()
+
+			output := filepath.Join(t.TempDir(), "out.txt")
+			err := retrieve.New(server.URL+"/file?x=1").
+				SetDigestAuth("alice", "secret").
+				SetOutput(output).
+				Exec()
+			assert.NoError(t, err)
+			assert.Equal(t, int32(1), challenges.Load())
+
+			data, err := os.ReadFile(output)
+			assert.NoError(t, err)
+			assert.Equal(t, "protected", string(data))
+		})
+	}
+}
+
+func TestExec_DigestAuthWrongPassword(t *testing.T) {
+	var challenges atomic.Int32
+	server := newDigestServer(t, "MD5", md5.New, &challenges)
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		SetDigestAuth("alice", "wrong").
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		Exec()
+
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+}
+
+// digestChallengeHandler challenges requests without Digest credentials and
+// passes the others to next.
+func digestChallengeHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Digest ") {
+			w.Header().Set("WWW-Authenticate", `Digest realm="uploads", qop="auth", nonce="abc"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func TestExec_DigestAuthCrossHostRedirect(t *testing.T) {
+	var cdnAuth []string
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cdnAuth = append(cdnAuth, r.Header.Get("Authorization"))
+		w.Write([]byte("blob"))
+	}))
+	defer cdn.Close()
+
+	server := httptest.NewServer(digestChallengeHandler(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, cdn.URL+"/blob", http.StatusFound)
+	}))
+	defer server.Close()
+
+	data, err := retrieve.New(server.URL).SetDigestAuth("alice", "secret").ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "blob", string(data))
+	assert.Equal(t, []string{""}, cdnAuth)
+}
+
+func TestExec_DigestAuthBody(t *testing.T) {
+	server := httptest.NewServer(digestChallengeHandler(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "upload.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("file body"), 0o644))
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	// A file is rewound to answer the challenge.
+	data, err := retrieve.New(server.URL).
+		SetMethod(http.MethodPost).
+		SetBody(f).
+		SetDigestAuth("alice", "secret").
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "file body", string(data))
+
+	// A stream cannot be resent.
+	_, err = retrieve.New(server.URL).
+		SetMethod(http.MethodPost).
+		SetBody(io.MultiReader(strings.NewReader("stream"))).
+		SetDigestAuth("alice", "secret").
+		ExecBytes()
+	assert.ErrorContains(t, err, "cannot resend the POST body")
+}
@@ -0,0 +1,113 @@
+package retrieve
+
+import (
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// dispositionFilename returns the file name given by a Content-Disposition
+// header, following RFC 6266. An extended "filename*" parameter (RFC 5987)
+// takes precedence over "filename".
+//
+// Headers that mime.ParseMediaType rejects, such as unquoted names with
+// spaces, are parsed leniently so that common server mistakes still work.
+func dispositionFilename(header string) (string, bool) {
+	_, params, err := mime.ParseMediaType(header)
+	if err == nil {
+		if name, ok := params["filename"]; ok && name != "" {
+			return name, true
+		}
+	}
+	return lenientDispositionFilename(header)
+}
+
+// lenientDispositionFilename extracts the file name from a malformed
+// Content-Disposition header, or from an extended parameter in a charset
+// mime.ParseMediaType does not decode.
+func lenientDispositionFilename(header string) (string, bool) {
+	var plain string
+	for _, param := range splitParams(header) {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "filename*":
+			if name, ok := decodeExtValue(value); ok && name != "" {
+				return name, true
+			}
+		case "filename":
+			plain = unquote(value)
+		}
+	}
+	return plain, plain != ""
+}
+
+// splitParams splits a header into its ";"-separated parts, ignoring
+// separators inside quoted strings.
+func splitParams(header string) []string {
+	var parts []string
+	var quoted, escaped bool
+	start := 0
+	for i := 0; i < len(header); i++ {
+		switch c := header[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\' && quoted:
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			parts = append(parts, header[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, header[start:])
+}
+
+// unquote removes the quotes and backslash escapes of an HTTP quoted-string.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	var b strings.Builder
+	s = s[1 : len(s)-1]
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// decodeExtValue decodes an RFC 5987 ext-value such as
+// "UTF-8”na%C3%AFve.txt". UTF-8 and ISO-8859-1 are supported.
+func decodeExtValue(value string) (string, bool) {
+	charset, rest, ok := strings.Cut(unquote(value), "'")
+	if !ok {
+		return "", false
+	}
+	_, encoded, ok := strings.Cut(rest, "'")
+	if !ok {
+		return "", false
+	}
+	decoded, err := url.PathUnescape(encoded)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(charset) {
+	case "utf-8", "us-ascii":
+		return decoded, true
+	case "iso-8859-1":
+		runes := make([]rune, len(decoded))
+		for i := 0; i < len(decoded); i++ {
+			runes[i] = rune(decoded[i])
+		}
+		return string(runes), true
+	}
+	return "", false
+}
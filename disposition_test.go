@@ -0,0 +1,51 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_ContentDisposition(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "quoted", header: `attachment; filename="report.pdf"`, want: "report.pdf"},
+		{name: "token", header: `attachment; filename=report.pdf`, want: "report.pdf"},
+		{name: "quoted semicolon", header: `attachment; filename="a;b.txt"; size=10`, want: "a;b.txt"},
+		{name: "escaped quote", header: `attachment; filename="say \"hi\".txt"`, want: `say "hi".txt`},
+		{name: "utf-8", header: `attachment; filename*=UTF-8''na%C3%AFve%20caf%C3%A9.txt`, want: "naïve café.txt"},
+		{name: "extended preferred", header: `attachment; filename="fallback.txt"; filename*=UTF-8''%E2%82%AC%20rates.txt`, want: "€ rates.txt"},
+		{name: "iso-8859-1", header: `attachment; filename*=iso-8859-1'en'%A3%20rates.txt`, want: "£ rates.txt"},
+		{name: "unquoted spaces", header: `attachment; filename=my report.pdf`, want: "my report.pdf"},
+		{name: "parameter case", header: `ATTACHMENT; FILENAME="upper.txt"`, want: "upper.txt"},
+		{name: "path separators", header: `attachment; filename*=UTF-8''..%2F..%2Fevil.txt`, want: "evil.txt"},
+		{name: "no filename", header: `inline`, want: "download.bin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Disposition", tt.header)
+				w.Write([]byte("success"))
+			}))
+			defer server.Close()
+
+			dir := t.TempDir()
+			err := retrieve.New(server.URL + "/download.bin").SetOutput(dir).Exec()
+			assert.NoError(t, err)
+
+			data, err := os.ReadFile(filepath.Join(dir, tt.want))
+			assert.NoError(t, err)
+			assert.Equal(t, "success", string(data))
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package retrieve
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// dohTimeout bounds a DNS-over-HTTPS query without a deadline.
+const dohTimeout = 10 * time.Second
+
+// dohClient sends DNS-over-HTTPS queries for resolvers without a client.
+var dohClient = &http.Client{Transport: newDefaultTransport()}
+
+// SetResolver sets the DNS resolver used to look up the host names of the
+// request, instead of the system's, such as one pinned to a specific
+// server. See NewDoHResolver for a resolver using DNS over HTTPS. Host
+// names reached through a proxy are resolved by the proxy.
+func (b *Builder) SetResolver(r *net.Resolver) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.dnsResolver = r
+	return b
+}
+
+// GetResolver returns the DNS resolver set for the request, if any.
+func (b *Builder) GetResolver() *net.Resolver {
+	return b.dnsResolver
+}
+
+// SetDNSOverHTTPS resolves host names with DNS over HTTPS (RFC 8484)
+// through the given endpoint, such as "https://1.1.1.1/dns-query", so
+// downloads work where the system's DNS is broken or untrusted. It is a
+// shorthand for SetResolver(NewDoHResolver(endpoint, nil)).
+//
+// The host name of the endpoint itself is resolved by the system, so an
+// endpoint with an IP address avoids the system's DNS entirely.
+func (b *Builder) SetDNSOverHTTPS(endpoint string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		b.err = fmt.Errorf("invalid DNS-over-HTTPS endpoint: %s", endpoint)
+		return b
+	}
+	return b.SetResolver(NewDoHResolver(endpoint, nil))
+}
+
+// NewDoHResolver returns a resolver sending its DNS queries to endpoint
+// with DNS over HTTPS (RFC 8484), using client, or a default client if nil.
+func NewDoHResolver(endpoint string, client *http.Client) *net.Resolver {
+	if client == nil {
+		client = dohClient
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return &dohConn{ctx: ctx, client: client, endpoint: endpoint}, nil
+		},
+	}
+}
+
+// dohConn carries the DNS exchanges of the Go resolver over HTTPS. It is
+// not a net.PacketConn, so the resolver writes each query with a two-byte
+// length prefix, as over TCP, and expects replies in the same format.
+type dohConn struct {
+	ctx      context.Context
+	client   *http.Client
+	endpoint string
+	deadline time.Time
+	query    []byte
+	reply    bytes.Reader
+}
+
+func (c *dohConn) Write(p []byte) (int, error) {
+	c.query = append(c.query, p...)
+	for len(c.query) >= 2 {
+		n := int(binary.BigEndian.Uint16(c.query))
+		if len(c.query) < 2+n {
+			break
+		}
+		reply, err := c.exchange(c.query[2 : 2+n])
+		if err != nil {
+			return 0, err
+		}
+		c.query = c.query[2+n:]
+		if len(reply) > 0xffff {
+			return 0, errors.New("DNS-over-HTTPS reply too large")
+		}
+		c.reply.Reset(append(binary.BigEndian.AppendUint16(nil, uint16(len(reply))), reply...))
+	}
+	return len(p), nil
+}
+
+// exchange sends a DNS query to the endpoint and returns the reply.
+func (c *dohConn) exchange(query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, dohTimeout)
+	defer cancel()
+	if !c.deadline.IsZero() {
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS-over-HTTPS query failed: received status code %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 0xffff+1))
+}
+
+func (c *dohConn) Read(p []byte) (int, error) {
+	return c.reply.Read(p)
+}
+
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr{} }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr{} }
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+type dohAddr struct{}
+
+func (dohAddr) Network() string { return "https" }
+func (dohAddr) String() string  { return "dns-over-https" }
@@ -0,0 +1,107 @@
+package retrieve_test
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newDoHServer answers DNS-over-HTTPS queries for A records with 127.0.0.1
+// and records the names queried.
+func newDoHServer() (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var names []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" || len(query) < 12 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Skip the labels of the question name.
+		end := 12
+		var labels []string
+		for end < len(query) && query[end] != 0 {
+			labels = append(labels, string(query[end+1:end+1+int(query[end])]))
+			end += 1 + int(query[end])
+		}
+		end += 5
+		qtype := binary.BigEndian.Uint16(query[end-4:])
+		mu.Lock()
+		names = append(names, strings.Join(labels, "."))
+		mu.Unlock()
+
+		reply := append([]byte(nil), query[:end]...)
+		// A response with recursion available and no records, other than
+		// the answer to A queries.
+		binary.BigEndian.PutUint16(reply[2:], 0x8180)
+		clear(reply[6:12])
+		if qtype == 1 {
+			binary.BigEndian.PutUint16(reply[6:], 1)
+			reply = append(reply, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(reply)
+	}))
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), names...)
+	}
+}
+
+func TestNewDoHResolver(t *testing.T) {
+	doh, names := newDoHServer()
+	defer doh.Close()
+
+	resolver := retrieve.NewDoHResolver(doh.URL+"/dns-query", doh.Client())
+	addrs, err := resolver.LookupHost(context.Background(), "files.example.test")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1"}, addrs)
+	assert.Contains(t, names(), "files.example.test")
+}
+
+func TestSetResolver(t *testing.T) {
+	doh, names := newDoHServer()
+	defer doh.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("resolved over https"))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	resolver := retrieve.NewDoHResolver(doh.URL+"/dns-query", doh.Client())
+	b := retrieve.New("http://files.example.test:" + port + "/file").SetResolver(resolver)
+	assert.Same(t, resolver, b.GetResolver())
+	data, err := b.ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "resolved over https", string(data))
+	assert.Contains(t, names(), "files.example.test")
+}
+
+func TestSetDNSOverHTTPS(t *testing.T) {
+	b := retrieve.New("http://example.com").SetDNSOverHTTPS("https://1.1.1.1/dns-query")
+	assert.NotNil(t, b.GetResolver())
+
+	assert.ErrorContains(t, retrieve.New("http://example.com").SetDNSOverHTTPS("http://1.1.1.1/dns-query").Exec(), "invalid DNS-over-HTTPS endpoint")
+	assert.ErrorContains(t, retrieve.New("http://example.com").SetDNSOverHTTPS("1.1.1.1").Exec(), "invalid DNS-over-HTTPS endpoint")
+}
+
+func TestNewDoHResolver_ServerError(t *testing.T) {
+	doh := httptest.NewTLSServer(http.NotFoundHandler())
+	defer doh.Close()
+
+	resolver := retrieve.NewDoHResolver(doh.URL, doh.Client())
+	_, err := resolver.LookupHost(context.Background(), "files.example.test")
+	assert.Error(t, err)
+}
@@ -0,0 +1,10 @@
+// Package retrieve downloads files over HTTP with a chainable Builder.
+//
+// The package depends only on the standard library, so embedded and
+// cross-compiled binaries (GOARCH=arm, GOOS=linux and similar) contain no
+// third-party code. Optional features that need third-party modules are
+// kept out of it: HTTP/3 support is only compiled with the http3 build tag,
+// and OpenTelemetry tracing, Prometheus metrics and SFTP downloads live in
+// the retrieveotel, retrieveprom and sftp sub-packages, which are only
+// linked when a program imports them.
+package retrieve
@@ -0,0 +1,59 @@
+package retrieve_test
+
+import (
+	"go/build"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const modulePath = "github.com/ciathefed/retrieve"
+
+// imports returns the packages of the module reached from the core package
+// when built with ctx, and the third-party packages they import.
+func imports(t *testing.T, ctx build.Context) (local, thirdParty []string) {
+	seen := map[string]bool{}
+	var walk func(path, dir string)
+	walk = func(path, dir string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		local = append(local, path)
+
+		pkg, err := ctx.ImportDir(dir, 0)
+		if !assert.NoError(t, err) {
+			return
+		}
+		for _, imp := range pkg.Imports {
+			switch {
+			case strings.HasPrefix(imp, modulePath+"/"):
+				walk(imp, "."+strings.TrimPrefix(imp, modulePath))
+			case strings.Contains(strings.Split(imp, "/")[0], "."):
+				thirdParty = append(thirdParty, imp)
+			}
+		}
+	}
+	walk(modulePath, ".")
+	return local, thirdParty
+}
+
+// TestMinimalBuild keeps the core package free of third-party dependencies
+// and of the optional sub-packages.
+func TestMinimalBuild(t *testing.T) {
+	local, thirdParty := imports(t, build.Default)
+	assert.Empty(t, thirdParty)
+	for _, optional := range []string{"retrieveotel", "retrieveprom", "sftp"} {
+		assert.NotContains(t, local, modulePath+"/"+optional)
+	}
+}
+
+// TestHTTP3BuildTag checks that the http3 build tag is what pulls in the
+// QUIC implementation.
+func TestHTTP3BuildTag(t *testing.T) {
+	ctx := build.Default
+	ctx.BuildTags = append(ctx.BuildTags, "http3")
+	_, thirdParty := imports(t, ctx)
+	assert.Contains(t, thirdParty, "github.com/quic-go/quic-go/http3")
+}
@@ -0,0 +1,62 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ErrChecksumMismatch is returned when the downloaded content does not match
+// the checksum set with VerifyChecksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// StatusError is returned when the server responds with an error status code.
+type StatusError struct {
+	StatusCode int
+
+	// RetryAfter is the delay asked for by a Retry-After header of the
+	// response, or 0 if it had none.
+	RetryAfter time.Duration
+}
+
+// newStatusError returns the StatusError for resp.
+func newStatusError(resp *http.Response) *StatusError {
+	e := &StatusError{StatusCode: resp.StatusCode}
+	if d, ok := retryAfter(resp); ok {
+		e.RetryAfter = d
+	}
+	return e
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("received status code %d", e.StatusCode)
+}
+
+// bodyReader wraps a response body so that read failures can be told apart
+// from failures writing to the output.
+type bodyReader struct {
+	r io.Reader
+}
+
+func (br *bodyReader) Read(p []byte) (int, error) {
+	n, err := br.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = &readError{err: err}
+	}
+	return n, err
+}
+
+// readError marks an error that occurred while reading the response body.
+type readError struct {
+	err error
+}
+
+func (e *readError) Error() string {
+	return e.err.Error()
+}
+
+func (e *readError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,92 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Exit codes returned by ExitCode, so command-line tools built on the
+// package report batch outcomes consistently.
+const (
+	// ExitOK means every item succeeded.
+	ExitOK = 0
+
+	// ExitPartialFailure means some items failed and others succeeded.
+	ExitPartialFailure = 1
+
+	// ExitFailure means every item failed.
+	ExitFailure = 2
+
+	// ExitVerificationFailure means at least one item failed its checksum
+	// or piece verification, whatever the outcome of the others.
+	ExitVerificationFailure = 3
+)
+
+// ExitCode maps the results of a Batch to a process exit code. Items that
+// failed with ErrNotModified were already up to date and count as
+// successful. An empty batch succeeds.
+func ExitCode(results []BatchResult) int {
+	s := summarize(results)
+	switch {
+	case s.verification > 0:
+		return ExitVerificationFailure
+	case s.failed == 0:
+		return ExitOK
+	case s.failed == len(results):
+		return ExitFailure
+	}
+	return ExitPartialFailure
+}
+
+// Summary returns a one-line, human-readable summary of the results of a
+// Batch, such as "8 of 10 succeeded (12.3 MiB), 1 failed, 1 failed
+// verification".
+func Summary(results []BatchResult) string {
+	s := summarize(results)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d of %d succeeded (%s)", len(results)-s.failed, len(results), formatBytes(s.bytes))
+	if failed := s.failed - s.verification; failed > 0 {
+		fmt.Fprintf(&sb, ", %d failed", failed)
+	}
+	if s.verification > 0 {
+		fmt.Fprintf(&sb, ", %d failed verification", s.verification)
+	}
+	return sb.String()
+}
+
+type batchSummary struct {
+	failed       int
+	verification int
+	bytes        int64
+}
+
+func summarize(results []BatchResult) batchSummary {
+	var s batchSummary
+	for _, result := range results {
+		s.bytes += result.BytesWritten
+		if result.Err == nil || errors.Is(result.Err, ErrNotModified) {
+			continue
+		}
+		s.failed++
+		if errors.Is(result.Err, ErrChecksumMismatch) {
+			s.verification++
+		}
+	}
+	return s
+}
+
+// formatBytes formats n with a binary unit, such as "12.3 MiB".
+func formatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n)
+	for _, unit := range []string{"KiB", "MiB", "GiB", "TiB"} {
+		value /= 1024
+		if value < 1024 || unit == "TiB" {
+			return fmt.Sprintf("%.1f %s", value, unit)
+		}
+	}
+	panic("unreachable")
+}
@@ -0,0 +1,42 @@
+package retrieve_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	ok := retrieve.BatchResult{BytesWritten: 1536}
+	failed := retrieve.BatchResult{Err: errors.New("connection refused")}
+	mismatch := retrieve.BatchResult{Err: fmt.Errorf("%w: expected sha256 ab, got cd", retrieve.ErrChecksumMismatch)}
+	unchanged := retrieve.BatchResult{Err: retrieve.ErrNotModified}
+
+	tests := []struct {
+		name    string
+		results []retrieve.BatchResult
+		code    int
+		summary string
+	}{
+		{"empty", nil, retrieve.ExitOK, "0 of 0 succeeded (0 B)"},
+		{"all ok", []retrieve.BatchResult{ok, unchanged}, retrieve.ExitOK, "2 of 2 succeeded (1.5 KiB)"},
+		{"partial", []retrieve.BatchResult{ok, failed}, retrieve.ExitPartialFailure, "1 of 2 succeeded (1.5 KiB), 1 failed"},
+		{"total", []retrieve.BatchResult{failed, failed}, retrieve.ExitFailure, "0 of 2 succeeded (0 B), 2 failed"},
+		{"verification", []retrieve.BatchResult{ok, failed, mismatch}, retrieve.ExitVerificationFailure, "1 of 3 succeeded (1.5 KiB), 1 failed, 1 failed verification"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.code, retrieve.ExitCode(tt.results))
+			assert.Equal(t, tt.summary, retrieve.Summary(tt.results))
+		})
+	}
+}
+
+func TestSummary_Units(t *testing.T) {
+	results := []retrieve.BatchResult{{BytesWritten: 12_900_000}}
+	assert.Equal(t, "1 of 1 succeeded (12.3 MiB)", retrieve.Summary(results))
+}
@@ -0,0 +1,203 @@
+package retrieve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrURLNotFound is returned when the download URL set up with ExtractURL
+// or ExtractURLJSON is missing from the initial response.
+var ErrURLNotFound = errors.New("download URL not found")
+
+// maxExtractBytes caps the size of a JSON response read by ExtractURLJSON.
+const maxExtractBytes = 1 << 20
+
+// extractConfig holds the option set with ExtractURL or ExtractURLJSON.
+type extractConfig struct {
+	header  string
+	pointer string
+}
+
+// ExtractURL makes Exec send the request as configured, take the URL of
+// the file from the named response header and download it with a GET.
+// Redirects of the initial request are not followed, so the header may be
+// "Location". For a "Link" header, the first link is used. A relative URL
+// is resolved against the URL of the request. Mirrors are not used.
+func (b *Builder) ExtractURL(fromHeader string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if fromHeader == "" {
+		b.err = errors.New("invalid header name for ExtractURL")
+		return b
+	}
+	b.extract = &extractConfig{header: http.CanonicalHeaderKey(fromHeader)}
+	return b
+}
+
+// ExtractURLJSON is like ExtractURL but takes the URL from the string at
+// jsonPointer (RFC 6901), such as "/data/download_url", in the JSON body of
+// the initial response.
+func (b *Builder) ExtractURLJSON(jsonPointer string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if jsonPointer != "" && !strings.HasPrefix(jsonPointer, "/") {
+		b.err = fmt.Errorf("invalid JSON pointer: %q", jsonPointer)
+		return b
+	}
+	b.extract = &extractConfig{pointer: jsonPointer}
+	return b
+}
+
+// locateArtifact runs the ExtractURL and PollUntilReady steps, in that
+// order, and returns the URL to download along with a function restoring
+// the request once the download is done.
+func (b *Builder) locateArtifact(client *http.Client) (string, func(), error) {
+	rawURL, restore := b.url, func() {}
+	if b.extract != nil {
+		var err error
+		if rawURL, err = b.extractURL(client, rawURL); err != nil {
+			return "", restore, err
+		}
+		restore = b.useArtifactRequest()
+	}
+	if b.poll != nil {
+		var err error
+		if rawURL, err = b.pollUntilReady(client, rawURL); err != nil {
+			restore()
+			return "", func() {}, err
+		}
+		if b.extract == nil {
+			restore = b.useArtifactRequest()
+		}
+	}
+	return rawURL, restore, nil
+}
+
+// extractURL sends the initial request to rawURL, retrying transient
+// failures, and returns the download URL found in the response.
+func (b *Builder) extractURL(client *http.Client, rawURL string) (string, error) {
+	if b.extract.header != "" {
+		c := *client
+		c.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		client = &c
+	}
+
+	for attempt := 0; ; attempt++ {
+		u, err := b.extractOnce(client, rawURL)
+		if err == nil || attempt >= b.retries || !b.isRetryable(err) {
+			return u, err
+		}
+		if err := sleepContext(b.ctx, b.retryDelay(err, attempt)); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (b *Builder) extractOnce(client *http.Client, rawURL string) (string, error) {
+	if err := b.rewindBody(); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(b.ctx, b.method, rawURL, b.body)
+	if err != nil {
+		return "", err
+	}
+	for key, value := range b.headers {
+		req.Header.Set(key, value)
+	}
+	if b.extract.header == "" && !b.hasHeader("Accept") {
+		req.Header.Set("Accept", "application/json")
+	}
+	b.prepareBody(req).stop()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	b.recordResponse(resp)
+	if resp.StatusCode > 399 {
+		return "", newStatusError(resp)
+	}
+
+	var found string
+	if b.extract.header != "" {
+		found = resp.Header.Get(b.extract.header)
+		if b.extract.header == "Link" {
+			found = firstLink(found)
+		}
+		if found == "" {
+			return "", fmt.Errorf("%w: no %s header", ErrURLNotFound, b.extract.header)
+		}
+	} else {
+		var doc any
+		if err := json.NewDecoder(io.LimitReader(resp.Body, maxExtractBytes)).Decode(&doc); err != nil {
+			return "", fmt.Errorf("failed to decode JSON response: %w", err)
+		}
+		v, err := resolvePointer(doc, b.extract.pointer)
+		if err != nil {
+			return "", err
+		}
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return "", fmt.Errorf("%w: %s is not a non-empty string", ErrURLNotFound, b.extract.pointer)
+		}
+		found = s
+	}
+
+	u, err := resp.Request.URL.Parse(found)
+	if err != nil {
+		return "", fmt.Errorf("invalid download URL: %w", err)
+	}
+	return u.String(), nil
+}
+
+// firstLink returns the target of the first link in a Link header
+// (RFC 8288), such as "<https://example.com/file>; rel=alternate".
+func firstLink(header string) string {
+	start := strings.IndexByte(header, '<')
+	if start < 0 {
+		return ""
+	}
+	end := strings.IndexByte(header[start:], '>')
+	if end < 0 {
+		return ""
+	}
+	return strings.TrimSpace(header[start+1 : start+end])
+}
+
+// resolvePointer returns the value at the JSON pointer p (RFC 6901) in doc.
+func resolvePointer(doc any, p string) (any, error) {
+	if p == "" {
+		return doc, nil
+	}
+	for _, token := range strings.Split(p[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := doc.(type) {
+		case map[string]any:
+			next, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s not in response", ErrURLNotFound, p)
+			}
+			doc = next
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) || token != strconv.Itoa(i) {
+				return nil, fmt.Errorf("%w: %s not in response", ErrURLNotFound, p)
+			}
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("%w: %s not in response", ErrURLNotFound, p)
+		}
+	}
+	return doc, nil
+}
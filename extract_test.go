@@ -0,0 +1,127 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newLocatorServer serves "file" at /files/data and a locator at /locate
+// written by the given handler.
+func newLocatorServer(t *testing.T, locate http.HandlerFunc) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/locate", locate)
+	mux.HandleFunc("/files/data", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		w.Write([]byte("file"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExtractURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{"location", "Location", "/files/data", http.StatusFound},
+		{"custom header", "X-Download-URL", "files/data", http.StatusOK},
+		{"link", "Link", `</files/data>; rel="alternate", </other>; rel="next"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newLocatorServer(t, func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, http.MethodPost, r.Method)
+				w.Header().Set(tt.header, tt.value)
+				w.WriteHeader(tt.status)
+			})
+
+			var buf bytes.Buffer
+			result, err := retrieve.New(server.URL + "/locate").
+				SetMethod(http.MethodPost).
+				SetBody("request").
+				SetWriter(&buf).
+				ExtractURL(tt.header).
+				ExecWithResult()
+			assert.NoError(t, err)
+			assert.Equal(t, "file", buf.String())
+			assert.Equal(t, server.URL+"/files/data", result.URL)
+		})
+	}
+}
+
+func TestExtractURL_Missing(t *testing.T) {
+	server := newLocatorServer(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	err := retrieve.New(server.URL + "/locate").SetWriter(io.Discard).ExtractURL("X-Download-URL").Exec()
+	assert.ErrorIs(t, err, retrieve.ErrURLNotFound)
+
+	err = retrieve.New(server.URL).ExtractURL("").Exec()
+	assert.ErrorContains(t, err, "invalid header name")
+}
+
+func TestExtractURLJSON(t *testing.T) {
+	server := newLocatorServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		w.Write([]byte(`{"data": {"links": [{"a/b": "/files/data"}], "size": 4}}`))
+	})
+
+	var buf bytes.Buffer
+	err := retrieve.New(server.URL + "/locate").SetWriter(&buf).ExtractURLJSON("/data/links/0/a~1b").Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, "file", buf.String())
+
+	for _, pointer := range []string{"/data/missing", "/data/links/1", "/data/size", "/data/links/01"} {
+		err = retrieve.New(server.URL + "/locate").SetWriter(io.Discard).ExtractURLJSON(pointer).Exec()
+		assert.ErrorIs(t, err, retrieve.ErrURLNotFound, pointer)
+	}
+
+	err = retrieve.New(server.URL).ExtractURLJSON("data").Exec()
+	assert.ErrorContains(t, err, "invalid JSON pointer")
+}
+
+func TestExtractURLJSON_PollUntilReady(t *testing.T) {
+	var polls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/exports", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status_url": "/exports/1"}`))
+	})
+	mux.HandleFunc("/exports/1", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		if polls.Add(1) < 2 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Location", "/exports/1.csv")
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/exports/1.csv", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a,b\n"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var buf bytes.Buffer
+	err := retrieve.New(server.URL+"/exports").
+		SetMethod(http.MethodPost).
+		SetWriter(&buf).
+		ExtractURLJSON("/status_url").
+		PollUntilReady(10*time.Millisecond, time.Second, nil).
+		Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, "a,b\n", buf.String())
+	assert.Equal(t, int32(2), polls.Load())
+}
@@ -0,0 +1,44 @@
+package retrieve
+
+import (
+	"io/fs"
+	"os"
+)
+
+// isNamedPipe reports whether path is an existing named pipe (FIFO).
+func isNamedPipe(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode()&fs.ModeNamedPipe != 0
+}
+
+// useStream sets the writer to standard output when the output path is "-",
+// or to the pipe when the output path is an existing named pipe, and
+// returns a function that restores it.
+//
+// A named pipe is written in place, without the create, overwrite and
+// rename steps used for files, so a consumer such as tar or ffmpeg can read
+// the download as it streams. Opening it blocks until a reader opens the
+// other end, and it is closed once the request completes so the reader sees
+// the end of the stream.
+func (b *Builder) useStream() (func(), error) {
+	if b.writer != nil {
+		return func() {}, nil
+	}
+	if b.output == stdoutPath {
+		b.writer = os.Stdout
+		return func() { b.writer = nil }, nil
+	}
+	if !isNamedPipe(b.output) {
+		return func() {}, nil
+	}
+
+	pipe, err := os.OpenFile(b.output, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	b.writer = pipe
+	return func() {
+		pipe.Close()
+		b.writer = nil
+	}, nil
+}
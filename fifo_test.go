@@ -0,0 +1,52 @@
+//go:build unix
+
+package retrieve_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_NamedPipe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("streamed through a pipe"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	fifo := filepath.Join(dir, "pipe")
+	if err := syscall.Mkfifo(fifo, 0o600); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+
+	done := make(chan string)
+	go func() {
+		f, err := os.Open(fifo)
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		defer f.Close()
+		data, _ := io.ReadAll(f)
+		done <- string(data)
+	}()
+
+	err := retrieve.New(server.URL).SetOutput(fifo).Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, "streamed through a pipe", <-done)
+
+	// The pipe is written in place: no partial file and no rename.
+	entries, _ := os.ReadDir(dir)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, os.ModeNamedPipe, entries[0].Type()&os.ModeNamedPipe)
+	}
+}
@@ -0,0 +1,129 @@
+package retrieve
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// schemeTransport serves file:// URLs from the local filesystem, data:
+// URLs from their content and ftp:// URLs with ftpTransport, and sends
+// other requests to base, so every source goes through the same progress,
+// checksum and output pipeline. Like a web server, it supports range
+// requests and conditional requests based on the modification time for
+// files, and reports missing files as 404 Not Found. Schemes registered
+// with RegisterScheme are sent to their RoundTripper.
+//
+// Only URLs the caller configured are dispatched by scheme: redirects to
+// other schemes are refused, so a server cannot make Exec read local files.
+type schemeTransport struct {
+	base   http.RoundTripper
+	files  http.RoundTripper
+	ftp    http.RoundTripper
+	sshKey []byte
+}
+
+func (b *Builder) newSchemeTransport(base http.RoundTripper) *schemeTransport {
+	return &schemeTransport{
+		base:   base,
+		files:  http.NewFileTransport(localFS{}),
+		ftp:    &ftpTransport{dial: b.dialContext, tlsConfig: b.buildTLSConfig(nil)},
+		sshKey: b.sshKey,
+	}
+}
+
+func (t *schemeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var registered http.RoundTripper
+	switch req.URL.Scheme {
+	case "file", "data", "ftp", "ftps":
+	default:
+		rt, ok := registeredScheme(req.URL.Scheme)
+		if !ok {
+			return t.base.RoundTrip(req)
+		}
+		registered = rt
+	}
+	if req.Response != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("refusing redirect to %s URL %s", req.URL.Scheme, req.URL.Redacted())
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp := localResponse(req, http.StatusMethodNotAllowed, nil)
+		resp.Header.Set("Allow", "GET, HEAD")
+		return resp, nil
+	}
+	if registered != nil {
+		if t.sshKey != nil {
+			req = req.WithContext(context.WithValue(req.Context(), sshKeyContextKey{}, t.sshKey))
+		}
+		return registered.RoundTrip(req)
+	}
+	switch req.URL.Scheme {
+	case "data":
+		return serveDataURL(req), nil
+	case "ftp", "ftps":
+		return t.ftp.RoundTrip(req)
+	}
+	return t.files.RoundTrip(req)
+}
+
+// localResponse returns a response to req with the given status and body.
+func localResponse(req *http.Request, code int, body []byte) *http.Response {
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          http.NoBody,
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	if len(body) > 0 && req.Method != http.MethodHead {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return resp
+}
+
+// localFS opens the paths of file:// URLs.
+type localFS struct{}
+
+func (localFS) Open(name string) (http.File, error) {
+	name = filepath.FromSlash(name)
+	if runtime.GOOS == "windows" {
+		// file:///C:/dir/file has the path /C:/dir/file.
+		name = strings.TrimPrefix(name, `\`)
+	}
+	return os.Open(name)
+}
+
+// isFileURL reports whether rawURL is a valid file:// URL: a local path,
+// with no host or localhost.
+func isFileURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Scheme == "file" && (u.Host == "" || u.Host == "localhost") && u.Path != ""
+}
+
+// usesOtherSchemes reports whether the URL or any mirror is a file://,
+// data: or ftp:// URL, or has a scheme registered with RegisterScheme.
+func (b *Builder) usesOtherSchemes() bool {
+	for _, rawURL := range append([]string{b.url}, b.mirrors...) {
+		if isFileURL(rawURL) || isDataURL(rawURL) || isFTPURL(rawURL) || isRegisteredURL(rawURL) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package retrieve_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if filepath.VolumeName(path) != "" {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+func TestExec_FileURL(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.txt")
+	assert.NoError(t, os.WriteFile(source, []byte("local content"), 0o644))
+	sum := sha256.Sum256([]byte("local content"))
+
+	var written int64
+	output := filepath.Join(dir, "out.txt")
+	err := retrieve.New(fileURL(source)).
+		SetOutput(output).
+		VerifyChecksum("sha256", hex.EncodeToString(sum[:])).
+		OnProgress(func(p retrieve.Progress) { written = p.BytesWritten }).
+		Exec()
+	assert.NoError(t, err)
+	assert.EqualValues(t, len("local content"), written)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "local content", string(data))
+}
+
+func TestExec_FileURLNotFound(t *testing.T) {
+	_, err := retrieve.New(fileURL(filepath.Join(t.TempDir(), "missing"))).ExecBytes()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
+
+func TestExec_FileURLMirror(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "source.txt")
+	assert.NoError(t, os.WriteFile(source, []byte("from disk"), 0o644))
+
+	data, err := retrieve.New("http://127.0.0.1:1/unreachable").
+		SetMirrors([]string{fileURL(source)}).
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "from disk", string(data))
+}
+
+func TestExec_FileURLRemoteHost(t *testing.T) {
+	err := retrieve.New("file://example.com/etc/passwd").Exec()
+	assert.ErrorContains(t, err, "invalid URL")
+}
+
+func TestExec_FileURLRedirectRefused(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret.txt")
+	assert.NoError(t, os.WriteFile(secret, []byte("local secret"), 0o644))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, fileURL(secret), http.StatusFound)
+	}))
+	defer server.Close()
+
+	// A data: mirror makes other schemes available, but only for the
+	// configured URLs.
+	output := filepath.Join(dir, "out.txt")
+	err := retrieve.New(server.URL).
+		SetMirrors([]string{"data:,mirror"}).
+		SetOutput(output).
+		Exec()
+	assert.NoError(t, err)
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, "mirror", string(data))
+
+	_, err = retrieve.New(server.URL).
+		SetMirrors([]string{fileURL(filepath.Join(dir, "missing"))}).
+		ExecBytes()
+	assert.ErrorContains(t, err, "refusing redirect from http to file URL")
+}
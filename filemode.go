@@ -0,0 +1,83 @@
+package retrieve
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SetFileMode sets the permission bits of the output file, such as 0o600
+// for a file only the owner can read. The mode is applied regardless of the
+// umask, including when an existing file is overwritten. By default, files
+// are created with mode 0o666 before the umask, as os.Create does.
+func (b *Builder) SetFileMode(mode os.FileMode) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if mode&^os.ModePerm != 0 {
+		b.err = fmt.Errorf("invalid file mode: %v", mode)
+		return b
+	}
+	b.fileMode = mode
+	return b
+}
+
+// GetFileMode returns the permission bits of the output file, or 0 if the
+// default applies.
+func (b *Builder) GetFileMode() os.FileMode {
+	return b.fileMode
+}
+
+// CreateDirs makes Exec create any missing parent directories of the output
+// file with mode 0o755 instead of failing. An output path ending in a path
+// separator is created as a directory and the file name is taken from the
+// response, as with an existing directory.
+func (b *Builder) CreateDirs() *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.createDirs = true
+	return b
+}
+
+// IsCreateDirs returns whether missing directories of the output path are created.
+func (b *Builder) IsCreateDirs() bool {
+	return b.createDirs
+}
+
+// createOutputDir creates the output directory when the output path names
+// one that does not exist yet.
+func (b *Builder) createOutputDir() error {
+	if !b.createDirs || b.writer != nil || b.output == "" || !os.IsPathSeparator(b.output[len(b.output)-1]) {
+		return nil
+	}
+	return os.MkdirAll(b.output, 0o755)
+}
+
+// createFile creates or truncates the file at path with the configured
+// mode, creating its parent directories if CreateDirs is set.
+func (b *Builder) createFile(path string) (*os.File, error) {
+	if b.createDirs {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	mode := b.fileMode
+	if mode == 0 {
+		return os.Create(path)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return nil, err
+	}
+	// Leave the mode of outputs that are not regular files, such as
+	// /dev/null, alone.
+	if info, err := f.Stat(); err == nil && !info.Mode().IsRegular() {
+		return f, nil
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
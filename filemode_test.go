@@ -0,0 +1,79 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetFileMode(t *testing.T) {
+	assert.Equal(t, os.FileMode(0), retrieve.New("http://example.com").GetFileMode())
+	assert.Equal(t, os.FileMode(0o600), retrieve.New("http://example.com").SetFileMode(0o600).GetFileMode())
+
+	err := retrieve.New("http://example.com").SetFileMode(os.ModeDir | 0o755).Exec()
+	assert.ErrorContains(t, err, "invalid file mode")
+
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not supported on Windows")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	for _, atomic := range []bool{true, false} {
+		output := filepath.Join(t.TempDir(), "file")
+		assert.NoError(t, os.WriteFile(output, []byte("old"), 0o644))
+
+		err := retrieve.New(server.URL).SetOutput(output).Atomic(atomic).SetFileMode(0o600).Exec()
+		assert.NoError(t, err)
+
+		info, err := os.Stat(output)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+		data, _ := os.ReadFile(output)
+		assert.Equal(t, "secret", string(data))
+	}
+}
+
+func TestCreateDirs(t *testing.T) {
+	assert.False(t, retrieve.New("http://example.com").IsCreateDirs())
+	assert.True(t, retrieve.New("http://example.com").CreateDirs().IsCreateDirs())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "a", "b", "file")
+	err := retrieve.New(server.URL).SetOutput(output).Exec()
+	assert.Error(t, err)
+
+	err = retrieve.New(server.URL).SetOutput(output).CreateDirs().Exec()
+	assert.NoError(t, err)
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
+
+func TestCreateDirs_Directory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "downloads")
+	err := retrieve.New(server.URL + "/file.txt").SetOutput(dir + string(filepath.Separator)).CreateDirs().Exec()
+	assert.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "file.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
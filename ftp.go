@@ -0,0 +1,156 @@
+package retrieve
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ciathefed/retrieve/internal/ftp"
+)
+
+// ftpTransport serves ftp:// and ftps:// URLs, so FTP downloads go through
+// the same pipeline as HTTP ones. Each request logs in with the user and
+// password of the URL, or anonymously, and downloads the file in passive
+// mode. Range requests are translated to REST, so interrupted downloads
+// resume and segmented downloads work.
+//
+// Replies are translated to HTTP status codes: 550 (file unavailable) to
+// 404, 530 (not logged in) to 401, other transient failures to 503 and
+// other failures to 502.
+//
+// ftps:// negotiates TLS with AUTH TLS, or uses implicit TLS on port 990.
+type ftpTransport struct {
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	tlsConfig *tls.Config
+}
+
+func (t *ftpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := req.URL
+	port := u.Port()
+	if port == "" {
+		port = "21"
+	}
+	cfg := ftp.Config{Dial: t.dial}
+	if u.Scheme == "ftps" {
+		cfg.TLS = t.tlsConfig
+		cfg.ImplicitTLS = port == "990"
+	}
+	c, err := ftp.Dial(req.Context(), net.JoinHostPort(u.Hostname(), port), cfg)
+	var resp *http.Response
+	if err == nil {
+		resp, err = t.retrieve(c, req)
+		if err != nil || resp.Body == http.NoBody {
+			c.Quit()
+		}
+	}
+	var ftpErr *ftp.Error
+	if errors.As(err, &ftpErr) {
+		return ftpErrorResponse(req, ftpErr), nil
+	}
+	return resp, err
+}
+
+// retrieve logs in and starts the download requested by req.
+func (t *ftpTransport) retrieve(c *ftp.Conn, req *http.Request) (*http.Response, error) {
+	user, password := "anonymous", "anonymous@"
+	if req.URL.User != nil {
+		user = req.URL.User.Username()
+		password, _ = req.URL.User.Password()
+	}
+	if err := c.Login(user, password); err != nil {
+		return nil, err
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	size, err := c.Size(path)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{"Accept-Ranges": {"bytes"}}
+	if modTime, err := c.ModTime(path); err == nil {
+		header.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	offset, end, ok := parseRange(req.Header.Get("Range"), size)
+	if !ok {
+		offset, end = 0, size-1
+	}
+	if req.Method == http.MethodHead {
+		resp := localResponse(req, http.StatusOK, nil)
+		resp.Header = header
+		resp.ContentLength = size
+		return resp, nil
+	}
+
+	body, err := c.Retr(req.Context(), path, offset)
+	var ftpErr *ftp.Error
+	if offset > 0 && errors.As(err, &ftpErr) && !ftpErr.Temporary() {
+		// The server does not support REST: send the whole file.
+		offset, end = 0, size-1
+		body, err = c.Retr(req.Context(), path, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = body
+	if end < size-1 {
+		// FTP has no way to stop at the end of a range: the rest is aborted.
+		r = io.LimitReader(body, end+1-offset)
+	}
+	resp := localResponse(req, http.StatusOK, nil)
+	resp.Header = header
+	resp.ContentLength = end + 1 - offset
+	resp.Body = &ftpBody{Reader: r, body: body, c: c}
+	if offset > 0 || end < size-1 {
+		resp.StatusCode = http.StatusPartialContent
+		resp.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		resp.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, end, size))
+	}
+	return resp, nil
+}
+
+// ftpErrorResponse translates a negative FTP reply to an HTTP response.
+func ftpErrorResponse(req *http.Request, err *ftp.Error) *http.Response {
+	code := http.StatusBadGateway
+	switch {
+	case err.Code == 550:
+		code = http.StatusNotFound
+	case err.Code == 530:
+		code = http.StatusUnauthorized
+	case err.Temporary():
+		code = http.StatusServiceUnavailable
+	}
+	resp := localResponse(req, code, []byte(err.Error()))
+	resp.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	return resp
+}
+
+// isFTPURL reports whether rawURL is an ftp:// or ftps:// URL.
+func isFTPURL(rawURL string) bool {
+	scheme, _, ok := strings.Cut(rawURL, "://")
+	return ok && (strings.EqualFold(scheme, "ftp") || strings.EqualFold(scheme, "ftps"))
+}
+
+// ftpBody ends the session once the download is complete or abandoned.
+type ftpBody struct {
+	io.Reader
+	body io.ReadCloser
+	c    *ftp.Conn
+	done bool
+}
+
+func (b *ftpBody) Close() error {
+	if b.done {
+		return nil
+	}
+	b.done = true
+	err := b.body.Close()
+	b.c.Quit()
+	return err
+}
@@ -0,0 +1,136 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+	"github.com/ciathefed/retrieve/internal/ftp/ftptest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_FTP(t *testing.T) {
+	server := ftptest.NewServer(map[string][]byte{"pub/file.txt": []byte("ftp content")})
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "file.txt")
+	result, err := retrieve.New(server.URL + "/pub/file.txt").
+		SetOutput(output).
+		PreserveModTime().
+		ExecWithResult()
+	assert.NoError(t, err)
+	assert.EqualValues(t, len("ftp content"), result.BytesWritten)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "ftp content", string(data))
+
+	info, err := os.Stat(output)
+	assert.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(server.ModTime))
+	assert.Contains(t, server.Commands(), "USER anonymous")
+}
+
+func TestExec_FTPNotFound(t *testing.T) {
+	server := ftptest.NewServer(nil)
+	defer server.Close()
+
+	_, err := retrieve.New(server.URL + "/missing").ExecBytes()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
+
+func TestExec_FTPInjectedCommand(t *testing.T) {
+	server := ftptest.NewServer(map[string][]byte{"important": []byte("keep")})
+	defer server.Close()
+
+	_, err := retrieve.New(server.URL + "/a%0d%0aDELE%20important").ExecBytes()
+	assert.Error(t, err)
+	for _, cmd := range server.Commands() {
+		assert.NotContains(t, cmd, "DELE")
+	}
+}
+
+func TestExec_FTPCredentials(t *testing.T) {
+	server := ftptest.NewUnstartedServer(map[string][]byte{"secret.txt": []byte("private")})
+	server.User, server.Password = "alice", "s3cret"
+	server.Start()
+	defer server.Close()
+
+	data, err := retrieve.New("ftp://alice:s3cret@" + server.Addr + "/secret.txt").ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "private", string(data))
+
+	_, err = retrieve.New("ftp://alice:wrong@" + server.Addr + "/secret.txt").ExecBytes()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+}
+
+func TestExec_FTPResume(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	server := ftptest.NewUnstartedServer(map[string][]byte{"data.bin": data})
+	server.AbortAfter = 4000
+	server.Start()
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "data.bin")
+	err := retrieve.New(server.URL + "/data.bin").
+		SetOutput(output).
+		SetRetries(1).
+		Exec()
+	assert.NoError(t, err)
+
+	got, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Contains(t, server.Commands(), "REST 4000")
+}
+
+func TestExec_FTPResumeUnsupported(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	server := ftptest.NewUnstartedServer(map[string][]byte{"data.bin": data})
+	server.AbortAfter = 4000
+	server.DisableREST = true
+	server.Start()
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "data.bin")
+	err := retrieve.New(server.URL + "/data.bin").
+		SetOutput(output).
+		SetRetries(1).
+		Exec()
+	assert.NoError(t, err)
+
+	// The server ignores the offset, so the download starts over.
+	got, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, data, got)
+}
+
+func TestExec_FTPS(t *testing.T) {
+	// Borrow the certificate of an HTTPS test server.
+	https := httptest.NewTLSServer(http.NotFoundHandler())
+	defer https.Close()
+
+	server := ftptest.NewUnstartedServer(map[string][]byte{"file.txt": []byte("over TLS")})
+	server.TLS = &tls.Config{Certificates: https.TLS.Certificates}
+	server.Start()
+	defer server.Close()
+
+	rootCAs := https.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	data, err := retrieve.New(strings.Replace(server.URL, "ftp://", "ftps://", 1) + "/file.txt").
+		SetRootCAs(rootCAs).
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "over TLS", string(data))
+	assert.Contains(t, server.Commands(), "PROT P")
+}
@@ -0,0 +1,329 @@
+package retrieve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// downloadState tracks the output of a download so it can be served while in progress.
+type downloadState struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	path    string
+	total   int64
+	written int64
+	gen     int
+	done    bool
+	err     error
+}
+
+func newDownloadState() *downloadState {
+	s := &downloadState{total: -1}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// reset clears the state at the beginning of an Exec.
+func (s *downloadState) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = ""
+	s.total = -1
+	s.written = 0
+	s.done = false
+	s.err = nil
+	s.gen++
+	s.cond.Broadcast()
+}
+
+// start records that an attempt began writing to path.
+func (s *downloadState) start(path string, total int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+	s.total = total
+	s.written = 0
+	s.gen++
+	s.cond.Broadcast()
+}
+
+// advance records that n more bytes were written to the output.
+func (s *downloadState) advance(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written += n
+	s.cond.Broadcast()
+}
+
+// rename records that the output was moved to path.
+func (s *downloadState) rename(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path = path
+}
+
+// open opens the output at path, following it if it has since been renamed.
+func (s *downloadState) open(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.mu.Lock()
+		current := s.path
+		s.mu.Unlock()
+		if current != path {
+			return os.Open(current)
+		}
+	}
+	return f, err
+}
+
+// finish records the final outcome of an Exec.
+func (s *downloadState) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	s.err = err
+	s.cond.Broadcast()
+}
+
+// wait blocks until more than offset bytes are written, the download ends,
+// a new attempt starts, or ctx is done. It returns the number of bytes written.
+func (s *downloadState) wait(ctx context.Context, gen int, offset int64) (int64, error) {
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.cond.Broadcast()
+	})
+	defer stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.written <= offset && !s.done && s.gen == gen && ctx.Err() == nil {
+		s.cond.Wait()
+	}
+	switch {
+	case ctx.Err() != nil:
+		return 0, ctx.Err()
+	case s.gen != gen:
+		return 0, errors.New("download restarted")
+	case s.done && s.err != nil:
+		return 0, s.err
+	}
+	return s.written, nil
+}
+
+// waitStart blocks until an attempt starts writing or the download ends.
+func (s *downloadState) waitStart(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.cond.Broadcast()
+	})
+	defer stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.path == "" && !s.done && ctx.Err() == nil {
+		s.cond.Wait()
+	}
+	return ctx.Err()
+}
+
+// Handler returns an http.Handler that serves the output of the download.
+//
+// Completed downloads are served with full Range support. While the download
+// is in progress, responses stream the output as it is written, and a Range
+// request is answered as soon as its bytes become available. Requests made
+// before the download starts receive 503 Service Unavailable, and requests
+// made after it fails receive 502 Bad Gateway.
+func (b *Builder) Handler() http.Handler {
+	return &downloadHandler{state: b.state}
+}
+
+type downloadHandler struct {
+	state *downloadState
+
+	// waitStart makes requests wait for the download to start instead of
+	// failing with 503 Service Unavailable.
+	waitStart bool
+
+	// forwardStatus answers requests for a download that failed with a
+	// *StatusError with its status code instead of 502 Bad Gateway.
+	forwardStatus bool
+}
+
+func (h *downloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	s := h.state
+	if h.waitStart {
+		if err := s.waitStart(r.Context()); err != nil {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	path, total, gen, done, err := s.path, s.total, s.gen, s.done, s.err
+	s.mu.Unlock()
+
+	var statusErr *StatusError
+	switch {
+	case done && err != nil && h.forwardStatus && errors.As(err, &statusErr) && statusErr.StatusCode >= 400:
+		http.Error(w, http.StatusText(statusErr.StatusCode), statusErr.StatusCode)
+	case done && err != nil:
+		http.Error(w, "download failed", http.StatusBadGateway)
+	case done:
+		h.serveComplete(w, r, path)
+	case path == "":
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "download not started", http.StatusServiceUnavailable)
+	default:
+		h.serveInProgress(w, r, path, total, gen)
+	}
+}
+
+func (h *downloadHandler) serveComplete(w http.ResponseWriter, r *http.Request, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "download unavailable", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "download unavailable", http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, filepath.Base(path), info.ModTime(), f)
+}
+
+func (h *downloadHandler) serveInProgress(w http.ResponseWriter, r *http.Request, path string, total int64, gen int) {
+	start, end := int64(0), max(total-1, -1)
+	status := http.StatusOK
+
+	// An open-ended range of unknown size cannot be described in
+	// Content-Range, so it is answered with the whole body instead.
+	if header := r.Header.Get("Range"); header != "" && !(total < 0 && strings.HasSuffix(header, "-")) {
+		var ok bool
+		start, end, ok = parseRange(header, total)
+		if !ok {
+			if total >= 0 {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", total))
+			}
+			http.Error(w, http.StatusText(http.StatusRequestedRangeNotSatisfiable), http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		status = http.StatusPartialContent
+		size := "*"
+		if total >= 0 {
+			size = strconv.FormatInt(total, 10)
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%s", start, end, size))
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	if end >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	}
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	f, err := h.state.open(path)
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	defer f.Close()
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for pos := start; end < 0 || pos <= end; {
+		written, err := h.state.wait(r.Context(), gen, pos)
+		if err != nil {
+			panic(http.ErrAbortHandler)
+		}
+		if written <= pos {
+			// The download finished without reaching pos.
+			if end >= 0 {
+				panic(http.ErrAbortHandler)
+			}
+			return
+		}
+
+		limit := written
+		if end >= 0 {
+			limit = min(limit, end+1)
+		}
+		for pos < limit {
+			n, err := f.ReadAt(buf[:min(int64(len(buf)), limit-pos)], pos)
+			if n > 0 {
+				if _, werr := w.Write(buf[:n]); werr != nil {
+					return
+				}
+				pos += int64(n)
+			}
+			if err != nil && err != io.EOF {
+				panic(http.ErrAbortHandler)
+			}
+			if n == 0 {
+				break
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// parseRange parses a single-range "bytes=" header against a representation
+// of the given total size, which may be -1 if unknown. The returned end is -1
+// for an open-ended range of unknown size.
+func parseRange(header string, total int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	if first == "" {
+		// Suffix range: the last n bytes.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || total < 0 {
+			return 0, 0, false
+		}
+		return max(total-n, 0), total - 1, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || (total >= 0 && start >= total) {
+		return 0, 0, false
+	}
+	end := max(total-1, -1)
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if total >= 0 {
+			end = min(end, total-1)
+		}
+	}
+	return start, end, true
+}
@@ -0,0 +1,165 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_NotStarted(t *testing.T) {
+	b := retrieve.New("http://example.com")
+	rec := httptest.NewRecorder()
+	b.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestHandler_Completed(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer origin.Close()
+
+	b := retrieve.New(origin.URL).SetOutput(filepath.Join(t.TempDir(), "out.txt"))
+	assert.NoError(t, b.Exec())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+	b.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "bytes 2-5/10", rec.Header().Get("Content-Range"))
+	assert.Equal(t, "2345", rec.Body.String())
+}
+
+func TestHandler_InProgress(t *testing.T) {
+	release := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte("01234"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("56789"))
+	}))
+	defer origin.Close()
+
+	var first sync.Once
+	started := make(chan struct{})
+	b := retrieve.New(origin.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		OnProgress(func(p retrieve.Progress) {
+			if p.BytesWritten >= 5 {
+				first.Do(func() { close(started) })
+			}
+		})
+
+	done := make(chan error)
+	go func() { done <- b.Exec() }()
+	<-started
+
+	proxy := httptest.NewServer(b.Handler())
+	defer proxy.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, proxy.URL, nil)
+	req.Header.Set("Range", "bytes=1-3")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "bytes 1-3/10", resp.Header.Get("Content-Range"))
+	assert.Equal(t, "123", string(body))
+
+	resp, err = http.Get(proxy.URL)
+	assert.NoError(t, err)
+	close(release)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0123456789", string(body))
+
+	assert.NoError(t, <-done)
+}
+
+func TestHandler_InProgressSegments(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	release := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+			return
+		}
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", "20")
+		w.Write(data[:5])
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write(data[5:])
+	}))
+	defer origin.Close()
+
+	var first sync.Once
+	started := make(chan struct{})
+	b := retrieve.New(origin.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		SetSegments(2).
+		OnProgress(func(p retrieve.Progress) {
+			if len(p.Segments) == 2 && p.Segments[0].BytesWritten >= 5 {
+				first.Do(func() { close(started) })
+			}
+		})
+
+	done := make(chan error)
+	go func() { done <- b.Exec() }()
+	<-started
+
+	proxy := httptest.NewServer(b.Handler())
+	defer proxy.Close()
+
+	// The first 5 bytes are available while the first segment is stalled.
+	req, _ := http.NewRequest(http.MethodGet, proxy.URL, nil)
+	req.Header.Set("Range", "bytes=1-3")
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		assert.Equal(t, "123", string(body))
+	}
+
+	resp, err = http.Get(proxy.URL)
+	close(release)
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, string(data), string(body))
+	}
+
+	assert.NoError(t, <-done)
+}
+
+func TestHandler_Failed(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer origin.Close()
+
+	b := retrieve.New(origin.URL).SetOutput(filepath.Join(t.TempDir(), "out.txt"))
+	assert.Error(t, b.Exec())
+
+	rec := httptest.NewRecorder()
+	b.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.True(t, strings.Contains(rec.Body.String(), "failed"))
+}
@@ -0,0 +1,75 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrHeadersTooLarge is returned when response headers exceed the limits
+// set with SetHeaderLimits.
+var ErrHeadersTooLarge = errors.New("response headers too large")
+
+// SetHeaderLimits limits the response headers to maxBytes bytes and
+// maxCount fields. Zero disables either limit.
+//
+// The limits apply to each response and to the headers of all responses in
+// a redirect chain together, so a server cannot exhaust memory with a long
+// chain of redirects carrying large headers. Exceeding a limit fails the
+// download with ErrHeadersTooLarge, and it is not retried.
+func (b *Builder) SetHeaderLimits(maxBytes int64, maxCount int) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if maxBytes < 0 || maxCount < 0 {
+		b.err = fmt.Errorf("invalid header limits: %d bytes, %d fields", maxBytes, maxCount)
+		return b
+	}
+	b.maxHeaderBytes = maxBytes
+	b.maxHeaderCount = maxCount
+	return b
+}
+
+// GetHeaderLimits returns the maximum response header size and field count.
+func (b *Builder) GetHeaderLimits() (int64, int) {
+	return b.maxHeaderBytes, b.maxHeaderCount
+}
+
+func (b *Builder) hasHeaderLimits() bool {
+	return b.maxHeaderBytes > 0 || b.maxHeaderCount > 0
+}
+
+// checkHeaderLimits checks the headers of resp and of the redirect
+// responses that led to it against the limits.
+func (b *Builder) checkHeaderLimits(resp *http.Response) error {
+	var size int64
+	var count int
+	for r := resp; r != nil; {
+		for name, values := range r.Header {
+			for _, value := range values {
+				size += int64(len(name) + len(value) + len(": \r\n"))
+				count++
+			}
+		}
+		if r.Request == nil {
+			break
+		}
+		r = r.Request.Response
+	}
+
+	if b.maxHeaderBytes > 0 && size > b.maxHeaderBytes {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d", ErrHeadersTooLarge, size, b.maxHeaderBytes)
+	}
+	if b.maxHeaderCount > 0 && count > b.maxHeaderCount {
+		return fmt.Errorf("%w: %d fields exceeds the limit of %d", ErrHeadersTooLarge, count, b.maxHeaderCount)
+	}
+	return nil
+}
+
+// isHeaderLimitError reports whether err is the transport rejecting a
+// response whose headers exceed MaxResponseHeaderBytes. The transport does
+// not export a sentinel for it.
+func isHeaderLimitError(err error) bool {
+	return strings.Contains(err.Error(), "server response headers exceeded")
+}
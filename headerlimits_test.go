@@ -0,0 +1,91 @@
+package retrieve_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetHeaderLimits(t *testing.T) {
+	size, count := retrieve.New("http://example.com").SetHeaderLimits(8<<10, 50).GetHeaderLimits()
+	assert.Equal(t, int64(8<<10), size)
+	assert.Equal(t, 50, count)
+
+	err := retrieve.New("http://example.com").SetHeaderLimits(-1, 0).Exec()
+	assert.Error(t, err)
+}
+
+func TestHeaderLimitsAllowSmallHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	data, err := retrieve.New(server.URL).SetHeaderLimits(4<<10, 20).ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(data))
+}
+
+func TestHeaderLimitsSize(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("X-Padding", strings.Repeat("a", 64<<10))
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out")).
+		SetHeaderLimits(4<<10, 0).
+		SetRetries(3).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrHeadersTooLarge)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestHeaderLimitsCount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := range 30 {
+			w.Header().Set(fmt.Sprintf("X-Field-%d", i), "v")
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	_, err := retrieve.New(server.URL).SetHeaderLimits(0, 20).ExecBytes()
+	assert.ErrorIs(t, err, retrieve.ErrHeadersTooLarge)
+}
+
+func TestHeaderLimitsRedirectChain(t *testing.T) {
+	var final atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/hop/%d", &n)
+		if n >= 5 {
+			final.Store(true)
+			w.Write([]byte("ok"))
+			return
+		}
+		w.Header().Set("X-Padding", strings.Repeat("a", 1<<10))
+		http.Redirect(w, r, fmt.Sprintf("/hop/%d", n+1), http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Each response is under the limit, but the chain together is not.
+	_, err := retrieve.New(server.URL+"/hop/0").SetHeaderLimits(3<<10, 0).ExecBytes()
+	assert.ErrorIs(t, err, retrieve.ErrHeadersTooLarge)
+	assert.False(t, final.Load())
+}
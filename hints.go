@@ -0,0 +1,219 @@
+package retrieve
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// hintRels are the link relations reported as hints.
+var hintRels = []string{"preload", "modulepreload", "prefetch", "preconnect", "dns-prefetch"}
+
+// Hint is a resource hint, from a Link header of a 103 Early Hints
+// response or of the final response, such as
+//
+//	Link: </style.css>; rel=preload; as=style
+type Hint struct {
+	// URL is the absolute URL of the resource, or of the origin for
+	// preconnect and dns-prefetch.
+	URL string
+
+	// Rel is the link relation: preload, modulepreload, prefetch,
+	// preconnect or dns-prefetch.
+	Rel string
+
+	// As is the destination of a preload, such as "style" or "script",
+	// if given.
+	As string
+
+	// Early reports whether the hint came in 103 Early Hints, before the
+	// final response.
+	Early bool
+}
+
+// OnEarlyHints registers a callback invoked with the hints of each 103
+// Early Hints response, as soon as it arrives and while the server is
+// still preparing the final response, so the resources can be fetched in
+// parallel. It is called from the transport's goroutine.
+//
+// See Batch.FollowHints to download preloaded resources along with the
+// other items of a Batch.
+func (b *Builder) OnEarlyHints(fn func([]Hint)) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.onEarlyHints = fn
+	return b
+}
+
+// GetHints returns the resource hints received by the most recent call to
+// Exec, from 103 Early Hints responses first, without duplicates.
+func (b *Builder) GetHints() []Hint {
+	return b.hints
+}
+
+// traceEarlyHints returns req with a trace collecting the hints of 103
+// Early Hints responses.
+func (b *Builder) traceEarlyHints(req *http.Request) *http.Request {
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code != http.StatusEarlyHints {
+				return nil
+			}
+			hints := parseHints(header.Values("Link"), req.URL, true)
+			b.addHints(hints)
+			if b.onEarlyHints != nil && len(hints) > 0 {
+				b.onEarlyHints(hints)
+			}
+			return nil
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// addHints adds the hints not received yet.
+func (b *Builder) addHints(hints []Hint) {
+	for _, hint := range hints {
+		if !slices.ContainsFunc(b.hints, func(h Hint) bool { return h.URL == hint.URL && h.Rel == hint.Rel }) {
+			b.hints = append(b.hints, hint)
+		}
+	}
+}
+
+// parseHints returns the resource hints of the Link header values, with
+// their URLs resolved against base.
+func parseHints(values []string, base *url.URL, early bool) []Hint {
+	var hints []Hint
+	for _, value := range values {
+		for _, link := range parseLinks(value) {
+			u, err := base.Parse(link.target)
+			if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+				continue
+			}
+			for _, rel := range strings.Fields(strings.ToLower(link.params["rel"])) {
+				if !slices.Contains(hintRels, rel) {
+					continue
+				}
+				hint := Hint{URL: u.String(), Rel: rel, As: link.params["as"], Early: early}
+				if rel == "preconnect" || rel == "dns-prefetch" {
+					hint.URL = u.Scheme + "://" + u.Host
+				}
+				hints = append(hints, hint)
+			}
+		}
+	}
+	return hints
+}
+
+type link struct {
+	target string
+	params map[string]string
+}
+
+// parseLinks parses a Link header value as defined by RFC 8288. Parameter
+// names are lowercased and only their first occurrence is kept.
+func parseLinks(value string) []link {
+	var links []link
+	for {
+		value = strings.TrimLeft(value, " \t,")
+		if !strings.HasPrefix(value, "<") {
+			return links
+		}
+		end := strings.IndexByte(value, '>')
+		if end < 0 {
+			return links
+		}
+		l := link{target: value[1:end], params: make(map[string]string)}
+		value = value[end+1:]
+
+		for {
+			value = strings.TrimLeft(value, " \t")
+			if !strings.HasPrefix(value, ";") {
+				break
+			}
+			value = strings.TrimLeft(value[1:], " \t")
+			i := strings.IndexAny(value, "=;,")
+			if i < 0 {
+				i = len(value)
+			}
+			name := strings.ToLower(strings.TrimSpace(value[:i]))
+			value = value[i:]
+			var param string
+			if strings.HasPrefix(value, "=") {
+				param, value = parseParamValue(strings.TrimLeft(value[1:], " \t"))
+			}
+			if _, ok := l.params[name]; !ok && name != "" {
+				l.params[name] = param
+			}
+		}
+		links = append(links, l)
+	}
+}
+
+// parseParamValue parses a token or quoted string at the start of s and
+// returns it and the rest of s.
+func parseParamValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		i := strings.IndexAny(s, ";,")
+		if i < 0 {
+			i = len(s)
+		}
+		return strings.TrimSpace(s[:i]), s[i:]
+	}
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i])
+			}
+		case '"':
+			return sb.String(), s[i+1:]
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), ""
+}
+
+// FollowHints makes Exec also download the resources that the items of
+// the batch preload, from their 103 Early Hints or Link headers, once
+// those items are done. fn returns the Builder to download a hinted
+// resource with, such as one with the parent's output directory and
+// headers, or nil to skip it. A URL already in the batch is not added
+// again. The added items, and their results, follow the others.
+func (b *Batch) FollowHints(fn func(parent *Builder, hint Hint) *Builder) *Batch {
+	b.followHints = fn
+	return b
+}
+
+// queueHints adds Builders for the preloaded resources of the items at the
+// given indices and returns the indices of those added.
+func (b *Batch) queueHints(items []int) []int {
+	queued := make(map[string]bool, len(b.builders))
+	for _, builder := range b.builders {
+		queued[builder.url] = true
+	}
+	var added []int
+	for _, i := range items {
+		parent := b.builders[i]
+		if b.results[i].Err != nil {
+			continue
+		}
+		for _, hint := range parent.GetHints() {
+			if hint.Rel != "preload" && hint.Rel != "modulepreload" || queued[hint.URL] {
+				continue
+			}
+			queued[hint.URL] = true
+			if builder := b.followHints(parent, hint); builder != nil {
+				added = append(added, len(b.builders))
+				b.builders = append(b.builders, builder)
+			}
+		}
+	}
+	return added
+}
@@ -0,0 +1,101 @@
+package retrieve_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnEarlyHints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `</style.css>; rel=preload; as=style, <https://cdn.example.test/lib/app.js>; rel="modulepreload preload"`)
+		w.Header().Add("Link", `<https://fonts.example.test/css?a=1,2>; rel=preconnect`)
+		w.WriteHeader(http.StatusEarlyHints)
+
+		w.Header().Set("Link", `</style.css>; rel=preload; as=style, </next.html>; rel=prefetch, </index.rss>; rel=alternate`)
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	var early [][]retrieve.Hint
+	b := retrieve.New(server.URL + "/index.html").OnEarlyHints(func(hints []retrieve.Hint) {
+		early = append(early, hints)
+	})
+	data, err := b.ExecString()
+	assert.NoError(t, err)
+	assert.Equal(t, "<html></html>", data)
+
+	expected := []retrieve.Hint{
+		{URL: server.URL + "/style.css", Rel: "preload", As: "style", Early: true},
+		{URL: "https://cdn.example.test/lib/app.js", Rel: "modulepreload", Early: true},
+		{URL: "https://cdn.example.test/lib/app.js", Rel: "preload", Early: true},
+		{URL: "https://fonts.example.test", Rel: "preconnect", Early: true},
+	}
+	assert.Equal(t, [][]retrieve.Hint{expected}, early)
+	assert.Equal(t, append(expected, retrieve.Hint{URL: server.URL + "/next.html", Rel: "prefetch"}), b.GetHints())
+}
+
+func TestGetHints_LinkHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `<img/logo.png>;rel="preload";as="image";title="a \"quoted\"; title", <ftp://example.test/file>; rel=preload`)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	called := false
+	b := retrieve.New(server.URL + "/docs/").OnEarlyHints(func([]retrieve.Hint) { called = true })
+	_, err := b.ExecString()
+	assert.NoError(t, err)
+	assert.False(t, called)
+	assert.Equal(t, []retrieve.Hint{{URL: server.URL + "/docs/img/logo.png", Rel: "preload", As: "image"}}, b.GetHints())
+}
+
+func TestBatch_FollowHints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.html":
+			w.Header().Set("Link", `</app.js>; rel=preload; as=script, </style.css>; rel=preload; as=style, </skip.png>; rel=preload`)
+			w.WriteHeader(http.StatusEarlyHints)
+			w.Write([]byte("index"))
+		case "/app.js":
+			w.Header().Set("Link", `</chunk.js>; rel=modulepreload, </index.html>; rel=preload`)
+			w.Write([]byte("app"))
+		case "/style.css", "/chunk.js":
+			w.Write([]byte(r.URL.Path))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var parents []string
+	results := retrieve.NewBatch().
+		Add(retrieve.New(server.URL + "/index.html").SetWriter(io.Discard)).
+		Add(retrieve.New(server.URL + "/style.css").SetWriter(io.Discard)).
+		FollowHints(func(parent *retrieve.Builder, hint retrieve.Hint) *retrieve.Builder {
+			if hint.URL == server.URL+"/skip.png" {
+				return nil
+			}
+			parents = append(parents, parent.GetUrl())
+			return retrieve.New(hint.URL).SetWriter(io.Discard)
+		}).
+		Exec()
+
+	var urls []string
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+		urls = append(urls, result.Builder.GetUrl())
+	}
+	assert.Equal(t, []string{
+		server.URL + "/index.html",
+		server.URL + "/style.css",
+		server.URL + "/app.js",
+		server.URL + "/chunk.js",
+	}, urls)
+	assert.Equal(t, []string{server.URL + "/index.html", server.URL + "/app.js"}, parents)
+}
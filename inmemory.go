@@ -0,0 +1,64 @@
+package retrieve
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrTooLarge is returned when a response is larger than allowed.
+var ErrTooLarge = errors.New("response too large")
+
+// defaultMaxBytes caps the size of a response read into memory.
+const defaultMaxBytes = 10 << 20
+
+// ExecBytes executes the request and returns the response body instead of
+// writing it to a file. Responses larger than 10 MiB, or the size set with
+// SetMaxSize, fail with ErrTooLarge.
+func (b *Builder) ExecBytes() ([]byte, error) {
+	buf := &limitedBuffer{max: defaultMaxBytes}
+	if b.maxSize > 0 {
+		buf.max = b.maxSize
+	}
+
+	writer := b.writer
+	b.writer = buf
+	defer func() { b.writer = writer }()
+
+	if err := b.Exec(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExecString executes the request and returns the response body as a string.
+// See ExecBytes.
+func (b *Builder) ExecString() (string, error) {
+	data, err := b.ExecBytes()
+	return string(data), err
+}
+
+// limitedBuffer is a bytes.Buffer that refuses to grow beyond max bytes.
+type limitedBuffer struct {
+	bytes.Buffer
+	max    int64
+	memory *memoryBudget
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if int64(lb.Len()+len(p)) > lb.max {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, lb.max)
+	}
+	if err := lb.memory.reserve(int64(len(p)), "response body"); err != nil {
+		return 0, err
+	}
+	return lb.Buffer.Write(p)
+}
+
+// checkSize fails early if the declared size of the response exceeds max.
+func (lb *limitedBuffer) checkSize(contentLength int64) error {
+	if contentLength > lb.max {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d", ErrTooLarge, contentLength, lb.max)
+	}
+	return nil
+}
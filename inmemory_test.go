@@ -0,0 +1,59 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("in memory"))
+	}))
+	defer server.Close()
+
+	data, err := retrieve.New(server.URL).ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("in memory"), data)
+
+	s, err := retrieve.New(server.URL).ExecString()
+	assert.NoError(t, err)
+	assert.Equal(t, "in memory", s)
+}
+
+func TestExecBytes_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := retrieve.New(server.URL).ExecBytes()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+}
+
+func TestExecBytes_TooLarge(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 11<<20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("chunked") {
+			w.Write(large[:1])
+			w.(http.Flusher).Flush()
+			w.Write(large[1:])
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(large)))
+		w.Write(large)
+	}))
+	defer server.Close()
+
+	for _, url := range []string{server.URL, server.URL + "?chunked"} {
+		_, err := retrieve.New(url).ExecBytes()
+		assert.ErrorIs(t, err, retrieve.ErrTooLarge, url)
+	}
+}
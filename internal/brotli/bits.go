@@ -0,0 +1,62 @@
+package brotli
+
+import (
+	"io"
+)
+
+// bitReader reads the least-significant-bit-first stream of RFC 7932.
+// Bits past the end of the input read as zero; overrun reports whether any
+// of them were consumed.
+type bitReader struct {
+	r    io.ByteReader
+	bits uint64
+	n    uint // valid bits in bits
+	pad  uint // zero bits appended after the end of the input
+	err  error
+}
+
+// fill makes at least need (<= 32) bits available.
+func (br *bitReader) fill(need uint) {
+	for br.n < need {
+		b, err := br.r.ReadByte()
+		if err != nil {
+			if err != io.EOF && br.err == nil {
+				br.err = err
+			}
+			br.pad += 8
+		}
+		br.bits |= uint64(b) << br.n
+		br.n += 8
+	}
+}
+
+// read consumes and returns the next n (<= 32) bits.
+func (br *bitReader) read(n uint) uint32 {
+	br.fill(n)
+	v := uint32(br.bits & (1<<n - 1))
+	br.bits >>= n
+	br.n -= n
+	return v
+}
+
+// align skips to the next byte boundary and reports whether the skipped
+// bits were all zero, as the format requires.
+func (br *bitReader) align() bool {
+	return br.read(br.n%8) == 0
+}
+
+// overrun reports whether more bits were consumed than the input held.
+func (br *bitReader) overrun() bool {
+	return br.pad > br.n
+}
+
+// check returns the error to report once the input has been overrun.
+func (br *bitReader) check() error {
+	if br.err != nil {
+		return br.err
+	}
+	if br.overrun() {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
@@ -0,0 +1,579 @@
+// Package brotli implements a decoder for the Brotli compressed data format
+// (RFC 7932), for the Content-Encoding "br".
+//
+// The static dictionary and transform tables are those of RFC 7932.
+package brotli
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// MaxWindowSize is the largest sliding window a stream may use.
+const MaxWindowSize = 1<<24 - 16
+
+// chunkSize is roughly how much output is decoded per refill.
+const chunkSize = 64 << 10
+
+// maxEmptyCommands bounds the commands in a row that produce no output,
+// which a crafted stream could otherwise repeat forever without consuming
+// input.
+const maxEmptyCommands = 1 << 16
+
+var errCorrupt = errors.New("brotli: corrupt input")
+
+// Reader decompresses a Brotli stream.
+type Reader struct {
+	br      bitReader
+	started bool
+	last    bool // the current meta-block is the last one
+	window  int
+	err     error
+
+	hist []byte // recent output, at least window bytes when available
+	out  int    // index in hist of the first byte not yet returned
+	pos  int64  // total bytes decoded
+
+	dist    [4]int // last distances, dist[distIdx&3] the most recent
+	distIdx int
+
+	rawLeft int        // bytes left in an uncompressed meta-block
+	mb      *metaBlock // the compressed meta-block being decoded
+}
+
+// NewReader returns a Reader that decompresses r.
+func NewReader(r io.Reader) *Reader {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &Reader{
+		br:      bitReader{r: br},
+		dist:    [4]int{16, 15, 11, 4},
+		distIdx: 3,
+	}
+}
+
+func (z *Reader) Read(p []byte) (int, error) {
+	for z.out == len(z.hist) {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.decode()
+		if z.err != nil && z.err != io.EOF && z.br.overrun() {
+			// Errors found in the zero bits read past the end of a
+			// truncated stream are reported as truncation.
+			z.err = z.br.check()
+		}
+	}
+	n := copy(p, z.hist[z.out:])
+	z.out += n
+	return n, nil
+}
+
+// decode decodes more output. Callers must have read all earlier output.
+func (z *Reader) decode() error {
+	if cut := len(z.hist) - z.window; cut > z.window {
+		z.hist = append(z.hist[:0], z.hist[cut:]...)
+		z.out = len(z.hist)
+	}
+	if !z.started {
+		if err := z.readWindowBits(); err != nil {
+			return err
+		}
+		z.started = true
+	}
+
+	for {
+		switch {
+		case z.rawLeft > 0:
+			n := min(z.rawLeft, chunkSize)
+			for range n {
+				z.hist = append(z.hist, byte(z.br.read(8)))
+			}
+			z.rawLeft -= n
+			z.pos += int64(n)
+			return z.br.check()
+		case z.mb != nil:
+			return z.commands()
+		case z.last:
+			if !z.br.align() {
+				return errCorrupt
+			}
+			if err := z.br.check(); err != nil {
+				return err
+			}
+			return io.EOF
+		}
+		if err := z.readMetaBlockHeader(); err != nil {
+			return err
+		}
+	}
+}
+
+// readWindowBits reads the stream header (section 9.1).
+func (z *Reader) readWindowBits() error {
+	wbits := uint(16)
+	if z.br.read(1) == 1 {
+		if n := z.br.read(3); n != 0 {
+			wbits = 17 + uint(n)
+		} else {
+			switch n := z.br.read(3); n {
+			case 0:
+				wbits = 17
+			case 1:
+				return errors.New("brotli: large window streams are not supported")
+			default:
+				wbits = 8 + uint(n)
+			}
+		}
+	}
+	z.window = 1<<wbits - 16
+	return z.br.check()
+}
+
+// readMetaBlockHeader reads the header of the next meta-block (section 9.2)
+// and prepares to decode its data.
+func (z *Reader) readMetaBlockHeader() error {
+	br := &z.br
+	z.last = br.read(1) == 1
+	if z.last && br.read(1) == 1 {
+		return br.check() // ISLASTEMPTY
+	}
+
+	nibbles := br.read(2) + 4
+	if nibbles == 7 {
+		// Metadata, which is skipped.
+		if br.read(1) != 0 {
+			return errCorrupt
+		}
+		skipBytes := br.read(2)
+		skip := 0
+		for i := range skipBytes {
+			b := br.read(8)
+			if i > 0 && i == skipBytes-1 && b == 0 {
+				return errCorrupt
+			}
+			skip |= int(b) << (8 * i)
+		}
+		if skipBytes > 0 {
+			skip++
+		}
+		if !br.align() {
+			return errCorrupt
+		}
+		for range skip {
+			br.read(8)
+		}
+		return br.check()
+	}
+
+	mlen := 0
+	for i := range nibbles {
+		nib := br.read(4)
+		if i > 3 && i == nibbles-1 && nib == 0 {
+			return errCorrupt
+		}
+		mlen |= int(nib) << (4 * i)
+	}
+	mlen++
+
+	if !z.last && br.read(1) == 1 {
+		if !br.align() {
+			return errCorrupt
+		}
+		z.rawLeft = mlen
+		return br.check()
+	}
+
+	mb, err := z.readCompressedHeader()
+	if err != nil {
+		return err
+	}
+	mb.remaining = mlen
+	z.mb = mb
+	return br.check()
+}
+
+// blockCategory tracks the block types and counts of literals, commands or
+// distances (section 6).
+type blockCategory struct {
+	types     int
+	typeCode  *huffman
+	countCode *huffman
+	cur, prev int
+	left      int // symbols left in the current block
+}
+
+// metaBlock holds the decoding state of a compressed meta-block.
+type metaBlock struct {
+	remaining int
+	cats      [3]blockCategory // literals, commands, distances
+
+	npostfix uint
+	ndirect  int
+
+	modes   []uint8 // context mode per literal block type
+	litMap  []uint8 // 64 entries per literal block type
+	distMap []uint8 // 4 entries per distance block type
+
+	lit  []*huffman
+	cmd  []*huffman
+	dist []*huffman
+}
+
+const (
+	catLiteral = iota
+	catCommand
+	catDistance
+)
+
+func (z *Reader) readCompressedHeader() (*metaBlock, error) {
+	br := &z.br
+	mb := &metaBlock{}
+	for i := range mb.cats {
+		c := &mb.cats[i]
+		c.types = z.readVarLenUint8() + 1
+		c.cur, c.prev = 0, 1
+		c.left = 1 << 28
+		if c.types >= 2 {
+			var err error
+			if c.typeCode, err = br.readPrefixCode(c.types + 2); err != nil {
+				return nil, err
+			}
+			if c.countCode, err = br.readPrefixCode(len(blockCountBase)); err != nil {
+				return nil, err
+			}
+			c.left = z.readBlockCount(c)
+		}
+		if br.overrun() {
+			return nil, br.check()
+		}
+	}
+
+	mb.npostfix = uint(br.read(2))
+	mb.ndirect = int(br.read(4)) << mb.npostfix
+	mb.modes = make([]uint8, mb.cats[catLiteral].types)
+	for i := range mb.modes {
+		mb.modes[i] = uint8(br.read(2))
+	}
+
+	var err error
+	litTrees := z.readVarLenUint8() + 1
+	if mb.litMap, err = z.readContextMap(64*mb.cats[catLiteral].types, litTrees); err != nil {
+		return nil, err
+	}
+	distTrees := z.readVarLenUint8() + 1
+	if mb.distMap, err = z.readContextMap(4*mb.cats[catDistance].types, distTrees); err != nil {
+		return nil, err
+	}
+
+	if mb.lit, err = z.readPrefixCodes(litTrees, 256); err != nil {
+		return nil, err
+	}
+	if mb.cmd, err = z.readPrefixCodes(mb.cats[catCommand].types, 704); err != nil {
+		return nil, err
+	}
+	distAlphabet := 16 + mb.ndirect + 48<<mb.npostfix
+	if mb.dist, err = z.readPrefixCodes(distTrees, distAlphabet); err != nil {
+		return nil, err
+	}
+	return mb, nil
+}
+
+func (z *Reader) readPrefixCodes(n, alphabetSize int) ([]*huffman, error) {
+	codes := make([]*huffman, n)
+	for i := range codes {
+		h, err := z.br.readPrefixCode(alphabetSize)
+		if err != nil {
+			return nil, err
+		}
+		if z.br.overrun() {
+			return nil, z.br.check()
+		}
+		codes[i] = h
+	}
+	return codes, nil
+}
+
+// readVarLenUint8 reads a value in [0, 255] (section 9.2).
+func (z *Reader) readVarLenUint8() int {
+	if z.br.read(1) == 0 {
+		return 0
+	}
+	n := uint(z.br.read(3))
+	if n == 0 {
+		return 1
+	}
+	return 1<<n + int(z.br.read(n))
+}
+
+// readContextMap reads a context map of size entries referring to trees
+// prefix codes (section 7.3).
+func (z *Reader) readContextMap(size, trees int) ([]uint8, error) {
+	br := &z.br
+	m := make([]uint8, size)
+	if trees < 2 {
+		return m, nil
+	}
+	rleMax := 0
+	if br.read(1) == 1 {
+		rleMax = int(br.read(4)) + 1
+	}
+	h, err := br.readPrefixCode(trees + rleMax)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < size; {
+		if br.overrun() {
+			return nil, br.check()
+		}
+		switch sym := br.decode(h); {
+		case sym == 0:
+			i++
+		case sym <= rleMax:
+			i += 1<<sym + int(br.read(uint(sym)))
+			if i > size {
+				return nil, errCorrupt
+			}
+		default:
+			m[i] = uint8(sym - rleMax)
+			i++
+		}
+	}
+	if br.read(1) == 1 {
+		// Inverse move-to-front transform.
+		var mtf [256]uint8
+		for i := range mtf {
+			mtf[i] = uint8(i)
+		}
+		for i, idx := range m {
+			v := mtf[idx]
+			m[i] = v
+			copy(mtf[1:idx+1], mtf[:idx])
+			mtf[0] = v
+		}
+	}
+	return m, nil
+}
+
+// Block count codes: base values and extra bits (section 6).
+var (
+	blockCountBase = [26]int{
+		1, 5, 9, 13, 17, 25, 33, 41, 49, 65, 81, 97, 113, 145, 177, 209,
+		241, 305, 369, 497, 753, 1265, 2289, 4337, 8433, 16625,
+	}
+	blockCountExtra = [26]uint{
+		2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5,
+		6, 6, 7, 8, 9, 10, 11, 12, 13, 24,
+	}
+)
+
+func (z *Reader) readBlockCount(c *blockCategory) int {
+	sym := z.br.decode(c.countCode)
+	return blockCountBase[sym] + int(z.br.read(blockCountExtra[sym]))
+}
+
+// nextBlock switches c to the block type read from the stream.
+func (z *Reader) nextBlock(c *blockCategory) {
+	var t int
+	switch sym := z.br.decode(c.typeCode); sym {
+	case 0:
+		t = c.prev
+	case 1:
+		t = c.cur + 1
+	default:
+		t = sym - 2
+	}
+	if t >= c.types {
+		t -= c.types
+	}
+	c.prev, c.cur = c.cur, t
+	c.left = z.readBlockCount(c)
+}
+
+// Insert and copy length codes: base values and extra bits (section 5).
+var (
+	insertBase = [24]int{
+		0, 1, 2, 3, 4, 5, 6, 8, 10, 14, 18, 26, 34, 50, 66, 98,
+		130, 194, 322, 578, 1090, 2114, 6210, 22594,
+	}
+	insertExtra = [24]uint{
+		0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5,
+		6, 7, 8, 9, 10, 12, 14, 24,
+	}
+	copyBase = [24]int{
+		2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 14, 18, 22, 30, 38, 54,
+		70, 102, 134, 198, 326, 582, 1094, 2118,
+	}
+	copyExtra = [24]uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4,
+		5, 5, 6, 7, 8, 9, 10, 24,
+	}
+
+	// The insert and copy length codes of each group of 64 commands.
+	insertCodeOffset = [11]int{0, 0, 0, 0, 8, 8, 0, 16, 8, 16, 16}
+	copyCodeOffset   = [11]int{0, 8, 0, 8, 0, 8, 16, 0, 16, 8, 16}
+)
+
+// commands decodes commands of the current meta-block until it ends or a
+// chunk of output is ready.
+func (z *Reader) commands() error {
+	br, mb := &z.br, z.mb
+	start := len(z.hist)
+	empty := 0
+	for mb.remaining > 0 && len(z.hist)-start < chunkSize {
+		if br.overrun() {
+			return br.check()
+		}
+		before := len(z.hist)
+
+		cmds := &mb.cats[catCommand]
+		if cmds.left == 0 {
+			z.nextBlock(cmds)
+		}
+		cmds.left--
+		cmd := br.decode(mb.cmd[cmds.cur])
+		cell := cmd >> 6
+		insCode := insertCodeOffset[cell] + cmd>>3&7
+		copyCode := copyCodeOffset[cell] + cmd&7
+		insertLen := insertBase[insCode] + int(br.read(insertExtra[insCode]))
+		copyLen := copyBase[copyCode] + int(br.read(copyExtra[copyCode]))
+
+		if insertLen > mb.remaining {
+			return errCorrupt
+		}
+		lits := &mb.cats[catLiteral]
+		for range insertLen {
+			if lits.left == 0 {
+				z.nextBlock(lits)
+			}
+			lits.left--
+			ctx := literalContext(mb.modes[lits.cur], z.back(1), z.back(2))
+			tree := mb.lit[mb.litMap[lits.cur<<6+ctx]]
+			z.hist = append(z.hist, byte(br.decode(tree)))
+		}
+		mb.remaining -= insertLen
+		z.pos += int64(insertLen)
+		if mb.remaining == 0 {
+			break
+		}
+
+		distance, code := z.dist[z.distIdx&3], 0
+		if cell >= 2 {
+			dists := &mb.cats[catDistance]
+			if dists.left == 0 {
+				z.nextBlock(dists)
+			}
+			dists.left--
+			ctx := min(copyLen, 5) - 2
+			code = br.decode(mb.dist[mb.distMap[dists.cur<<2+ctx]])
+			var err error
+			if distance, err = z.distance(code); err != nil {
+				return err
+			}
+		}
+
+		if maxDistance := min(int64(z.window), z.pos); int64(distance) > maxDistance {
+			if err := z.dictionaryWord(distance-int(maxDistance)-1, copyLen); err != nil {
+				return err
+			}
+		} else {
+			if copyLen > mb.remaining {
+				return errCorrupt
+			}
+			if code != 0 {
+				z.distIdx++
+				z.dist[z.distIdx&3] = distance
+			}
+			from := len(z.hist) - distance
+			if distance >= copyLen {
+				z.hist = append(z.hist, z.hist[from:from+copyLen]...)
+			} else {
+				for i := range copyLen {
+					z.hist = append(z.hist, z.hist[from+i])
+				}
+			}
+			mb.remaining -= copyLen
+			z.pos += int64(copyLen)
+		}
+
+		if len(z.hist) == before {
+			if empty++; empty > maxEmptyCommands {
+				return errCorrupt
+			}
+		} else {
+			empty = 0
+		}
+	}
+	if mb.remaining == 0 {
+		z.mb = nil
+	}
+	return br.check()
+}
+
+// back returns the byte i positions before the end of the output, or zero.
+func (z *Reader) back(i int) byte {
+	if n := len(z.hist) - i; n >= 0 {
+		return z.hist[n]
+	}
+	return 0
+}
+
+// distance decodes a distance from its code (section 4).
+func (z *Reader) distance(code int) (int, error) {
+	mb := z.mb
+	var d int
+	switch {
+	case code < 16:
+		last := z.dist[(z.distIdx-distanceRing[code])&3]
+		d = last + distanceDelta[code]
+	case code < 16+mb.ndirect:
+		d = code - 15
+	default:
+		code -= 16 + mb.ndirect
+		ndistbits := 1 + uint(code)>>(mb.npostfix+1)
+		hcode := code >> mb.npostfix
+		lcode := code & (1<<mb.npostfix - 1)
+		offset := (2+hcode&1)<<ndistbits - 4
+		d = (offset+int(z.br.read(ndistbits)))<<mb.npostfix + lcode + mb.ndirect + 1
+	}
+	if d <= 0 {
+		return 0, errCorrupt
+	}
+	return d, nil
+}
+
+// distanceRing and distanceDelta describe the 16 codes that refer to the
+// last distances: which one, counting back from the most recent, and what
+// to add to it.
+var (
+	distanceRing  = [16]int{0, 1, 2, 3, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1}
+	distanceDelta = [16]int{0, 0, 0, 0, -1, 1, -2, 2, -3, 3, -1, 1, -2, 2, -3, 3}
+)
+
+// dictionaryWord appends a transformed static dictionary word (section 8).
+func (z *Reader) dictionaryWord(wordID, length int) error {
+	if length < minWordLength || length > maxWordLength {
+		return errCorrupt
+	}
+	n := wordBits[length]
+	idx := wordID & (1<<n - 1)
+	t := wordID >> n
+	if t >= len(transforms) {
+		return errCorrupt
+	}
+	offset := wordOffset[length] + idx*length
+	word := dictionary()[offset : offset+length]
+
+	before := len(z.hist)
+	z.hist = transformWord(z.hist, word, transforms[t])
+	n2 := len(z.hist) - before
+	if n2 > z.mb.remaining {
+		return errCorrupt
+	}
+	z.mb.remaining -= n2
+	z.pos += int64(n2)
+	return nil
+}
@@ -0,0 +1,85 @@
+package brotli_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve/internal/brotli"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Fixtures were made with a conforming encoder at several qualities and
+// window sizes. Outputs are identified by length and SHA-256.
+var fixtures = []struct {
+	name   string
+	length int
+	sha256 string
+}{
+	{"empty.br", 0, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	{"hello.br", 13, "853ff93762a06ddbf722c4ebe9ddd66d8f63ddaea97f521c3ecc20da7c976020"},
+	{"random.br", 8192, "d90a5a9e01e537bb02359ce3937f63c80825f1e593eb5f79c09f7bed34f988ac"},
+	{"rle.br", 204800, "4b4f0f46ac02d177dea0ab36a66a657840e2fb98b20bb27a688db4d8ea9cd22c"},
+	{"text.br", 163840, "2100022f1f8650170c92a3fed3e7c220b3f0a2a324dc8710782824f1b6557c58"},
+	{"text-small-window.br", 163840, "2100022f1f8650170c92a3fed3e7c220b3f0a2a324dc8710782824f1b6557c58"},
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestReader(t *testing.T) {
+	for _, f := range fixtures {
+		t.Run(f.name, func(t *testing.T) {
+			got, err := io.ReadAll(brotli.NewReader(bytes.NewReader(readFixture(t, f.name))))
+			assert.NoError(t, err)
+			assert.Len(t, got, f.length)
+			sum := sha256.Sum256(got)
+			assert.Equal(t, f.sha256, hex.EncodeToString(sum[:]))
+		})
+	}
+}
+
+func TestReaderSmallReads(t *testing.T) {
+	r := brotli.NewReader(bytes.NewReader(readFixture(t, "hello.br")))
+	var got []byte
+	buf := make([]byte, 3)
+	for {
+		n, err := r.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "hello, world\n", string(got))
+}
+
+func TestReaderTruncated(t *testing.T) {
+	data := readFixture(t, "text.br")
+	for _, n := range []int{0, 1, 10, len(data) / 2, len(data) - 1} {
+		_, err := io.ReadAll(brotli.NewReader(bytes.NewReader(data[:n])))
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "truncated to %d bytes", n)
+	}
+}
+
+func TestReaderCorrupt(t *testing.T) {
+	data := readFixture(t, "text.br")
+	for i := 0; i < len(data); i += 89 {
+		corrupt := bytes.Clone(data)
+		corrupt[i] ^= 0x55
+		// Damaged input must fail cleanly or decode to something else,
+		// never panic or hang.
+		io.Copy(io.Discard, brotli.NewReader(bytes.NewReader(corrupt)))
+	}
+}
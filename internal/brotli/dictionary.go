@@ -0,0 +1,249 @@
+package brotli
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"io"
+	"sync"
+)
+
+// dictionaryData is the static dictionary of RFC 7932 appendix A,
+// gzip-compressed.
+//
+//go:embed dictionary.bin.gz
+var dictionaryData []byte
+
+const dictionarySize = 122784
+
+// dictionary returns the decompressed static dictionary.
+var dictionary = sync.OnceValue(func() []byte {
+	zr, err := gzip.NewReader(bytes.NewReader(dictionaryData))
+	if err != nil {
+		panic("brotli: corrupt embedded dictionary: " + err.Error())
+	}
+	d, err := io.ReadAll(zr)
+	if err != nil || len(d) != dictionarySize {
+		panic("brotli: corrupt embedded dictionary")
+	}
+	return d
+})
+
+const (
+	minWordLength = 4
+	maxWordLength = 24
+)
+
+// wordBits is the number of bits indexing the words of each length, and
+// wordOffset where those words start in the dictionary.
+var (
+	wordBits = [maxWordLength + 1]uint{
+		4: 10, 10, 11, 11, 10, 10, 10, 10, 10, 9, 9, 8, 7, 7, 8, 7, 7, 6, 6, 5, 5,
+	}
+	wordOffset = func() (o [maxWordLength + 1]int) {
+		for l := minWordLength; l < maxWordLength; l++ {
+			o[l+1] = o[l] + l<<wordBits[l]
+		}
+		return o
+	}()
+)
+
+// Transform types (section 8).
+const (
+	identity = iota
+	uppercaseFirst
+	uppercaseAll
+	omitFirst1
+	omitFirst2
+	omitFirst3
+	omitFirst4
+	omitFirst5
+	omitFirst6
+	omitFirst7
+	omitFirst8
+	omitFirst9
+	omitLast1
+	omitLast2
+	omitLast3
+	omitLast4
+	omitLast5
+	omitLast6
+	omitLast7
+	omitLast8
+	omitLast9
+)
+
+type transform struct {
+	prefix string
+	kind   int
+	suffix string
+}
+
+// transformWord appends word, transformed by transform t, to dst.
+func transformWord(dst, word []byte, t transform) []byte {
+	dst = append(dst, t.prefix...)
+	switch k := t.kind; {
+	case k >= omitFirst1 && k <= omitFirst9:
+		word = word[min(k-omitFirst1+1, len(word)):]
+	case k >= omitLast1 && k <= omitLast9:
+		word = word[:len(word)-min(k-omitLast1+1, len(word))]
+	}
+	start := len(dst)
+	dst = append(dst, word...)
+	switch t.kind {
+	case uppercaseFirst:
+		toUpper(dst[start:])
+	case uppercaseAll:
+		for w := dst[start:]; len(w) > 0; {
+			w = w[min(toUpper(w), len(w)):]
+		}
+	}
+	return append(dst, t.suffix...)
+}
+
+// toUpper upper-cases the UTF-8 sequence at the start of p in the way the
+// format defines, and returns the sequence length.
+func toUpper(p []byte) int {
+	switch {
+	case p[0] < 0xc0:
+		if p[0] >= 'a' && p[0] <= 'z' {
+			p[0] ^= 32
+		}
+		return 1
+	case p[0] < 0xe0:
+		if len(p) > 1 {
+			p[1] ^= 32
+		}
+		return 2
+	default:
+		if len(p) > 2 {
+			p[2] ^= 5
+		}
+		return 3
+	}
+}
+
+// transforms is the transform list of RFC 7932 appendix B.
+var transforms = [...]transform{
+	{"", identity, ""},
+	{"", identity, " "},
+	{" ", identity, " "},
+	{"", omitFirst1, ""},
+	{"", uppercaseFirst, " "},
+	{"", identity, " the "},
+	{" ", identity, ""},
+	{"s ", identity, " "},
+	{"", identity, " of "},
+	{"", uppercaseFirst, ""},
+	{"", identity, " and "},
+	{"", omitFirst2, ""},
+	{"", omitLast1, ""},
+	{", ", identity, " "},
+	{"", identity, ", "},
+	{" ", uppercaseFirst, " "},
+	{"", identity, " in "},
+	{"", identity, " to "},
+	{"e ", identity, " "},
+	{"", identity, "\""},
+	{"", identity, "."},
+	{"", identity, "\">"},
+	{"", identity, "\n"},
+	{"", omitLast3, ""},
+	{"", identity, "]"},
+	{"", identity, " for "},
+	{"", omitFirst3, ""},
+	{"", omitLast2, ""},
+	{"", identity, " a "},
+	{"", identity, " that "},
+	{" ", uppercaseFirst, ""},
+	{"", identity, ". "},
+	{".", identity, ""},
+	{" ", identity, ", "},
+	{"", omitFirst4, ""},
+	{"", identity, " with "},
+	{"", identity, "'"},
+	{"", identity, " from "},
+	{"", identity, " by "},
+	{"", omitFirst5, ""},
+	{"", omitFirst6, ""},
+	{" the ", identity, ""},
+	{"", omitLast4, ""},
+	{"", identity, ". The "},
+	{"", uppercaseAll, ""},
+	{"", identity, " on "},
+	{"", identity, " as "},
+	{"", identity, " is "},
+	{"", omitLast7, ""},
+	{"", omitLast1, "ing "},
+	{"", identity, "\n\t"},
+	{"", identity, ":"},
+	{" ", identity, ". "},
+	{"", identity, "ed "},
+	{"", omitFirst9, ""},
+	{"", omitFirst7, ""},
+	{"", omitLast6, ""},
+	{"", identity, "("},
+	{"", uppercaseFirst, ", "},
+	{"", omitLast8, ""},
+	{"", identity, " at "},
+	{"", identity, "ly "},
+	{" the ", identity, " of "},
+	{"", omitLast5, ""},
+	{"", omitLast9, ""},
+	{" ", uppercaseFirst, ", "},
+	{"", uppercaseFirst, "\""},
+	{".", identity, "("},
+	{"", uppercaseAll, " "},
+	{"", uppercaseFirst, "\">"},
+	{"", identity, "=\""},
+	{" ", identity, "."},
+	{".com/", identity, ""},
+	{" the ", identity, " of the "},
+	{"", uppercaseFirst, "'"},
+	{"", identity, ". This "},
+	{"", identity, ","},
+	{".", identity, " "},
+	{"", uppercaseFirst, "("},
+	{"", uppercaseFirst, "."},
+	{"", identity, " not "},
+	{" ", identity, "=\""},
+	{"", identity, "er "},
+	{" ", uppercaseAll, " "},
+	{"", identity, "al "},
+	{" ", uppercaseAll, ""},
+	{"", identity, "='"},
+	{"", uppercaseAll, "\""},
+	{"", uppercaseFirst, ". "},
+	{" ", identity, "("},
+	{"", identity, "ful "},
+	{" ", uppercaseFirst, ". "},
+	{"", identity, "ive "},
+	{"", identity, "less "},
+	{"", uppercaseAll, "'"},
+	{"", identity, "est "},
+	{" ", uppercaseFirst, "."},
+	{"", uppercaseAll, "\">"},
+	{" ", identity, "='"},
+	{"", uppercaseFirst, ","},
+	{"", identity, "ize "},
+	{"", uppercaseAll, "."},
+	{"\u00a0", identity, ""},
+	{" ", identity, ","},
+	{"", uppercaseFirst, "=\""},
+	{"", uppercaseAll, "=\""},
+	{"", identity, "ous "},
+	{"", uppercaseAll, ", "},
+	{"", uppercaseFirst, "='"},
+	{" ", uppercaseFirst, ","},
+	{" ", uppercaseAll, "=\""},
+	{" ", uppercaseAll, ", "},
+	{"", uppercaseAll, ","},
+	{"", uppercaseAll, "("},
+	{"", uppercaseAll, ". "},
+	{" ", uppercaseAll, "."},
+	{"", uppercaseAll, "='"},
+	{" ", uppercaseAll, ". "},
+	{" ", uppercaseFirst, "=\""},
+	{" ", uppercaseAll, "='"},
+	{" ", uppercaseFirst, "='"},
+}
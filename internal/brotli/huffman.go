@@ -0,0 +1,249 @@
+package brotli
+
+import "math/bits"
+
+const (
+	maxCodeLength = 15
+	rootBits      = 8
+)
+
+// huffman is a canonical prefix code decoding table. Codes up to root bits
+// long are resolved by one lookup; longer codes link to a second-level
+// table.
+type huffman struct {
+	root  uint
+	table []huffmanEntry
+}
+
+// huffmanEntry holds a symbol and its code length, or for a link the offset
+// of the second-level table and 0x80 | its index bits.
+type huffmanEntry struct {
+	sym  uint16
+	bits uint8
+}
+
+// newHuffman builds a decoding table from code lengths. The code must be
+// complete, except that a single symbol with any length decodes from zero
+// bits.
+func newHuffman(lengths []uint8) (*huffman, error) {
+	var count [maxCodeLength + 1]int
+	maxLen, used, single := 0, 0, 0
+	for sym, l := range lengths {
+		if l > 0 {
+			count[l]++
+			maxLen = max(maxLen, int(l))
+			used++
+			single = sym
+		}
+	}
+	if used == 0 {
+		return nil, errCorrupt
+	}
+	if used == 1 {
+		return &huffman{table: []huffmanEntry{{sym: uint16(single)}}}, nil
+	}
+
+	space := 1 << maxCodeLength
+	for l := 1; l <= maxLen; l++ {
+		space -= count[l] << (maxCodeLength - l)
+	}
+	if space != 0 {
+		return nil, errCorrupt
+	}
+
+	var next [maxCodeLength + 2]int
+	for l := 1; l <= maxLen; l++ {
+		next[l+1] = (next[l] + count[l]) << 1
+	}
+	codes := make([]uint16, len(lengths))
+	for sym, l := range lengths {
+		if l > 0 {
+			c := next[l]
+			next[l]++
+			codes[sym] = bits.Reverse16(uint16(c)) >> (16 - l)
+		}
+	}
+
+	root := uint(min(maxLen, rootBits))
+	h := &huffman{root: root, table: make([]huffmanEntry, 1<<root)}
+	mask := uint16(1<<root - 1)
+
+	// Size the second-level tables by the longest code under each prefix.
+	var subBits [1 << rootBits]uint8
+	for sym, l := range lengths {
+		if uint(l) > root {
+			p := codes[sym] & mask
+			subBits[p] = max(subBits[p], l-uint8(root))
+		}
+	}
+	for p, sb := range subBits {
+		if sb > 0 {
+			h.table[p] = huffmanEntry{sym: uint16(len(h.table)), bits: 0x80 | sb}
+			h.table = append(h.table, make([]huffmanEntry, 1<<sb)...)
+		}
+	}
+
+	for sym, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		e := huffmanEntry{sym: uint16(sym), bits: l}
+		code := int(codes[sym])
+		if uint(l) <= root {
+			for i := code; i < 1<<root; i += 1 << l {
+				h.table[i] = e
+			}
+			continue
+		}
+		link := h.table[code&int(mask)]
+		sub := h.table[link.sym : int(link.sym)+1<<(link.bits&0x7f)]
+		for i := code >> root; i < len(sub); i += 1 << (uint(l) - root) {
+			sub[i] = e
+		}
+	}
+	return h, nil
+}
+
+// decode reads one symbol.
+func (br *bitReader) decode(h *huffman) int {
+	br.fill(maxCodeLength)
+	e := h.table[br.bits&(1<<h.root-1)]
+	if e.bits&0x80 != 0 {
+		e = h.table[int(e.sym)+int(br.bits>>h.root&(1<<(e.bits&0x7f)-1))]
+	}
+	br.bits >>= e.bits
+	br.n -= uint(e.bits)
+	return int(e.sym)
+}
+
+// codeLengthOrder is the order in which code length code lengths are
+// stored.
+var codeLengthOrder = [18]int{1, 2, 3, 4, 0, 5, 17, 6, 16, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Code length code lengths use a fixed code, looked up by the next four
+// bits.
+var (
+	codeLengthCodeBits  = [16]uint8{2, 2, 2, 3, 2, 2, 2, 4, 2, 2, 2, 3, 2, 2, 2, 4}
+	codeLengthCodeValue = [16]uint8{0, 4, 3, 2, 0, 4, 3, 1, 0, 4, 3, 2, 0, 4, 3, 5}
+)
+
+// readPrefixCode reads a prefix code over an alphabet of the given size
+// (section 3.4 and 3.5).
+func (br *bitReader) readPrefixCode(alphabetSize int) (*huffman, error) {
+	hskip := br.read(2)
+	if hskip == 1 {
+		return br.readSimplePrefixCode(alphabetSize)
+	}
+
+	var clLengths [18]uint8
+	space, codes := 32, 0
+	for _, sym := range codeLengthOrder[hskip:] {
+		br.fill(4)
+		i := br.bits & 15
+		br.bits >>= codeLengthCodeBits[i]
+		br.n -= uint(codeLengthCodeBits[i])
+		v := codeLengthCodeValue[i]
+		clLengths[sym] = v
+		if v != 0 {
+			space -= 32 >> v
+			codes++
+			if space <= 0 {
+				break
+			}
+		}
+	}
+	if codes != 1 && space != 0 {
+		return nil, errCorrupt
+	}
+	clCode, err := newHuffman(clLengths[:])
+	if err != nil {
+		return nil, err
+	}
+
+	lengths := make([]uint8, alphabetSize)
+	prevLen, repeatLen := uint8(8), uint8(0)
+	repeat := 0
+	space = 1 << maxCodeLength
+	for sym := 0; sym < alphabetSize && space > 0; {
+		if br.overrun() {
+			return nil, br.check()
+		}
+		l := br.decode(clCode)
+		if l < 16 {
+			repeat = 0
+			lengths[sym] = uint8(l)
+			sym++
+			if l != 0 {
+				prevLen = uint8(l)
+				space -= 1 << (maxCodeLength - l)
+			}
+			continue
+		}
+
+		extraBits, newLen := uint(3), uint8(0)
+		if l == 16 {
+			extraBits, newLen = 2, prevLen
+		}
+		if repeatLen != newLen {
+			repeat, repeatLen = 0, newLen
+		}
+		old := repeat
+		if repeat > 0 {
+			repeat = (repeat - 2) << extraBits
+		}
+		repeat += int(br.read(extraBits)) + 3
+		delta := repeat - old
+		if sym+delta > alphabetSize {
+			return nil, errCorrupt
+		}
+		for range delta {
+			lengths[sym] = repeatLen
+			sym++
+		}
+		if repeatLen != 0 {
+			space -= delta << (maxCodeLength - repeatLen)
+		}
+	}
+	if space != 0 {
+		return nil, errCorrupt
+	}
+	return newHuffman(lengths)
+}
+
+// readSimplePrefixCode reads a code of at most four symbols.
+func (br *bitReader) readSimplePrefixCode(alphabetSize int) (*huffman, error) {
+	n := int(br.read(2)) + 1
+	symBits := uint(bits.Len(uint(alphabetSize - 1)))
+	var syms [4]int
+	for i := range n {
+		syms[i] = int(br.read(symBits))
+		if syms[i] >= alphabetSize {
+			return nil, errCorrupt
+		}
+		for _, s := range syms[:i] {
+			if s == syms[i] {
+				return nil, errCorrupt
+			}
+		}
+	}
+
+	var order []uint8
+	switch n {
+	case 1:
+		order = []uint8{1}
+	case 2:
+		order = []uint8{1, 1}
+	case 3:
+		order = []uint8{1, 2, 2}
+	case 4:
+		order = []uint8{2, 2, 2, 2}
+		if br.read(1) == 1 {
+			order = []uint8{1, 2, 3, 3}
+		}
+	}
+	lengths := make([]uint8, alphabetSize)
+	for i, l := range order {
+		lengths[syms[i]] = l
+	}
+	return newHuffman(lengths)
+}
@@ -0,0 +1,381 @@
+// Package ftp implements the client side of the File Transfer Protocol
+// (RFC 959) needed to download files: passive mode (EPSV from RFC 2428,
+// with PASV as a fallback), SIZE, MDTM and REST from RFC 3659, and TLS
+// (RFC 4217), either negotiated with AUTH TLS or implicit.
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is a negative reply from the server.
+type Error struct {
+	Code int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("ftp: %d %s", e.Code, e.Msg)
+}
+
+// Temporary reports whether the reply is a transient negative completion
+// (4xx), so the command may succeed if retried.
+func (e *Error) Temporary() bool {
+	return e.Code >= 400 && e.Code < 500
+}
+
+// errInvalidArg is returned for command arguments that would let a path
+// or credentials inject further commands.
+var errInvalidArg = errors.New("ftp: command argument contains CR, LF or NUL")
+
+// Config configures a connection.
+type Config struct {
+	// Dial dials the control and data connections. If nil, a net.Dialer is used.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// TLS, if not nil, secures the control and data connections.
+	TLS *tls.Config
+
+	// ImplicitTLS starts TLS as soon as the control connection is open,
+	// instead of negotiating it with AUTH TLS.
+	ImplicitTLS bool
+}
+
+// Conn is a control connection to an FTP server. It is not safe for
+// concurrent use.
+type Conn struct {
+	cfg  Config
+	host string
+	conn net.Conn
+	text *textproto.Conn
+	stop func() bool
+}
+
+// Dial connects to the server at addr and reads its greeting. Cancelling
+// ctx closes the connection.
+func Dial(ctx context.Context, addr string, cfg Config) (*Conn, error) {
+	if cfg.Dial == nil {
+		cfg.Dial = (&net.Dialer{}).DialContext
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TLS != nil {
+		cfg.TLS = cfg.TLS.Clone()
+		if cfg.TLS.ServerName == "" {
+			cfg.TLS.ServerName = host
+		}
+		// Servers commonly require data connections to resume the TLS
+		// session of the control connection.
+		if cfg.TLS.ClientSessionCache == nil {
+			cfg.TLS.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+		}
+	}
+
+	conn, err := cfg.Dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TLS != nil && cfg.ImplicitTLS {
+		conn = tls.Client(conn, cfg.TLS)
+	}
+	c := &Conn{cfg: cfg, host: host, conn: conn, text: textproto.NewConn(conn)}
+	c.stop = context.AfterFunc(ctx, func() { c.conn.Close() })
+
+	if err := c.handshake(); err != nil {
+		c.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Conn) handshake() error {
+	if _, err := c.read(2); err != nil {
+		return err
+	}
+	if c.cfg.TLS == nil {
+		return nil
+	}
+	if !c.cfg.ImplicitTLS {
+		if _, err := c.cmd(2, "AUTH TLS"); err != nil {
+			return err
+		}
+		c.conn = tls.Client(c.conn, c.cfg.TLS)
+		c.text = textproto.NewConn(c.conn)
+	}
+	if _, err := c.cmd(2, "PBSZ 0"); err != nil {
+		return err
+	}
+	_, err := c.cmd(2, "PROT P")
+	return err
+}
+
+// Login logs in as user and switches to binary mode.
+func (c *Conn) Login(user, password string) error {
+	code, err := c.cmd(0, "USER %s", user)
+	if err != nil {
+		return err
+	}
+	if code == 331 {
+		code, err = c.cmd(0, "PASS %s", password)
+		if err != nil {
+			return err
+		}
+	}
+	if code != 230 && code != 202 {
+		return &Error{Code: code, Msg: "login failed"}
+	}
+	_, err = c.cmd(2, "TYPE I")
+	return err
+}
+
+// Size returns the size of the file at path.
+func (c *Conn) Size(path string) (int64, error) {
+	msg, err := c.cmdMsg(213, "SIZE %s", path)
+	if err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(msg), 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("ftp: invalid SIZE reply: %q", msg)
+	}
+	return size, nil
+}
+
+// ModTime returns the modification time of the file at path.
+func (c *Conn) ModTime(path string) (time.Time, error) {
+	msg, err := c.cmdMsg(213, "MDTM %s", path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseTime(strings.TrimSpace(msg))
+}
+
+// parseTime parses a time-val of RFC 3659: YYYYMMDDHHMMSS[.sss] in UTC.
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse("20060102150405", s)
+	if err != nil {
+		t, err = time.Parse("20060102150405.999999999", s)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ftp: invalid time: %q", s)
+	}
+	return t, nil
+}
+
+// Retr starts downloading the file at path from offset, and returns its
+// content. Once it has been read to the end, the connection can be used
+// again; closing it before closes the connection.
+func (c *Conn) Retr(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	data, err := c.passive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := c.cmd(3, "REST %d", offset); err != nil {
+			data.Close()
+			return nil, err
+		}
+	}
+	if _, err := c.cmd(1, "RETR %s", path); err != nil {
+		data.Close()
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { data.Close() })
+	return &transfer{c: c, data: data, stop: stop}, nil
+}
+
+// passive opens a data connection in passive mode.
+func (c *Conn) passive(ctx context.Context) (net.Conn, error) {
+	port, err := c.epsv()
+	if err != nil {
+		var ftpErr *Error
+		if !errors.As(err, &ftpErr) {
+			return nil, err
+		}
+		if port, err = c.pasv(); err != nil {
+			return nil, err
+		}
+	}
+	// The address of the control connection is used rather than the one in
+	// a PASV reply, which may be private or point at a third party.
+	conn, err := c.cfg.Dial(ctx, "tcp", net.JoinHostPort(c.host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.TLS != nil {
+		// The handshake happens on the first read, once the server
+		// accepts the connection in response to RETR.
+		conn = tls.Client(conn, c.cfg.TLS)
+	}
+	return conn, nil
+}
+
+// epsv sends EPSV and returns the port of its reply: "(|||port|)".
+func (c *Conn) epsv() (int, error) {
+	msg, err := c.cmdMsg(229, "EPSV")
+	if err != nil {
+		return 0, err
+	}
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start+5 {
+		return 0, fmt.Errorf("ftp: invalid EPSV reply: %q", msg)
+	}
+	fields := strings.Split(msg[start+2:end-1], msg[start+1:start+2])
+	if len(fields) != 3 {
+		return 0, fmt.Errorf("ftp: invalid EPSV reply: %q", msg)
+	}
+	port, err := strconv.Atoi(fields[2])
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("ftp: invalid EPSV reply: %q", msg)
+	}
+	return port, nil
+}
+
+// pasv sends PASV and returns the port of its reply: "(h1,h2,h3,h4,p1,p2)".
+func (c *Conn) pasv() (int, error) {
+	msg, err := c.cmdMsg(227, "PASV")
+	if err != nil {
+		return 0, err
+	}
+	start := strings.IndexFunc(msg, func(r rune) bool { return r >= '0' && r <= '9' })
+	if start < 0 {
+		return 0, fmt.Errorf("ftp: invalid PASV reply: %q", msg)
+	}
+	end := start + strings.LastIndexFunc(msg[start:], func(r rune) bool { return r >= '0' && r <= '9' }) + 1
+	fields := strings.Split(msg[start:end], ",")
+	if len(fields) != 6 {
+		return 0, fmt.Errorf("ftp: invalid PASV reply: %q", msg)
+	}
+	p1, err1 := strconv.Atoi(fields[4])
+	p2, err2 := strconv.Atoi(fields[5])
+	if err1 != nil || err2 != nil || p1 < 0 || p1 > 255 || p2 < 0 || p2 > 255 || p1 == 0 && p2 == 0 {
+		return 0, fmt.Errorf("ftp: invalid PASV reply: %q", msg)
+	}
+	return p1<<8 | p2, nil
+}
+
+// Quit ends the session and closes the connection.
+func (c *Conn) Quit() error {
+	_, err := c.cmd(2, "QUIT")
+	if closeErr := c.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Close closes the connection without ending the session.
+func (c *Conn) Close() error {
+	c.stop()
+	return c.conn.Close()
+}
+
+// cmd sends a command and reads its reply, which must have the expected
+// code or, if expect is a single digit, class. An expect of 0 accepts any
+// reply.
+func (c *Conn) cmd(expect int, format string, args ...any) (int, error) {
+	if err := checkArgs(args); err != nil {
+		return 0, err
+	}
+	id, err := c.text.Cmd(format, args...)
+	if err != nil {
+		return 0, err
+	}
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+	return c.read(expect)
+}
+
+// cmdMsg is like cmd but returns the message of the reply.
+func (c *Conn) cmdMsg(expect int, format string, args ...any) (string, error) {
+	if err := checkArgs(args); err != nil {
+		return "", err
+	}
+	id, err := c.text.Cmd(format, args...)
+	if err != nil {
+		return "", err
+	}
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+	_, msg, err := c.readMsg(expect)
+	return msg, err
+}
+
+// checkArgs rejects string arguments containing CR, LF or NUL, which
+// would end the command line early.
+func checkArgs(args []any) error {
+	for _, arg := range args {
+		if s, ok := arg.(string); ok && strings.ContainsAny(s, "\r\n\x00") {
+			return errInvalidArg
+		}
+	}
+	return nil
+}
+
+func (c *Conn) read(expect int) (int, error) {
+	code, _, err := c.readMsg(expect)
+	return code, err
+}
+
+func (c *Conn) readMsg(expect int) (int, string, error) {
+	code, msg, err := c.text.ReadResponse(expect)
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return code, msg, &Error{Code: protoErr.Code, Msg: protoErr.Msg}
+	}
+	return code, msg, err
+}
+
+// transfer is the content of a file being downloaded.
+type transfer struct {
+	c    *Conn
+	data net.Conn
+	stop func() bool
+	done bool
+}
+
+func (t *transfer) Read(p []byte) (int, error) {
+	n, err := t.data.Read(p)
+	if err == io.EOF {
+		// Wait for the server to confirm the transfer completed.
+		if err := t.finish(); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+// finish closes the data connection and reads the reply to RETR.
+func (t *transfer) finish() error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+	t.stop()
+	t.data.Close()
+	_, err := t.c.read(2)
+	return err
+}
+
+func (t *transfer) Close() error {
+	if t.done {
+		return nil
+	}
+	t.done = true
+	t.stop()
+	t.data.Close()
+	return t.c.Close()
+}
@@ -0,0 +1,146 @@
+package ftp_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve/internal/ftp"
+	"github.com/ciathefed/retrieve/internal/ftp/ftptest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var files = map[string][]byte{"pub/data.bin": []byte("0123456789")}
+
+func dial(t *testing.T, server *ftptest.Server, cfg ftp.Config) *ftp.Conn {
+	c, err := ftp.Dial(context.Background(), server.Addr, cfg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { c.Close() })
+	assert.NoError(t, c.Login("anonymous", "anonymous@"))
+	return c
+}
+
+func retr(t *testing.T, c *ftp.Conn, path string, offset int64) string {
+	body, err := c.Retr(context.Background(), path, offset)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestConn(t *testing.T) {
+	server := ftptest.NewServer(files)
+	defer server.Close()
+	c := dial(t, server, ftp.Config{})
+
+	size, err := c.Size("pub/data.bin")
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, size)
+
+	modTime, err := c.ModTime("pub/data.bin")
+	assert.NoError(t, err)
+	assert.Equal(t, server.ModTime, modTime)
+
+	assert.Equal(t, "0123456789", retr(t, c, "pub/data.bin", 0))
+	// The connection is reused after a complete transfer.
+	assert.Equal(t, "6789", retr(t, c, "pub/data.bin", 6))
+	assert.Contains(t, server.Commands(), "REST 6")
+	assert.NoError(t, c.Quit())
+}
+
+func TestConn_PASVFallback(t *testing.T) {
+	server := ftptest.NewUnstartedServer(files)
+	server.DisableEPSV = true
+	server.Start()
+	defer server.Close()
+
+	c := dial(t, server, ftp.Config{})
+	assert.Equal(t, "0123456789", retr(t, c, "pub/data.bin", 0))
+	assert.Contains(t, server.Commands(), "PASV")
+}
+
+func TestConn_Errors(t *testing.T) {
+	server := ftptest.NewUnstartedServer(files)
+	server.User, server.Password = "alice", "secret"
+	server.Start()
+	defer server.Close()
+
+	c, err := ftp.Dial(context.Background(), server.Addr, ftp.Config{})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	var ftpErr *ftp.Error
+	assert.ErrorAs(t, c.Login("alice", "wrong"), &ftpErr)
+	assert.Equal(t, 530, ftpErr.Code)
+
+	assert.NoError(t, c.Login("alice", "secret"))
+	_, err = c.Size("missing")
+	assert.ErrorAs(t, err, &ftpErr)
+	assert.Equal(t, 550, ftpErr.Code)
+	assert.False(t, ftpErr.Temporary())
+}
+
+func TestConn_InjectedCommand(t *testing.T) {
+	server := ftptest.NewServer(files)
+	defer server.Close()
+
+	c, err := ftp.Dial(context.Background(), server.Addr, ftp.Config{})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	assert.Error(t, c.Login("anonymous\r\nDELE pub/data.bin", "anonymous@"))
+	assert.NoError(t, c.Login("anonymous", "anonymous@"))
+	_, err = c.Size("a\r\nDELE pub/data.bin")
+	assert.Error(t, err)
+	_, err = c.Retr(context.Background(), "a\x00b", 0)
+	assert.Error(t, err)
+
+	for _, cmd := range server.Commands() {
+		assert.NotContains(t, cmd, "DELE")
+	}
+}
+
+func TestConn_TLS(t *testing.T) {
+	// Borrow the certificate of an HTTPS test server.
+	https := httptest.NewTLSServer(http.NotFoundHandler())
+	defer https.Close()
+
+	server := ftptest.NewUnstartedServer(files)
+	server.TLS = &tls.Config{Certificates: https.TLS.Certificates}
+	server.Start()
+	defer server.Close()
+
+	rootCAs := https.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	c := dial(t, server, ftp.Config{TLS: &tls.Config{RootCAs: rootCAs, ServerName: "example.com"}})
+	assert.Equal(t, "0123456789", retr(t, c, "pub/data.bin", 0))
+	assert.Contains(t, server.Commands(), "PROT P")
+}
+
+func TestDial_Cancel(t *testing.T) {
+	server := ftptest.NewServer(files)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := ftp.Dial(ctx, server.Addr, ftp.Config{})
+	assert.NoError(t, err)
+	cancel()
+
+	done := make(chan error)
+	go func() { done <- c.Login("anonymous", "") }()
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Login did not fail after the context was cancelled")
+	}
+}
@@ -0,0 +1,294 @@
+// Package ftptest provides an FTP server for tests, in the spirit of
+// net/http/httptest. It serves files from memory and supports what the
+// ftp package uses: passive mode, SIZE, MDTM, REST and AUTH TLS.
+package ftptest
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an FTP server listening on the loopback interface.
+type Server struct {
+	// Addr is the address of the server, as host:port.
+	Addr string
+
+	// URL is the base URL of the server, such as ftp://127.0.0.1:2121.
+	URL string
+
+	// Files holds the content of each file, by path without a leading slash.
+	Files map[string][]byte
+
+	// ModTime is the modification time reported for every file.
+	ModTime time.Time
+
+	// User and Password, if set, are required to log in; otherwise any
+	// login is accepted.
+	User, Password string
+
+	// TLS, if set, enables AUTH TLS.
+	TLS *tls.Config
+
+	// DisableEPSV and DisableREST make the server reject those commands.
+	DisableEPSV, DisableREST bool
+
+	// AbortAfter, if positive, makes the next transfer stop after that many
+	// bytes, as if the connection dropped.
+	AbortAfter int64
+
+	mu       sync.Mutex
+	commands []string
+	ln       net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server serving files.
+func NewServer(files map[string][]byte) *Server {
+	s := NewUnstartedServer(files)
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a server serving files, to be configured and
+// then started with Start.
+func NewUnstartedServer(files map[string][]byte) *Server {
+	return &Server{Files: files, ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+}
+
+// Start starts the server.
+func (s *Server) Start() {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("ftptest: failed to listen: " + err.Error())
+	}
+	s.ln = ln
+	s.Addr = ln.Addr().String()
+	s.URL = "ftp://" + s.Addr
+	s.conns = make(map[net.Conn]struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+			}()
+		}
+	}()
+}
+
+// Close stops the server, closes the open sessions and waits for them to
+// end.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Commands returns the commands received so far, such as "RETR file".
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+type session struct {
+	s      *Server
+	conn   net.Conn
+	text   *textproto.Conn
+	user   string
+	prot   bool
+	rest   int64
+	data   net.Listener
+	closed bool
+}
+
+func (s *Server) serve(conn net.Conn) {
+	ss := &session{s: s, conn: conn, text: textproto.NewConn(conn)}
+	defer func() {
+		if ss.data != nil {
+			ss.data.Close()
+		}
+		ss.conn.Close()
+	}()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	ss.reply(220, "ftptest ready")
+	for !ss.closed {
+		line, err := ss.text.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		cmd = strings.ToUpper(cmd)
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+		ss.handle(cmd, arg)
+	}
+}
+
+func (ss *session) reply(code int, format string, args ...any) {
+	ss.text.PrintfLine("%d %s", code, fmt.Sprintf(format, args...))
+}
+
+func (ss *session) handle(cmd, arg string) {
+	s := ss.s
+	switch cmd {
+	case "USER":
+		ss.user = arg
+		ss.reply(331, "password required")
+	case "PASS":
+		if s.User != "" && (ss.user != s.User || arg != s.Password) {
+			ss.reply(530, "login incorrect")
+			return
+		}
+		ss.reply(230, "logged in")
+	case "AUTH":
+		if s.TLS == nil || !strings.EqualFold(arg, "TLS") {
+			ss.reply(502, "not supported")
+			return
+		}
+		ss.reply(234, "starting TLS")
+		ss.conn = tls.Server(ss.conn, s.TLS)
+		ss.text = textproto.NewConn(ss.conn)
+	case "PBSZ":
+		ss.reply(200, "ok")
+	case "PROT":
+		ss.prot = strings.EqualFold(arg, "P")
+		ss.reply(200, "ok")
+	case "TYPE":
+		ss.reply(200, "type set")
+	case "SIZE":
+		data, ok := s.Files[arg]
+		if !ok {
+			ss.reply(550, "%s: no such file", arg)
+			return
+		}
+		ss.reply(213, "%d", len(data))
+	case "MDTM":
+		if _, ok := s.Files[arg]; !ok {
+			ss.reply(550, "%s: no such file", arg)
+			return
+		}
+		ss.reply(213, "%s", s.ModTime.UTC().Format("20060102150405"))
+	case "EPSV":
+		if s.DisableEPSV {
+			ss.reply(502, "not supported")
+			return
+		}
+		port, err := ss.listen()
+		if err != nil {
+			ss.reply(425, "cannot open data connection")
+			return
+		}
+		ss.reply(229, "Entering Extended Passive Mode (|||%d|)", port)
+	case "PASV":
+		port, err := ss.listen()
+		if err != nil {
+			ss.reply(425, "cannot open data connection")
+			return
+		}
+		ss.reply(227, "Entering Passive Mode (127,0,0,1,%d,%d)", port>>8, port&0xff)
+	case "REST":
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if s.DisableREST || err != nil || n < 0 {
+			ss.reply(502, "not supported")
+			return
+		}
+		ss.rest = n
+		ss.reply(350, "restarting at %d", n)
+	case "RETR":
+		ss.retr(arg)
+	case "QUIT":
+		ss.reply(221, "bye")
+		ss.closed = true
+	default:
+		ss.reply(502, "%s not implemented", cmd)
+	}
+}
+
+// listen opens the listener of a passive data connection.
+func (ss *session) listen() (int, error) {
+	if ss.data != nil {
+		ss.data.Close()
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	ss.data = ln
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+func (ss *session) retr(path string) {
+	s := ss.s
+	data, ok := s.Files[path]
+	rest := ss.rest
+	ss.rest = 0
+	if !ok {
+		ss.reply(550, "%s: no such file", path)
+		return
+	}
+	if ss.data == nil {
+		ss.reply(425, "use PASV or EPSV first")
+		return
+	}
+	ln := ss.data
+	ss.data = nil
+	defer ln.Close()
+
+	ss.reply(150, "opening data connection")
+	ln.(*net.TCPListener).SetDeadline(time.Now().Add(10 * time.Second))
+	conn, err := ln.Accept()
+	if err != nil {
+		ss.reply(425, "cannot open data connection")
+		return
+	}
+	if ss.prot && s.TLS != nil {
+		conn = tls.Server(conn, s.TLS)
+	}
+
+	s.mu.Lock()
+	limit := s.AbortAfter
+	s.AbortAfter = 0
+	s.mu.Unlock()
+
+	content := data[min(rest, int64(len(data))):]
+	aborted := limit > 0 && limit < int64(len(content))
+	if aborted {
+		content = content[:limit]
+	}
+	w := bufio.NewWriter(conn)
+	w.Write(content)
+	err = w.Flush()
+	conn.Close()
+	if aborted || err != nil {
+		ss.reply(426, "connection closed; transfer aborted")
+		return
+	}
+	ss.reply(226, "transfer complete")
+}
@@ -0,0 +1,96 @@
+package zstd
+
+import "math/bits"
+
+// forwardReader reads a little-endian bit stream from the start, as used by
+// FSE table descriptions.
+type forwardReader struct {
+	data []byte
+	pos  int // bit position
+}
+
+// peek returns the next n bits (n <= 32) without consuming them. Bits past
+// the end of the data read as zero.
+func (fr *forwardReader) peek(n int) uint32 {
+	var v uint64
+	byteIndex := fr.pos >> 3
+	for i := 0; i < 5 && byteIndex+i < len(fr.data); i++ {
+		v |= uint64(fr.data[byteIndex+i]) << (8 * i)
+	}
+	return uint32(v>>(fr.pos&7)) & (1<<n - 1)
+}
+
+func (fr *forwardReader) skip(n int) {
+	fr.pos += n
+}
+
+// overrun reports whether more bits were consumed than the data holds.
+func (fr *forwardReader) overrun() bool {
+	return fr.pos > len(fr.data)*8
+}
+
+// bytesRead returns the number of bytes consumed, rounding up.
+func (fr *forwardReader) bytesRead() int {
+	return (fr.pos + 7) >> 3
+}
+
+// backwardReader reads a bit stream from its end towards its start, as used
+// by Huffman and FSE encoded data. The last byte holds a 1 bit marking
+// where the stream begins.
+type backwardReader struct {
+	data      []byte
+	remaining int // bits left to read; negative once overrun
+}
+
+func newBackwardReader(data []byte) (*backwardReader, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, errCorrupt
+	}
+	last := data[len(data)-1]
+	return &backwardReader{
+		data:      data,
+		remaining: (len(data)-1)*8 + bits.Len8(last) - 1,
+	}, nil
+}
+
+// peek returns the next n bits (n <= 56) without consuming them. Bits
+// before the start of the data read as zero.
+func (br *backwardReader) peek(n int) uint64 {
+	if n == 0 {
+		return 0
+	}
+	start := br.remaining - n
+	first := start >> 3 // floor division, also for negative start
+	var v uint64
+	for i := 0; i < 8; i++ {
+		idx := first + i
+		if idx < 0 {
+			continue
+		}
+		if idx >= len(br.data) || idx<<3 >= br.remaining {
+			break
+		}
+		v |= uint64(br.data[idx]) << (8 * i)
+	}
+	return (v >> (start - first<<3)) & (1<<n - 1)
+}
+
+func (br *backwardReader) read(n int) uint64 {
+	v := br.peek(n)
+	br.remaining -= n
+	return v
+}
+
+func (br *backwardReader) skip(n int) {
+	br.remaining -= n
+}
+
+// overrun reports whether more bits were consumed than the stream holds.
+func (br *backwardReader) overrun() bool {
+	return br.remaining < 0
+}
+
+// done reports whether every bit of the stream was consumed exactly.
+func (br *backwardReader) done() bool {
+	return br.remaining == 0
+}
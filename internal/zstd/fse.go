@@ -0,0 +1,168 @@
+package zstd
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// fseEntry is one state of an FSE decoding table.
+type fseEntry struct {
+	symbol   uint8
+	nbBits   uint8
+	newState uint16
+}
+
+// fseTable is an FSE decoding table.
+type fseTable struct {
+	accuracyLog int
+	entries     []fseEntry
+}
+
+// readFSETable reads an FSE table description from data and returns the
+// table and the number of bytes it used.
+func readFSETable(data []byte, maxSymbol, maxAccuracyLog int) (*fseTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errCorrupt
+	}
+	fr := &forwardReader{data: data}
+	accuracyLog := int(fr.peek(4)) + 5
+	fr.skip(4)
+	if accuracyLog > maxAccuracyLog {
+		return nil, 0, errors.New("zstd: FSE accuracy log too large")
+	}
+
+	norm := make([]int16, maxSymbol+1)
+	remaining := 1<<accuracyLog + 1
+	threshold := 1 << accuracyLog
+	nbBits := accuracyLog + 1
+	symbol := 0
+	previous0 := false
+	for remaining > 1 && symbol <= maxSymbol {
+		if previous0 {
+			// A zero probability is followed by 2-bit counts of further zeros.
+			n := symbol
+			for {
+				repeat := int(fr.peek(2))
+				fr.skip(2)
+				n += repeat
+				if repeat != 3 {
+					break
+				}
+			}
+			if n > maxSymbol+1 {
+				return nil, 0, errCorrupt
+			}
+			symbol = n
+			if symbol > maxSymbol {
+				break
+			}
+		}
+
+		max := 2*threshold - 1 - remaining
+		var count int
+		if low := int(fr.peek(nbBits - 1)); low < max {
+			count = low
+			fr.skip(nbBits - 1)
+		} else {
+			count = int(fr.peek(nbBits))
+			if count >= threshold {
+				count -= max
+			}
+			fr.skip(nbBits)
+		}
+		count--
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+		norm[symbol] = int16(count)
+		symbol++
+		previous0 = count == 0
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+		if fr.overrun() {
+			return nil, 0, errCorrupt
+		}
+	}
+	if remaining != 1 || fr.overrun() {
+		return nil, 0, errCorrupt
+	}
+
+	t, err := buildFSETable(norm[:symbol], accuracyLog)
+	if err != nil {
+		return nil, 0, err
+	}
+	return t, fr.bytesRead(), nil
+}
+
+// buildFSETable builds the decoding table for the normalized counts norm,
+// where -1 denotes a "less than one" probability.
+func buildFSETable(norm []int16, accuracyLog int) (*fseTable, error) {
+	size := 1 << accuracyLog
+	t := &fseTable{accuracyLog: accuracyLog, entries: make([]fseEntry, size)}
+	next := make([]uint16, len(norm))
+
+	high := size - 1
+	for s, n := range norm {
+		if n == -1 {
+			t.entries[high].symbol = uint8(s)
+			high--
+			next[s] = 1
+		} else {
+			next[s] = uint16(n)
+		}
+	}
+
+	step := size>>1 + size>>3 + 3
+	mask := size - 1
+	pos := 0
+	for s, n := range norm {
+		for range max(int(n), 0) {
+			t.entries[pos].symbol = uint8(s)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	if pos != 0 {
+		return nil, errCorrupt
+	}
+
+	for i := range t.entries {
+		e := &t.entries[i]
+		state := next[e.symbol]
+		next[e.symbol]++
+		e.nbBits = uint8(accuracyLog - (bits.Len16(state) - 1))
+		e.newState = state<<e.nbBits - uint16(size)
+	}
+	return t, nil
+}
+
+// rleTable returns a table that always decodes symbol.
+func rleTable(symbol uint8) *fseTable {
+	return &fseTable{entries: []fseEntry{{symbol: symbol}}}
+}
+
+// fseState is the state of an FSE decoder reading a backward bit stream.
+type fseState struct {
+	table *fseTable
+	state uint16
+}
+
+func (s *fseState) init(br *backwardReader, t *fseTable) {
+	s.table = t
+	s.state = uint16(br.read(t.accuracyLog))
+}
+
+func (s *fseState) symbol() uint8 {
+	return s.table.entries[s.state].symbol
+}
+
+func (s *fseState) update(br *backwardReader) {
+	e := s.table.entries[s.state]
+	s.state = e.newState + uint16(br.read(int(e.nbBits)))
+}
@@ -0,0 +1,165 @@
+package zstd
+
+import (
+	"errors"
+	"math/bits"
+)
+
+const maxHuffmanBits = 11
+
+// huffmanEntry is one entry of a Huffman decoding table, indexed by the
+// next maxBits bits of the stream.
+type huffmanEntry struct {
+	symbol uint8
+	nbBits uint8
+}
+
+type huffmanTable struct {
+	maxBits int
+	entries []huffmanEntry
+}
+
+// readHuffmanTable reads a Huffman tree description and returns the table
+// and the number of bytes it used.
+func readHuffmanTable(data []byte) (*huffmanTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errCorrupt
+	}
+	header := int(data[0])
+	var weights []uint8
+	var size int
+
+	if header < 128 {
+		// The weights are FSE compressed.
+		size = 1 + header
+		if len(data) < size {
+			return nil, 0, errCorrupt
+		}
+		var err error
+		if weights, err = decodeWeights(data[1:size]); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		// The weights are stored directly, 4 bits each.
+		n := header - 127
+		size = 1 + (n+1)/2
+		if len(data) < size {
+			return nil, 0, errCorrupt
+		}
+		weights = make([]uint8, n)
+		for i := range weights {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				weights[i] = b >> 4
+			} else {
+				weights[i] = b & 0xf
+			}
+		}
+	}
+
+	t, err := buildHuffmanTable(weights)
+	if err != nil {
+		return nil, 0, err
+	}
+	return t, size, nil
+}
+
+// decodeWeights decodes FSE compressed Huffman weights with two
+// interleaved states.
+func decodeWeights(data []byte) ([]uint8, error) {
+	t, n, err := readFSETable(data, 255, 6)
+	if err != nil {
+		return nil, err
+	}
+	br, err := newBackwardReader(data[n:])
+	if err != nil {
+		return nil, err
+	}
+
+	var s1, s2 fseState
+	s1.init(br, t)
+	s2.init(br, t)
+	var weights []uint8
+	for {
+		if len(weights) > 255 {
+			return nil, errCorrupt
+		}
+		weights = append(weights, s1.symbol())
+		s1.update(br)
+		if br.overrun() {
+			weights = append(weights, s2.symbol())
+			break
+		}
+		weights = append(weights, s2.symbol())
+		s2.update(br)
+		if br.overrun() {
+			weights = append(weights, s1.symbol())
+			break
+		}
+	}
+	return weights, nil
+}
+
+// buildHuffmanTable builds a decoding table from the weights of all but
+// the last symbol, whose weight is implied.
+func buildHuffmanTable(weights []uint8) (*huffmanTable, error) {
+	var total uint32
+	for _, w := range weights {
+		if w > maxHuffmanBits {
+			return nil, errCorrupt
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 {
+		return nil, errCorrupt
+	}
+
+	maxBits := bits.Len32(total)
+	if maxBits > maxHuffmanBits {
+		return nil, errors.New("zstd: Huffman code too long")
+	}
+	rest := uint32(1)<<maxBits - total
+	if rest&(rest-1) != 0 {
+		return nil, errCorrupt
+	}
+	weights = append(weights, uint8(bits.Len32(rest)))
+	if len(weights) > 256 {
+		return nil, errCorrupt
+	}
+
+	t := &huffmanTable{maxBits: maxBits, entries: make([]huffmanEntry, 1<<maxBits)}
+	pos := 0
+	for w := 1; w <= maxBits; w++ {
+		for s, sw := range weights {
+			if int(sw) != w {
+				continue
+			}
+			n := 1 << (w - 1)
+			e := huffmanEntry{symbol: uint8(s), nbBits: uint8(maxBits + 1 - w)}
+			for i := range n {
+				t.entries[pos+i] = e
+			}
+			pos += n
+		}
+	}
+	return t, nil
+}
+
+// decode decodes n symbols of a single Huffman stream into out.
+func (t *huffmanTable) decode(out []byte, data []byte) error {
+	br, err := newBackwardReader(data)
+	if err != nil {
+		return err
+	}
+	for i := range out {
+		e := t.entries[br.peek(t.maxBits)]
+		out[i] = e.symbol
+		br.skip(int(e.nbBits))
+	}
+	if !br.done() {
+		return errCorrupt
+	}
+	return nil
+}
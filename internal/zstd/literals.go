@@ -0,0 +1,131 @@
+package zstd
+
+import "encoding/binary"
+
+// decodeLiterals reads the literals section of a compressed block and
+// returns the literals and the number of bytes used.
+func (f *frameDecoder) decodeLiterals(data []byte, maxSize int) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errCorrupt
+	}
+	b0 := int(data[0])
+	blockType := b0 & 3
+	sizeFormat := b0 >> 2 & 3
+
+	if blockType < 2 {
+		// Raw or RLE literals.
+		var size, header int
+		switch sizeFormat {
+		case 0, 2:
+			size, header = b0>>3, 1
+		case 1:
+			if len(data) < 2 {
+				return nil, 0, errCorrupt
+			}
+			size, header = b0>>4+int(data[1])<<4, 2
+		default:
+			if len(data) < 3 {
+				return nil, 0, errCorrupt
+			}
+			size, header = b0>>4+int(data[1])<<4+int(data[2])<<12, 3
+		}
+		if size > maxSize {
+			return nil, 0, errCorrupt
+		}
+
+		if blockType == 0 {
+			if len(data) < header+size {
+				return nil, 0, errCorrupt
+			}
+			return data[header : header+size], header + size, nil
+		}
+		if len(data) < header+1 {
+			return nil, 0, errCorrupt
+		}
+		literals := make([]byte, size)
+		for i := range literals {
+			literals[i] = data[header]
+		}
+		return literals, header + 1, nil
+	}
+
+	// Huffman compressed literals, with a new table or the previous one.
+	var regenerated, compressed, header int
+	streams := 4
+	switch sizeFormat {
+	case 0, 1:
+		if len(data) < 3 {
+			return nil, 0, errCorrupt
+		}
+		if sizeFormat == 0 {
+			streams = 1
+		}
+		v := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+		regenerated, compressed, header = v>>4&0x3ff, v>>14&0x3ff, 3
+	case 2:
+		if len(data) < 4 {
+			return nil, 0, errCorrupt
+		}
+		v := int(binary.LittleEndian.Uint32(data))
+		regenerated, compressed, header = v>>4&0x3fff, v>>18&0x3fff, 4
+	default:
+		if len(data) < 5 {
+			return nil, 0, errCorrupt
+		}
+		v := int(binary.LittleEndian.Uint32(data)) | int(data[4])<<32
+		regenerated, compressed, header = v>>4&0x3ffff, v>>22&0x3ffff, 5
+	}
+	if regenerated > maxSize || len(data) < header+compressed {
+		return nil, 0, errCorrupt
+	}
+	payload := data[header : header+compressed]
+
+	if blockType == 2 {
+		t, n, err := readHuffmanTable(payload)
+		if err != nil {
+			return nil, 0, err
+		}
+		f.huffman = t
+		payload = payload[n:]
+	} else if f.huffman == nil {
+		return nil, 0, errCorrupt
+	}
+
+	literals := make([]byte, regenerated)
+	if streams == 1 {
+		if err := f.huffman.decode(literals, payload); err != nil {
+			return nil, 0, err
+		}
+		return literals, header + compressed, nil
+	}
+
+	if len(payload) < 6 {
+		return nil, 0, errCorrupt
+	}
+	sizes := [4]int{
+		int(binary.LittleEndian.Uint16(payload)),
+		int(binary.LittleEndian.Uint16(payload[2:])),
+		int(binary.LittleEndian.Uint16(payload[4:])),
+	}
+	payload = payload[6:]
+	sizes[3] = len(payload) - sizes[0] - sizes[1] - sizes[2]
+	if sizes[3] < 1 {
+		return nil, 0, errCorrupt
+	}
+	segment := (regenerated + 3) / 4
+	if segment*3 > regenerated {
+		return nil, 0, errCorrupt
+	}
+	out := literals
+	for i, size := range sizes {
+		n := segment
+		if i == 3 {
+			n = len(out)
+		}
+		if err := f.huffman.decode(out[:n], payload[:size]); err != nil {
+			return nil, 0, err
+		}
+		out, payload = out[n:], payload[size:]
+	}
+	return literals, header + compressed, nil
+}
@@ -0,0 +1,218 @@
+package zstd
+
+const (
+	maxLiteralsLengthSymbol = 35
+	maxMatchLengthSymbol    = 52
+	maxOffsetSymbol         = 31
+
+	maxLiteralsLengthLog = 9
+	maxMatchLengthLog    = 9
+	maxOffsetLog         = 8
+)
+
+var (
+	literalsLengthDefault = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	matchLengthDefault = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	offsetDefault = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+
+	literalsLengthTable, _ = buildFSETable(literalsLengthDefault, 6)
+	matchLengthTable, _    = buildFSETable(matchLengthDefault, 6)
+	offsetTable, _         = buildFSETable(offsetDefault, 5)
+)
+
+// codeInfo is the baseline and number of extra bits of a length code.
+type codeInfo struct {
+	baseline uint32
+	bits     uint8
+}
+
+var literalsLengthCodes = func() [maxLiteralsLengthSymbol + 1]codeInfo {
+	var codes [maxLiteralsLengthSymbol + 1]codeInfo
+	for i := range 16 {
+		codes[i] = codeInfo{uint32(i), 0}
+	}
+	copy(codes[16:], []codeInfo{
+		{16, 1}, {18, 1}, {20, 1}, {22, 1}, {24, 2}, {28, 2}, {32, 3}, {40, 3},
+		{48, 4}, {64, 6}, {128, 7}, {256, 8}, {512, 9}, {1024, 10}, {2048, 11},
+		{4096, 12}, {8192, 13}, {16384, 14}, {32768, 15}, {65536, 16},
+	})
+	return codes
+}()
+
+var matchLengthCodes = func() [maxMatchLengthSymbol + 1]codeInfo {
+	var codes [maxMatchLengthSymbol + 1]codeInfo
+	for i := range 32 {
+		codes[i] = codeInfo{uint32(i + 3), 0}
+	}
+	copy(codes[32:], []codeInfo{
+		{35, 1}, {37, 1}, {39, 1}, {41, 1}, {43, 2}, {47, 2}, {51, 3}, {59, 3},
+		{67, 4}, {83, 4}, {99, 5}, {131, 7}, {259, 8}, {515, 9}, {1027, 10},
+		{2051, 11}, {4099, 12}, {8195, 13}, {16387, 14}, {32771, 15}, {65539, 16},
+	})
+	return codes
+}()
+
+// sequence is a literal run followed by a match.
+type sequence struct {
+	literals uint32
+	match    uint32
+	offset   uint32
+}
+
+// readSequenceTable reads the decoding table for one sequence field in the
+// given mode, returning the table and the number of bytes used.
+func readSequenceTable(data []byte, mode byte, def *fseTable, prev *fseTable, maxSymbol, maxLog int) (*fseTable, int, error) {
+	switch mode {
+	case 0: // Predefined
+		return def, 0, nil
+	case 1: // RLE
+		if len(data) < 1 || int(data[0]) > maxSymbol {
+			return nil, 0, errCorrupt
+		}
+		return rleTable(data[0]), 1, nil
+	case 2: // FSE compressed
+		return readFSETable(data, maxSymbol, maxLog)
+	default: // Repeat
+		if prev == nil {
+			return nil, 0, errCorrupt
+		}
+		return prev, 0, nil
+	}
+}
+
+// decodeSequences reads the sequences section of a compressed block.
+func (d *frameDecoder) decodeSequences(data []byte) ([]sequence, error) {
+	if len(data) == 0 {
+		return nil, errCorrupt
+	}
+	var count int
+	switch b0 := int(data[0]); {
+	case b0 == 0:
+		if len(data) != 1 {
+			return nil, errCorrupt
+		}
+		return nil, nil
+	case b0 < 128:
+		count, data = b0, data[1:]
+	case b0 < 255:
+		if len(data) < 2 {
+			return nil, errCorrupt
+		}
+		count, data = (b0-128)<<8+int(data[1]), data[2:]
+	default:
+		if len(data) < 3 {
+			return nil, errCorrupt
+		}
+		count, data = int(data[1])+int(data[2])<<8+0x7f00, data[3:]
+	}
+
+	if len(data) < 1 {
+		return nil, errCorrupt
+	}
+	modes := data[0]
+	if modes&3 != 0 {
+		return nil, errCorrupt
+	}
+	data = data[1:]
+
+	var n int
+	var err error
+	if d.llTable, n, err = readSequenceTable(data, modes>>6, literalsLengthTable, d.llTable, maxLiteralsLengthSymbol, maxLiteralsLengthLog); err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	if d.ofTable, n, err = readSequenceTable(data, modes>>4&3, offsetTable, d.ofTable, maxOffsetSymbol, maxOffsetLog); err != nil {
+		return nil, err
+	}
+	data = data[n:]
+	if d.mlTable, n, err = readSequenceTable(data, modes>>2&3, matchLengthTable, d.mlTable, maxMatchLengthSymbol, maxMatchLengthLog); err != nil {
+		return nil, err
+	}
+	data = data[n:]
+
+	br, err := newBackwardReader(data)
+	if err != nil {
+		return nil, err
+	}
+	var ll, of, ml fseState
+	ll.init(br, d.llTable)
+	of.init(br, d.ofTable)
+	ml.init(br, d.mlTable)
+
+	seqs := make([]sequence, count)
+	for i := range seqs {
+		ofCode := of.symbol()
+		mlCode := ml.symbol()
+		llCode := ll.symbol()
+		if ofCode > maxOffsetSymbol || mlCode > maxMatchLengthSymbol || llCode > maxLiteralsLengthSymbol {
+			return nil, errCorrupt
+		}
+
+		offsetValue := uint32(1)<<ofCode + uint32(br.read(int(ofCode)))
+		mc := matchLengthCodes[mlCode]
+		lc := literalsLengthCodes[llCode]
+		seq := sequence{
+			match:    mc.baseline + uint32(br.read(int(mc.bits))),
+			literals: lc.baseline + uint32(br.read(int(lc.bits))),
+		}
+		seq.offset = d.resolveOffset(offsetValue, seq.literals)
+		if seq.offset == 0 {
+			return nil, errCorrupt
+		}
+		seqs[i] = seq
+
+		if i < len(seqs)-1 {
+			ll.update(br)
+			ml.update(br)
+			of.update(br)
+		}
+		if br.overrun() {
+			return nil, errCorrupt
+		}
+	}
+	if !br.done() {
+		return nil, errCorrupt
+	}
+	return seqs, nil
+}
+
+// resolveOffset turns an offset value into a match offset, applying and
+// updating the repeat offsets.
+func (d *frameDecoder) resolveOffset(value, literals uint32) uint32 {
+	if value > 3 {
+		offset := value - 3
+		d.rep = [3]uint32{offset, d.rep[0], d.rep[1]}
+		return offset
+	}
+
+	index := value - 1
+	if literals == 0 {
+		index++
+	}
+	switch index {
+	case 0:
+		return d.rep[0]
+	case 1:
+		d.rep[0], d.rep[1] = d.rep[1], d.rep[0]
+		return d.rep[0]
+	case 2:
+		d.rep = [3]uint32{d.rep[2], d.rep[0], d.rep[1]}
+		return d.rep[0]
+	default: // the first repeat offset minus one
+		offset := d.rep[0] - 1
+		d.rep = [3]uint32{offset, d.rep[0], d.rep[1]}
+		return offset
+	}
+}
@@ -0,0 +1,101 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// xxhash64 is a streaming XXH64 hash with seed 0, used for frame checksums.
+type xxhash64 struct {
+	v     [4]uint64
+	buf   [32]byte
+	n     int // bytes in buf
+	total uint64
+}
+
+const (
+	prime1 uint64 = 11400714785074694791
+	prime2 uint64 = 14029467366897019727
+	prime3 uint64 = 1609587929392839161
+	prime4 uint64 = 9650029242287828579
+	prime5 uint64 = 2870177450012600261
+)
+
+func newXXHash64() *xxhash64 {
+	p1, p2 := prime1, prime2
+	return &xxhash64{v: [4]uint64{p1 + p2, p2, 0, -p1}}
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * prime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * prime1
+}
+
+func xxMerge(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*prime1 + prime4
+}
+
+func (h *xxhash64) Write(p []byte) (int, error) {
+	n := len(p)
+	h.total += uint64(n)
+	if h.n > 0 {
+		c := copy(h.buf[h.n:], p)
+		h.n += c
+		p = p[c:]
+		if h.n < 32 {
+			return n, nil
+		}
+		h.block(h.buf[:])
+		h.n = 0
+	}
+	for len(p) >= 32 {
+		h.block(p[:32])
+		p = p[32:]
+	}
+	h.n = copy(h.buf[:], p)
+	return n, nil
+}
+
+func (h *xxhash64) block(b []byte) {
+	for i := range h.v {
+		h.v[i] = xxRound(h.v[i], binary.LittleEndian.Uint64(b[8*i:]))
+	}
+}
+
+func (h *xxhash64) Sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) +
+			bits.RotateLeft64(h.v[2], 12) + bits.RotateLeft64(h.v[3], 18)
+		for _, v := range h.v {
+			acc = xxMerge(acc, v)
+		}
+	} else {
+		acc = prime5
+	}
+	acc += h.total
+
+	b := h.buf[:h.n]
+	for ; len(b) >= 8; b = b[8:] {
+		acc ^= xxRound(0, binary.LittleEndian.Uint64(b))
+		acc = bits.RotateLeft64(acc, 27)*prime1 + prime4
+	}
+	if len(b) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(b)) * prime1
+		acc = bits.RotateLeft64(acc, 23)*prime2 + prime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		acc ^= uint64(c) * prime5
+		acc = bits.RotateLeft64(acc, 11) * prime1
+	}
+
+	acc ^= acc >> 33
+	acc *= prime2
+	acc ^= acc >> 29
+	acc *= prime3
+	acc ^= acc >> 32
+	return acc
+}
@@ -0,0 +1,308 @@
+// Package zstd implements a decoder for the Zstandard compression format
+// (RFC 8878), for the Content-Encoding "zstd".
+//
+// Dictionaries are not supported, and frames must fit in a window of at
+// most MaxWindowSize bytes.
+package zstd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxWindowSize is the largest window a frame may require: 8 MiB, the limit
+// RFC 8878 recommends for HTTP.
+const MaxWindowSize = 8 << 20
+
+const (
+	frameMagic         = 0xFD2FB528
+	skippableMagicMask = 0xFFFFFFF0
+	skippableMagic     = 0x184D2A50
+	maxBlockSize       = 128 << 10
+)
+
+var errCorrupt = errors.New("zstd: corrupt input")
+
+// Reader decompresses a Zstandard stream of one or more frames.
+type Reader struct {
+	r     *bufio.Reader
+	frame *frameDecoder
+	out   []byte // decoded data not yet returned
+	err   error
+}
+
+// NewReader returns a Reader that decompresses r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+func (z *Reader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.out, z.err = z.next()
+	}
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+	return n, nil
+}
+
+// next decodes the next block, starting a new frame when needed.
+func (z *Reader) next() ([]byte, error) {
+	if z.frame == nil {
+		var magic [4]byte
+		if _, err := io.ReadFull(z.r, magic[:]); err != nil {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, io.ErrUnexpectedEOF
+		}
+		switch m := binary.LittleEndian.Uint32(magic[:]); {
+		case m == frameMagic:
+			f, err := readFrameHeader(z.r)
+			if err != nil {
+				return nil, err
+			}
+			z.frame = f
+		case m&skippableMagicMask == skippableMagic:
+			return nil, skipFrame(z.r)
+		default:
+			return nil, errors.New("zstd: invalid frame magic number")
+		}
+	}
+
+	out, last, err := z.frame.decodeBlock(z.r)
+	if err != nil {
+		return nil, err
+	}
+	if last {
+		if err := z.frame.finish(z.r); err != nil {
+			return nil, err
+		}
+		z.frame = nil
+	}
+	return out, nil
+}
+
+func skipFrame(r *bufio.Reader) error {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	_, err := r.Discard(int(binary.LittleEndian.Uint32(size[:])))
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// frameDecoder holds the state carried between the blocks of a frame.
+type frameDecoder struct {
+	windowSize  int
+	contentSize int64 // -1 if unknown
+	checksum    *xxhash64
+	written     int64
+
+	history []byte // the most recent output, at least windowSize bytes when available
+	rep     [3]uint32
+
+	huffman                   *huffmanTable
+	llTable, ofTable, mlTable *fseTable
+}
+
+func readFrameHeader(r *bufio.Reader) (*frameDecoder, error) {
+	descriptor, err := r.ReadByte()
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	fcsFlag := descriptor >> 6
+	singleSegment := descriptor&0x20 != 0
+	if descriptor&0x08 != 0 {
+		return nil, errCorrupt
+	}
+
+	f := &frameDecoder{rep: [3]uint32{1, 4, 8}, contentSize: -1}
+	if descriptor&0x04 != 0 {
+		f.checksum = newXXHash64()
+	}
+
+	var windowSize uint64
+	if !singleSegment {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		windowLog := 10 + uint(b>>3)
+		base := uint64(1) << windowLog
+		windowSize = base + base/8*uint64(b&7)
+	}
+
+	if dictIDSize := [4]int{0, 1, 2, 4}[descriptor&3]; dictIDSize > 0 {
+		var id [4]byte
+		if _, err := io.ReadFull(r, id[:dictIDSize]); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if binary.LittleEndian.Uint32(id[:]) != 0 {
+			return nil, errors.New("zstd: dictionaries are not supported")
+		}
+	}
+
+	fcsSize := [4]int{0, 2, 4, 8}[fcsFlag]
+	if fcsFlag == 0 && singleSegment {
+		fcsSize = 1
+	}
+	if fcsSize > 0 {
+		var b [8]byte
+		if _, err := io.ReadFull(r, b[:fcsSize]); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		size := binary.LittleEndian.Uint64(b[:])
+		if fcsSize == 2 {
+			size += 256
+		}
+		if size > 1<<62 {
+			return nil, errCorrupt
+		}
+		f.contentSize = int64(size)
+		if singleSegment {
+			windowSize = size
+		}
+	}
+
+	if windowSize > MaxWindowSize {
+		return nil, fmt.Errorf("zstd: window size %d exceeds the maximum of %d", windowSize, MaxWindowSize)
+	}
+	f.windowSize = int(windowSize)
+	return f, nil
+}
+
+// decodeBlock decodes the next block of the frame.
+func (f *frameDecoder) decodeBlock(r *bufio.Reader) (out []byte, last bool, err error) {
+	var header [3]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, false, io.ErrUnexpectedEOF
+	}
+	h := uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16
+	last = h&1 != 0
+	blockType := h >> 1 & 3
+	size := int(h >> 3)
+
+	maxSize := min(max(f.windowSize, 1), maxBlockSize)
+	switch blockType {
+	case 0: // Raw
+		if size > maxSize {
+			return nil, false, errCorrupt
+		}
+		out = make([]byte, size)
+		if _, err := io.ReadFull(r, out); err != nil {
+			return nil, false, io.ErrUnexpectedEOF
+		}
+	case 1: // RLE
+		if size > maxSize {
+			return nil, false, errCorrupt
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, false, io.ErrUnexpectedEOF
+		}
+		out = make([]byte, size)
+		for i := range out {
+			out[i] = b
+		}
+	case 2: // Compressed
+		if size > maxSize {
+			return nil, false, errCorrupt
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, false, io.ErrUnexpectedEOF
+		}
+		if out, err = f.decompressBlock(data, maxSize); err != nil {
+			return nil, false, err
+		}
+	default:
+		return nil, false, errCorrupt
+	}
+
+	f.remember(out)
+	f.written += int64(len(out))
+	if f.checksum != nil {
+		f.checksum.Write(out)
+	}
+	return out, last, nil
+}
+
+// remember appends out to the history, keeping at least a window of data.
+func (f *frameDecoder) remember(out []byte) {
+	f.history = append(f.history, out...)
+	if excess := len(f.history) - f.windowSize; excess > f.windowSize+maxBlockSize {
+		f.history = append(f.history[:0], f.history[excess:]...)
+	}
+}
+
+// finish checks the end of the frame: its size and checksum.
+func (f *frameDecoder) finish(r *bufio.Reader) error {
+	if f.contentSize >= 0 && f.written != f.contentSize {
+		return errCorrupt
+	}
+	if f.checksum == nil {
+		return nil
+	}
+	var sum [4]byte
+	if _, err := io.ReadFull(r, sum[:]); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if binary.LittleEndian.Uint32(sum[:]) != uint32(f.checksum.Sum64()) {
+		return errors.New("zstd: checksum mismatch")
+	}
+	return nil
+}
+
+// decompressBlock decodes a compressed block.
+func (f *frameDecoder) decompressBlock(data []byte, maxSize int) ([]byte, error) {
+	literals, n, err := f.decodeLiterals(data, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	seqs, err := f.decodeSequences(data[n:])
+	if err != nil {
+		return nil, err
+	}
+	return f.execute(literals, seqs, maxSize)
+}
+
+// execute applies the sequences to the literals and history.
+func (f *frameDecoder) execute(literals []byte, seqs []sequence, maxSize int) ([]byte, error) {
+	out := make([]byte, 0, maxSize)
+	for _, seq := range seqs {
+		if int(seq.literals) > len(literals) {
+			return nil, errCorrupt
+		}
+		out = append(out, literals[:seq.literals]...)
+		literals = literals[seq.literals:]
+
+		offset := int(seq.offset)
+		if offset > len(f.history)+len(out) || offset > f.windowSize || len(out)+int(seq.match) > maxSize {
+			return nil, errCorrupt
+		}
+		match := int(seq.match)
+		if offset > len(out) {
+			// The match starts in the output of earlier blocks.
+			start := len(f.history) - (offset - len(out))
+			n := min(match, len(f.history)-start)
+			out = append(out, f.history[start:start+n]...)
+			match -= n
+		}
+		for ; match > 0; match-- {
+			out = append(out, out[len(out)-offset])
+		}
+	}
+	if len(out)+len(literals) > maxSize {
+		return nil, errCorrupt
+	}
+	return append(out, literals...), nil
+}
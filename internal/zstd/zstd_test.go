@@ -0,0 +1,97 @@
+package zstd_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve/internal/zstd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Fixtures were made with the reference zstd command line tool. Outputs
+// are identified by length and SHA-256.
+var fixtures = []struct {
+	name   string
+	length int
+	sha256 string
+}{
+	{"empty.zst", 0, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	{"hello.zst", 13, "853ff93762a06ddbf722c4ebe9ddd66d8f63ddaea97f521c3ecc20da7c976020"},
+	{"hello-nocheck.zst", 13, "853ff93762a06ddbf722c4ebe9ddd66d8f63ddaea97f521c3ecc20da7c976020"},
+	{"random.zst", 8192, "d90a5a9e01e537bb02359ce3937f63c80825f1e593eb5f79c09f7bed34f988ac"},
+	{"rle.zst", 204800, "4b4f0f46ac02d177dea0ab36a66a657840e2fb98b20bb27a688db4d8ea9cd22c"},
+	{"text.zst", 163840, "2100022f1f8650170c92a3fed3e7c220b3f0a2a324dc8710782824f1b6557c58"},
+	{"text-fast.zst", 163840, "2100022f1f8650170c92a3fed3e7c220b3f0a2a324dc8710782824f1b6557c58"},
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestReader(t *testing.T) {
+	for _, f := range fixtures {
+		t.Run(f.name, func(t *testing.T) {
+			got, err := io.ReadAll(zstd.NewReader(bytes.NewReader(readFixture(t, f.name))))
+			assert.NoError(t, err)
+			assert.Len(t, got, f.length)
+			sum := sha256.Sum256(got)
+			assert.Equal(t, f.sha256, hex.EncodeToString(sum[:]))
+		})
+	}
+}
+
+func TestReaderMultipleFrames(t *testing.T) {
+	// hello.zst, a skippable frame, then rle.zst.
+	got, err := io.ReadAll(zstd.NewReader(bytes.NewReader(readFixture(t, "frames.zst"))))
+	assert.NoError(t, err)
+	assert.Len(t, got, 13+204800)
+	assert.Equal(t, "hello, world\n", string(got[:13]))
+	assert.Equal(t, bytes.Repeat([]byte("a"), 204800), got[13:])
+}
+
+func TestReaderTruncated(t *testing.T) {
+	data := readFixture(t, "text.zst")
+	for _, n := range []int{3, 10, len(data) / 2, len(data) - 1} {
+		_, err := io.ReadAll(zstd.NewReader(bytes.NewReader(data[:n])))
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "truncated to %d bytes", n)
+	}
+}
+
+func TestReaderChecksumMismatch(t *testing.T) {
+	data := readFixture(t, "hello.zst")
+	data[len(data)-1] ^= 0xff
+	_, err := io.ReadAll(zstd.NewReader(bytes.NewReader(data)))
+	assert.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestReaderCorrupt(t *testing.T) {
+	data := readFixture(t, "text.zst")
+	for i := 20; i < len(data); i += 97 {
+		corrupt := bytes.Clone(data)
+		corrupt[i] ^= 0x55
+		// Damaged input must fail cleanly or decode to something else,
+		// never panic.
+		io.Copy(io.Discard, zstd.NewReader(bytes.NewReader(corrupt)))
+	}
+
+	_, err := io.ReadAll(zstd.NewReader(bytes.NewReader([]byte("not zstd"))))
+	assert.ErrorContains(t, err, "invalid frame magic number")
+}
+
+func TestReaderWindowTooLarge(t *testing.T) {
+	// A frame header asking for a 1 GiB window, which is over the limit.
+	frame := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0xa0}
+	_, err := io.ReadAll(zstd.NewReader(bytes.NewReader(frame)))
+	assert.ErrorContains(t, err, "exceeds the maximum")
+}
@@ -0,0 +1,42 @@
+package retrieve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ExecJSON executes the request and decodes the JSON response body into v.
+//
+// Unless an Accept header is set, it asks for "application/json". The same
+// status checks and size limit as ExecBytes apply.
+func (b *Builder) ExecJSON(v any) error {
+	if b.err != nil {
+		return b.err
+	}
+
+	if !b.hasHeader("Accept") {
+		b.headers["Accept"] = "application/json"
+		defer delete(b.headers, "Accept")
+	}
+
+	data, err := b.ExecBytes()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to decode JSON response: %w", err)
+	}
+	return nil
+}
+
+// hasHeader reports whether a request header named key has been set.
+func (b *Builder) hasHeader(key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	for k := range b.headers {
+		if http.CanonicalHeaderKey(k) == key {
+			return true
+		}
+	}
+	return false
+}
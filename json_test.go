@@ -0,0 +1,72 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecJSON(t *testing.T) {
+	var accept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"retrieve","stars":42}`))
+	}))
+	defer server.Close()
+
+	var v struct {
+		Name  string `json:"name"`
+		Stars int    `json:"stars"`
+	}
+	b := retrieve.New(server.URL)
+	err := b.ExecJSON(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, "retrieve", v.Name)
+	assert.Equal(t, 42, v.Stars)
+	assert.Equal(t, "application/json", accept)
+	assert.Empty(t, b.GetHeaders())
+}
+
+func TestExecJSON_KeepsAccept(t *testing.T) {
+	var accept string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	var v []int
+	err := retrieve.New(server.URL).SetHeader("accept", "application/vnd.api+json").ExecJSON(&v)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/vnd.api+json", accept)
+}
+
+func TestExecJSON_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	}))
+	defer server.Close()
+
+	var v map[string]string
+	err := retrieve.New(server.URL).ExecJSON(&v)
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Nil(t, v)
+}
+
+func TestExecJSON_Invalid(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`not json`))
+	}))
+	defer server.Close()
+
+	var v map[string]string
+	err := retrieve.New(server.URL).ExecJSON(&v)
+	assert.ErrorContains(t, err, "decode JSON")
+}
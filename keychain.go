@@ -0,0 +1,41 @@
+package retrieve
+
+import (
+	"fmt"
+
+	"github.com/ciathefed/retrieve/keychain"
+)
+
+// SetBearerTokenFrom authenticates the request with a bearer token read
+// from store for service and account, such as a token saved by an earlier
+// run with keychain.New().Set.
+//
+// The token is read each time Exec runs, so a token refreshed in the store
+// is picked up without rebuilding the request.
+func (b *Builder) SetBearerTokenFrom(store keychain.Store, service, account string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.tokenStore = &storedToken{store: store, service: service, account: account}
+	return b
+}
+
+// storedToken identifies a token kept in a keychain.Store.
+type storedToken struct {
+	store   keychain.Store
+	service string
+	account string
+}
+
+// applyStoredToken sets the Authorization header from the token store, if any.
+func (b *Builder) applyStoredToken() error {
+	if b.tokenStore == nil {
+		return nil
+	}
+	token, err := b.tokenStore.store.Get(b.tokenStore.service, b.tokenStore.account)
+	if err != nil {
+		return fmt.Errorf("failed to read token for %s: %w", b.tokenStore.service, err)
+	}
+	b.headers["Authorization"] = "Bearer " + token
+	return nil
+}
@@ -0,0 +1,76 @@
+// Package keychain stores secrets such as API tokens in the operating
+// system's credential store, so tools built on retrieve can persist them
+// securely between runs.
+//
+// On macOS secrets are kept in the login Keychain, on Windows in the
+// Credential Manager, and on Linux and the BSDs in the Secret Service
+// (GNOME Keyring, KWallet) through libsecret's secret-tool.
+package keychain
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned when no secret is stored for a service and account.
+var ErrNotFound = errors.New("keychain: secret not found")
+
+// ErrUnsupported is returned when the platform has no supported credential store.
+var ErrUnsupported = errors.New("keychain: not supported on this platform")
+
+// Store reads and writes secrets identified by a service and an account name.
+type Store interface {
+	// Get returns the secret for service and account, or ErrNotFound.
+	Get(service, account string) (string, error)
+
+	// Set stores secret for service and account, replacing any existing secret.
+	Set(service, account, secret string) error
+
+	// Delete removes the secret for service and account, or returns ErrNotFound.
+	Delete(service, account string) error
+}
+
+// New returns the credential store of the operating system. Its methods
+// return ErrUnsupported if the platform has none.
+func New() Store {
+	return systemStore{}
+}
+
+// NewMemory returns a Store that keeps secrets in memory, for tests and
+// platforms without a credential store.
+func NewMemory() Store {
+	return &memoryStore{secrets: make(map[[2]string]string)}
+}
+
+type memoryStore struct {
+	mu      sync.Mutex
+	secrets map[[2]string]string
+}
+
+func (m *memoryStore) Get(service, account string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	secret, ok := m.secrets[[2]string{service, account}]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (m *memoryStore) Set(service, account, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.secrets[[2]string{service, account}] = secret
+	return nil
+}
+
+func (m *memoryStore) Delete(service, account string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := [2]string{service, account}
+	if _, ok := m.secrets[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.secrets, key)
+	return nil
+}
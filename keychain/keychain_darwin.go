@@ -0,0 +1,62 @@
+package keychain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const securityCmd = "/usr/bin/security"
+
+// errItemNotFound is the exit status of security(1) when no item matches.
+const errItemNotFound = 44
+
+// systemStore keeps secrets as generic passwords in the login Keychain.
+type systemStore struct{}
+
+func (systemStore) Get(service, account string) (string, error) {
+	out, err := exec.Command(securityCmd, "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		return "", securityError(err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (systemStore) Set(service, account, secret string) error {
+	// The secret is passed on stdin in interactive mode, hex-encoded, so it
+	// never appears in the process list.
+	cmd := exec.Command(securityCmd, "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n",
+		quote(service), quote(account), hex.EncodeToString([]byte(secret))))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("keychain: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (systemStore) Delete(service, account string) error {
+	err := exec.Command(securityCmd, "delete-generic-password", "-s", service, "-a", account).Run()
+	return securityError(err)
+}
+
+// securityError translates a failure of security(1).
+func securityError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == errItemNotFound {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("keychain: %w", err)
+	}
+	return nil
+}
+
+// quote quotes s for the command parser of security(1) in interactive mode.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
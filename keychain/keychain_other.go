@@ -0,0 +1,18 @@
+//go:build !darwin && !windows && !linux && !freebsd && !openbsd && !netbsd && !dragonfly
+
+package keychain
+
+// systemStore reports that the platform has no supported credential store.
+type systemStore struct{}
+
+func (systemStore) Get(service, account string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (systemStore) Set(service, account, secret string) error {
+	return ErrUnsupported
+}
+
+func (systemStore) Delete(service, account string) error {
+	return ErrUnsupported
+}
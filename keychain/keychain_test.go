@@ -0,0 +1,31 @@
+package keychain_test
+
+import (
+	"testing"
+
+	"github.com/ciathefed/retrieve/keychain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory(t *testing.T) {
+	store := keychain.NewMemory()
+
+	_, err := store.Get("example.com", "alice")
+	assert.ErrorIs(t, err, keychain.ErrNotFound)
+
+	assert.NoError(t, store.Set("example.com", "alice", "token-1"))
+	assert.NoError(t, store.Set("example.com", "alice", "token-2"))
+	assert.NoError(t, store.Set("example.com", "bob", "token-3"))
+
+	secret, err := store.Get("example.com", "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, "token-2", secret)
+
+	assert.NoError(t, store.Delete("example.com", "alice"))
+	assert.ErrorIs(t, store.Delete("example.com", "alice"), keychain.ErrNotFound)
+
+	secret, err = store.Get("example.com", "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, "token-3", secret)
+}
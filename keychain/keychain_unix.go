@@ -0,0 +1,68 @@
+//go:build linux || freebsd || openbsd || netbsd || dragonfly
+
+package keychain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const secretToolCmd = "secret-tool"
+
+// systemStore keeps secrets in the Secret Service through secret-tool(1).
+type systemStore struct{}
+
+func (systemStore) Get(service, account string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(secretToolCmd, "lookup", "service", service, "account", account)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// secret-tool exits with status 1 and no output when nothing matches.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stdout.Len() == 0 && stderr.Len() == 0 {
+			return "", ErrNotFound
+		}
+		return "", secretToolError(err, &stderr)
+	}
+	return stdout.String(), nil
+}
+
+func (systemStore) Set(service, account, secret string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(secretToolCmd, "store", "--label="+service+" ("+account+")", "service", service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return secretToolError(err, &stderr)
+	}
+	return nil
+}
+
+func (s systemStore) Delete(service, account string) error {
+	// secret-tool clear succeeds even if nothing matches.
+	if _, err := s.Get(service, account); err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(secretToolCmd, "clear", "service", service, "account", account)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return secretToolError(err, &stderr)
+	}
+	return nil
+}
+
+// secretToolError translates a failure of secret-tool(1).
+func secretToolError(err error, stderr *bytes.Buffer) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%w: %s is not installed", ErrUnsupported, secretToolCmd)
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("keychain: %w: %s", err, msg)
+	}
+	return fmt.Errorf("keychain: %w", err)
+}
@@ -0,0 +1,36 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+	"github.com/ciathefed/retrieve/keychain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetBearerTokenFrom(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	store := keychain.NewMemory()
+	b := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out")).
+		SetBearerTokenFrom(store, "example", "alice")
+
+	assert.ErrorIs(t, b.Exec(), keychain.ErrNotFound)
+
+	assert.NoError(t, store.Set("example", "alice", "token-1"))
+	assert.NoError(t, b.Exec())
+	assert.Equal(t, "Bearer token-1", auth)
+
+	assert.NoError(t, store.Set("example", "alice", "token-2"))
+	assert.NoError(t, b.Exec())
+	assert.Equal(t, "Bearer token-2", auth)
+}
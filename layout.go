@@ -0,0 +1,61 @@
+package retrieve
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// OutputByHost saves the file under baseDir in a directory named after the
+// host of the URL, at the URL's path, as mirroring tools do:
+//
+//	New("https://example.com/pub/v1/app.tar.gz").OutputByHost("mirror")
+//
+// saves mirror/example.com/pub/v1/app.tar.gz. A non-default port is added to
+// the directory name, as in "example.com_8080". The URL is the one given,
+// before rewrite rules, Resolvers, mirrors and redirects. If the path ends
+// with a slash, the file name is taken from the response. Missing
+// directories are created, as with CreateDirs.
+//
+// SetOutput replaces the output set by OutputByHost.
+func (b *Builder) OutputByHost(baseDir string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if baseDir == "" {
+		b.err = fmt.Errorf("invalid base directory: %q", baseDir)
+		return b
+	}
+	b.outputByHost = baseDir
+	b.createDirs = true
+	return b
+}
+
+// GetOutputByHost returns the base directory set with OutputByHost, if any.
+func (b *Builder) GetOutputByHost() string {
+	return b.outputByHost
+}
+
+// hostOutputPath returns the output path of rawURL under baseDir, ending
+// with a path separator if the file name is to be taken from the response.
+func hostOutputPath(baseDir, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return "", fmt.Errorf("OutputByHost requires a URL with a host: %s", rawURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != defaultPort(u.Scheme) {
+		host += "_" + port
+	}
+	host = strings.ReplaceAll(host, ":", "_")
+
+	// Cleaning the rooted path drops any ".." that would leave the host's directory.
+	p := path.Clean("/" + u.Path)
+	output := filepath.Join(baseDir, host, filepath.FromSlash(p))
+	if p == "/" || strings.HasSuffix(u.Path, "/") {
+		output += string(filepath.Separator)
+	}
+	return output, nil
+}
@@ -0,0 +1,68 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputByHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	host := strings.ReplaceAll(server.Listener.Addr().String(), ":", "_")
+
+	b := retrieve.New(server.URL + "/pub/v1/app.tar.gz?token=abc").OutputByHost(dir)
+	assert.Equal(t, dir, b.GetOutputByHost())
+	result, err := b.ExecWithResult()
+	assert.NoError(t, err)
+	expected := filepath.Join(dir, host, "pub", "v1", "app.tar.gz")
+	assert.Equal(t, expected, result.Output)
+	data, err := os.ReadFile(expected)
+	assert.NoError(t, err)
+	assert.Equal(t, "/pub/v1/app.tar.gz", string(data))
+}
+
+func TestOutputByHost_Directory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="index.json"`)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	host := strings.ReplaceAll(server.Listener.Addr().String(), ":", "_")
+
+	result, err := retrieve.New(server.URL + "/simple/").OutputByHost(dir).ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, host, "simple", "index.json"), result.Output)
+}
+
+func TestOutputByHost_StaysInBaseDir(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	host := strings.ReplaceAll(server.Listener.Addr().String(), ":", "_")
+
+	result, err := retrieve.New(server.URL + "/a/%2e%2e/%2e%2e/%2e%2e/escape.txt").OutputByHost(dir).ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, host, "escape.txt"), result.Output)
+}
+
+func TestOutputByHost_Invalid(t *testing.T) {
+	assert.ErrorContains(t, retrieve.New("http://example.com/file").OutputByHost("").Exec(), "invalid base directory")
+	assert.ErrorContains(t, retrieve.New("data:,hello").OutputByHost(t.TempDir()).Exec(), "OutputByHost requires a URL with a host")
+
+	b := retrieve.New("http://example.com/file").OutputByHost(t.TempDir()).SetOutput("file")
+	assert.Empty(t, b.GetOutputByHost())
+}
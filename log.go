@@ -0,0 +1,72 @@
+package retrieve
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// SetLogger logs the progress of the request to logger at debug level: each
+// request sent, redirect followed and retry, and the outcome of Exec with
+// the method, URL, status code, bytes written and duration. Passwords in
+// URLs are redacted.
+func (b *Builder) SetLogger(logger *slog.Logger) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.logger = logger
+	return b
+}
+
+// GetLogger returns the logger set with SetLogger, if any.
+func (b *Builder) GetLogger() *slog.Logger {
+	return b.logger
+}
+
+// logDebug logs msg at debug level if a logger is set.
+func (b *Builder) logDebug(msg string, args ...any) {
+	if b.logger != nil {
+		b.logger.DebugContext(b.ctx, msg, args...)
+	}
+}
+
+// logRequest logs a request about to be sent.
+func (b *Builder) logRequest(req *http.Request) {
+	args := []any{"method", req.Method, "url", req.URL.Redacted(), "attempt", b.progress.Attempt}
+	if r := req.Header.Get("Range"); r != "" {
+		args = append(args, "range", r)
+	}
+	b.logDebug("sending request", args...)
+}
+
+// logRedirect logs a redirect about to be followed.
+func (b *Builder) logRedirect(req *http.Request, via []*http.Request) {
+	args := []any{"from", via[len(via)-1].URL.Redacted(), "to", req.URL.Redacted()}
+	if req.Response != nil {
+		args = append(args, "status", req.Response.StatusCode)
+	}
+	b.logDebug("following redirect", args...)
+}
+
+// logDone logs the outcome of Exec.
+func (b *Builder) logDone(err error, elapsed time.Duration) {
+	if b.logger == nil {
+		return
+	}
+	rawURL := b.result.URL
+	if rawURL == "" {
+		rawURL = b.url
+	}
+	args := []any{
+		"method", b.method,
+		"url", redactURL(rawURL),
+		"status", b.result.StatusCode,
+		"bytes", b.progress.BytesWritten,
+		"duration", elapsed,
+	}
+	if err != nil {
+		b.logDebug("request failed", append(args, "error", err)...)
+		return
+	}
+	b.logDebug("request completed", args...)
+}
@@ -0,0 +1,96 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readLogRecords(t *testing.T, log *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func newTestLogger(log *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(log, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestSetLogger(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	var log bytes.Buffer
+	logger := newTestLogger(&log)
+	b := retrieve.New(server.URL+"/old").SetLogger(logger).SetRetries(1).SetRetryBackoff(0, 0)
+	assert.Same(t, logger, b.GetLogger())
+	_, err := b.ExecBytes()
+	assert.NoError(t, err)
+
+	var messages []string
+	for _, record := range readLogRecords(t, &log) {
+		assert.Equal(t, "DEBUG", record["level"])
+		messages = append(messages, record["msg"].(string))
+	}
+	assert.Equal(t, []string{
+		"sending request", "following redirect",
+		"retrying request",
+		"sending request", "following redirect",
+		"request completed",
+	}, messages)
+
+	records := readLogRecords(t, &log)
+	assert.Equal(t, server.URL+"/old", records[1]["from"])
+	assert.Equal(t, server.URL+"/new", records[1]["to"])
+	assert.EqualValues(t, http.StatusMovedPermanently, records[1]["status"])
+
+	done := records[len(records)-1]
+	assert.Equal(t, http.MethodGet, done["method"])
+	assert.Equal(t, server.URL+"/new", done["url"])
+	assert.EqualValues(t, http.StatusOK, done["status"])
+	assert.EqualValues(t, len("content"), done["bytes"])
+	assert.Contains(t, done, "duration")
+}
+
+func TestSetLogger_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	var log bytes.Buffer
+	u := strings.Replace(server.URL, "http://", "http://user:secret@", 1) + "/missing"
+	_, err := retrieve.New(u).SetLogger(newTestLogger(&log)).ExecBytes()
+	assert.Error(t, err)
+
+	records := readLogRecords(t, &log)
+	done := records[len(records)-1]
+	assert.Equal(t, "request failed", done["msg"])
+	assert.EqualValues(t, http.StatusNotFound, done["status"])
+	assert.Contains(t, done["error"], "404")
+	assert.NotContains(t, log.String(), "secret")
+}
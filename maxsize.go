@@ -0,0 +1,70 @@
+package retrieve
+
+import (
+	"fmt"
+	"io"
+)
+
+// SetMaxSize limits the download to n bytes, for services fetching URLs
+// supplied by users. A response whose Content-Length exceeds the limit
+// fails before anything is written, and one streaming past it is aborted;
+// either way Exec fails with ErrTooLarge, which is not retried, and
+// removes the output file. The limit applies to the decoded content.
+//
+// ExecBytes and ExecString use the limit instead of their default.
+func (b *Builder) SetMaxSize(n int64) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if n <= 0 {
+		b.err = fmt.Errorf("invalid maximum size: %d", n)
+		return b
+	}
+	b.maxSize = n
+	return b
+}
+
+// GetMaxSize returns the maximum size of the download in bytes, or 0 if
+// there is no limit.
+func (b *Builder) GetMaxSize() int64 {
+	return b.maxSize
+}
+
+// checkMaxSize fails if the declared size of the download exceeds the
+// maximum size. size is -1 if unknown.
+func (b *Builder) checkMaxSize(size int64) error {
+	if b.maxSize > 0 && size > b.maxSize {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d", ErrTooLarge, size, b.maxSize)
+	}
+	return nil
+}
+
+// limitSize returns a reader that fails once more than the maximum size,
+// less the offset already written, is read from r.
+func (b *Builder) limitSize(r io.Reader, offset int64) io.Reader {
+	if b.maxSize <= 0 {
+		return r
+	}
+	return &sizeLimitReader{r: r, remaining: b.maxSize - offset, max: b.maxSize}
+}
+
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int64
+	max       int64
+}
+
+func (lr *sizeLimitReader) Read(p []byte) (int, error) {
+	// Read one byte past the limit to tell a body ending at it from a longer one.
+	if int64(len(p)) > lr.remaining+1 {
+		p = p[:lr.remaining+1]
+	}
+	n, err := lr.r.Read(p)
+	if int64(n) > lr.remaining {
+		n = int(lr.remaining)
+		lr.remaining = 0
+		return n, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, lr.max)
+	}
+	lr.remaining -= int64(n)
+	return n, err
+}
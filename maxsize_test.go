@@ -0,0 +1,76 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMaxSize(t *testing.T) {
+	assert.Equal(t, int64(0), retrieve.New("http://example.com").GetMaxSize())
+	assert.Equal(t, int64(100), retrieve.New("http://example.com").SetMaxSize(100).GetMaxSize())
+
+	err := retrieve.New("http://example.com").SetMaxSize(0).Exec()
+	assert.ErrorContains(t, err, "invalid maximum size")
+
+	body := strings.Repeat("x", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "file")
+	err = retrieve.New(server.URL).SetOutput(output).SetMaxSize(100).Exec()
+	assert.NoError(t, err)
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, body, string(data))
+
+	output = filepath.Join(t.TempDir(), "file")
+	err = retrieve.New(server.URL).SetOutput(output).SetMaxSize(99).Exec()
+	assert.ErrorIs(t, err, retrieve.ErrTooLarge)
+	assert.NoFileExists(t, output)
+}
+
+func TestSetMaxSize_Streamed(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Without a Content-Length, the size is only known while streaming.
+		for range 10 {
+			w.Write([]byte(strings.Repeat("x", 1000)))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	for _, atomic := range []bool{true, false} {
+		output := filepath.Join(t.TempDir(), "file")
+		err := retrieve.New(server.URL).SetOutput(output).Atomic(atomic).SetRetries(2).SetMaxSize(2500).Exec()
+		assert.ErrorIs(t, err, retrieve.ErrTooLarge)
+		assert.NoFileExists(t, output)
+		assert.NoFileExists(t, output+".part")
+	}
+	assert.Equal(t, int32(2), requests.Load(), "not retried")
+}
+
+func TestSetMaxSize_ExecBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	_, err := retrieve.New(server.URL).SetMaxSize(5).ExecBytes()
+	assert.ErrorIs(t, err, retrieve.ErrTooLarge)
+
+	data, err := retrieve.New(server.URL).SetMaxSize(10).ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+}
@@ -0,0 +1,362 @@
+package media
+
+import (
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type mpd struct {
+	XMLName  xml.Name `xml:"MPD"`
+	Type     string   `xml:"type,attr"`
+	Duration string   `xml:"mediaPresentationDuration,attr"`
+	BaseURL  string   `xml:"BaseURL"`
+	Periods  []period `xml:"Period"`
+}
+
+type period struct {
+	Duration       string          `xml:"duration,attr"`
+	BaseURL        string          `xml:"BaseURL"`
+	AdaptationSets []adaptationSet `xml:"AdaptationSet"`
+}
+
+type adaptationSet struct {
+	MimeType        string           `xml:"mimeType,attr"`
+	Codecs          string           `xml:"codecs,attr"`
+	BaseURL         string           `xml:"BaseURL"`
+	SegmentTemplate *segmentTemplate `xml:"SegmentTemplate"`
+	SegmentList     *segmentList     `xml:"SegmentList"`
+	Representations []representation `xml:"Representation"`
+}
+
+type representation struct {
+	ID              string           `xml:"id,attr"`
+	Bandwidth       int64            `xml:"bandwidth,attr"`
+	Width           int              `xml:"width,attr"`
+	Height          int              `xml:"height,attr"`
+	MimeType        string           `xml:"mimeType,attr"`
+	Codecs          string           `xml:"codecs,attr"`
+	BaseURL         string           `xml:"BaseURL"`
+	SegmentTemplate *segmentTemplate `xml:"SegmentTemplate"`
+	SegmentList     *segmentList     `xml:"SegmentList"`
+}
+
+type segmentTemplate struct {
+	Media          string           `xml:"media,attr"`
+	Initialization string           `xml:"initialization,attr"`
+	StartNumber    *int64           `xml:"startNumber,attr"`
+	Duration       int64            `xml:"duration,attr"`
+	Timescale      int64            `xml:"timescale,attr"`
+	Timeline       *segmentTimeline `xml:"SegmentTimeline"`
+}
+
+type segmentTimeline struct {
+	S []struct {
+		T *int64 `xml:"t,attr"`
+		D int64  `xml:"d,attr"`
+		R int64  `xml:"r,attr"`
+	} `xml:"S"`
+}
+
+type segmentList struct {
+	Duration       int64 `xml:"duration,attr"`
+	Timescale      int64 `xml:"timescale,attr"`
+	Initialization *struct {
+		SourceURL string `xml:"sourceURL,attr"`
+	} `xml:"Initialization"`
+	SegmentURLs []struct {
+		Media string `xml:"media,attr"`
+	} `xml:"SegmentURL"`
+}
+
+// ParseDASH parses a DASH manifest (MPD), resolving URLs against base.
+//
+// Every Representation becomes a Variant with its segments listed in
+// Variant.Playlist. Audio and video are usually separate Representations,
+// so a complete download needs one of each. SegmentTemplate (with a
+// duration or a SegmentTimeline), SegmentList and single-file
+// Representations are supported; live and multi-period manifests are not.
+func ParseDASH(data []byte, base *url.URL) (*Playlist, error) {
+	var m mpd
+	if err := xml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("not a DASH manifest: %w", err)
+	}
+	if m.Type == "dynamic" {
+		return nil, errors.New("live DASH manifests are not supported")
+	}
+	if len(m.Periods) != 1 {
+		return nil, fmt.Errorf("DASH manifests with %d periods are not supported", len(m.Periods))
+	}
+	per := m.Periods[0]
+
+	total, err := parseDuration(per.Duration)
+	if err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		if total, err = parseDuration(m.Duration); err != nil {
+			return nil, err
+		}
+	}
+
+	periodBase, err := joinBase(base, m.BaseURL, per.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Playlist
+	for _, as := range per.AdaptationSets {
+		setBase, err := joinBase(periodBase, as.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		for _, rep := range as.Representations {
+			v, err := parseRepresentation(as, rep, setBase, total)
+			if err != nil {
+				return nil, fmt.Errorf("representation %q: %w", rep.ID, err)
+			}
+			p.Variants = append(p.Variants, v)
+		}
+	}
+	if len(p.Variants) == 0 {
+		return nil, errors.New("DASH manifest has no representations")
+	}
+	return &p, nil
+}
+
+func parseRepresentation(as adaptationSet, rep representation, base *url.URL, total time.Duration) (Variant, error) {
+	v := Variant{
+		ID:        rep.ID,
+		Bandwidth: rep.Bandwidth,
+		MimeType:  cmp.Or(rep.MimeType, as.MimeType),
+		Codecs:    cmp.Or(rep.Codecs, as.Codecs),
+	}
+	if rep.Width > 0 && rep.Height > 0 {
+		v.Resolution = fmt.Sprintf("%dx%d", rep.Width, rep.Height)
+	}
+
+	repBase, err := joinBase(base, rep.BaseURL)
+	if err != nil {
+		return v, err
+	}
+
+	var segments *Playlist
+	switch {
+	case rep.SegmentTemplate != nil || as.SegmentTemplate != nil:
+		tmpl := mergeTemplates(as.SegmentTemplate, rep.SegmentTemplate)
+		segments, err = templateSegments(tmpl, rep, repBase, total)
+	case rep.SegmentList != nil || as.SegmentList != nil:
+		list := rep.SegmentList
+		if list == nil {
+			list = as.SegmentList
+		}
+		segments, err = listSegments(list, repBase)
+	case rep.BaseURL != "":
+		segments = &Playlist{Segments: []Segment{{URL: repBase.String(), Duration: total}}}
+	default:
+		err = errors.New("no segment information")
+	}
+	v.Playlist = segments
+	return v, err
+}
+
+// mergeTemplates combines the adaptation set's template with the
+// representation's, whose attributes take precedence.
+func mergeTemplates(set, rep *segmentTemplate) segmentTemplate {
+	var t segmentTemplate
+	for _, src := range []*segmentTemplate{set, rep} {
+		if src == nil {
+			continue
+		}
+		t.Media = cmp.Or(src.Media, t.Media)
+		t.Initialization = cmp.Or(src.Initialization, t.Initialization)
+		if src.StartNumber != nil {
+			t.StartNumber = src.StartNumber
+		}
+		if src.Duration != 0 {
+			t.Duration = src.Duration
+		}
+		if src.Timescale != 0 {
+			t.Timescale = src.Timescale
+		}
+		if src.Timeline != nil {
+			t.Timeline = src.Timeline
+		}
+	}
+	return t
+}
+
+func templateSegments(t segmentTemplate, rep representation, base *url.URL, total time.Duration) (*Playlist, error) {
+	timescale := cmp.Or(t.Timescale, 1)
+	number := int64(1)
+	if t.StartNumber != nil {
+		number = *t.StartNumber
+	}
+
+	var p Playlist
+	if t.Initialization != "" {
+		uri, err := resolve(base, expandTemplate(t.Initialization, rep, 0, 0))
+		if err != nil {
+			return nil, err
+		}
+		p.Init = &Segment{URL: uri}
+	}
+
+	add := func(start, d int64) error {
+		uri, err := resolve(base, expandTemplate(t.Media, rep, number, start))
+		if err != nil {
+			return err
+		}
+		p.Segments = append(p.Segments, Segment{URL: uri, Duration: scale(d, timescale)})
+		number++
+		return nil
+	}
+
+	switch {
+	case t.Timeline != nil:
+		end := int64(math.Ceil(total.Seconds() * float64(timescale)))
+		var start int64
+		for i, s := range t.Timeline.S {
+			if s.T != nil {
+				start = *s.T
+			}
+			if s.D <= 0 {
+				return nil, errors.New("SegmentTimeline entry without a duration")
+			}
+			repeat := s.R
+			if repeat < 0 {
+				// Repeat until the next entry, or the end of the period.
+				limit := end
+				if i+1 < len(t.Timeline.S) && t.Timeline.S[i+1].T != nil {
+					limit = *t.Timeline.S[i+1].T
+				}
+				if limit <= start {
+					return nil, errors.New("open-ended SegmentTimeline without a period duration")
+				}
+				repeat = (limit-start+s.D-1)/s.D - 1
+			}
+			for range repeat + 1 {
+				if err := add(start, s.D); err != nil {
+					return nil, err
+				}
+				start += s.D
+			}
+		}
+
+	case t.Duration > 0:
+		if total <= 0 {
+			return nil, errors.New("SegmentTemplate without a timeline needs a presentation duration")
+		}
+		count := int64(math.Ceil(total.Seconds() * float64(timescale) / float64(t.Duration)))
+		for i := range count {
+			if err := add(i*t.Duration, t.Duration); err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		return nil, errors.New("SegmentTemplate without a duration or timeline")
+	}
+	return &p, nil
+}
+
+func listSegments(list *segmentList, base *url.URL) (*Playlist, error) {
+	var p Playlist
+	if list.Initialization != nil && list.Initialization.SourceURL != "" {
+		uri, err := resolve(base, list.Initialization.SourceURL)
+		if err != nil {
+			return nil, err
+		}
+		p.Init = &Segment{URL: uri}
+	}
+	d := scale(list.Duration, cmp.Or(list.Timescale, 1))
+	for _, su := range list.SegmentURLs {
+		uri, err := resolve(base, su.Media)
+		if err != nil {
+			return nil, err
+		}
+		p.Segments = append(p.Segments, Segment{URL: uri, Duration: d})
+	}
+	return &p, nil
+}
+
+var templateVar = regexp.MustCompile(`\$(RepresentationID|Number|Bandwidth|Time)(%0\d+d)?\$|\$\$`)
+
+// expandTemplate substitutes the identifiers of a SegmentTemplate URL.
+func expandTemplate(tmpl string, rep representation, number, time int64) string {
+	return templateVar.ReplaceAllStringFunc(tmpl, func(s string) string {
+		if s == "$$" {
+			return "$"
+		}
+		m := templateVar.FindStringSubmatch(s)
+		format := cmp.Or(m[2], "%d")
+		switch m[1] {
+		case "RepresentationID":
+			return rep.ID
+		case "Number":
+			return fmt.Sprintf(format, number)
+		case "Bandwidth":
+			return fmt.Sprintf(format, rep.Bandwidth)
+		default:
+			return fmt.Sprintf(format, time)
+		}
+	})
+}
+
+// joinBase resolves each non-empty BaseURL in turn against base.
+func joinBase(base *url.URL, refs ...string) (*url.URL, error) {
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+		u, err := url.Parse(ref)
+		if err != nil {
+			return nil, err
+		}
+		if base != nil {
+			u = base.ResolveReference(u)
+		}
+		base = u
+	}
+	if base == nil {
+		return &url.URL{}, nil
+	}
+	return base, nil
+}
+
+// durationPattern matches the ISO 8601 durations used in manifests, such
+// as "PT1H2M3.5S" or "P1DT2H".
+var durationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseDuration parses an ISO 8601 duration. An empty string is zero.
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	m := durationPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, _ := strconv.ParseFloat(m[i+1], 64)
+		d += time.Duration(n * float64(unit))
+	}
+	return d, nil
+}
+
+// scale converts d units of 1/timescale seconds to a time.Duration.
+func scale(d, timescale int64) time.Duration {
+	return time.Duration(float64(d) / float64(timescale) * float64(time.Second))
+}
@@ -0,0 +1,168 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseHLS parses an HLS (m3u8) playlist, resolving URIs against base.
+//
+// Master playlists yield Variants and media playlists yield Segments.
+// Byte-range segments are supported; encrypted segments are not.
+func ParseHLS(data []byte, base *url.URL) (*Playlist, error) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+
+	var (
+		p        Playlist
+		header   bool
+		variant  *Variant
+		duration time.Duration
+		byteRng  *Segment
+		lastURL  string
+		lastEnd  int64
+	)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if !header {
+			if line != "#EXTM3U" {
+				return nil, errors.New("not an HLS playlist: missing #EXTM3U")
+			}
+			header = true
+			continue
+		}
+
+		tag, value, _ := strings.Cut(line, ":")
+		switch {
+		case tag == "#EXT-X-STREAM-INF":
+			attrs := parseAttributes(value)
+			bandwidth, _ := strconv.ParseInt(attrs["BANDWIDTH"], 10, 64)
+			variant = &Variant{
+				Bandwidth:  bandwidth,
+				Resolution: attrs["RESOLUTION"],
+				Codecs:     attrs["CODECS"],
+			}
+
+		case tag == "#EXT-X-KEY":
+			if method := parseAttributes(value)["METHOD"]; method != "NONE" {
+				return nil, fmt.Errorf("%w: method %s", ErrEncrypted, method)
+			}
+
+		case tag == "#EXT-X-MAP":
+			attrs := parseAttributes(value)
+			uri, err := resolve(base, attrs["URI"])
+			if err != nil {
+				return nil, err
+			}
+			p.Init = &Segment{URL: uri}
+			if r, ok := attrs["BYTERANGE"]; ok {
+				if p.Init.Length, p.Init.Offset, err = parseByteRange(r, 0); err != nil {
+					return nil, err
+				}
+			}
+
+		case tag == "#EXTINF":
+			seconds, _, _ := strings.Cut(value, ",")
+			d, err := strconv.ParseFloat(seconds, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid #EXTINF duration %q", seconds)
+			}
+			duration = time.Duration(d * float64(time.Second))
+
+		case tag == "#EXT-X-BYTERANGE":
+			byteRng = &Segment{}
+			var err error
+			if byteRng.Length, byteRng.Offset, err = parseByteRange(value, -1); err != nil {
+				return nil, err
+			}
+
+		case strings.HasPrefix(line, "#"):
+			// Other tags do not affect which segments are downloaded.
+
+		default:
+			uri, err := resolve(base, line)
+			if err != nil {
+				return nil, err
+			}
+			if variant != nil {
+				variant.URL = uri
+				p.Variants = append(p.Variants, *variant)
+				variant = nil
+				continue
+			}
+
+			seg := Segment{URL: uri, Duration: duration}
+			if byteRng != nil {
+				seg.Offset, seg.Length = byteRng.Offset, byteRng.Length
+				if seg.Offset < 0 {
+					// Without an offset, the range follows the previous one.
+					if uri != lastURL {
+						return nil, fmt.Errorf("byte range without offset for %s", uri)
+					}
+					seg.Offset = lastEnd
+				}
+				lastURL, lastEnd = uri, seg.Offset+seg.Length
+			}
+			p.Segments = append(p.Segments, seg)
+			duration, byteRng = 0, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if !header {
+		return nil, errors.New("not an HLS playlist: missing #EXTM3U")
+	}
+	return &p, nil
+}
+
+// parseByteRange parses "length[@offset]", using offset def when it is omitted.
+func parseByteRange(s string, def int64) (length, offset int64, err error) {
+	l, o, hasOffset := strings.Cut(s, "@")
+	if length, err = strconv.ParseInt(l, 10, 64); err != nil || length <= 0 {
+		return 0, 0, fmt.Errorf("invalid byte range %q", s)
+	}
+	offset = def
+	if hasOffset {
+		if offset, err = strconv.ParseInt(o, 10, 64); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid byte range %q", s)
+		}
+	}
+	return length, offset, nil
+}
+
+// parseAttributes parses an HLS attribute list such as
+// `BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2"`.
+func parseAttributes(s string) map[string]string {
+	attrs := map[string]string{}
+	for s != "" {
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.TrimSpace(name)] = value
+		s = rest
+	}
+	return attrs
+}
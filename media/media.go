@@ -0,0 +1,279 @@
+// Package media downloads segmented media streams: HLS playlists (m3u8) and
+// DASH manifests (MPD).
+//
+// A Downloader fetches the playlist, picks a variant, downloads the
+// segments concurrently with a retrieve.Batch and either concatenates them
+// into a single file or stores each segment under a name template. Every
+// request is a retrieve.Builder, so headers, retries, rate limits and the
+// other Builder options apply to the playlist and to each segment.
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ciathefed/retrieve"
+)
+
+const defaultWorkers = 4
+
+// Downloader downloads the segments of an HLS or DASH stream.
+type Downloader struct {
+	url       string
+	output    string
+	template  string
+	workers   int
+	ctx       context.Context
+	configure func(*retrieve.Builder)
+	selectFn  func([]Variant) Variant
+	err       error
+}
+
+// New initializes a Downloader for the playlist or manifest at url.
+func New(url string) *Downloader {
+	return &Downloader{
+		url:      url,
+		workers:  defaultWorkers,
+		ctx:      context.Background(),
+		selectFn: HighestBandwidth,
+	}
+}
+
+// SetOutput sets the file the segments are concatenated into, or the
+// directory they are stored in when SetSegmentTemplate is used.
+func (d *Downloader) SetOutput(output string) *Downloader {
+	if d.err != nil {
+		return d
+	}
+	d.output = output
+	return d
+}
+
+// SetSegmentTemplate stores each segment as its own file in the output
+// directory instead of concatenating them. The template is a fmt format
+// for the segment index, such as "segment-%05d.ts". An initialization
+// segment is stored as "init" with the extension of its URL.
+func (d *Downloader) SetSegmentTemplate(template string) *Downloader {
+	if d.err != nil {
+		return d
+	}
+	if !strings.Contains(template, "%") {
+		d.err = fmt.Errorf("segment template %q has no index verb", template)
+		return d
+	}
+	d.template = template
+	return d
+}
+
+// SetWorkers sets how many segments are downloaded concurrently.
+func (d *Downloader) SetWorkers(n int) *Downloader {
+	if d.err != nil {
+		return d
+	}
+	if n < 1 {
+		d.err = fmt.Errorf("invalid number of workers: %d", n)
+		return d
+	}
+	d.workers = n
+	return d
+}
+
+// SetContext sets a context that cancels the whole download.
+func (d *Downloader) SetContext(ctx context.Context) *Downloader {
+	if d.err != nil {
+		return d
+	}
+	d.ctx = ctx
+	return d
+}
+
+// Configure registers fn to configure the Builder of every request, the
+// playlist and each segment, for example to set headers, retries or a rate
+// limit. The rate limit applies to each request separately.
+func (d *Downloader) Configure(fn func(*retrieve.Builder)) *Downloader {
+	if d.err != nil {
+		return d
+	}
+	d.configure = fn
+	return d
+}
+
+// SelectVariant sets how a variant is chosen when the playlist lists
+// several. The default is HighestBandwidth.
+func (d *Downloader) SelectVariant(fn func([]Variant) Variant) *Downloader {
+	if d.err != nil {
+		return d
+	}
+	d.selectFn = fn
+	return d
+}
+
+// Exec downloads the stream.
+func (d *Downloader) Exec() error {
+	if d.err != nil {
+		return d.err
+	}
+	if d.output == "" {
+		return errors.New("no output set")
+	}
+
+	p, err := d.playlist()
+	if err != nil {
+		return err
+	}
+
+	segments := p.Segments
+	if p.Init != nil {
+		segments = append([]Segment{*p.Init}, segments...)
+	}
+
+	if d.template != "" {
+		if err := os.MkdirAll(d.output, 0o755); err != nil {
+			return err
+		}
+		return d.download(segments, d.output, func(i int) string {
+			if p.Init != nil {
+				if i == 0 {
+					return "init" + path.Ext(urlPath(p.Init.URL))
+				}
+				i--
+			}
+			return fmt.Sprintf(d.template, i)
+		})
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(d.output), ".segments-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	name := func(i int) string { return fmt.Sprintf("%06d", i) }
+	if err := d.download(segments, tmp, name); err != nil {
+		return err
+	}
+	return concat(d.output, tmp, len(segments), name)
+}
+
+// playlist fetches and parses the playlist, resolving a variant if it lists several.
+func (d *Downloader) playlist() (*Playlist, error) {
+	p, err := d.fetchPlaylist(d.url)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Variants) > 0 {
+		v := d.selectFn(p.Variants)
+		if v.Playlist != nil {
+			p = v.Playlist
+		} else if p, err = d.fetchPlaylist(v.URL); err != nil {
+			return nil, err
+		}
+		if len(p.Variants) > 0 {
+			return nil, errors.New("variant playlist lists further variants")
+		}
+	}
+	if len(p.Segments) == 0 {
+		return nil, errors.New("playlist has no segments")
+	}
+	return p, nil
+}
+
+// fetchPlaylist downloads and parses the playlist at rawURL, detecting its format.
+func (d *Downloader) fetchPlaylist(rawURL string) (*Playlist, error) {
+	var buf bytes.Buffer
+	result, err := d.builder(rawURL).SetWriter(&buf).ExecWithResult()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist: %w", err)
+	}
+
+	base, err := url.Parse(result.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	data := bytes.TrimSpace(buf.Bytes())
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	switch {
+	case bytes.HasPrefix(data, []byte("#EXTM3U")):
+		return ParseHLS(data, base)
+	case bytes.Contains(data, []byte("<MPD")):
+		return ParseDASH(data, base)
+	}
+	return nil, fmt.Errorf("unrecognized playlist format at %s", rawURL)
+}
+
+// download fetches segments concurrently into dir, naming them with name.
+func (d *Downloader) download(segments []Segment, dir string, name func(int) string) error {
+	batch := retrieve.NewBatch().SetWorkers(d.workers).SetContext(d.ctx)
+	for i, seg := range segments {
+		b := d.builder(seg.URL).SetOutput(filepath.Join(dir, name(i)))
+		if seg.Length > 0 {
+			b.SetHeader("Range", fmt.Sprintf("bytes=%d-%d", seg.Offset, seg.Offset+seg.Length-1))
+		}
+		batch.Add(b)
+	}
+
+	for i, result := range batch.Exec() {
+		if result.Err != nil {
+			return fmt.Errorf("segment %d: %w", i, result.Err)
+		}
+	}
+	return nil
+}
+
+// builder returns a Builder for rawURL with the Downloader's configuration.
+func (d *Downloader) builder(rawURL string) *retrieve.Builder {
+	b := retrieve.New(rawURL).SetContext(d.ctx)
+	if d.configure != nil {
+		d.configure(b)
+	}
+	return b
+}
+
+// concat writes the n segments in dir to output, in order.
+func concat(output, dir string, n int, name func(int) string) error {
+	part := output + ".part"
+	out, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	for i := range n {
+		if err = appendFile(out, filepath.Join(dir, name(i))); err != nil {
+			break
+		}
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(part)
+		return err
+	}
+	return os.Rename(part, output)
+}
+
+func appendFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func urlPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Path
+}
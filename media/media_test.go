@@ -0,0 +1,254 @@
+package media_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+	"github.com/ciathefed/retrieve/media"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParse(t *testing.T, rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	assert.NoError(t, err)
+	return u
+}
+
+func TestParseHLS_Master(t *testing.T) {
+	data := `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=5120000,RESOLUTION=1920x1080
+https://cdn.example.com/high/index.m3u8
+`
+	p, err := media.ParseHLS([]byte(data), mustParse(t, "https://example.com/video/master.m3u8"))
+	assert.NoError(t, err)
+	if assert.Len(t, p.Variants, 2) {
+		assert.Equal(t, "https://example.com/video/low/index.m3u8", p.Variants[0].URL)
+		assert.Equal(t, int64(1280000), p.Variants[0].Bandwidth)
+		assert.Equal(t, "640x360", p.Variants[0].Resolution)
+		assert.Equal(t, "avc1.4d401e,mp4a.40.2", p.Variants[0].Codecs)
+		assert.Equal(t, "https://cdn.example.com/high/index.m3u8", media.HighestBandwidth(p.Variants).URL)
+	}
+	assert.Empty(t, p.Segments)
+}
+
+func TestParseHLS_Media(t *testing.T) {
+	data := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:6
+#EXT-X-MAP:URI="init.mp4",BYTERANGE="720@0"
+#EXTINF:6.0,
+#EXT-X-BYTERANGE:1000@720
+media.mp4
+#EXTINF:4.5,title
+#EXT-X-BYTERANGE:500
+media.mp4
+#EXTINF:2,
+last.ts
+#EXT-X-ENDLIST
+`
+	p, err := media.ParseHLS([]byte(data), mustParse(t, "https://example.com/a/index.m3u8"))
+	assert.NoError(t, err)
+	assert.Equal(t, &media.Segment{URL: "https://example.com/a/init.mp4", Length: 720}, p.Init)
+	assert.Equal(t, []media.Segment{
+		{URL: "https://example.com/a/media.mp4", Duration: 6 * time.Second, Offset: 720, Length: 1000},
+		{URL: "https://example.com/a/media.mp4", Duration: 4500 * time.Millisecond, Offset: 1720, Length: 500},
+		{URL: "https://example.com/a/last.ts", Duration: 2 * time.Second},
+	}, p.Segments)
+}
+
+func TestParseHLS_Errors(t *testing.T) {
+	_, err := media.ParseHLS([]byte("segment.ts\n"), nil)
+	assert.ErrorContains(t, err, "#EXTM3U")
+
+	_, err = media.ParseHLS([]byte("#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key\"\n#EXTINF:1,\na.ts\n"), nil)
+	assert.ErrorIs(t, err, media.ErrEncrypted)
+
+	p, err := media.ParseHLS([]byte("#EXTM3U\n#EXT-X-KEY:METHOD=NONE\n#EXTINF:1,\na.ts\n"), nil)
+	assert.NoError(t, err)
+	assert.Len(t, p.Segments, 1)
+}
+
+func TestParseDASH_Template(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT9.5S">
+  <Period>
+    <AdaptationSet mimeType="video/mp4">
+      <SegmentTemplate media="$RepresentationID$/seg-$Number%03d$.m4s" initialization="$RepresentationID$/init.mp4" duration="4" timescale="1" startNumber="0"/>
+      <Representation id="720p" bandwidth="3000000" width="1280" height="720"/>
+      <Representation id="1080p" bandwidth="6000000" width="1920" height="1080"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4">
+      <Representation id="audio" bandwidth="128000">
+        <SegmentTemplate media="audio/$Time$.m4s" timescale="1000">
+          <SegmentTimeline>
+            <S t="0" d="5000" r="1"/>
+          </SegmentTimeline>
+        </SegmentTemplate>
+      </Representation>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+	p, err := media.ParseDASH([]byte(data), mustParse(t, "https://example.com/v/manifest.mpd"))
+	assert.NoError(t, err)
+	if !assert.Len(t, p.Variants, 3) {
+		return
+	}
+
+	hd := media.HighestBandwidth(p.Variants)
+	assert.Equal(t, "1080p", hd.ID)
+	assert.Equal(t, "1920x1080", hd.Resolution)
+	assert.Equal(t, "video/mp4", hd.MimeType)
+	assert.Equal(t, "https://example.com/v/1080p/init.mp4", hd.Playlist.Init.URL)
+	if assert.Len(t, hd.Playlist.Segments, 3) {
+		assert.Equal(t, "https://example.com/v/1080p/seg-000.m4s", hd.Playlist.Segments[0].URL)
+		assert.Equal(t, "https://example.com/v/1080p/seg-002.m4s", hd.Playlist.Segments[2].URL)
+		assert.Equal(t, 4*time.Second, hd.Playlist.Segments[0].Duration)
+	}
+
+	audio := p.Variants[2]
+	assert.Nil(t, audio.Playlist.Init)
+	if assert.Len(t, audio.Playlist.Segments, 2) {
+		assert.Equal(t, "https://example.com/v/audio/0.m4s", audio.Playlist.Segments[0].URL)
+		assert.Equal(t, "https://example.com/v/audio/5000.m4s", audio.Playlist.Segments[1].URL)
+	}
+}
+
+func TestParseDASH_ListAndErrors(t *testing.T) {
+	data := `<MPD type="static"><BaseURL>https://cdn.example.com/</BaseURL><Period>
+  <AdaptationSet><Representation id="a" bandwidth="1">
+    <SegmentList duration="2"><Initialization sourceURL="init.mp4"/><SegmentURL media="1.m4s"/><SegmentURL media="2.m4s"/></SegmentList>
+  </Representation></AdaptationSet></Period></MPD>`
+	p, err := media.ParseDASH([]byte(data), mustParse(t, "https://example.com/manifest.mpd"))
+	assert.NoError(t, err)
+	if assert.Len(t, p.Variants, 1) {
+		list := p.Variants[0].Playlist
+		assert.Equal(t, "https://cdn.example.com/init.mp4", list.Init.URL)
+		assert.Equal(t, []media.Segment{
+			{URL: "https://cdn.example.com/1.m4s", Duration: 2 * time.Second},
+			{URL: "https://cdn.example.com/2.m4s", Duration: 2 * time.Second},
+		}, list.Segments)
+	}
+
+	_, err = media.ParseDASH([]byte(`<MPD type="dynamic"><Period/></MPD>`), nil)
+	assert.ErrorContains(t, err, "live")
+
+	_, err = media.ParseDASH([]byte(`<MPD><Period/><Period/></MPD>`), nil)
+	assert.ErrorContains(t, err, "periods")
+}
+
+// newStreamServer serves an HLS master playlist at /master.m3u8 whose best
+// variant has n segments, each containing its own name.
+func newStreamServer(n int, requests *atomic.Int32) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=100\nlow.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=900\nhigh/index.m3u8\n")
+	})
+	mux.HandleFunc("/high/index.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		var sb strings.Builder
+		sb.WriteString("#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\"\n")
+		for i := range n {
+			fmt.Fprintf(&sb, "#EXTINF:2,\nseg%d.m4s\n", i)
+		}
+		sb.WriteString("#EXT-X-ENDLIST\n")
+		w.Write([]byte(sb.String()))
+	})
+	mux.HandleFunc("/high/", func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil && requests.Add(1) == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("[" + filepath.Base(r.URL.Path) + "]"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestDownloader_Concat(t *testing.T) {
+	var requests atomic.Int32
+	server := newStreamServer(5, &requests)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "video.mp4")
+	err := media.New(server.URL + "/master.m3u8").
+		SetOutput(output).
+		SetWorkers(3).
+		Configure(func(b *retrieve.Builder) {
+			b.SetRetries(2).SetRetryBackoff(time.Millisecond, time.Millisecond)
+		}).
+		Exec()
+	assert.NoError(t, err)
+
+	got, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "[init.mp4][seg0.m4s][seg1.m4s][seg2.m4s][seg3.m4s][seg4.m4s]", string(got))
+
+	entries, _ := os.ReadDir(filepath.Dir(output))
+	assert.Len(t, entries, 1)
+}
+
+func TestDownloader_Template(t *testing.T) {
+	server := newStreamServer(3, nil)
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "segments")
+	err := media.New(server.URL + "/master.m3u8").
+		SetOutput(dir).
+		SetSegmentTemplate("part-%03d.m4s").
+		Exec()
+	assert.NoError(t, err)
+
+	for name, want := range map[string]string{
+		"init.mp4":     "[init.mp4]",
+		"part-000.m4s": "[seg0.m4s]",
+		"part-002.m4s": "[seg2.m4s]",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(got))
+	}
+}
+
+func TestDownloader_SelectVariant(t *testing.T) {
+	server := newStreamServer(1, nil)
+	defer server.Close()
+
+	var offered []media.Variant
+	err := media.New(server.URL + "/master.m3u8").
+		SetOutput(filepath.Join(t.TempDir(), "out")).
+		SelectVariant(func(vs []media.Variant) media.Variant {
+			offered = vs
+			return vs[0]
+		}).
+		Exec()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Len(t, offered, 2)
+}
+
+func TestDownloader_SegmentFailure(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "#EXTM3U\n#EXTINF:1,\na.ts\n#EXTINF:1,\nmissing.ts\n")
+	})
+	mux.HandleFunc("/a.ts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.ts")
+	err := media.New(server.URL + "/index.m3u8").SetOutput(output).Exec()
+	assert.ErrorContains(t, err, "segment 1")
+	assert.NoFileExists(t, output)
+}
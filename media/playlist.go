@@ -0,0 +1,74 @@
+package media
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+// ErrEncrypted is returned for playlists whose segments are encrypted.
+var ErrEncrypted = errors.New("encrypted segments are not supported")
+
+// Playlist lists the segments of a stream, or the variants it is available in.
+type Playlist struct {
+	// Variants are the alternative streams listed by an HLS master playlist
+	// or a DASH manifest. Empty for an HLS media playlist.
+	Variants []Variant
+
+	// Init is the initialization segment that precedes the media segments,
+	// or nil if there is none.
+	Init *Segment
+
+	// Segments are the media segments, in playback order.
+	Segments []Segment
+}
+
+// Variant is one rendition of a stream, such as a bitrate or resolution.
+type Variant struct {
+	// URL is the URL of the variant's media playlist (HLS).
+	URL string
+
+	// Playlist holds the variant's segments when the manifest lists them
+	// directly (DASH).
+	Playlist *Playlist
+
+	ID         string
+	Bandwidth  int64  // bits per second
+	Resolution string // such as "1920x1080"; empty if unknown
+	MimeType   string // DASH only
+	Codecs     string
+}
+
+// Segment is a single media file, or a byte range of one.
+type Segment struct {
+	URL      string
+	Duration time.Duration
+
+	// Offset and Length select a byte range of URL. A Length of zero means
+	// the whole resource.
+	Offset int64
+	Length int64
+}
+
+// HighestBandwidth selects the variant with the highest bandwidth.
+func HighestBandwidth(variants []Variant) Variant {
+	best := variants[0]
+	for _, v := range variants[1:] {
+		if v.Bandwidth > best.Bandwidth {
+			best = v
+		}
+	}
+	return best
+}
+
+// resolve resolves ref against base.
+func resolve(base *url.URL, ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if base == nil {
+		return u.String(), nil
+	}
+	return base.ResolveReference(u).String(), nil
+}
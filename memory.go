@@ -0,0 +1,119 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ciathefed/retrieve/internal/brotli"
+	"github.com/ciathefed/retrieve/internal/zstd"
+)
+
+// ErrMemoryBudget is returned when a request needs more memory than the
+// budget set with SetMemoryBudget allows.
+var ErrMemoryBudget = errors.New("memory budget exceeded")
+
+const (
+	// copyBufferSize is the size of the buffer used to copy a response body.
+	copyBufferSize = 32 << 10
+
+	// gzipMemory approximates the memory held by a gzip decompressor: its
+	// 32 KiB window plus Huffman tables and state.
+	gzipMemory = 48 << 10
+
+	// zstdMemory and brotliMemory bound the memory held by the zstd and
+	// brotli decompressors: up to two windows of history plus the output
+	// being decoded.
+	zstdMemory   = 2*zstd.MaxWindowSize + 256<<10
+	brotliMemory = 2*brotli.MaxWindowSize + 128<<10
+)
+
+// MemoryBudgetError reports an allocation that would exceed the memory budget.
+type MemoryBudgetError struct {
+	Purpose   string // what the memory was needed for
+	Requested int64  // bytes requested
+	InUse     int64  // bytes already in use by the request
+	Budget    int64  // the budget set with SetMemoryBudget
+}
+
+func (e *MemoryBudgetError) Error() string {
+	return fmt.Sprintf("%s: %s needs %d bytes, %d of %d in use",
+		ErrMemoryBudget, e.Purpose, e.Requested, e.InUse, e.Budget)
+}
+
+func (e *MemoryBudgetError) Is(target error) bool {
+	return target == ErrMemoryBudget
+}
+
+// SetMemoryBudget caps the memory a single request may hold for its own
+// buffers at n bytes: copy buffers (one per segment), bodies read into
+// memory by ExecBytes and ExecJSON, decompression windows and pieces
+// fetched during repair. Zero disables the budget.
+//
+// A request that would exceed the budget fails with a *MemoryBudgetError,
+// which matches ErrMemoryBudget, and it is not retried. Memory used by the
+// HTTP transport itself, such as connection buffers, is not counted.
+func (b *Builder) SetMemoryBudget(n int64) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if n < 0 {
+		b.err = fmt.Errorf("invalid memory budget: %d", n)
+		return b
+	}
+	b.memoryBudget = n
+	return b
+}
+
+// GetMemoryBudget returns the memory budget of a request.
+func (b *Builder) GetMemoryBudget() int64 {
+	return b.memoryBudget
+}
+
+// memoryBudget tracks the memory in use by a request. A nil budget is unlimited.
+type memoryBudget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+}
+
+func newMemoryBudget(limit int64) *memoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &memoryBudget{limit: limit}
+}
+
+// reserve accounts for n more bytes, failing if that exceeds the budget.
+func (m *memoryBudget) reserve(n int64, purpose string) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.used+n > m.limit {
+		return &MemoryBudgetError{Purpose: purpose, Requested: n, InUse: m.used, Budget: m.limit}
+	}
+	m.used += n
+	return nil
+}
+
+// release returns n bytes to the budget.
+func (m *memoryBudget) release(n int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.used -= n
+	m.mu.Unlock()
+}
+
+// copyBody copies src to dst with a buffer accounted against the memory budget.
+func (b *Builder) copyBody(dst io.Writer, src io.Reader) (int64, error) {
+	if err := b.memory.reserve(copyBufferSize, "copy buffer"); err != nil {
+		return 0, err
+	}
+	defer b.memory.release(copyBufferSize)
+	return io.CopyBuffer(dst, src, make([]byte, copyBufferSize))
+}
@@ -0,0 +1,88 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMemoryBudget(t *testing.T) {
+	assert.Equal(t, int64(0), retrieve.New("http://example.com").GetMemoryBudget())
+	assert.Equal(t, int64(1<<20), retrieve.New("http://example.com").SetMemoryBudget(1<<20).GetMemoryBudget())
+	assert.Error(t, retrieve.New("http://example.com").SetMemoryBudget(-1).Exec())
+}
+
+func TestMemoryBudget_File(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 1<<20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	// Streaming to a file only needs a copy buffer, whatever the size.
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(server.URL).SetOutput(output).SetMemoryBudget(64 << 10).Exec()
+	assert.NoError(t, err)
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+
+	err = retrieve.New(server.URL).SetOutput(output).SetMemoryBudget(1 << 10).Exec()
+	var budgetErr *retrieve.MemoryBudgetError
+	if assert.ErrorAs(t, err, &budgetErr) {
+		assert.Equal(t, "copy buffer", budgetErr.Purpose)
+		assert.Equal(t, int64(1<<10), budgetErr.Budget)
+	}
+}
+
+func TestMemoryBudget_InMemory(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write(bytes.Repeat([]byte("a"), 256<<10))
+	}))
+	defer server.Close()
+
+	_, err := retrieve.New(server.URL).
+		SetMemoryBudget(128<<10).
+		SetRetries(3).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		ExecBytes()
+	assert.ErrorIs(t, err, retrieve.ErrMemoryBudget)
+	assert.Equal(t, int32(1), requests.Load())
+
+	data, err := retrieve.New(server.URL).SetMemoryBudget(512 << 10).ExecBytes()
+	assert.NoError(t, err)
+	assert.Len(t, data, 256<<10)
+}
+
+func TestMemoryBudget_Decompression(t *testing.T) {
+	var requests atomic.Int32
+	server := newGzipServer(gzipBytes(t, []byte("compressed")), &requests)
+	defer server.Close()
+
+	// The copy buffer fits, but the decompression window does not.
+	_, err := retrieve.New(server.URL).
+		SetDecompressionLimits(1<<20, 0).
+		SetMemoryBudget(40 << 10).
+		ExecBytes()
+	var budgetErr *retrieve.MemoryBudgetError
+	if assert.ErrorAs(t, err, &budgetErr) {
+		assert.Equal(t, "decompression window", budgetErr.Purpose)
+	}
+
+	s, err := retrieve.New(server.URL).
+		SetDecompressionLimits(1<<20, 0).
+		SetMemoryBudget(128 << 10).
+		ExecString()
+	assert.NoError(t, err)
+	assert.Equal(t, "compressed", s)
+}
@@ -0,0 +1,100 @@
+package retrieve
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Metrics records what downloads do, so their behavior can be monitored
+// across a fleet. The retrieveprom package implements it with Prometheus.
+// Methods may be called concurrently.
+type Metrics interface {
+	// Request is called for each attempt sent to host, the host name of
+	// its URL before redirects.
+	Request(method, host string)
+
+	// Retry is called before retrying a request to host after err.
+	Retry(host string, err error)
+
+	// Done is called when Exec returns, with the host of the final URL,
+	// the time Exec took, the bytes written and the error returned, which
+	// is nil on success.
+	Done(host string, elapsed time.Duration, bytes int64, err error)
+}
+
+var defaultMetrics struct {
+	mu      sync.RWMutex
+	metrics Metrics
+}
+
+// SetDefaultMetrics records the metrics of every Builder without
+// SetMetrics with m. A nil m stops recording them.
+func SetDefaultMetrics(m Metrics) {
+	defaultMetrics.mu.Lock()
+	defer defaultMetrics.mu.Unlock()
+	defaultMetrics.metrics = m
+}
+
+// SetMetrics records the metrics of the request with m instead of the
+// Metrics set with SetDefaultMetrics.
+func (b *Builder) SetMetrics(m Metrics) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.metrics = m
+	return b
+}
+
+// GetMetrics returns the Metrics set with SetMetrics, if any.
+func (b *Builder) GetMetrics() Metrics {
+	return b.metrics
+}
+
+// activeMetrics returns the Metrics set with SetMetrics or else with
+// SetDefaultMetrics, if any.
+func (b *Builder) activeMetrics() Metrics {
+	if b.metrics != nil {
+		return b.metrics
+	}
+	defaultMetrics.mu.RLock()
+	defer defaultMetrics.mu.RUnlock()
+	return defaultMetrics.metrics
+}
+
+// recordRequest records an attempt of req.
+func (b *Builder) recordRequest(req *http.Request) {
+	if m := b.activeMetrics(); m != nil {
+		m.Request(req.Method, req.URL.Hostname())
+	}
+}
+
+// recordRetry records a retry of rawURL after err.
+func (b *Builder) recordRetry(rawURL string, err error) {
+	if m := b.activeMetrics(); m != nil {
+		m.Retry(urlHostname(rawURL), err)
+	}
+}
+
+// recordDone records the outcome of Exec.
+func (b *Builder) recordDone(err error, elapsed time.Duration) {
+	m := b.activeMetrics()
+	if m == nil {
+		return
+	}
+	rawURL := b.result.URL
+	if rawURL == "" {
+		rawURL = b.url
+	}
+	m.Done(urlHostname(rawURL), elapsed, b.progress.BytesWritten, err)
+}
+
+// urlHostname returns the host name of rawURL, or "" if it has none.
+func urlHostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
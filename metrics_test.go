@@ -0,0 +1,98 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMetrics records the calls made to it.
+type recordingMetrics struct {
+	mu       sync.Mutex
+	requests []string
+	retries  []string
+	done     []string
+	bytes    int64
+	elapsed  time.Duration
+	err      error
+}
+
+func (m *recordingMetrics) Request(method, host string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, method+" "+host)
+}
+
+func (m *recordingMetrics) Retry(host string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries = append(m.retries, host)
+}
+
+func (m *recordingMetrics) Done(host string, elapsed time.Duration, bytes int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.done = append(m.done, host)
+	m.elapsed, m.bytes, m.err = elapsed, bytes, err
+}
+
+func TestSetMetrics(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	host := u.Hostname()
+
+	metrics := &recordingMetrics{}
+	b := retrieve.New(server.URL).SetMetrics(metrics).SetRetries(1).SetRetryBackoff(0, 0)
+	assert.Same(t, metrics, b.GetMetrics())
+	_, err := b.ExecBytes()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"GET " + host, "GET " + host}, metrics.requests)
+	assert.Equal(t, []string{host}, metrics.retries)
+	assert.Equal(t, []string{host}, metrics.done)
+	assert.EqualValues(t, len("content"), metrics.bytes)
+	assert.Positive(t, metrics.elapsed)
+	assert.NoError(t, metrics.err)
+}
+
+func TestSetDefaultMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	defaults := &recordingMetrics{}
+	retrieve.SetDefaultMetrics(defaults)
+	defer retrieve.SetDefaultMetrics(nil)
+
+	_, err := retrieve.New(server.URL).ExecBytes()
+	assert.Error(t, err)
+	assert.Len(t, defaults.requests, 1)
+	assert.Equal(t, err, defaults.err)
+
+	// Metrics set on the builder take precedence.
+	own := &recordingMetrics{}
+	b := retrieve.New(server.URL).SetMetrics(own)
+	assert.Same(t, own, b.GetMetrics())
+	b.Exec()
+	assert.Len(t, own.requests, 1)
+	assert.Len(t, defaults.requests, 1)
+
+	assert.Nil(t, retrieve.New(server.URL).GetMetrics())
+}
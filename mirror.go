@@ -0,0 +1,168 @@
+package retrieve
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SetMirrors sets fallback URLs that are tried in order when the primary URL
+// fails or returns an error status, after its retries are exhausted.
+//
+// Query parameters set with SetQueryParam only apply to the primary URL.
+// See Race to try them all at once instead.
+func (b *Builder) SetMirrors(mirrors []string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	for _, mirror := range mirrors {
+		if !isValidURL(mirror) {
+			b.err = fmt.Errorf("invalid mirror URL: %s", mirror)
+			return b
+		}
+	}
+	b.mirrors = append([]string(nil), mirrors...)
+	return b
+}
+
+// GetMirrors returns the fallback URLs set for the request.
+func (b *Builder) GetMirrors() []string {
+	return b.mirrors
+}
+
+// MirrorSelector decides the order in which the primary URL and its
+// mirrors are tried.
+type MirrorSelector interface {
+	// Order returns the candidate URLs in the order they should be tried.
+	// The builder is the one being executed and may be used to make requests.
+	Order(b *Builder, urls []string) []string
+}
+
+// SetMirrorSelector sets the strategy used to order the primary URL and
+// its mirrors. By default they are tried in the order they were given,
+// as with FirstSuccess.
+func (b *Builder) SetMirrorSelector(selector MirrorSelector) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.mirrorSelector = selector
+	return b
+}
+
+// GetMirrorSelector returns the mirror selector set for the request, if any.
+func (b *Builder) GetMirrorSelector() MirrorSelector {
+	return b.mirrorSelector
+}
+
+// candidateURLs returns the primary URL and mirrors in the order they should be tried.
+func (b *Builder) candidateURLs() []string {
+	urls := append([]string{b.url}, b.mirrors...)
+	if b.mirrorSelector == nil {
+		return urls
+	}
+	return b.mirrorSelector.Order(b, urls)
+}
+
+type firstSuccess struct{}
+
+// FirstSuccess returns a MirrorSelector that tries URLs in the order they were given.
+func FirstSuccess() MirrorSelector {
+	return firstSuccess{}
+}
+
+func (firstSuccess) Order(b *Builder, urls []string) []string {
+	return urls
+}
+
+type lowestLatency struct {
+	sampleBytes int64
+	ttl         time.Duration
+
+	mu       sync.Mutex
+	rankings map[string]latencyRanking
+}
+
+type latencyRanking struct {
+	urls    []string
+	expires time.Time
+}
+
+// LowestLatency returns a MirrorSelector that benchmarks every URL with a
+// ranged sample of sampleBytes, as BenchmarkMirrors does, and tries the
+// fastest first. Rankings are reused for ttl.
+//
+// Share the selector between Builders to share its rankings.
+func LowestLatency(sampleBytes int64, ttl time.Duration) MirrorSelector {
+	return &lowestLatency{
+		sampleBytes: sampleBytes,
+		ttl:         ttl,
+		rankings:    make(map[string]latencyRanking),
+	}
+}
+
+func (s *lowestLatency) Order(b *Builder, urls []string) []string {
+	key := strings.Join(urls, "\n")
+
+	s.mu.Lock()
+	ranking, ok := s.rankings[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(ranking.expires) {
+		return ranking.urls
+	}
+
+	results := b.benchmarkMirrors(urls, s.sampleBytes)
+	ordered := make([]string, len(results))
+	for i, result := range results {
+		ordered[i] = result.URL
+	}
+
+	s.mu.Lock()
+	s.rankings[key] = latencyRanking{urls: ordered, expires: time.Now().Add(s.ttl)}
+	s.mu.Unlock()
+	return ordered
+}
+
+type weightedRoundRobin struct {
+	weights map[string]int
+
+	mu      sync.Mutex
+	current map[string]int
+}
+
+// WeightedRoundRobin returns a MirrorSelector that spreads downloads across
+// URLs in proportion to their weights, using smooth weighted round-robin.
+// The remaining URLs follow as fallbacks. URLs without a weight have a weight of 1.
+//
+// Share the selector between Builders to balance load across them.
+func WeightedRoundRobin(weights map[string]int) MirrorSelector {
+	return &weightedRoundRobin{
+		weights: maps.Clone(weights),
+		current: make(map[string]int),
+	}
+}
+
+func (s *weightedRoundRobin) Order(b *Builder, urls []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	total := 0
+	for _, u := range urls {
+		weight, ok := s.weights[u]
+		if !ok {
+			weight = 1
+		}
+		s.current[u] += weight
+		total += weight
+	}
+
+	ordered := slices.Clone(urls)
+	slices.SortStableFunc(ordered, func(x, y string) int {
+		return cmp.Compare(s.current[y], s.current[x])
+	})
+	s.current[ordered[0]] -= total
+	return ordered
+}
@@ -0,0 +1,121 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMirrors(t *testing.T) {
+	mirrors := []string{"http://mirror1.example.com", "http://mirror2.example.com"}
+	b := retrieve.New("http://example.com").SetMirrors(mirrors)
+	assert.Equal(t, mirrors, b.GetMirrors())
+}
+
+func TestSetMirrors_InvalidURL(t *testing.T) {
+	err := retrieve.New("http://example.com").SetMirrors([]string{"not a url"}).Exec()
+	assert.Error(t, err)
+}
+
+func TestExec_FallsBackToMirror(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer primary.Close()
+
+	missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer missing.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from mirror"))
+	}))
+	defer mirror.Close()
+
+	output := filepath.Join(t.TempDir(), "out.txt")
+	err := retrieve.New(primary.URL).
+		SetOutput(output).
+		SetMirrors([]string{missing.URL, mirror.URL}).
+		Exec()
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "from mirror", string(data))
+}
+
+func TestExec_AllMirrorsFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		SetOutput(t.TempDir()).
+		SetMirrors([]string{server.URL + "/mirror"}).
+		Exec()
+
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusBadGateway, statusErr.StatusCode)
+}
+
+func TestFirstSuccess(t *testing.T) {
+	urls := []string{"http://a.example.com", "http://b.example.com"}
+	assert.Equal(t, urls, retrieve.FirstSuccess().Order(retrieve.New(urls[0]), urls))
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	a, b := "http://a.example.com", "http://b.example.com"
+	selector := retrieve.WeightedRoundRobin(map[string]int{a: 3})
+
+	counts := map[string]int{}
+	for range 8 {
+		order := selector.Order(retrieve.New(a), []string{a, b})
+		assert.Len(t, order, 2)
+		counts[order[0]]++
+	}
+	assert.Equal(t, map[string]int{a: 6, b: 2}, counts)
+}
+
+func TestLowestLatency(t *testing.T) {
+	var slowCalls atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slowCalls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("slow"))
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+
+	selector := retrieve.LowestLatency(4, time.Minute)
+	for range 2 {
+		output := filepath.Join(t.TempDir(), "out.txt")
+		b := retrieve.New(slow.URL).
+			SetMirrors([]string{fast.URL}).
+			SetMirrorSelector(selector).
+			SetOutput(output)
+		assert.Equal(t, selector, b.GetMirrorSelector())
+		assert.NoError(t, b.Exec())
+
+		data, err := os.ReadFile(output)
+		assert.NoError(t, err)
+		assert.Equal(t, "fast", string(data))
+	}
+
+	// The ranking is cached, so the slow mirror is only benchmarked once.
+	assert.Equal(t, int32(1), slowCalls.Load())
+}
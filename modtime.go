@@ -0,0 +1,33 @@
+package retrieve
+
+import (
+	"net/http"
+	"os"
+)
+
+// PreserveModTime sets the modification time of the output file to the
+// Last-Modified time of the response once the download completes, like
+// curl -R, so mirrors can be synced by timestamp. Responses without a valid
+// Last-Modified header leave the file's times unchanged.
+func (b *Builder) PreserveModTime() *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.preserveModTime = true
+	return b
+}
+
+// IsPreserveModTime returns whether the output file gets the server's modification time.
+func (b *Builder) IsPreserveModTime() bool {
+	return b.preserveModTime
+}
+
+// applyModTime sets the times of the file at path to the Last-Modified
+// time of resp.
+func applyModTime(path string, resp *http.Response) error {
+	modTime, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return nil
+	}
+	return os.Chtimes(path, modTime, modTime)
+}
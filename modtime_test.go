@@ -0,0 +1,50 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreserveModTime(t *testing.T) {
+	assert.False(t, retrieve.New("http://example.com").IsPreserveModTime())
+	assert.True(t, retrieve.New("http://example.com").PreserveModTime().IsPreserveModTime())
+
+	modTime := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "file")
+	err := retrieve.New(server.URL).SetOutput(output).PreserveModTime().Exec()
+	assert.NoError(t, err)
+
+	info, err := os.Stat(output)
+	assert.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(modTime), "mtime %v", info.ModTime())
+}
+
+func TestPreserveModTime_NoHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	before := time.Now().Add(-time.Minute)
+	output := filepath.Join(t.TempDir(), "file")
+	err := retrieve.New(server.URL).SetOutput(output).PreserveModTime().Exec()
+	assert.NoError(t, err)
+
+	info, err := os.Stat(output)
+	assert.NoError(t, err)
+	assert.True(t, info.ModTime().After(before))
+}
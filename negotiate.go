@@ -0,0 +1,122 @@
+package retrieve
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SetAcceptLanguage sets the Accept-Language header from language tags,
+// such as "fr-CH" or "en", in decreasing order of preference, with the
+// q-values the header expects:
+//
+//	SetAcceptLanguage("fr-CH", "fr", "en") // fr-CH, fr;q=0.9, en;q=0.8
+//
+// A tag may carry its own q-value, such as "de;q=0.5", which is kept.
+func (b *Builder) SetAcceptLanguage(tags ...string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	value, err := weightedList(tags, validLanguage)
+	if err != nil {
+		b.err = fmt.Errorf("invalid Accept-Language: %w", err)
+		return b
+	}
+	b.replaceHeader("Accept-Language", value)
+	return b
+}
+
+// Accept sets the Accept header from media types, such as "text/csv" or
+// "application/*", in decreasing order of preference, with q-values like
+// SetAcceptLanguage:
+//
+//	Accept("application/json", "text/csv", "*/*") // application/json, text/csv;q=0.9, */*;q=0.8
+func (b *Builder) Accept(types ...string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	value, err := weightedList(types, validMediaRange)
+	if err != nil {
+		b.err = fmt.Errorf("invalid Accept: %w", err)
+		return b
+	}
+	b.replaceHeader("Accept", value)
+	return b
+}
+
+// replaceHeader sets the request header key, replacing it if it was set
+// with another case.
+func (b *Builder) replaceHeader(key, value string) {
+	for k := range b.headers {
+		if http.CanonicalHeaderKey(k) == key {
+			delete(b.headers, k)
+		}
+	}
+	b.headers[key] = value
+}
+
+// weightedList joins items in decreasing order of preference, giving each
+// a q-value below the previous one, unless it has its own. The q-values
+// go down by 0.1, or less when there are more than ten items.
+func weightedList(items []string, valid func(string) bool) (string, error) {
+	if len(items) == 0 {
+		return "", fmt.Errorf("no values")
+	}
+	step := 0.1
+	if len(items) > 10 {
+		step = 0.9 / float64(len(items)-1)
+	}
+
+	parts := make([]string, len(items))
+	for i, item := range items {
+		item = strings.TrimSpace(item)
+		name, params, _ := strings.Cut(item, ";")
+		if !valid(strings.TrimSpace(name)) {
+			return "", fmt.Errorf("%q", item)
+		}
+		if i > 0 && !hasQValue(params) {
+			q := math.Round((1-float64(i)*step)*1000) / 1000
+			item += ";q=" + strconv.FormatFloat(q, 'f', -1, 64)
+		}
+		parts[i] = item
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+// hasQValue reports whether the parameters of a list item include a q-value.
+func hasQValue(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		key, _, _ := strings.Cut(param, "=")
+		if strings.EqualFold(strings.TrimSpace(key), "q") {
+			return true
+		}
+	}
+	return false
+}
+
+// validLanguage reports whether tag looks like a language tag or "*".
+func validLanguage(tag string) bool {
+	if tag == "*" {
+		return true
+	}
+	for _, sub := range strings.Split(tag, "-") {
+		if sub == "" || len(sub) > 8 {
+			return false
+		}
+		for _, c := range sub {
+			if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validMediaRange reports whether s looks like a media range, such as
+// "text/html" or "image/*".
+func validMediaRange(s string) bool {
+	typ, sub, ok := strings.Cut(s, "/")
+	return ok && typ != "" && sub != "" && !strings.ContainsAny(s, " ,\t") && (typ != "*" || sub == "*")
+}
@@ -0,0 +1,55 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		tags []string
+		want string
+	}{
+		{[]string{"en"}, "en"},
+		{[]string{"fr-CH", "fr", "en", "*"}, "fr-CH, fr;q=0.9, en;q=0.8, *;q=0.7"},
+		{[]string{"de", "en;q=0.5"}, "de, en;q=0.5"},
+		{[]string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}, "a, b;q=0.9, c;q=0.8, d;q=0.7, e;q=0.6, f;q=0.5, g;q=0.4, h;q=0.3, i;q=0.2, j;q=0.1"},
+	}
+	for _, tt := range tests {
+		b := retrieve.New("http://example.com").SetAcceptLanguage(tt.tags...)
+		assert.Equal(t, tt.want, b.GetHeaders()["Accept-Language"])
+	}
+
+	tags := strings.Split("a b c d e f g h i j k l", " ")
+	value := retrieve.New("http://example.com").SetAcceptLanguage(tags...).GetHeaders()["Accept-Language"]
+	assert.True(t, strings.HasSuffix(value, "k;q=0.182, l;q=0.1"), value)
+}
+
+func TestSetAcceptLanguage_Invalid(t *testing.T) {
+	assert.ErrorContains(t, retrieve.New("http://example.com").SetAcceptLanguage().Exec(), "invalid Accept-Language")
+	assert.ErrorContains(t, retrieve.New("http://example.com").SetAcceptLanguage("en us").Exec(), "invalid Accept-Language")
+}
+
+func TestAccept(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Accept") + "|" + r.Header.Get("Accept-Language")))
+	}))
+	defer server.Close()
+
+	data, err := retrieve.New(server.URL).
+		SetHeader("accept", "text/plain").
+		Accept("application/json", "text/csv;charset=utf-8", "*/*").
+		SetAcceptLanguage("en-GB", "en").
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json, text/csv;charset=utf-8;q=0.9, */*;q=0.8|en-GB, en;q=0.9", string(data))
+
+	assert.ErrorContains(t, retrieve.New(server.URL).Accept("json").Exec(), "invalid Accept")
+	assert.ErrorContains(t, retrieve.New(server.URL).Accept("*/json").Exec(), "invalid Accept")
+}
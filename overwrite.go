@@ -0,0 +1,90 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrFileExists is returned by Exec when the output file already exists
+// and the overwrite policy is ErrorIfExists.
+var ErrFileExists = errors.New("output file already exists")
+
+// OverwritePolicy controls what happens when the output file already exists.
+type OverwritePolicy int
+
+const (
+	// Overwrite replaces the existing file. This is the default.
+	Overwrite OverwritePolicy = iota
+	// Skip leaves the existing file untouched and reports success.
+	Skip
+	// ErrorIfExists leaves the existing file untouched and fails with ErrFileExists.
+	ErrorIfExists
+	// RenameUnique saves the download next to the existing file with a
+	// numbered name, such as "file (1).txt".
+	RenameUnique
+)
+
+func (p OverwritePolicy) String() string {
+	switch p {
+	case Overwrite:
+		return "overwrite"
+	case Skip:
+		return "skip"
+	case ErrorIfExists:
+		return "error-if-exists"
+	case RenameUnique:
+		return "rename-unique"
+	}
+	return fmt.Sprintf("OverwritePolicy(%d)", int(p))
+}
+
+// SetOverwritePolicy sets what happens when the output file already exists.
+//
+// When the output is a file path, the policy is applied before any request
+// is made. When it is a directory, it is applied once the file name is known.
+func (b *Builder) SetOverwritePolicy(policy OverwritePolicy) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if policy < Overwrite || policy > RenameUnique {
+		b.err = fmt.Errorf("invalid overwrite policy: %d", policy)
+		return b
+	}
+	b.overwritePolicy = policy
+	return b
+}
+
+// GetOverwritePolicy returns the overwrite policy.
+func (b *Builder) GetOverwritePolicy() OverwritePolicy {
+	return b.overwritePolicy
+}
+
+// checkOverwrite applies the overwrite policy to outputPath. It returns the
+// path to write to, or skip if the download should not happen.
+func (b *Builder) checkOverwrite(outputPath string) (path string, skip bool, err error) {
+	if b.overwritePolicy == Overwrite || !isExist(outputPath) {
+		return outputPath, false, nil
+	}
+	switch b.overwritePolicy {
+	case Skip:
+		return outputPath, true, nil
+	case ErrorIfExists:
+		return "", false, fmt.Errorf("%w: %s", ErrFileExists, outputPath)
+	}
+	return uniquePath(outputPath), false, nil
+}
+
+// uniquePath returns the first of "name (1).ext", "name (2).ext", ... that
+// does not exist.
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", stem, i, ext)
+		if !isExist(candidate) {
+			return candidate
+		}
+	}
+}
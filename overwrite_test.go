@@ -0,0 +1,104 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetOverwritePolicy(t *testing.T) {
+	b := retrieve.New("http://example.com")
+	assert.Equal(t, retrieve.Overwrite, b.GetOverwritePolicy())
+	assert.Equal(t, retrieve.Skip, b.SetOverwritePolicy(retrieve.Skip).GetOverwritePolicy())
+	assert.Equal(t, "rename-unique", retrieve.RenameUnique.String())
+	assert.Error(t, retrieve.New("http://example.com").SetOverwritePolicy(retrieve.OverwritePolicy(42)).Exec())
+}
+
+func TestExec_OverwritePolicy(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("remote"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		policy   retrieve.OverwritePolicy
+		wantErr  error
+		want     string
+		requests int32
+	}{
+		{policy: retrieve.Overwrite, want: "remote", requests: 1},
+		{policy: retrieve.Skip, want: "local", requests: 0},
+		{policy: retrieve.ErrorIfExists, wantErr: retrieve.ErrFileExists, want: "local", requests: 0},
+		{policy: retrieve.RenameUnique, want: "local", requests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			requests.Store(0)
+			output := filepath.Join(t.TempDir(), "file.txt")
+			assert.NoError(t, os.WriteFile(output, []byte("local"), 0o644))
+
+			err := retrieve.New(server.URL).SetOutput(output).SetOverwritePolicy(tt.policy).Exec()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			data, _ := os.ReadFile(output)
+			assert.Equal(t, tt.want, string(data))
+			assert.Equal(t, tt.requests, requests.Load())
+		})
+	}
+}
+
+func TestExec_RenameUnique(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("remote"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("local"), 0o644))
+
+	for range 2 {
+		err := retrieve.New(server.URL + "/file.txt").SetOutput(dir).SetOverwritePolicy(retrieve.RenameUnique).Exec()
+		assert.NoError(t, err)
+	}
+
+	for name, want := range map[string]string{
+		"file.txt":     "local",
+		"file (1).txt": "remote",
+		"file (2).txt": "remote",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err, name)
+		assert.Equal(t, want, string(data), name)
+	}
+}
+
+func TestExec_SkipInDirectory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("remote"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "file.txt")
+	assert.NoError(t, os.WriteFile(output, []byte("local"), 0o644))
+
+	err := retrieve.New(server.URL + "/file.txt").SetOutput(dir).SetOverwritePolicy(retrieve.Skip).Exec()
+	assert.NoError(t, err)
+
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, "local", string(data))
+}
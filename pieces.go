@@ -0,0 +1,266 @@
+package retrieve
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// VerifyPieces verifies the download piece by piece against a list of
+// hex-encoded piece hashes, as used by torrents and Metalink.
+//
+// Every piece except the last is pieceSize bytes long. Pieces that do not
+// match are re-fetched individually with Range requests instead of
+// restarting the whole download. If a piece still does not match, Exec
+// fails with ErrChecksumMismatch and the downloaded file is deleted.
+//
+// Supported algorithms: "md5", "sha256", "sha512".
+func (b *Builder) VerifyPieces(algo string, pieceSize int64, hashes []string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	algo = strings.ToLower(algo)
+	if _, ok := hashFuncs[algo]; !ok {
+		b.err = fmt.Errorf("unsupported checksum algorithm: %s", algo)
+		return b
+	}
+	if pieceSize <= 0 {
+		b.err = fmt.Errorf("invalid piece size: %d", pieceSize)
+		return b
+	}
+	b.pieces = &pieceSet{
+		algo: algo,
+		size: pieceSize,
+	}
+	for _, h := range hashes {
+		b.pieces.hashes = append(b.pieces.hashes, strings.ToLower(strings.TrimSpace(h)))
+	}
+	return b
+}
+
+// GetPieces returns the piece hash algorithm, piece size and piece hashes, if set.
+func (b *Builder) GetPieces() (string, int64, []string) {
+	if b.pieces == nil {
+		return "", 0, nil
+	}
+	return b.pieces.algo, b.pieces.size, b.pieces.hashes
+}
+
+// pieceSet describes the expected piece hashes of a file.
+type pieceSet struct {
+	algo   string
+	size   int64
+	hashes []string
+}
+
+// digest returns the hex-encoded hash of data.
+func (p *pieceSet) digest(data []byte) string {
+	h := hashFuncs[p.algo]()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// pieceWriter hashes pieces as the response is streamed and records the
+// indices of the pieces that do not match.
+type pieceWriter struct {
+	pieces *pieceSet
+	h      hash.Hash
+	index  int
+	filled int64
+	bad    []int
+}
+
+func newPieceWriter(pieces *pieceSet) *pieceWriter {
+	return &pieceWriter{
+		pieces: pieces,
+		h:      hashFuncs[pieces.algo](),
+	}
+}
+
+func (pw *pieceWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		chunk := p[:min(int64(len(p)), pw.pieces.size-pw.filled)]
+		pw.h.Write(chunk)
+		pw.filled += int64(len(chunk))
+		p = p[len(chunk):]
+		if pw.filled == pw.pieces.size {
+			pw.finishPiece()
+		}
+	}
+	return n, nil
+}
+
+// finishPiece compares the current piece against its expected hash.
+func (pw *pieceWriter) finishPiece() {
+	if pw.index >= len(pw.pieces.hashes) || hex.EncodeToString(pw.h.Sum(nil)) != pw.pieces.hashes[pw.index] {
+		pw.bad = append(pw.bad, pw.index)
+	}
+	pw.index++
+	pw.filled = 0
+	pw.h.Reset()
+}
+
+// result returns the indices of the pieces that did not match, or an error
+// if the number of pieces written does not match the expected count.
+func (pw *pieceWriter) result() ([]int, error) {
+	if pw.filled > 0 {
+		pw.finishPiece()
+	}
+	if pw.index != len(pw.pieces.hashes) {
+		return nil, fmt.Errorf("%w: received %d pieces, expected %d", ErrChecksumMismatch, pw.index, len(pw.pieces.hashes))
+	}
+	return pw.bad, nil
+}
+
+// repairPieces re-fetches the given pieces of the file at path from rawURL
+// with Range requests, verifying each before it is written. The file is
+// truncated after the last piece if that piece is repaired.
+func (b *Builder) repairPieces(client *http.Client, rawURL, path string, bad []int, validator string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := b.memory.reserve(b.pieces.size, "piece"); err != nil {
+		return err
+	}
+	defer b.memory.release(b.pieces.size)
+
+	for _, i := range bad {
+		start := int64(i) * b.pieces.size
+		data, err := b.fetchRange(client, rawURL, start, start+b.pieces.size-1, validator)
+		if err != nil {
+			return fmt.Errorf("failed to repair piece %d: %w", i, err)
+		}
+		if b.pieces.digest(data) != b.pieces.hashes[i] {
+			return fmt.Errorf("%w: piece %d", ErrChecksumMismatch, i)
+		}
+		if _, err := f.WriteAt(data, start); err != nil {
+			return err
+		}
+		if i == len(b.pieces.hashes)-1 {
+			if err := f.Truncate(start + int64(len(data))); err != nil {
+				return err
+			}
+		}
+	}
+	return f.Close()
+}
+
+// badPieces returns the indices of the pieces of the file at path that do
+// not match their expected hash. Pieces beyond the end of the file are bad,
+// and so is the last piece if the file continues past it.
+func (p *pieceSet) badPieces(path string) ([]int, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		bad := make([]int, len(p.hashes))
+		for i := range bad {
+			bad[i] = i
+		}
+		return bad, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var bad []int
+	buf := make([]byte, p.size)
+	for i, expected := range p.hashes {
+		start := int64(i) * p.size
+		n, err := f.ReadAt(buf, start)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		last := i == len(p.hashes)-1
+		if n == 0 || p.digest(buf[:n]) != expected || (last && info.Size() > start+int64(n)) {
+			bad = append(bad, i)
+		}
+	}
+	return bad, nil
+}
+
+// fetchRange downloads bytes start through end (inclusive) of rawURL.
+func (b *Builder) fetchRange(client *http.Client, rawURL string, start, end int64, validator string) ([]byte, error) {
+	body, err := b.openRange(b.ctx, client, rawURL, start, end, validator)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(body, end-start+1)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// openRange requests bytes start through end (inclusive) of rawURL and
+// returns the response body. If validator is not empty, it is sent as
+// If-Range so that a file changed on the server is not mixed with the
+// bytes already downloaded.
+func (b *Builder) openRange(ctx context.Context, client *http.Client, rawURL string, start, end int64, validator string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range b.headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if validator != "" {
+		req.Header.Set("If-Range", validator)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK && validator != "" {
+			return nil, errors.New("file changed on the server during the download")
+		}
+		return nil, fmt.Errorf("server does not support range requests: received status code %d", resp.StatusCode)
+	}
+	// The range may only end early at the end of the file.
+	header := resp.Header.Get("Content-Range")
+	first, last, total, ok := parseByteRange(header)
+	if !ok || first != start || last > end || last != end && last != total-1 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected Content-Range %q for bytes %d-%d", header, start, end)
+	}
+	return resp.Body, nil
+}
+
+// hashFile returns the digest of the file at path using h.
+func hashFile(path string, h hash.Hash) error {
+	h.Reset()
+	return copyFile(path, h)
+}
+
+// copyFile writes the contents of the file at path to w.
+func copyFile(path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
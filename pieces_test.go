@@ -0,0 +1,104 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pieceHashes(data []byte, size int) []string {
+	var hashes []string
+	for start := 0; start < len(data); start += size {
+		sum := sha256.Sum256(data[start:min(start+size, len(data))])
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+	return hashes
+}
+
+func TestVerifyPieces(t *testing.T) {
+	b := retrieve.New("http://example.com").VerifyPieces("SHA256", 4, []string{"AB", "cd"})
+	algo, size, hashes := b.GetPieces()
+	assert.Equal(t, "sha256", algo)
+	assert.Equal(t, int64(4), size)
+	assert.Equal(t, []string{"ab", "cd"}, hashes)
+
+	assert.Error(t, retrieve.New("http://example.com").VerifyPieces("sha256", 0, nil).Exec())
+	assert.Error(t, retrieve.New("http://example.com").VerifyPieces("crc32", 4, nil).Exec())
+}
+
+func TestExec_RepairsBadPieces(t *testing.T) {
+	good := []byte("0123456789abcdefghij")
+	corrupt := bytes.Clone(good)
+	corrupt[5] = 'X'
+	corrupt[17] = 'Y'
+
+	var fullRequests, rangeRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			rangeRequests.Add(1)
+			http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(good))
+			return
+		}
+		fullRequests.Add(1)
+		w.Write(corrupt)
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256(good)
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		VerifyPieces("sha256", 8, pieceHashes(good, 8)).
+		VerifyChecksum("sha256", hex.EncodeToString(sum[:])).
+		Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), fullRequests.Load())
+	assert.Equal(t, int32(2), rangeRequests.Load())
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, good, data)
+}
+
+func TestExec_PiecesUnrepairable(t *testing.T) {
+	good := []byte("0123456789abcdefghij")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789abcdefghiX"))
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		VerifyPieces("sha256", 8, pieceHashes(good, 8)).
+		Exec()
+	assert.Error(t, err)
+
+	_, err = os.Stat(output)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExec_PiecesWrongLength(t *testing.T) {
+	good := []byte("0123456789abcdefghij")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(good[:10])
+	}))
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out.bin")).
+		VerifyPieces("sha256", 8, pieceHashes(good, 8)).
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrChecksumMismatch)
+}
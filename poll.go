@@ -0,0 +1,152 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrNotReady is returned when the artifact polled for with PollUntilReady
+// is not ready within the maximum wait.
+var ErrNotReady = errors.New("not ready")
+
+// ReadyFunc inspects a response from the status endpoint polled with
+// PollUntilReady. It reports whether the artifact is ready and, if so, the
+// URL to download it from, which may be relative to the status endpoint.
+// An empty URL means the URL of the response itself. Returning an error
+// stops polling and fails Exec with that error.
+type ReadyFunc func(resp *http.Response) (artifactURL string, ready bool, err error)
+
+// pollConfig holds the options set with PollUntilReady.
+type pollConfig struct {
+	interval time.Duration
+	maxWait  time.Duration
+	ready    ReadyFunc
+}
+
+// PollUntilReady makes Exec poll the URL until an artifact is ready, then
+// download the artifact, as for APIs that answer 202 Accepted while an
+// export is being prepared. Each poll sends the request as configured,
+// including its method and body, and the artifact is then fetched with a
+// GET. Mirrors are not used with polling.
+//
+// Polls are sent every interval, or after the delay in seconds of a
+// Retry-After header if the response has one. Transient failures are
+// polled again. If the artifact is not ready within maxWait, Exec fails
+// with ErrNotReady; a maxWait of 0 waits until the context is done.
+//
+// A nil readyFn treats any response other than 202 Accepted as ready, with
+// the artifact at its Location header or, without one, at the URL of the
+// response after redirects.
+func (b *Builder) PollUntilReady(interval, maxWait time.Duration, readyFn ReadyFunc) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if interval <= 0 {
+		b.err = fmt.Errorf("invalid poll interval: %v", interval)
+		return b
+	}
+	if maxWait < 0 {
+		b.err = fmt.Errorf("invalid poll maximum wait: %v", maxWait)
+		return b
+	}
+	if readyFn == nil {
+		readyFn = defaultReady
+	}
+	b.poll = &pollConfig{interval: interval, maxWait: maxWait, ready: readyFn}
+	return b
+}
+
+// IsPollUntilReady returns whether Exec polls until an artifact is ready.
+func (b *Builder) IsPollUntilReady() bool {
+	return b.poll != nil
+}
+
+// defaultReady is the ReadyFunc used when PollUntilReady is given none.
+func defaultReady(resp *http.Response) (string, bool, error) {
+	if resp.StatusCode == http.StatusAccepted {
+		return "", false, nil
+	}
+	return resp.Header.Get("Location"), true, nil
+}
+
+// pollUntilReady polls rawURL until the artifact is ready and returns its
+// URL.
+func (b *Builder) pollUntilReady(client *http.Client, rawURL string) (string, error) {
+	var deadline time.Time
+	if b.poll.maxWait > 0 {
+		deadline = time.Now().Add(b.poll.maxWait)
+	}
+
+	for {
+		artifactURL, ready, delay, err := b.pollOnce(client, rawURL)
+		if err == nil && ready {
+			return artifactURL, nil
+		}
+		if err != nil && !b.isRetryable(err) {
+			return "", err
+		}
+
+		if !deadline.IsZero() {
+			if remaining := time.Until(deadline); remaining <= 0 {
+				return "", fmt.Errorf("%w: gave up after %v", ErrNotReady, b.poll.maxWait)
+			} else if delay > remaining {
+				delay = remaining
+			}
+		}
+		if err := sleepContext(b.ctx, delay); err != nil {
+			return "", err
+		}
+	}
+}
+
+// pollOnce sends a single poll request. It returns the delay before the
+// next poll when the artifact is not ready.
+func (b *Builder) pollOnce(client *http.Client, rawURL string) (artifactURL string, ready bool, delay time.Duration, err error) {
+	delay = b.poll.interval
+	if err := b.rewindBody(); err != nil {
+		return "", false, delay, err
+	}
+	req, err := http.NewRequestWithContext(b.ctx, b.method, rawURL, b.body)
+	if err != nil {
+		return "", false, delay, err
+	}
+	for key, value := range b.headers {
+		req.Header.Set(key, value)
+	}
+	// Upload progress is only reported for the download.
+	b.prepareBody(req).stop()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false, delay, err
+	}
+	defer resp.Body.Close()
+
+	b.recordResponse(resp)
+	if d, ok := retryAfter(resp); ok {
+		delay = d
+	}
+	if resp.StatusCode > 399 {
+		return "", false, delay, newStatusError(resp)
+	}
+
+	artifactURL, ready, err = b.poll.ready(resp)
+	if err != nil || !ready {
+		return "", false, delay, err
+	}
+	u, err := resp.Request.URL.Parse(artifactURL)
+	if err != nil {
+		return "", false, delay, fmt.Errorf("invalid artifact URL: %w", err)
+	}
+	return u.String(), true, delay, nil
+}
+
+// useArtifactRequest switches the builder to a GET without a body for
+// downloading the artifact, and returns a function restoring the request.
+func (b *Builder) useArtifactRequest() func() {
+	method, body := b.method, b.body
+	b.method, b.body = http.MethodGet, nil
+	return func() { b.method, b.body = method, body }
+}
@@ -0,0 +1,129 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newExportServer serves an export that is ready after the given number of
+// polls: POST /exports answers 202 with a status URL, GET /status answers
+// 202 until ready and then 303 to /artifact.
+func newExportServer(t *testing.T, pollsUntilReady int32) (*httptest.Server, *atomic.Int32) {
+	var polls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if polls.Add(1) < pollsUntilReady {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Location", "/artifact")
+		w.WriteHeader(http.StatusSeeOther)
+	})
+	mux.HandleFunc("/artifact", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		w.Write([]byte("artifact"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &polls
+}
+
+func TestPollUntilReady(t *testing.T) {
+	assert.False(t, retrieve.New("http://example.com").IsPollUntilReady())
+	assert.True(t, retrieve.New("http://example.com").PollUntilReady(time.Second, 0, nil).IsPollUntilReady())
+
+	server, polls := newExportServer(t, 3)
+
+	var buf bytes.Buffer
+	result, err := retrieve.New(server.URL+"/status").
+		SetWriter(&buf).
+		PollUntilReady(10*time.Millisecond, 0, nil).
+		ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, "artifact", buf.String())
+	assert.Equal(t, int32(3), polls.Load())
+	assert.Equal(t, server.URL+"/artifact", result.URL)
+}
+
+func TestPollUntilReady_ReadyFunc(t *testing.T) {
+	var calls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/exports", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, `{"format":"csv"}`, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		if calls.Add(1) < 2 {
+			w.Write([]byte(`{"state":"running"}`))
+			return
+		}
+		w.Write([]byte(`{"state":"done","url":"files/export.csv"}`))
+	})
+	mux.HandleFunc("/files/export.csv", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a,b\n"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ready := func(resp *http.Response) (string, bool, error) {
+		var status struct {
+			State string `json:"state"`
+			URL   string `json:"url"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			return "", false, err
+		}
+		return status.URL, status.State == "done", nil
+	}
+
+	output := filepath.Join(t.TempDir(), "export.csv")
+	err := retrieve.New(server.URL+"/exports").
+		SetMethod(http.MethodPost).
+		SetBody(`{"format":"csv"}`).
+		SetOutput(output).
+		PollUntilReady(10*time.Millisecond, time.Second, ready).
+		Exec()
+	assert.NoError(t, err)
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "a,b\n", string(data))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestPollUntilReady_MaxWait(t *testing.T) {
+	server, _ := newExportServer(t, 1<<30)
+
+	start := time.Now()
+	err := retrieve.New(server.URL+"/status").
+		SetWriter(io.Discard).
+		PollUntilReady(10*time.Millisecond, 50*time.Millisecond, nil).
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrNotReady)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestPollUntilReady_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := retrieve.New(server.URL).SetWriter(io.Discard).PollUntilReady(10*time.Millisecond, 0, nil).Exec()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+
+	err = retrieve.New(server.URL).PollUntilReady(0, 0, nil).Exec()
+	assert.ErrorContains(t, err, "invalid poll interval")
+}
@@ -0,0 +1,92 @@
+package retrieve
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FileInfo describes a remote file, as reported by Probe.
+type FileInfo struct {
+	// URL is the URL of the file after redirects.
+	URL string
+
+	// Size is the size of the file in bytes, or -1 if unknown.
+	Size int64
+
+	ContentType  string
+	LastModified time.Time // zero if the server did not send it
+	ETag         string
+
+	// AcceptRanges reports whether the server supports Range requests,
+	// so an interrupted download can be resumed or split into segments.
+	AcceptRanges bool
+}
+
+// Probe issues a HEAD request and describes the remote file without
+// downloading it. Transient failures are retried like Exec.
+func (b *Builder) Probe() (*FileInfo, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	if !isValidURL(b.url) {
+		return nil, fmt.Errorf("invalid URL: %s", b.url)
+	}
+
+	if err := b.applyStoredToken(); err != nil {
+		return nil, err
+	}
+
+	client, release := b.httpClient()
+	defer release()
+
+	for attempt := 0; ; attempt++ {
+		info, err := b.probe(client, b.url)
+		if err == nil || attempt >= b.retries || !b.isRetryable(err) {
+			return info, err
+		}
+		if err := sleepContext(b.ctx, b.retryDelay(err, attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// probe performs a single HEAD request for rawURL.
+func (b *Builder) probe(client *http.Client, rawURL string) (*FileInfo, error) {
+	req, err := http.NewRequestWithContext(b.ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range b.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if !b.ignoreStatusCode && resp.StatusCode > 399 {
+		return nil, newStatusError(resp)
+	}
+
+	return newFileInfo(resp, resp.ContentLength), nil
+}
+
+// newFileInfo describes the file of resp, of the given size.
+func newFileInfo(resp *http.Response, size int64) *FileInfo {
+	info := &FileInfo{
+		URL:          resp.Request.URL.String(),
+		Size:         size,
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		AcceptRanges: strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes"),
+	}
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = lastModified
+	}
+	return info
+}
@@ -0,0 +1,83 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbe(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	data := bytes.Repeat([]byte("x"), 1234)
+
+	var method string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("ETag", `"abc"`)
+		http.ServeContent(w, r, "file.zip", modified, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	info, err := retrieve.New(server.URL).Probe()
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodHead, method)
+	assert.Equal(t, server.URL, info.URL)
+	assert.Equal(t, int64(1234), info.Size)
+	assert.Equal(t, "application/zip", info.ContentType)
+	assert.Equal(t, `"abc"`, info.ETag)
+	assert.True(t, info.LastModified.Equal(modified))
+	assert.True(t, info.AcceptRanges)
+}
+
+func TestProbe_Unknown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Transfer-Encoding", "chunked")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	info, err := retrieve.New(server.URL).Probe()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), info.Size)
+	assert.False(t, info.AcceptRanges)
+	assert.True(t, info.LastModified.IsZero())
+}
+
+func TestProbe_Retry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Length", "10")
+	}))
+	defer server.Close()
+
+	info, err := retrieve.New(server.URL).
+		SetRetries(2).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		Probe()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), info.Size)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestProbe_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := retrieve.New(server.URL).Probe()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+}
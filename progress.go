@@ -0,0 +1,104 @@
+package retrieve
+
+import (
+	"io"
+	"time"
+)
+
+// Progress describes the state of a download at the time a progress event is emitted.
+type Progress struct {
+	// URL is the URL currently being downloaded.
+	URL string
+
+	// BytesWritten is the number of bytes written to the output by the current attempt.
+	BytesWritten int64
+
+	// TotalBytes is the expected size of the response, or -1 if unknown.
+	TotalBytes int64
+
+	// Attempt is the 1-based number of the current attempt. While waiting to
+	// retry, it is the number of the attempt about to start.
+	Attempt int
+
+	// MaxAttempts is the maximum number of attempts for the current URL.
+	MaxAttempts int
+
+	// LastError is the error that caused the most recent retry, if any.
+	LastError error
+
+	// NextRetry is when the next attempt starts, or the zero time if no retry is pending.
+	NextRetry time.Time
+
+	// BytesSent is the number of bytes of the request body sent by the
+	// current attempt, for uploads with SetBody.
+	BytesSent int64
+
+	// TotalBytesToSend is the size of the request body, or -1 if unknown.
+	// It is zero for requests without a body.
+	TotalBytesToSend int64
+
+	// Segments describes each segment of a segmented download, or nil if
+	// the download uses a single connection. See SetSegments.
+	Segments []SegmentProgress
+}
+
+// Retrying reports whether the download is waiting before its next attempt.
+func (p Progress) Retrying() bool {
+	return !p.NextRetry.IsZero()
+}
+
+// RetryIn returns the time remaining until the next attempt.
+func (p Progress) RetryIn() time.Duration {
+	if !p.Retrying() {
+		return 0
+	}
+	return max(time.Until(p.NextRetry), 0)
+}
+
+// Percent returns the completion percentage, or -1 if the total size is unknown.
+func (p Progress) Percent() float64 {
+	if p.TotalBytes <= 0 {
+		return -1
+	}
+	return float64(p.BytesWritten) / float64(p.TotalBytes) * 100
+}
+
+// UploadPercent returns the percentage of the request body sent, or -1 if
+// its size is unknown.
+func (p Progress) UploadPercent() float64 {
+	if p.TotalBytesToSend <= 0 {
+		return -1
+	}
+	return float64(p.BytesSent) / float64(p.TotalBytesToSend) * 100
+}
+
+// OnProgress registers a callback invoked as the request body is sent, as
+// data is written and while waiting between retries.
+func (b *Builder) OnProgress(fn func(Progress)) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.onProgress = fn
+	return b
+}
+
+func (b *Builder) emitProgress() {
+	if b.onProgress != nil {
+		b.onProgress(b.progress)
+	}
+}
+
+// progressWriter reports every write to the builder's progress callback
+// and to any handlers serving the download.
+type progressWriter struct {
+	w io.Writer
+	b *Builder
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.b.progress.BytesWritten += int64(n)
+	pw.b.state.advance(int64(n))
+	pw.b.emitProgress()
+	return n, err
+}
@@ -0,0 +1,70 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_Progress(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	var last retrieve.Progress
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		OnProgress(func(p retrieve.Progress) {
+			last = p
+		}).
+		Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), last.BytesWritten)
+	assert.Equal(t, int64(7), last.TotalBytes)
+	assert.Equal(t, float64(100), last.Percent())
+	assert.Equal(t, 1, last.Attempt)
+	assert.False(t, last.Retrying())
+}
+
+func TestExec_ProgressDuringRetry(t *testing.T) {
+	var calls atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("success"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	var retries []retrieve.Progress
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		SetRetries(3).
+		SetRetryBackoff(time.Millisecond, 5*time.Millisecond).
+		OnProgress(func(p retrieve.Progress) {
+			if p.Retrying() {
+				retries = append(retries, p)
+			}
+		}).
+		Exec()
+	assert.NoError(t, err)
+
+	assert.Len(t, retries, 1)
+	assert.Equal(t, 2, retries[0].Attempt)
+	assert.Equal(t, 4, retries[0].MaxAttempts)
+	assert.Error(t, retries[0].LastError)
+	assert.LessOrEqual(t, retries[0].RetryIn(), 5*time.Millisecond)
+}
@@ -0,0 +1,97 @@
+package retrieve
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"slices"
+)
+
+// ErrHTTP3Unavailable is returned by Exec when EnableHTTP3 is used in a
+// build without the http3 build tag.
+var ErrHTTP3Unavailable = errors.New("HTTP/3 support requires building with -tags http3")
+
+// newHTTP3Transport returns an HTTP/3 transport using config, and a
+// function that closes it. It is only set in builds with the http3 tag.
+var newHTTP3Transport func(config *tls.Config) (http.RoundTripper, func())
+
+// ForceHTTP2 controls the use of HTTP/2 over TLS. With true, HTTP/2 is
+// negotiated even with options that would otherwise disable it, such as a
+// custom TLS config or dialer. With false, only HTTP/1.1 is used, which
+// some broken middleboxes require.
+//
+// Unencrypted http:// URLs always use HTTP/1.1.
+func (b *Builder) ForceHTTP2(enabled bool) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.http2 = &enabled
+	return b
+}
+
+// GetForceHTTP2 returns the value set with ForceHTTP2, and whether it was set.
+func (b *Builder) GetForceHTTP2() (enabled, set bool) {
+	if b.http2 == nil {
+		return false, false
+	}
+	return *b.http2, true
+}
+
+// EnableHTTP3 sends requests over HTTP/3 (QUIC), which some CDNs serve
+// much faster. Servers that do not support HTTP/3 cannot be reached.
+//
+// This is experimental and requires building with -tags http3, which adds
+// a dependency on github.com/quic-go/quic-go; otherwise Exec fails with
+// ErrHTTP3Unavailable. Options that configure the TCP connection, such as
+// proxies, dialers and connect timeouts, do not apply, while TLS options
+// do.
+func (b *Builder) EnableHTTP3() *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.http3 = true
+	return b
+}
+
+// IsHTTP3 returns whether requests are sent over HTTP/3.
+func (b *Builder) IsHTTP3() bool {
+	return b.http3
+}
+
+// checkHTTP3 fails if HTTP/3 is enabled but not compiled in.
+func (b *Builder) checkHTTP3() error {
+	if b.http3 && newHTTP3Transport == nil {
+		return ErrHTTP3Unavailable
+	}
+	return nil
+}
+
+// http3RoundTripper returns the HTTP/3 transport for the request.
+func (b *Builder) http3RoundTripper() (http.RoundTripper, func()) {
+	var base *tls.Config
+	if t, ok := b.baseClient().Transport.(*http.Transport); ok {
+		base = t.TLSClientConfig
+	}
+	return newHTTP3Transport(b.buildTLSConfig(base))
+}
+
+// configureHTTP2 applies the option set with ForceHTTP2 to t.
+func (b *Builder) configureHTTP2(t *http.Transport) {
+	if *b.http2 {
+		t.ForceAttemptHTTP2 = true
+		return
+	}
+	t.ForceAttemptHTTP2 = false
+	// A non-nil empty map disables HTTP/2.
+	t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	if t.TLSClientConfig != nil && slices.Contains(t.TLSClientConfig.NextProtos, "h2") {
+		config := t.TLSClientConfig.Clone()
+		config.NextProtos = nil
+		for _, p := range t.TLSClientConfig.NextProtos {
+			if p != "h2" {
+				config.NextProtos = append(config.NextProtos, p)
+			}
+		}
+		t.TLSClientConfig = config
+	}
+}
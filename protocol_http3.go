@@ -0,0 +1,17 @@
+//go:build http3
+
+package retrieve
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func init() {
+	newHTTP3Transport = func(config *tls.Config) (http.RoundTripper, func()) {
+		t := &http3.Transport{TLSClientConfig: config}
+		return t, func() { t.Close() }
+	}
+}
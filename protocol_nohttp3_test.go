@@ -0,0 +1,18 @@
+//go:build !http3
+
+package retrieve_test
+
+import (
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_HTTP3Unavailable(t *testing.T) {
+	b := retrieve.New("https://example.com").EnableHTTP3()
+	assert.True(t, b.IsHTTP3())
+	_, err := b.ExecBytes()
+	assert.ErrorIs(t, err, retrieve.ErrHTTP3Unavailable)
+}
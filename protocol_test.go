@@ -0,0 +1,53 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newHTTP2Server(t *testing.T) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestForceHTTP2(t *testing.T) {
+	b := retrieve.New("http://example.com")
+	_, set := b.GetForceHTTP2()
+	assert.False(t, set)
+
+	enabled, set := b.ForceHTTP2(false).GetForceHTTP2()
+	assert.True(t, set)
+	assert.False(t, enabled)
+}
+
+func TestExec_ForceHTTP2(t *testing.T) {
+	server := newHTTP2Server(t)
+	tests := []struct {
+		name    string
+		enabled bool
+		want    string
+	}{
+		{"enabled", true, "HTTP/2.0"},
+		{"disabled", false, "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := retrieve.New(server.URL).
+				SetRootCAs(server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs).
+				ForceHTTP2(tt.enabled).
+				ExecBytes()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package retrieve
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// CachingProxy is an http.Handler that fetches files from an upstream
+// server, stores them on disk and serves them to any number of clients.
+//
+// Concurrent requests for the same file share a single upstream download,
+// and clients arriving while it is in progress are streamed the data as it
+// is written. Error statuses from upstream, such as 404 Not Found, are
+// passed on to the clients, and other failures result in 502 Bad Gateway.
+type CachingProxy struct {
+	upstream  *url.URL
+	dir       string
+	configure func(*Builder)
+
+	mu      sync.Mutex
+	flights map[string]*Builder
+}
+
+// NewCachingProxy initializes a CachingProxy that forwards request paths to
+// upstream and caches the responses in dir.
+func NewCachingProxy(upstream, dir string) (*CachingProxy, error) {
+	if !isValidURL(upstream) {
+		return nil, fmt.Errorf("invalid URL: %s", upstream)
+	}
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &CachingProxy{
+		upstream: u,
+		dir:      dir,
+		flights:  make(map[string]*Builder),
+	}, nil
+}
+
+// Configure registers a function applied to every upstream Builder before it
+// executes, for example to set retries, mirrors or headers.
+func (p *CachingProxy) Configure(fn func(*Builder)) *CachingProxy {
+	p.configure = fn
+	return p
+}
+
+func (p *CachingProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	target, err := p.target(r.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sum := sha256.Sum256([]byte(target))
+	key := hex.EncodeToString(sum[:])
+	path := filepath.Join(p.dir, key)
+
+	p.mu.Lock()
+	builder, inFlight := p.flights[key]
+	if !inFlight {
+		if isExist(path + completeSuffix) {
+			p.mu.Unlock()
+			(&downloadHandler{}).serveComplete(w, r, path)
+			return
+		}
+		builder = New(target).SetOutput(path)
+		if p.configure != nil {
+			p.configure(builder)
+		}
+		p.flights[key] = builder
+		go p.fetch(key, path, target, builder)
+	}
+	p.mu.Unlock()
+
+	handler := &downloadHandler{state: builder.state, waitStart: true, forwardStatus: true}
+	handler.ServeHTTP(w, r)
+}
+
+const completeSuffix = ".complete"
+
+// fetch downloads target and marks it complete in the cache.
+func (p *CachingProxy) fetch(key, path, target string, builder *Builder) {
+	err := builder.Exec()
+	if err == nil {
+		err = os.WriteFile(path+completeSuffix, []byte(target), 0o644)
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+
+	p.mu.Lock()
+	delete(p.flights, key)
+	p.mu.Unlock()
+}
+
+// target returns the upstream URL for a proxied request URL. Paths are
+// cleaned, and paths with ".." elements leading out of the upstream path
+// are rejected.
+func (p *CachingProxy) target(u *url.URL) (string, error) {
+	rel := path.Clean(strings.TrimLeft(u.Path, "/"))
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("invalid path: %s", u.Path)
+	}
+	if rel == "." {
+		rel = ""
+	}
+
+	t := *p.upstream
+	t.Path = strings.TrimSuffix(t.Path, "/") + "/" + rel
+	t.RawPath = ""
+	t.RawQuery = u.RawQuery
+	return t.String(), nil
+}
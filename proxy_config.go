@@ -0,0 +1,58 @@
+package retrieve
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// SetProxy routes the request through the proxy at proxyURL.
+//
+// Supported schemes: "http", "https", "socks5" and "socks5h".
+// Credentials can be given in the URL's user info.
+func (b *Builder) SetProxy(proxyURL string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		b.err = fmt.Errorf("invalid proxy URL: %v", err)
+		return b
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		b.err = fmt.Errorf("unsupported proxy scheme: %s", u.Scheme)
+		return b
+	}
+	if u.Host == "" {
+		b.err = fmt.Errorf("invalid proxy URL: %s", proxyURL)
+		return b
+	}
+	b.proxy = http.ProxyURL(u)
+	return b
+}
+
+// UseEnvProxy routes the request through the proxy configured by the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables (or their
+// lowercase versions).
+func (b *Builder) UseEnvProxy() *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.proxy = http.ProxyFromEnvironment
+	return b
+}
+
+// GetProxy returns the proxy URL used for a request to rawURL, or nil if
+// the request is made directly.
+func (b *Builder) GetProxy(rawURL string) (*url.URL, error) {
+	if b.proxy == nil {
+		return nil, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return b.proxy(req)
+}
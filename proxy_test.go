@@ -0,0 +1,106 @@
+package retrieve_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCachingProxy_InvalidURL(t *testing.T) {
+	_, err := retrieve.NewCachingProxy("not a url", t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestCachingProxy(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte("toolchain:" + r.URL.Path))
+	}))
+	defer origin.Close()
+
+	p, err := retrieve.NewCachingProxy(origin.URL+"/dist", t.TempDir())
+	assert.NoError(t, err)
+
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 3)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(proxy.URL + "/go.tar.gz")
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			data, _ := io.ReadAll(resp.Body)
+			bodies[i] = string(data)
+		}()
+	}
+
+	for calls.Load() == 0 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	for _, body := range bodies {
+		assert.Equal(t, "toolchain:/dist/go.tar.gz", body)
+	}
+
+	resp, err := http.Get(proxy.URL + "/go.tar.gz")
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "toolchain:/dist/go.tar.gz", string(data))
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestCachingProxy_UpstreamStatus(t *testing.T) {
+	origin := httptest.NewServer(http.NotFoundHandler())
+	defer origin.Close()
+
+	p, err := retrieve.NewCachingProxy(origin.URL, t.TempDir())
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing.tar.gz", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestCachingProxy_PathTraversal(t *testing.T) {
+	var paths []string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte("ok"))
+	}))
+	defer origin.Close()
+
+	p, err := retrieve.NewCachingProxy(origin.URL+"/dist", t.TempDir())
+	assert.NoError(t, err)
+
+	for _, target := range []string{"/../secret", "/%2e%2e/secret", "/a/../../secret"} {
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+	assert.Empty(t, paths)
+
+	rec := httptest.NewRecorder()
+	p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a/../go.tar.gz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"/dist/go.tar.gz"}, paths)
+}
@@ -0,0 +1,225 @@
+package retrieve
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Race makes Exec send the request to the primary URL and its mirrors at
+// once, keep the first response that succeeds and cancel the others, which
+// cuts the tail latency of downloads from CDNs. With a hedge delay set with
+// SetHedgeDelay, each URL is only tried once the previous one has failed
+// or has not answered within the delay.
+//
+// Requests with a body are not raced. Every request for the file is raced,
+// including those for resumed and segmented downloads, and any cleanup
+// request goes to the URL that won.
+func (b *Builder) Race() *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.race = true
+	return b
+}
+
+// IsRace returns whether the primary URL and its mirrors are raced.
+func (b *Builder) IsRace() bool {
+	return b.race
+}
+
+// SetHedgeDelay staggers the requests of Race by d. See Race.
+func (b *Builder) SetHedgeDelay(d time.Duration) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if d < 0 {
+		b.err = fmt.Errorf("invalid hedge delay: %v", d)
+		return b
+	}
+	b.hedgeDelay = d
+	return b
+}
+
+// GetHedgeDelay returns the delay between the requests of Race.
+func (b *Builder) GetHedgeDelay() time.Duration {
+	return b.hedgeDelay
+}
+
+// execRace downloads the file from the first of the candidate URLs to respond.
+func (b *Builder) execRace(client *http.Client) error {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	rt := &raceTransport{base: base, primary: b.url, urls: b.candidateURLs(), delay: b.hedgeDelay}
+	raceClient := *client
+	raceClient.Transport = rt
+
+	if err := b.execURL(&raceClient, b.url); err != nil {
+		return err
+	}
+	return b.cleanupSource(client, rt.lastWinner())
+}
+
+// raceTransport sends requests for the primary URL to every candidate URL.
+type raceTransport struct {
+	base    http.RoundTripper
+	primary string
+	urls    []string
+	delay   time.Duration
+
+	mu     sync.Mutex
+	winner string
+}
+
+type raceResult struct {
+	index int
+	resp  *http.Response
+	err   error
+}
+
+func (t *raceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.String() != t.primary || req.Body != nil && req.Body != http.NoBody {
+		return t.base.RoundTrip(req)
+	}
+
+	results := make(chan raceResult, len(t.urls))
+	cancels := make([]context.CancelFunc, len(t.urls))
+	started, pending := 0, 0
+	launch := func() {
+		i := started
+		started++
+		pending++
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels[i] = cancel
+		go func() {
+			resp, err := t.send(ctx, req, t.urls[i])
+			results <- raceResult{index: i, resp: resp, err: err}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(t.delay)
+	defer timer.Stop()
+
+	var failed []raceResult
+	for pending > 0 {
+		var next <-chan time.Time
+		if started < len(t.urls) {
+			next = timer.C
+		}
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil && r.resp.StatusCode < 400 {
+				t.mu.Lock()
+				t.winner = t.urls[r.index]
+				t.mu.Unlock()
+				for i, cancel := range cancels {
+					if i != r.index && cancel != nil {
+						cancel()
+					}
+				}
+				go discardResults(results, pending)
+				for _, f := range failed {
+					closeResult(f, cancels)
+				}
+				r.resp.Body = &cancelBody{ReadCloser: r.resp.Body, cancel: cancels[r.index]}
+				return r.resp, nil
+			}
+			failed = append(failed, r)
+			// A failure starts the next URL right away.
+			if started < len(t.urls) {
+				launch()
+				timer.Reset(t.delay)
+			}
+		case <-next:
+			launch()
+			timer.Reset(t.delay)
+		}
+	}
+
+	// Every URL failed: report the outcome of the first one.
+	first := failed[0]
+	for _, f := range failed[1:] {
+		if f.index < first.index {
+			first = f
+		}
+	}
+	for _, f := range failed {
+		if f.index != first.index {
+			closeResult(f, cancels)
+		}
+	}
+	if first.err != nil {
+		cancels[first.index]()
+		return nil, first.err
+	}
+	first.resp.Body = &cancelBody{ReadCloser: first.resp.Body, cancel: cancels[first.index]}
+	return first.resp, nil
+}
+
+// send sends a copy of req to rawURL.
+func (t *raceTransport) send(ctx context.Context, req *http.Request, rawURL string) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	r := req.Clone(ctx)
+	r.URL = u
+	r.Host = ""
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	// The client resolves redirects against the primary URL.
+	if loc := resp.Header.Get("Location"); loc != "" {
+		if abs, err := u.Parse(loc); err == nil {
+			resp.Header.Set("Location", abs.String())
+		}
+	}
+	return resp, nil
+}
+
+// lastWinner returns the URL of the last race won, or the primary URL.
+func (t *raceTransport) lastWinner() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.winner == "" {
+		return t.primary
+	}
+	return t.winner
+}
+
+// discardResults closes the responses of the n requests that lost a race.
+func discardResults(results <-chan raceResult, n int) {
+	for range n {
+		if r := <-results; r.resp != nil {
+			r.resp.Body.Close()
+		}
+	}
+}
+
+func closeResult(r raceResult, cancels []context.CancelFunc) {
+	if r.resp != nil {
+		r.resp.Body.Close()
+	}
+	cancels[r.index]()
+}
+
+// cancelBody cancels the context of its request once closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (cb *cancelBody) Close() error {
+	err := cb.ReadCloser.Close()
+	cb.cancel()
+	return err
+}
@@ -0,0 +1,121 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRace(t *testing.T) {
+	b := retrieve.New("http://example.com")
+	assert.False(t, b.IsRace())
+	b.Race().SetHedgeDelay(50 * time.Millisecond)
+	assert.True(t, b.IsRace())
+	assert.Equal(t, 50*time.Millisecond, b.GetHedgeDelay())
+}
+
+func TestSetHedgeDelay_Negative(t *testing.T) {
+	err := retrieve.New("http://example.com").SetHedgeDelay(-time.Second).Exec()
+	assert.ErrorContains(t, err, "invalid hedge delay")
+}
+
+func TestExec_RaceKeepsFastestMirror(t *testing.T) {
+	var slowCancelled atomic.Bool
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			slowCancelled.Store(true)
+		case <-time.After(5 * time.Second):
+			w.Write([]byte("from primary"))
+		}
+	}))
+	defer slow.Close()
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from mirror"))
+	}))
+	defer fast.Close()
+
+	start := time.Now()
+	result, err := retrieve.New(slow.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		SetMirrors([]string{fast.URL}).
+		Race().
+		ExecWithResult()
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, fast.URL, result.URL)
+	assert.Eventually(t, slowCancelled.Load, time.Second, 10*time.Millisecond)
+}
+
+func TestExec_RaceHedgeDelay(t *testing.T) {
+	var mirrorHits atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from primary"))
+	}))
+	defer primary.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits.Add(1)
+		w.Write([]byte("from mirror"))
+	}))
+	defer mirror.Close()
+
+	data, err := retrieve.New(primary.URL).
+		SetMirrors([]string{mirror.URL}).
+		Race().
+		SetHedgeDelay(time.Second).
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "from primary", string(data))
+	assert.Zero(t, mirrorHits.Load())
+}
+
+func TestExec_RaceFailureStartsNextMirror(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer primary.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from mirror"))
+	}))
+	defer mirror.Close()
+
+	start := time.Now()
+	data, err := retrieve.New(primary.URL).
+		SetMirrors([]string{mirror.URL}).
+		Race().
+		SetHedgeDelay(5 * time.Second).
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "from mirror", string(data))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestExec_RaceAllFail(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer primary.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer mirror.Close()
+
+	_, err := retrieve.New(primary.URL).
+		SetMirrors([]string{mirror.URL}).
+		Race().
+		ExecBytes()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
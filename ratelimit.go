@@ -0,0 +1,105 @@
+package retrieve
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxRateBurst caps how many bytes are read at once from a rate-limited body.
+const maxRateBurst = 32 << 10
+
+// SetRateLimit limits the download speed to bytesPerSecond. Zero disables the limit.
+//
+// The limit applies to the whole download: when segments are used, they
+// share a single token bucket so each receives an even share of the rate.
+func (b *Builder) SetRateLimit(bytesPerSecond int64) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if bytesPerSecond < 0 {
+		b.err = fmt.Errorf("invalid rate limit: %d", bytesPerSecond)
+		return b
+	}
+	b.rateLimit = bytesPerSecond
+	return b
+}
+
+// GetRateLimit returns the download speed limit in bytes per second, or 0 if unlimited.
+func (b *Builder) GetRateLimit() int64 {
+	return b.rateLimit
+}
+
+// rateLimiter is a token bucket shared by every reader of a download.
+//
+// Readers take tokens after each read and may leave the bucket in debt;
+// later readers wait for the debt to be repaid first, so concurrent
+// readers are served in turn.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter for bytesPerSecond, or nil if it is zero.
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	burst := float64(min(max(bytesPerSecond/10, 1), maxRateBurst))
+	return &rateLimiter{
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait takes n tokens from the bucket, blocking until any debt is repaid or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	return sleepContext(ctx, delay)
+}
+
+// limitReader returns r throttled by the builder's rate limiter, if any.
+func (b *Builder) limitReader(ctx context.Context, r io.Reader) io.Reader {
+	if b.limiter == nil {
+		return r
+	}
+	return &limitedReader{ctx: ctx, r: r, l: b.limiter}
+}
+
+// limitedReader reads from r in chunks no larger than the limiter's burst,
+// waiting for tokens after each read.
+type limitedReader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *rateLimiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > int(lr.l.burst) {
+		p = p[:int(lr.l.burst)]
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		if waitErr := lr.l.wait(lr.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
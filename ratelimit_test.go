@@ -0,0 +1,72 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetRateLimit(t *testing.T) {
+	assert.Equal(t, int64(0), retrieve.New("http://example.com").GetRateLimit())
+	assert.Equal(t, int64(1024), retrieve.New("http://example.com").SetRateLimit(1024).GetRateLimit())
+	assert.Error(t, retrieve.New("http://example.com").SetRateLimit(-1).Exec())
+}
+
+func TestExec_RateLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 30<<10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	start := time.Now()
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetRateLimit(100 << 10).
+		Exec()
+	assert.NoError(t, err)
+	// The first 10 KiB are covered by the initial burst.
+	assert.GreaterOrEqual(t, time.Since(start), 180*time.Millisecond)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+}
+
+func TestExec_RateLimitSharedBySegments(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 300<<10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	var last retrieve.Progress
+	start := time.Now()
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out.bin")).
+		SetSegments(3).
+		SetRateLimit(1 << 20).
+		OnProgress(func(p retrieve.Progress) { last = p }).
+		Exec()
+	assert.NoError(t, err)
+
+	// Segments share one limit rather than each getting the full rate.
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	if assert.Len(t, last.Segments, 3) {
+		lowest, highest := last.Segments[0].BytesPerSecond, last.Segments[0].BytesPerSecond
+		for _, seg := range last.Segments {
+			lowest = min(lowest, seg.BytesPerSecond)
+			highest = max(highest, seg.BytesPerSecond)
+		}
+		assert.Less(t, highest/lowest, 2.0)
+	}
+}
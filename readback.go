@@ -0,0 +1,79 @@
+package retrieve
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"os"
+)
+
+// VerifyReadBack makes Exec flush the downloaded file to disk with fsync,
+// then read it back and hash it again before reporting success, catching
+// silent corruption on the way to the disk, such as from faulty memory,
+// controllers or filesystems. It is meant for content where a bad write is
+// costly, such as firmware images.
+//
+// The file is compared with the checksum set with VerifyChecksum, or
+// otherwise with a SHA-256 digest of the data as it was written. When they
+// differ, the file is deleted and Exec fails with ErrChecksumMismatch. The
+// operating system may serve the read from its cache, so this does not
+// detect every fault of the storage device itself.
+//
+// It has no effect when the output is a writer set with SetWriter.
+func (b *Builder) VerifyReadBack() *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.verifyReadBack = true
+	return b
+}
+
+// IsVerifyReadBack returns whether the downloaded file is read back and verified.
+func (b *Builder) IsVerifyReadBack() bool {
+	return b.verifyReadBack
+}
+
+// newReadBackHash returns the hash of the data written to compare the
+// file with, when reading back without a checksum, or nil.
+func (b *Builder) newReadBackHash() hash.Hash {
+	if !b.verifyReadBack || b.checksumAlgo != "" {
+		return nil
+	}
+	return sha256.New()
+}
+
+// readBack syncs the file at path and checks its content against the
+// checksum or, without one, against the digest of the data written.
+func (b *Builder) readBack(path string, written hash.Hash) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if written == nil {
+		h := b.newHash()
+		if err := hashFile(path, h); err != nil {
+			return err
+		}
+		if err := b.verifyHash(h); err != nil {
+			return fmt.Errorf("read-back of %s: %w", path, err)
+		}
+		return nil
+	}
+	h := sha256.New()
+	if err := hashFile(path, h); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), written.Sum(nil)) {
+		return fmt.Errorf("%w: read-back of %s differs from the data written", ErrChecksumMismatch, path)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package retrieve_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const firmware = "firmware image"
+
+func newFirmwareServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(firmware))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// corruptOnCompletion returns a progress callback that flips the first
+// byte of the partial download of output on disk once the whole body has
+// been written.
+func corruptOnCompletion(t *testing.T, output string) func(retrieve.Progress) {
+	return func(p retrieve.Progress) {
+		if p.BytesWritten != int64(len(firmware)) {
+			return
+		}
+		f, err := os.OpenFile(output+".part", os.O_WRONLY, 0)
+		if assert.NoError(t, err) {
+			f.WriteAt([]byte{'F'}, 0)
+			f.Close()
+		}
+	}
+}
+
+func TestExec_VerifyReadBack(t *testing.T) {
+	server := newFirmwareServer(t)
+	sum := sha256.Sum256([]byte(firmware))
+
+	tests := []struct {
+		name     string
+		checksum bool
+	}{
+		{"with checksum", true},
+		{"without checksum", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "fw.bin")
+			b := retrieve.New(server.URL).SetOutput(output).VerifyReadBack()
+			if tt.checksum {
+				b.VerifyChecksum("sha256", hex.EncodeToString(sum[:]))
+			}
+			assert.True(t, b.IsVerifyReadBack())
+			assert.NoError(t, b.Exec())
+			assert.FileExists(t, output)
+		})
+	}
+}
+
+func TestExec_VerifyReadBackDetectsCorruption(t *testing.T) {
+	server := newFirmwareServer(t)
+	sum := sha256.Sum256([]byte(firmware))
+
+	tests := []struct {
+		name     string
+		checksum bool
+	}{
+		{"with checksum", true},
+		{"without checksum", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "fw.bin")
+			b := retrieve.New(server.URL).
+				SetOutput(output).
+				VerifyReadBack().
+				OnProgress(corruptOnCompletion(t, output))
+			if tt.checksum {
+				b.VerifyChecksum("sha256", hex.EncodeToString(sum[:]))
+			}
+			err := b.Exec()
+			assert.ErrorIs(t, err, retrieve.ErrChecksumMismatch)
+			assert.ErrorContains(t, err, "read-back")
+			assert.NoFileExists(t, output)
+			assert.NoFileExists(t, output+".part")
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+)
+
+const defaultMaxRedirects = 10
+
+// ErrTooManyRedirects is returned when a redirect chain exceeds the limit set with SetMaxRedirects.
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// RedirectError is returned when the server responds with a redirect that
+// is not followed because of NoFollowRedirects.
+type RedirectError struct {
+	StatusCode int
+	Location   string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("received redirect %d to %s", e.StatusCode, e.Location)
+}
+
+// redirectPolicyError marks an error returned by the redirect policy so
+// that it is not retried.
+type redirectPolicyError struct {
+	err error
+}
+
+func (e *redirectPolicyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *redirectPolicyError) Unwrap() error {
+	return e.err
+}
+
+// SetMaxRedirects limits how many redirects are followed before Exec fails
+// with ErrTooManyRedirects.
+func (b *Builder) SetMaxRedirects(n int) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if n < 0 {
+		n = 0
+	}
+	b.maxRedirects = n
+	return b
+}
+
+// GetMaxRedirects returns the maximum number of redirects followed, or -1
+// if the client's default policy applies.
+func (b *Builder) GetMaxRedirects() int {
+	return b.maxRedirects
+}
+
+// NoFollowRedirects stops Exec from following redirects. A redirect
+// response fails with a *RedirectError unless IgnoreStatusCode is set, in
+// which case its body is written to the output.
+func (b *Builder) NoFollowRedirects() *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.noFollowRedirects = true
+	return b
+}
+
+// IsNoFollowRedirects returns whether redirects are followed.
+func (b *Builder) IsNoFollowRedirects() bool {
+	return b.noFollowRedirects
+}
+
+// OnRedirect registers a hook called before following each redirect with
+// the upcoming request and the requests made so far, oldest first.
+//
+// Returning an error stops the redirect chain and fails Exec with that error.
+func (b *Builder) OnRedirect(fn func(req *http.Request, via []*http.Request) error) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.onRedirect = fn
+	return b
+}
+
+// PreserveMethodOnRedirect keeps the method and body of a request other
+// than GET or HEAD, such as a POST, when following a 301, 302 or 303
+// redirect, as is always done for 307 and 308. By default, and as RFC 9110
+// allows, those redirects are followed with a GET without the body, which
+// some APIs expect and others do not.
+//
+// Without arguments, it applies to 301, 302 and 303; otherwise only to the
+// status codes given.
+func (b *Builder) PreserveMethodOnRedirect(statusCodes ...int) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if len(statusCodes) == 0 {
+		statusCodes = []int{http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther}
+	}
+	for _, code := range statusCodes {
+		if code != http.StatusMovedPermanently && code != http.StatusFound && code != http.StatusSeeOther {
+			b.err = fmt.Errorf("invalid redirect status code for PreserveMethodOnRedirect: %d", code)
+			return b
+		}
+	}
+	b.preserveRedirects = statusCodes
+	return b
+}
+
+// GetPreserveMethodOnRedirect returns the redirect status codes for which
+// the method and body are kept.
+func (b *Builder) GetPreserveMethodOnRedirect() []int {
+	return b.preserveRedirects
+}
+
+// hasRedirectPolicy reports whether any redirect option is set.
+func (b *Builder) hasRedirectPolicy() bool {
+	return b.maxRedirects >= 0 || b.noFollowRedirects || b.onRedirect != nil || b.hasHeaderLimits() ||
+		len(b.preserveRedirects) > 0 || b.blockPrivate || b.urlPolicy != nil || b.logger != nil ||
+		b.usesOtherSchemes()
+}
+
+// checkRedirect implements http.Client.CheckRedirect for the builder's
+// redirect options. Without a limit set, it defers to next or, if nil, to
+// the standard limit of 10 redirects.
+func (b *Builder) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if b.noFollowRedirects {
+			return http.ErrUseLastResponse
+		}
+
+		limit := b.maxRedirects
+		if limit < 0 && next == nil {
+			limit = defaultMaxRedirects
+		}
+		if limit >= 0 && len(via) > limit {
+			return &redirectPolicyError{err: fmt.Errorf("%w: stopped after %d redirects", ErrTooManyRedirects, limit)}
+		}
+
+		if err := checkRedirectScheme(req, via); err != nil {
+			return &redirectPolicyError{err: err}
+		}
+
+		if b.hasHeaderLimits() {
+			if err := b.checkHeaderLimits(req.Response); err != nil {
+				return &redirectPolicyError{err: err}
+			}
+		}
+
+		if b.blockPrivate {
+			if err := checkAllowedURL(req.URL); err != nil {
+				return &redirectPolicyError{err: err}
+			}
+		}
+
+		if b.urlPolicy != nil {
+			if err := b.applyURLPolicy(req.URL); err != nil {
+				return &redirectPolicyError{err: err}
+			}
+		}
+
+		if len(b.preserveRedirects) > 0 {
+			if err := b.preserveMethod(req, via); err != nil {
+				return &redirectPolicyError{err: err}
+			}
+		}
+
+		if b.onRedirect != nil {
+			if err := b.onRedirect(req, via); err != nil {
+				return &redirectPolicyError{err: err}
+			}
+		}
+
+		if limit < 0 {
+			if err := next(req, via); err != nil {
+				return err
+			}
+		}
+		b.logRedirect(req, via)
+		return nil
+	}
+}
+
+// checkRedirectScheme refuses redirects from http or https URLs to other
+// schemes, such as file or data.
+func checkRedirectScheme(req *http.Request, via []*http.Request) error {
+	from := via[len(via)-1].URL.Scheme
+	if from != "http" && from != "https" || req.URL.Scheme == "http" || req.URL.Scheme == "https" {
+		return nil
+	}
+	return fmt.Errorf("refusing redirect from %s to %s URL %s", from, req.URL.Scheme, req.URL.Redacted())
+}
+
+// bodyHeaders are the headers describing a request body, which the client
+// drops along with the body when a redirect changes the method to GET.
+var bodyHeaders = []string{"Content-Type", "Content-Encoding", "Content-Language", "Content-Location"}
+
+// preserveMethod restores the method and body of the original request on a
+// redirect the client changed to GET, and keeps sending the body on later
+// redirects.
+func (b *Builder) preserveMethod(req *http.Request, via []*http.Request) error {
+	first, prev := via[0], via[len(via)-1]
+	if first.Method == http.MethodGet || first.Method == http.MethodHead || prev.Method != first.Method {
+		return nil
+	}
+	if req.Method != first.Method {
+		if req.Response == nil || !slices.Contains(b.preserveRedirects, req.Response.StatusCode) {
+			return nil
+		}
+		req.Method = first.Method
+	}
+	if req.Body != nil && req.Body != http.NoBody || first.Body == nil || first.Body == http.NoBody {
+		return nil
+	}
+
+	getBody := first.GetBody
+	if getBody == nil {
+		if _, ok := b.body.(io.Seeker); !ok {
+			return fmt.Errorf("cannot resend the %s body to %s", first.Method, req.URL)
+		}
+		getBody = func() (io.ReadCloser, error) {
+			if err := b.rewindBody(); err != nil {
+				return nil, err
+			}
+			return io.NopCloser(b.body), nil
+		}
+	}
+	body, err := getBody()
+	if err != nil {
+		return err
+	}
+	req.Body, req.GetBody = body, getBody
+	req.ContentLength = first.ContentLength
+	for _, key := range bodyHeaders {
+		if v, ok := first.Header[key]; ok && req.Header.Get(key) == "" {
+			req.Header[key] = v
+		}
+	}
+	return nil
+}
+
+// checkRedirectResponse fails for redirect responses that were not followed.
+func (b *Builder) checkRedirectResponse(resp *http.Response) error {
+	if !b.noFollowRedirects || b.ignoreStatusCode {
+		return nil
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") != "" {
+		return &RedirectError{StatusCode: resp.StatusCode, Location: resp.Header.Get("Location")}
+	}
+	return nil
+}
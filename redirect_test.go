@@ -0,0 +1,171 @@
+package retrieve_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newRedirectServer returns a server that redirects /n to /n-1 until /0.
+func newRedirectServer(calls *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls != nil {
+			calls.Add(1)
+		}
+		n, _ := strconv.Atoi(r.URL.Path[1:])
+		if n > 0 {
+			http.Redirect(w, r, "/"+strconv.Itoa(n-1), http.StatusFound)
+			return
+		}
+		w.Write([]byte("final"))
+	}))
+}
+
+func TestSetMaxRedirects(t *testing.T) {
+	var calls atomic.Int32
+	server := newRedirectServer(&calls)
+	defer server.Close()
+
+	b := retrieve.New(server.URL+"/3").
+		SetMaxRedirects(2).
+		SetRetries(2).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt"))
+	assert.Equal(t, 2, b.GetMaxRedirects())
+	assert.ErrorIs(t, b.Exec(), retrieve.ErrTooManyRedirects)
+	assert.Equal(t, int32(3), calls.Load())
+
+	err := retrieve.New(server.URL + "/2").
+		SetMaxRedirects(2).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		Exec()
+	assert.NoError(t, err)
+}
+
+func TestNoFollowRedirects(t *testing.T) {
+	server := newRedirectServer(nil)
+	defer server.Close()
+
+	b := retrieve.New(server.URL + "/1").
+		NoFollowRedirects().
+		SetOutput(filepath.Join(t.TempDir(), "out.txt"))
+	assert.True(t, b.IsNoFollowRedirects())
+
+	var redirectErr *retrieve.RedirectError
+	assert.ErrorAs(t, b.Exec(), &redirectErr)
+	assert.Equal(t, http.StatusFound, redirectErr.StatusCode)
+	assert.Equal(t, "/0", redirectErr.Location)
+}
+
+func TestOnRedirect(t *testing.T) {
+	server := newRedirectServer(nil)
+	defer server.Close()
+
+	var hops []string
+	output := filepath.Join(t.TempDir(), "out.txt")
+	err := retrieve.New(server.URL + "/2").
+		OnRedirect(func(req *http.Request, via []*http.Request) error {
+			hops = append(hops, req.URL.Path)
+			return nil
+		}).
+		SetOutput(output).
+		Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/1", "/0"}, hops)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "final", string(data))
+
+	blocked := errors.New("blocked")
+	err = retrieve.New(server.URL + "/1").
+		OnRedirect(func(req *http.Request, via []*http.Request) error {
+			return blocked
+		}).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		Exec()
+	assert.ErrorIs(t, err, blocked)
+}
+
+// newEchoRedirectServer redirects /submit to /result through the given
+// status codes, one per hop, and /result echoes the method, Content-Type
+// and body it received.
+func newEchoRedirectServer(codes ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hop, _ := strconv.Atoi(r.URL.Query().Get("hop"))
+		if r.URL.Path == "/submit" && hop < len(codes) {
+			http.Redirect(w, r, fmt.Sprintf("/submit?hop=%d", hop+1), codes[hop])
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("Content-Type"), body)
+	}))
+}
+
+func TestPreserveMethodOnRedirect(t *testing.T) {
+	assert.Nil(t, retrieve.New("http://example.com").GetPreserveMethodOnRedirect())
+	assert.Equal(t, []int{301, 302, 303}, retrieve.New("http://example.com").PreserveMethodOnRedirect().GetPreserveMethodOnRedirect())
+	assert.Error(t, retrieve.New("http://example.com").PreserveMethodOnRedirect(307).Exec())
+
+	tests := []struct {
+		name     string
+		codes    []int
+		preserve []int
+		want     string
+	}{
+		{"default", []int{302}, nil, "GET  "},
+		{"preserved", []int{302}, []int{}, "POST text/plain payload"},
+		{"other code", []int{302}, []int{303}, "GET  "},
+		{"then 307", []int{301, 307}, []int{301}, "POST text/plain payload"},
+		{"307 only", []int{307}, nil, "POST text/plain payload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newEchoRedirectServer(tt.codes...)
+			defer server.Close()
+
+			b := retrieve.New(server.URL+"/submit").
+				SetMethod("POST").
+				SetHeader("Content-Type", "text/plain").
+				SetBody(strings.NewReader("payload"))
+			if tt.preserve != nil {
+				b.PreserveMethodOnRedirect(tt.preserve...)
+			}
+			data, err := b.ExecBytes()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+		})
+	}
+}
+
+func TestPreserveMethodOnRedirect_File(t *testing.T) {
+	server := newEchoRedirectServer(http.StatusFound)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "body.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("from a file"), 0o644))
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	data, err := retrieve.New(server.URL + "/submit").
+		SetMethod("POST").
+		SetBody(f).
+		PreserveMethodOnRedirect().
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "POST  from a file", string(data))
+}
@@ -0,0 +1,98 @@
+package retrieve
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// RenameData is the data available to the template set with SetRenameTemplate.
+type RenameData struct {
+	// Name is the file name the download was saved under, such as "tool.tar.gz".
+	Name string
+	// Stem is Name without its extension, such as "tool.tar".
+	Stem string
+	// Ext is the extension of Name, such as ".gz".
+	Ext string
+	// URL is the URL of the final response.
+	URL string
+	// StatusCode is the status code of the final response.
+	StatusCode int
+	// Header holds the headers of the final response.
+	Header http.Header
+	// Metadata holds the metadata returned by the Resolvers of the URL, if any.
+	Metadata map[string]string
+}
+
+// SetRenameTemplate renames the downloaded file once complete, to the name
+// produced by a text/template executed with a RenameData, so the name can
+// include data only known from the response. For example
+//
+//	SetRenameTemplate(`{{.Stem}}-{{.Header.Get "X-Version"}}{{.Ext}}`)
+//
+// saves tool.zip as tool-1.2.3.zip. The file stays in the same directory,
+// and the overwrite policy applies if the new name is taken: with Skip, the
+// existing file is kept and the download removed, and with ErrorIfExists,
+// the download is left under its original name.
+func (b *Builder) SetRenameTemplate(text string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	tmpl, err := template.New("rename").Option("missingkey=error").Parse(text)
+	if err != nil {
+		b.err = fmt.Errorf("invalid rename template: %w", err)
+		return b
+	}
+	b.renameTemplate = tmpl
+	b.renameText = text
+	return b
+}
+
+// GetRenameTemplate returns the text of the rename template, if any.
+func (b *Builder) GetRenameTemplate() string {
+	return b.renameText
+}
+
+// renameOutput applies the rename template to the download completed at
+// outputPath. It returns the new path, and skip if the download was
+// removed in favor of an existing file.
+func (b *Builder) renameOutput(outputPath string, resp *http.Response) (newPath string, skip bool, err error) {
+	name := filepath.Base(outputPath)
+	ext := filepath.Ext(name)
+	data := RenameData{
+		Name:       name,
+		Stem:       strings.TrimSuffix(name, ext),
+		Ext:        ext,
+		URL:        b.result.URL,
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Metadata:   b.resolverMetadata,
+	}
+	var sb strings.Builder
+	if err := b.renameTemplate.Execute(&sb, data); err != nil {
+		return "", false, fmt.Errorf("rename template: %w", err)
+	}
+	newName := sanitizeFilename(sb.String())
+	if newName == "" {
+		return "", false, fmt.Errorf("rename template produced an invalid file name: %q", sb.String())
+	}
+
+	newPath = filepath.Join(filepath.Dir(outputPath), newName)
+	if newPath == outputPath {
+		return outputPath, false, nil
+	}
+	newPath, skip, err = b.checkOverwrite(newPath)
+	if err != nil {
+		return "", false, err
+	}
+	if skip {
+		return newPath, true, os.Remove(outputPath)
+	}
+	if err := os.Rename(outputPath, newPath); err != nil {
+		return "", false, err
+	}
+	return newPath, false, nil
+}
@@ -0,0 +1,104 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newVersionServer(t *testing.T, version string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Version", version)
+		w.Write([]byte("version " + version))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSetRenameTemplate(t *testing.T) {
+	text := `{{.Stem}}-{{.Header.Get "X-Version"}}{{.Ext}}`
+	b := retrieve.New("http://example.com").SetRenameTemplate(text)
+	assert.Equal(t, text, b.GetRenameTemplate())
+
+	err := retrieve.New("http://example.com").SetRenameTemplate("{{.Stem").Exec()
+	assert.ErrorContains(t, err, "invalid rename template")
+}
+
+func TestExec_RenameTemplate(t *testing.T) {
+	server := newVersionServer(t, "1.2.3")
+	dir := t.TempDir()
+
+	result, err := retrieve.New(server.URL + "/tool.zip").
+		SetOutput(dir + string(filepath.Separator)).
+		SetRenameTemplate(`{{.Stem}}-{{.Header.Get "X-Version"}}{{.Ext}}`).
+		ExecWithResult()
+	assert.NoError(t, err)
+
+	want := filepath.Join(dir, "tool-1.2.3.zip")
+	assert.Equal(t, want, result.Output)
+	data, err := os.ReadFile(want)
+	assert.NoError(t, err)
+	assert.Equal(t, "version 1.2.3", string(data))
+	assert.NoFileExists(t, filepath.Join(dir, "tool.zip"))
+}
+
+func TestExec_RenameTemplateCollision(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   retrieve.OverwritePolicy
+		wantErr  error
+		want     string // content of tool-1.0.zip
+		wantFile string // where the download ends up
+	}{
+		{"overwrite", retrieve.Overwrite, nil, "version 1.0", "tool-1.0.zip"},
+		{"skip", retrieve.Skip, nil, "existing", "tool-1.0.zip"},
+		{"error", retrieve.ErrorIfExists, retrieve.ErrFileExists, "existing", "tool.zip"},
+		{"unique", retrieve.RenameUnique, nil, "existing", "tool-1.0 (1).zip"},
+	}
+	server := newVersionServer(t, "1.0")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			existing := filepath.Join(dir, "tool-1.0.zip")
+			assert.NoError(t, os.WriteFile(existing, []byte("existing"), 0o644))
+
+			result, err := retrieve.New(server.URL + "/tool.zip").
+				SetOutput(filepath.Join(dir, "tool.zip")).
+				SetOverwritePolicy(tt.policy).
+				SetRenameTemplate(`{{.Stem}}-{{.Header.Get "X-Version"}}{{.Ext}}`).
+				ExecWithResult()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, filepath.Join(dir, tt.wantFile), result.Output)
+			}
+
+			data, err := os.ReadFile(existing)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+			assert.FileExists(t, filepath.Join(dir, tt.wantFile))
+			if tt.policy == retrieve.Skip {
+				assert.NoFileExists(t, filepath.Join(dir, "tool.zip"))
+			}
+		})
+	}
+}
+
+func TestExec_RenameTemplateSanitized(t *testing.T) {
+	server := newVersionServer(t, "../../escape")
+	dir := t.TempDir()
+
+	result, err := retrieve.New(server.URL + "/tool.zip").
+		SetOutput(filepath.Join(dir, "tool.zip")).
+		SetRenameTemplate(`{{.Header.Get "X-Version"}}`).
+		ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "escape"), result.Output)
+}
@@ -0,0 +1,96 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// Repair verifies an existing output file and re-downloads only the parts
+// that are corrupt, which is useful for fixing bit-rotted copies in place.
+//
+// With VerifyPieces, each piece of the file is checked and the pieces that
+// do not match (or are missing) are re-fetched with Range requests from the
+// URL or its mirrors. With only VerifyChecksum, the whole file is checked
+// and downloaded again with Exec if it does not match.
+// When both are set, the full checksum is verified after the pieces are repaired.
+//
+// The output must be a file path, not a directory.
+func (b *Builder) Repair() error {
+	if b.err != nil {
+		return b.err
+	}
+
+	if !isValidURL(b.url) {
+		return fmt.Errorf("invalid URL: %s", b.url)
+	}
+
+	if b.pieces == nil && b.checksum == "" {
+		return errors.New("repair requires VerifyPieces or VerifyChecksum")
+	}
+
+	if b.output == "" || b.output == stdoutPath {
+		return errors.New("repair requires an output file path")
+	}
+	if isExist(b.output) {
+		isDir, err := isDirectory(b.output)
+		if err != nil {
+			return err
+		}
+		if isDir {
+			return fmt.Errorf("repair requires an output file path, got directory: %s", b.output)
+		}
+	}
+
+	b.memory = newMemoryBudget(b.memoryBudget)
+
+	if b.pieces == nil {
+		h := b.newHash()
+		err := hashFile(b.output, h)
+		if err == nil && b.verifyHash(h) == nil {
+			return nil
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return b.Exec()
+	}
+
+	bad, err := b.pieces.badPieces(b.output)
+	if err != nil {
+		return err
+	}
+	if len(bad) > 0 {
+		if err := b.repairFrom(bad); err != nil {
+			return err
+		}
+	}
+
+	if h := b.newHash(); h != nil {
+		if err := hashFile(b.output, h); err != nil {
+			return err
+		}
+		return b.verifyHash(h)
+	}
+	return nil
+}
+
+// repairFrom re-fetches the bad pieces of the output, trying each candidate
+// URL until one succeeds.
+func (b *Builder) repairFrom(bad []int) error {
+	client, release := b.httpClient()
+	defer release()
+
+	var errs []error
+	for _, rawURL := range b.candidateURLs() {
+		err := b.repairPieces(client, rawURL, b.output, bad, "")
+		if err == nil {
+			return nil
+		}
+		if b.ctx.Err() != nil || len(b.mirrors) == 0 {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", rawURL, err))
+	}
+	return fmt.Errorf("all mirrors failed: %w", errors.Join(errs...))
+}
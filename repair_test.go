@@ -0,0 +1,134 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRangeServer(data []byte, ranges, full *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranges.Add(1)
+		} else {
+			full.Add(1)
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+}
+
+func TestRepair_Pieces(t *testing.T) {
+	good := []byte("0123456789abcdefghij")
+	corrupt := bytes.Clone(good)
+	corrupt[5] = 'X'
+
+	var ranges, full atomic.Int32
+	server := newRangeServer(good, &ranges, &full)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	assert.NoError(t, os.WriteFile(output, corrupt, 0o644))
+
+	sum := sha256.Sum256(good)
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		VerifyPieces("sha256", 4, pieceHashes(good, 4)).
+		VerifyChecksum("sha256", hex.EncodeToString(sum[:])).
+		Repair()
+	assert.NoError(t, err)
+
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, good, data)
+	assert.Equal(t, int32(1), ranges.Load())
+	assert.Equal(t, int32(0), full.Load())
+}
+
+func TestRepair_TruncatedAndOversized(t *testing.T) {
+	good := []byte("0123456789abcdefghi")
+
+	var ranges, full atomic.Int32
+	server := newRangeServer(good, &ranges, &full)
+	defer server.Close()
+
+	dir := t.TempDir()
+	for name, content := range map[string][]byte{
+		"truncated": good[:9],
+		"oversized": append(bytes.Clone(good), "junk"...),
+		"missing":   nil,
+	} {
+		output := filepath.Join(dir, name)
+		if content != nil {
+			assert.NoError(t, os.WriteFile(output, content, 0o644))
+		}
+		err := retrieve.New(server.URL).
+			SetOutput(output).
+			VerifyPieces("sha256", 4, pieceHashes(good, 4)).
+			Repair()
+		assert.NoError(t, err, name)
+
+		data, _ := os.ReadFile(output)
+		assert.Equal(t, good, data, name)
+	}
+	assert.Equal(t, int32(0), full.Load())
+}
+
+func TestRepair_Intact(t *testing.T) {
+	good := []byte("0123456789abcdefghij")
+
+	var ranges, full atomic.Int32
+	server := newRangeServer(good, &ranges, &full)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	assert.NoError(t, os.WriteFile(output, good, 0o644))
+
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		VerifyPieces("sha256", 4, pieceHashes(good, 4)).
+		Repair()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), ranges.Load()+full.Load())
+}
+
+func TestRepair_ChecksumOnly(t *testing.T) {
+	good := []byte("0123456789abcdefghij")
+
+	var ranges, full atomic.Int32
+	server := newRangeServer(good, &ranges, &full)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	assert.NoError(t, os.WriteFile(output, []byte("corrupt"), 0o644))
+
+	sum := sha256.Sum256(good)
+	b := retrieve.New(server.URL).
+		SetOutput(output).
+		VerifyChecksum("sha256", hex.EncodeToString(sum[:]))
+	assert.NoError(t, b.Repair())
+
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, good, data)
+	assert.Equal(t, int32(1), full.Load())
+
+	assert.NoError(t, b.Repair())
+	assert.Equal(t, int32(1), full.Load())
+}
+
+func TestRepair_Errors(t *testing.T) {
+	assert.Error(t, retrieve.New("http://example.com").SetOutput("out.bin").Repair())
+	assert.Error(t, retrieve.New("http://example.com").
+		SetOutput(t.TempDir()).
+		VerifyPieces("sha256", 4, []string{"ab"}).
+		Repair())
+}
@@ -0,0 +1,139 @@
+package retrieve
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// maxResolutions limits how many times a URL is resolved, as the URL a
+// Resolver returns may itself match a Resolver.
+const maxResolutions = 10
+
+// Resolver translates a URL, typically an internal shorthand such as
+// artifact://payments/build/123, into the URL to download. The metadata it
+// returns, such as a build number or checksum, is reported in the Result.
+type Resolver interface {
+	Resolve(ctx context.Context, rawURL string) (resolvedURL string, metadata map[string]string, err error)
+}
+
+// ResolverFunc adapts a function to a Resolver.
+type ResolverFunc func(ctx context.Context, rawURL string) (string, map[string]string, error)
+
+// Resolve calls f(ctx, rawURL).
+func (f ResolverFunc) Resolve(ctx context.Context, rawURL string) (string, map[string]string, error) {
+	return f(ctx, rawURL)
+}
+
+type registeredResolver struct {
+	pattern  string
+	resolver Resolver
+}
+
+var resolvers struct {
+	mu      sync.RWMutex
+	schemes map[string]Resolver
+	hosts   []registeredResolver
+}
+
+// RegisterResolver registers r for every Builder, for the URLs matching
+// pattern: either a scheme followed by "://", such as "artifact://", or a
+// host pattern as accepted by path.Match, such as "*.artifacts.internal".
+// Schemes are matched first, then hosts in the order they were registered.
+// Registering a nil Resolver removes the one registered for pattern.
+//
+// It panics if pattern is invalid.
+func RegisterResolver(pattern string, r Resolver) {
+	resolvers.mu.Lock()
+	defer resolvers.mu.Unlock()
+
+	if scheme, ok := strings.CutSuffix(pattern, "://"); ok {
+		if scheme == "" {
+			panic("retrieve: invalid resolver pattern " + pattern)
+		}
+		scheme = strings.ToLower(scheme)
+		if r == nil {
+			delete(resolvers.schemes, scheme)
+			return
+		}
+		if resolvers.schemes == nil {
+			resolvers.schemes = make(map[string]Resolver)
+		}
+		resolvers.schemes[scheme] = r
+		return
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+		panic("retrieve: invalid resolver pattern " + pattern)
+	}
+	pattern = strings.ToLower(pattern)
+	for i, registered := range resolvers.hosts {
+		if registered.pattern == pattern {
+			if r == nil {
+				resolvers.hosts = append(resolvers.hosts[:i:i], resolvers.hosts[i+1:]...)
+			} else {
+				resolvers.hosts[i].resolver = r
+			}
+			return
+		}
+	}
+	if r != nil {
+		resolvers.hosts = append(resolvers.hosts, registeredResolver{pattern: pattern, resolver: r})
+	}
+}
+
+// lookupResolver returns the Resolver registered for rawURL, if any.
+func lookupResolver(rawURL string) Resolver {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	resolvers.mu.RLock()
+	defer resolvers.mu.RUnlock()
+	if r, ok := resolvers.schemes[strings.ToLower(u.Scheme)]; ok {
+		return r
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, registered := range resolvers.hosts {
+		if ok, _ := path.Match(registered.pattern, host); ok {
+			return registered.resolver
+		}
+	}
+	return nil
+}
+
+// resolveURL replaces the URL of the request with the one its Resolvers
+// return, and returns a function that restores it.
+func (b *Builder) resolveURL() (func(), error) {
+	rawURL := b.url
+	b.resolverMetadata = nil
+	resolved := rawURL
+	for range maxResolutions {
+		r := lookupResolver(resolved)
+		if r == nil {
+			b.url = resolved
+			return func() { b.url = rawURL }, nil
+		}
+		next, metadata, err := r.Resolve(b.ctx, resolved)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s: %w", resolved, err)
+		}
+		if len(metadata) > 0 {
+			if b.resolverMetadata == nil {
+				b.resolverMetadata = make(map[string]string)
+			}
+			maps.Copy(b.resolverMetadata, metadata)
+		}
+		if next == resolved {
+			b.url = resolved
+			return func() { b.url = rawURL }, nil
+		}
+		resolved = next
+	}
+	return nil, fmt.Errorf("resolving %s: too many resolutions", rawURL)
+}
@@ -0,0 +1,80 @@
+package retrieve_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_ResolverScheme(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	retrieve.RegisterResolver("artifact://", retrieve.ResolverFunc(func(ctx context.Context, rawURL string) (string, map[string]string, error) {
+		ref := strings.TrimPrefix(rawURL, "artifact://")
+		return server.URL + "/builds/" + ref, map[string]string{"ref": ref}, nil
+	}))
+	defer retrieve.RegisterResolver("artifact://", nil)
+
+	b := retrieve.New("artifact://payments/build/123").SetWriter(&strings.Builder{})
+	result, err := b.ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/builds/payments/build/123", result.URL)
+	assert.Equal(t, map[string]string{"ref": "payments/build/123"}, result.Metadata)
+	assert.Equal(t, "artifact://payments/build/123", b.GetUrl())
+}
+
+func TestExec_ResolverHostPattern(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("resolved"))
+	}))
+	defer server.Close()
+
+	// Chained: the scheme resolver returns a host another resolver handles.
+	retrieve.RegisterResolver("pkg://", retrieve.ResolverFunc(func(ctx context.Context, rawURL string) (string, map[string]string, error) {
+		return "http://mirror.artifacts.internal/file", nil, nil
+	}))
+	defer retrieve.RegisterResolver("pkg://", nil)
+	retrieve.RegisterResolver("*.artifacts.internal", retrieve.ResolverFunc(func(ctx context.Context, rawURL string) (string, map[string]string, error) {
+		return server.URL + "/file", nil, nil
+	}))
+	defer retrieve.RegisterResolver("*.artifacts.internal", nil)
+
+	data, err := retrieve.New("pkg://tool/1.0").ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "resolved", string(data))
+}
+
+func TestExec_ResolverError(t *testing.T) {
+	retrieve.RegisterResolver("broken://", retrieve.ResolverFunc(func(ctx context.Context, rawURL string) (string, map[string]string, error) {
+		return "", nil, errors.New("unknown build")
+	}))
+	defer retrieve.RegisterResolver("broken://", nil)
+
+	_, err := retrieve.New("broken://x/y").ExecBytes()
+	assert.ErrorContains(t, err, "resolving broken://x/y: unknown build")
+}
+
+func TestExec_ResolverLoop(t *testing.T) {
+	retrieve.RegisterResolver("loop://", retrieve.ResolverFunc(func(ctx context.Context, rawURL string) (string, map[string]string, error) {
+		return rawURL + "x", nil, nil
+	}))
+	defer retrieve.RegisterResolver("loop://", nil)
+
+	_, err := retrieve.New("loop://host/").ExecBytes()
+	assert.ErrorContains(t, err, "too many resolutions")
+}
+
+func TestRegisterResolver_InvalidPattern(t *testing.T) {
+	assert.Panics(t, func() { retrieve.RegisterResolver("://", nil) })
+	assert.Panics(t, func() { retrieve.RegisterResolver("[", nil) })
+}
@@ -0,0 +1,104 @@
+package retrieve
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Result describes a completed request.
+type Result struct {
+	// StatusCode is the status code of the final response.
+	StatusCode int
+
+	// Header holds the headers of the final response.
+	Header http.Header
+
+	// URL is the URL of the final response, after redirects and mirrors.
+	URL string
+
+	// BytesWritten is the size of the content written to the output,
+	// including any part resumed from an earlier attempt.
+	BytesWritten int64
+
+	// Output is the path of the downloaded file, or empty when the
+	// response was written to a writer set with SetWriter.
+	Output string
+
+	// Elapsed is the time taken by the whole request, including retries.
+	Elapsed time.Duration
+
+	// Metadata holds the metadata returned by the Resolvers of the URL, if any.
+	Metadata map[string]string
+
+	// ContentLanguage lists the languages of the content, from the
+	// Content-Language header of the final response.
+	ContentLanguage []string
+
+	// Vary lists the request headers, in canonical form, the server chose
+	// the content by, from the Vary header of the final response. It is
+	// ["*"] if the content may vary by anything else.
+	Vary []string
+
+	// Variant holds the value sent for each request header in Vary, such as
+	// the Accept-Language the content was negotiated with, so variants of a
+	// URL can be stored separately. Headers that were not sent map to "".
+	Variant map[string]string
+}
+
+// ExecWithResult executes the request like Exec and describes the outcome.
+//
+// The Result is returned even when the error is not nil, describing the
+// last response received, if any.
+func (b *Builder) ExecWithResult() (*Result, error) {
+	start := time.Now()
+	err := b.Exec()
+
+	result := b.result
+	if result.StatusCode != 0 {
+		result.BytesWritten = b.progress.BytesWritten
+	}
+	result.Elapsed = time.Since(start)
+	result.Metadata = b.resolverMetadata
+	return &result, err
+}
+
+// recordResponse notes the response in the Result.
+func (b *Builder) recordResponse(resp *http.Response) {
+	b.result = Result{
+		StatusCode:      resp.StatusCode,
+		Header:          resp.Header,
+		URL:             resp.Request.URL.String(),
+		ContentLanguage: headerList(resp.Header, "Content-Language", false),
+		Vary:            headerList(resp.Header, "Vary", true),
+	}
+	b.addHints(parseHints(resp.Header.Values("Link"), resp.Request.URL, false))
+	for _, name := range b.result.Vary {
+		if name == "*" {
+			continue
+		}
+		if b.result.Variant == nil {
+			b.result.Variant = make(map[string]string)
+		}
+		b.result.Variant[name] = strings.Join(resp.Request.Header.Values(name), ", ")
+	}
+}
+
+// headerList returns the comma-separated elements of the header key,
+// without duplicates, canonicalizing them as header names if names is set.
+func headerList(header http.Header, key string, names bool) []string {
+	var list []string
+	for _, value := range header.Values(key) {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if names {
+				item = http.CanonicalHeaderKey(item)
+			}
+			if item != "" && !slices.Contains(list, item) {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
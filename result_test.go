@@ -0,0 +1,108 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecWithResult(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/file.txt", http.StatusFound)
+	})
+	mux.HandleFunc("/file.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("hello, world"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	dir := t.TempDir()
+	result, err := retrieve.New(server.URL + "/old").SetOutput(dir).ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, `"v1"`, result.Header.Get("ETag"))
+	assert.Equal(t, server.URL+"/file.txt", result.URL)
+	assert.Equal(t, int64(12), result.BytesWritten)
+	assert.Equal(t, filepath.Join(dir, "old"), result.Output)
+	assert.Positive(t, result.Elapsed)
+}
+
+func TestExecWithResult_Writer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("streamed"))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	result, err := retrieve.New(server.URL).SetWriter(&buf).ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), result.BytesWritten)
+	assert.Empty(t, result.Output)
+}
+
+func TestExecWithResult_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Reason", "gone")
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	result, err := retrieve.New(server.URL).SetOutput(filepath.Join(t.TempDir(), "out")).ExecWithResult()
+	assert.Error(t, err)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, http.StatusGone, result.StatusCode)
+		assert.Equal(t, "gone", result.Header.Get("X-Reason"))
+		assert.Empty(t, result.Output)
+	}
+}
+
+func TestExecWithResult_InvalidURL(t *testing.T) {
+	result, err := retrieve.New("not a url").ExecWithResult()
+	assert.Error(t, err)
+	assert.Zero(t, result.StatusCode)
+}
+
+func TestExecWithResult_Variant(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "accept-language, Accept")
+		w.Header().Add("Vary", "User-Agent, Accept")
+		w.Header().Set("Content-Language", "fr-CH, fr")
+		w.Write([]byte("bonjour"))
+	}))
+	defer server.Close()
+
+	result, err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "doc")).
+		SetAcceptLanguage("fr-CH", "en").
+		SetHeader("User-Agent", "mirror/1.0").
+		ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fr-CH", "fr"}, result.ContentLanguage)
+	assert.Equal(t, []string{"Accept-Language", "Accept", "User-Agent"}, result.Vary)
+	assert.Equal(t, map[string]string{
+		"Accept-Language": "fr-CH, en;q=0.9",
+		"Accept":          "",
+		"User-Agent":      "mirror/1.0",
+	}, result.Variant)
+}
+
+func TestExecWithResult_VaryStar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "*")
+	}))
+	defer server.Close()
+
+	result, err := retrieve.New(server.URL).SetOutput(filepath.Join(t.TempDir(), "doc")).ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"*"}, result.Vary)
+	assert.Nil(t, result.Variant)
+	assert.Nil(t, result.ContentLanguage)
+}
@@ -0,0 +1,221 @@
+package retrieve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// resumeSuffix is appended to the output path to name the sidecar file that
+// stores the state of a paused download.
+const resumeSuffix = ".resume"
+
+// partialDownload describes output left behind by an attempt that failed
+// while reading the response, so a later attempt can resume it.
+type partialDownload struct {
+	path         string
+	size         int64
+	etag         string
+	lastModified string
+	written      int64
+
+	// stored reports whether the download was paused by an earlier Exec,
+	// so the file may have changed since and is checked with If-Range.
+	stored bool
+}
+
+// recordPartial remembers the output of a failed attempt for resumption.
+// Only GET downloads of a known size that failed while reading are resumable.
+func (b *Builder) recordPartial(path string, size int64, header http.Header, written int64, err error) {
+	var re *readError
+	if !strings.EqualFold(b.method, http.MethodGet) || size <= 0 || written <= 0 || !errors.As(err, &re) {
+		b.partial = nil
+		return
+	}
+	etag, lastModified := header.Get("ETag"), header.Get("Last-Modified")
+	if b.partial != nil && b.partial.etag != "" {
+		etag = b.partial.etag
+	}
+	if b.partial != nil && b.partial.lastModified != "" {
+		lastModified = b.partial.lastModified
+	}
+	b.partial = &partialDownload{path: path, size: size, etag: etag, lastModified: lastModified, written: written}
+}
+
+// ifRange returns the validator to send as If-Range when resuming, or ""
+// if there is none.
+func (p *partialDownload) ifRange() string {
+	return rangeValidator(p.etag, p.lastModified)
+}
+
+// rangeValidator returns the validator to send as If-Range for a response
+// with the given ETag and Last-Modified headers, or "" if there is none.
+// Weak ETags cannot be used with If-Range.
+func rangeValidator(etag, lastModified string) string {
+	if etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return lastModified
+}
+
+// resumeState is the state of a paused download stored in a sidecar file.
+type resumeState struct {
+	Size         int64  `json:"size"`
+	Written      int64  `json:"written"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// savePartial stores the partial download in the sidecar file of its
+// output, so that a later Exec, possibly in another process, can resume
+// it. Downloads without a validator to check them with If-Range are not
+// stored.
+func (b *Builder) savePartial() error {
+	p := b.partial
+	if p == nil || p.ifRange() == "" {
+		return nil
+	}
+	data, err := json.Marshal(resumeState{
+		Size:         p.size,
+		Written:      p.written,
+		ETag:         p.etag,
+		LastModified: p.lastModified,
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.path+resumeSuffix, data, 0o644)
+}
+
+// loadPartial returns the partial download stored in the sidecar file of
+// the output by savePartial, if its partially written file still exists.
+// The sidecar is removed, and stored again if the download is paused again.
+func (b *Builder) loadPartial() *partialDownload {
+	if !strings.EqualFold(b.method, http.MethodGet) || b.writer != nil || b.output == "" {
+		return nil
+	}
+	path := b.conditionalPath(b.url)
+	data, err := os.ReadFile(path + resumeSuffix)
+	if err != nil {
+		return nil
+	}
+	os.Remove(path + resumeSuffix)
+
+	var state resumeState
+	if err := json.Unmarshal(data, &state); err != nil || state.Size <= 0 || state.Written <= 0 {
+		return nil
+	}
+	if info, err := os.Stat(b.writePath(path)); err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	return &partialDownload{
+		path:         path,
+		size:         state.Size,
+		etag:         state.ETag,
+		lastModified: state.LastModified,
+		written:      state.Written,
+		stored:       true,
+	}
+}
+
+// resumeOffset returns the offset the partial download can be resumed
+// from, or 0 if it must restart. With VerifyPieces, only the leading
+// pieces that match their hashes are kept.
+func (b *Builder) resumeOffset() int64 {
+	path := b.writePath(b.partial.path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	offset := min(info.Size(), b.partial.written)
+	if offset >= b.partial.size {
+		return 0
+	}
+	if b.pieces != nil {
+		offset = b.pieces.verifiedPrefix(path, offset)
+	}
+	return offset
+}
+
+// canResume reports whether resp continues the partial download at offset:
+// it must be a 206 for the same range of a file of the same size and, unless
+// piece hashes are available, carry the same strong ETag if both mirrors send one.
+func (b *Builder) canResume(resp *http.Response, offset int64) bool {
+	if resp.StatusCode != http.StatusPartialContent {
+		return false
+	}
+	start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	if !ok || start != offset || total != b.partial.size {
+		return false
+	}
+	if b.pieces != nil {
+		return true
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" || b.partial.etag == "" || strings.HasPrefix(etag, "W/") || strings.HasPrefix(b.partial.etag, "W/") {
+		return true
+	}
+	return etag == b.partial.etag
+}
+
+// openPartial opens the partial download for appending at offset and writes
+// the bytes before offset to digest so checksums cover the whole file.
+func (b *Builder) openPartial(offset int64, digest io.Writer) (*os.File, error) {
+	out, err := os.OpenFile(b.writePath(b.partial.path), os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(digest, io.NewSectionReader(out, 0, offset)); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if err := out.Truncate(offset); err != nil {
+		out.Close()
+		return nil, err
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		out.Close()
+		return nil, err
+	}
+	return out, nil
+}
+
+// verifiedPrefix returns the length of the leading whole pieces of the file
+// at path, up to limit bytes, that match their expected hashes.
+func (p *pieceSet) verifiedPrefix(path string, limit int64) int64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	buf := make([]byte, p.size)
+	var offset int64
+	for i := 0; i < len(p.hashes) && offset+p.size <= limit; i++ {
+		if _, err := f.ReadAt(buf, offset); err != nil || p.digest(buf) != p.hashes[i] {
+			break
+		}
+		offset += p.size
+	}
+	return offset
+}
+
+// parseContentRange parses a Content-Range header of the form
+// "bytes start-end/total".
+func parseContentRange(header string) (start, total int64, ok bool) {
+	start, _, total, ok = parseByteRange(header)
+	return start, total, ok
+}
+
+// parseByteRange is like parseContentRange but also returns the last byte
+// of the range.
+func parseByteRange(header string) (start, end, total int64, ok bool) {
+	if _, err := fmt.Sscanf(header, "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		return 0, 0, 0, false
+	}
+	return start, end, total, start <= end && end < total
+}
@@ -0,0 +1,159 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newDyingServer serves the first n bytes of data and then drops the connection.
+func newDyingServer(data []byte, n int, etag string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		w.Write(data[:n])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+}
+
+// newRecordingServer serves data with range support and records the Range
+// header of each request.
+func newRecordingServer(data []byte, etag string) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	return server, &ranges
+}
+
+func TestExec_ResumeFromMirror(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	dead := newDyingServer(data, 4000, `"abc"`)
+	defer dead.Close()
+	mirror, ranges := newRecordingServer(data, `"abc"`)
+	defer mirror.Close()
+
+	sum := sha256.Sum256(data)
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(dead.URL).
+		SetMirrors([]string{mirror.URL}).
+		SetOutput(output).
+		VerifyChecksum("sha256", hex.EncodeToString(sum[:])).
+		Exec()
+	assert.NoError(t, err)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+	assert.Equal(t, []string{"bytes=4000-"}, *ranges)
+}
+
+func TestExec_ResumeETagMismatch(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	dead := newDyingServer(data, 4000, `"abc"`)
+	defer dead.Close()
+	mirror, ranges := newRecordingServer(data, `"xyz"`)
+	defer mirror.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(dead.URL).
+		SetMirrors([]string{mirror.URL}).
+		SetOutput(output).
+		Exec()
+	assert.NoError(t, err)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+	assert.Equal(t, []string{"bytes=4000-", ""}, *ranges)
+}
+
+func TestExec_ResumeSizeMismatch(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	other := bytes.Repeat([]byte("abcdefghij"), 900)
+
+	dead := newDyingServer(data, 4000, "")
+	defer dead.Close()
+	mirror, ranges := newRecordingServer(other, "")
+	defer mirror.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(dead.URL).
+		SetMirrors([]string{mirror.URL}).
+		SetOutput(output).
+		Exec()
+	assert.NoError(t, err)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, other, got)
+	assert.Equal(t, []string{"bytes=4000-", ""}, *ranges)
+}
+
+func TestExec_ResumeVerifiedPieces(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	corrupt := bytes.Clone(data)
+	corrupt[2500] = 'X'
+
+	dead := newDyingServer(corrupt, 4500, `"abc"`)
+	defer dead.Close()
+	mirror, ranges := newRecordingServer(data, `"xyz"`)
+	defer mirror.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(dead.URL).
+		SetMirrors([]string{mirror.URL}).
+		SetOutput(output).
+		VerifyPieces("sha256", 1000, pieceHashes(data, 1000)).
+		Exec()
+	assert.NoError(t, err)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+	assert.Equal(t, []string{"bytes=2000-"}, *ranges)
+}
+
+func TestExec_ResumeStoredChanged(t *testing.T) {
+	data := []byte("abcdefghij")
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	// State left behind by a download of an earlier version that was paused.
+	output := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(output+".part", []byte("01234"), 0o644))
+	assert.NoError(t, os.WriteFile(output+".resume", []byte(`{"size":10,"written":5,"etag":"\"v1\""}`), 0o644))
+
+	err := retrieve.New(server.URL).SetOutput(output).Exec()
+	assert.NoError(t, err)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+	assert.False(t, isExist(output+".resume"))
+	assert.Equal(t, []string{"bytes=5-"}, ranges)
+}
@@ -3,17 +3,27 @@ package retrieve
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"maps"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
+	"text/template"
 	"time"
+	"unicode"
+
+	"github.com/ciathefed/retrieve/cache"
 )
 
 const defaultTimeout = 10 * time.Second
@@ -21,17 +31,150 @@ const defaultTimeout = 10 * time.Second
 var validMethods = []string{"GET", "POST", "PUT", "PATCH"}
 
 type Builder struct {
-	url     string
-	method  string
-	headers map[string]string
-	body    io.Reader
-	ctx     context.Context
-	timeout time.Duration
+	url       string
+	method    string
+	headers   map[string]string
+	body      io.Reader
+	ctx       context.Context
+	timeout   time.Duration
+	client    *http.Client
+	transport http.RoundTripper
+
+	proxy func(*http.Request) (*url.URL, error)
+
+	tokenStore *storedToken
+	reauth     *reauthState
+	digestAuth *digestCredentials
+	awsSigner  *AWSV4Signer
+
+	keepAlive    *net.KeepAliveConfig
+	unixSocket   string
+	dnsResolver  *net.Resolver
+	resolveTo    map[string][]string
+	ipFamily     string
+	ipFamilyOnly bool
+	blockPrivate bool
+
+	asOf      time.Time
+	timeGate  string
+	urlPolicy func(*url.URL) error
+	auditLog  io.Writer
+	auditURL  string
+	logger    *slog.Logger
+	tracer    Tracer
+	metrics   Metrics
+	traceCtx  context.Context
+
+	onEarlyHints func([]Hint)
+	hints        []Hint
+
+	timeoutSet            bool
+	minBytesPerSec        int64
+	connectTimeout        time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+	idleReadTimeout       time.Duration
+	attemptTimeout        time.Duration
+	totalDeadline         time.Duration
+
+	maxRedirects      int
+	noFollowRedirects bool
+	onRedirect        func(*http.Request, []*http.Request) error
+	preserveRedirects []int
+
+	tlsConfig          *tls.Config
+	insecureSkipVerify bool
+	rootCAs            *x509.CertPool
+	clientCerts        []tls.Certificate
+	tlsSessionCache    tls.ClientSessionCache
+	echConfigList      []byte
+	echAccepted        bool
 
-	output string
+	sshKey []byte
+
+	output       string
+	outputByHost string
+	writer       io.Writer
 
 	ignoreStatusCode bool
 
+	mirrors        []string
+	mirrorSelector MirrorSelector
+
+	sizeHint int64
+	priority int
+
+	maxDecompressedSize int64
+	maxCompressionRatio float64
+	autoDecompress      bool
+	removeTruncated     bool
+
+	maxHeaderBytes int64
+	maxHeaderCount int
+
+	memoryBudget int64
+	memory       *memoryBudget
+	chunkSize    int64
+
+	skipIfSameSize  bool
+	preserveModTime bool
+	fileMode        os.FileMode
+	createDirs      bool
+	maxSize         int64
+
+	poll    *pollConfig
+	extract *extractConfig
+
+	race       bool
+	hedgeDelay time.Duration
+
+	http2 *bool
+	http3 bool
+
+	resolverMetadata map[string]string
+	renameTemplate   *template.Template
+	renameText       string
+	verifyReadBack   bool
+
+	cleanupMethod string
+	cleanupURL    string
+
+	result Result
+
+	responseCache   *cache.Cache
+	cacheDirective  string
+	onlyIfModified  bool
+	overwritePolicy OverwritePolicy
+	atomic          bool
+	partial         *partialDownload
+	keepPartial     bool
+	onConfirm       func(FileInfo) bool
+	confirmed       bool
+
+	segments  int
+	rateLimit int64
+	limiter   *rateLimiter
+
+	retries         int
+	retryBackoff    time.Duration
+	retryMaxBackoff time.Duration
+	retryIf         func(*http.Response, error, int) bool
+	retryStatuses   []int
+	maxRetryAfter   time.Duration
+	retryIfCalled   bool
+
+	checksumAlgo string
+	checksum     string
+	pieces       *pieceSet
+
+	onProgress func(Progress)
+	progress   Progress
+	state      *downloadState
+
+	onWarning func(Warning)
+	warnings  []Warning
+	strict    map[WarningCode]bool
+
 	err error
 }
 
@@ -45,7 +188,16 @@ func New(url string) *Builder {
 		ctx:              context.Background(),
 		timeout:          defaultTimeout,
 		output:           "./",
+		sizeHint:         -1,
+		maxRedirects:     -1,
+		segments:         1,
+		atomic:           true,
 		ignoreStatusCode: false,
+		retries:          0,
+		retryBackoff:     defaultRetryBackoff,
+		retryMaxBackoff:  defaultRetryMaxBackoff,
+		maxRetryAfter:    defaultMaxRetryAfter,
+		state:            newDownloadState(),
 		err:              nil,
 	}
 }
@@ -93,6 +245,9 @@ func (b *Builder) GetHeaders() map[string]string {
 //
 // If the input is a string, it's used as-is.
 // If the input is a byte slice, it's wrapped in a reader.
+// If the input is an io.Reader, such as an *os.File, it's streamed. It
+// must also implement io.Seeker for a failed request to be retried or sent
+// to a mirror; otherwise the first error is returned.
 // If the input is any other type, it's serialized to JSON.
 //
 // Automatically sets the "Content-Type" header to "application/json" if JSON encoding is used.
@@ -105,6 +260,8 @@ func (b *Builder) SetBody(body any) *Builder {
 		b.body = strings.NewReader(v)
 	case []byte:
 		b.body = bytes.NewReader(v)
+	case io.Reader:
+		b.body = v
 	default:
 		jsonData, err := json.Marshal(v)
 		if err != nil {
@@ -145,12 +302,15 @@ func (b *Builder) GetContext() context.Context {
 	return b.ctx
 }
 
-// SetTimeout configures the request timeout duration.
+// SetTimeout configures the request timeout duration, which covers the
+// whole request including reading the body. A duration of 0 means no
+// timeout; see SetIdleReadTimeout for large downloads.
 func (b *Builder) SetTimeout(duration time.Duration) *Builder {
 	if b.err != nil {
 		return b
 	}
 	b.timeout = duration
+	b.timeoutSet = true
 	return b
 }
 
@@ -159,12 +319,53 @@ func (b *Builder) GetTimeout() time.Duration {
 	return b.timeout
 }
 
+// SetClient sets the http.Client used to execute the request instead of
+// DefaultClient.
+//
+// The client's own Timeout applies instead of the one set with SetTimeout.
+// Proxy, TLS, dialer, timeout and header limit options require its
+// Transport to be nil or an *http.Transport; otherwise Exec fails.
+func (b *Builder) SetClient(client *http.Client) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.client = client
+	return b
+}
+
+// GetClient returns the custom http.Client set for the request, if any.
+func (b *Builder) GetClient() *http.Client {
+	return b.client
+}
+
+// SetTransport sets the http.RoundTripper used to execute the request,
+// such as an instrumented or mocked transport.
+//
+// When combined with SetClient, the transport replaces the client's own
+// for this request only. It takes precedence over the options that
+// configure the standard transport, such as proxy, TLS, dialer, timeout
+// and header limit options, so Exec fails if any of them is also set.
+func (b *Builder) SetTransport(transport http.RoundTripper) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.transport = transport
+	return b
+}
+
+// GetTransport returns the custom http.RoundTripper set for the request, if any.
+func (b *Builder) GetTransport() http.RoundTripper {
+	return b.transport
+}
+
 // SetOutput defines the file path or directory where the downloaded content will be saved.
+// The path "-" writes to standard output; see ToStdout.
 func (b *Builder) SetOutput(output string) *Builder {
 	if b.err != nil {
 		return b
 	}
 	b.output = output
+	b.outputByHost = ""
 	return b
 }
 
@@ -227,11 +428,49 @@ func (b *Builder) IsIgnoreStatusCode() bool {
 	return b.ignoreStatusCode
 }
 
+// SetSizeHint records the expected size of the download in bytes, used by
+// SmallestFirst to order items in a Batch.
+func (b *Builder) SetSizeHint(size int64) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.sizeHint = size
+	return b
+}
+
+// GetSizeHint returns the expected size of the download, or -1 if unknown.
+func (b *Builder) GetSizeHint() int64 {
+	return b.sizeHint
+}
+
+// SetPriority sets a priority hint used by HighestPriorityFirst to order items in a Batch.
+func (b *Builder) SetPriority(priority int) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.priority = priority
+	return b
+}
+
+// GetPriority returns the priority hint set for the request.
+func (b *Builder) GetPriority() int {
+	return b.priority
+}
+
 // GetUrl returns the current URL.
 func (b *Builder) GetUrl() string {
 	return b.url
 }
 
+// urlPath returns the path component of the URL, or the raw URL if it cannot be parsed.
+func (b *Builder) urlPath() string {
+	parsedURL, err := url.Parse(b.url)
+	if err != nil {
+		return b.url
+	}
+	return parsedURL.Path
+}
+
 // BuildURL constructs and returns the final URL with all query parameters applied.
 func (b *Builder) BuildURL() (string, error) {
 	if b.err != nil {
@@ -246,41 +485,292 @@ func (b *Builder) BuildURL() (string, error) {
 	return parsedURL.String(), nil
 }
 
-// Exec executes the HTTP request and downloads the file.
+// Exec executes the HTTP request and downloads the file. A file:// URL is
+// copied from the local filesystem, the content of a data: URL is written
+// as is, and ftp:// and ftps:// URLs are downloaded over FTP.
+//
+// Transient failures are retried according to SetRetries and SetRetryBackoff.
 func (b *Builder) Exec() error {
+	start := time.Now()
+	err := b.withTotalDeadline(b.exec)
+	b.discardIncomplete(err)
+	b.state.finish(err)
+	elapsed := time.Since(start)
+	b.logDone(err, elapsed)
+	b.recordDone(err, elapsed)
+	return err
+}
+
+func (b *Builder) exec() error {
 	if b.err != nil {
 		return b.err // Return the first encountered error
 	}
+	b.auditURL = b.url
+
+	if b.outputByHost != "" {
+		output, err := hostOutputPath(b.outputByHost, b.url)
+		if err != nil {
+			return err
+		}
+		b.output = output
+	}
+
+	restoreURLs, err := b.rewriteURLs()
+	if err != nil {
+		return err
+	}
+	defer restoreURLs()
+
+	restoreURL, err := b.resolveURL()
+	if err != nil {
+		return err
+	}
+	defer restoreURL()
 
 	if !isValidURL(b.url) {
 		return fmt.Errorf("invalid URL: %s", b.url)
 	}
 
+	if err := b.resolveSnapshot(); err != nil {
+		return err
+	}
+
 	if !isValidMethod(b.method) {
 		return fmt.Errorf("invalid method: %s", b.method)
 	}
 
-	req, err := http.NewRequestWithContext(b.ctx, b.method, b.url, b.body)
+	b.warnings = nil
+	b.hints = nil
+	b.echAccepted = false
+	b.result = Result{}
+	b.limiter = newRateLimiter(b.rateLimit)
+	b.memory = newMemoryBudget(b.memoryBudget)
+	if !b.keepPartial {
+		b.partial = b.loadPartial()
+	}
+	b.keepPartial = false
+	b.confirmed = false
+	b.traceCtx = nil
+	b.state.reset()
+
+	if err := b.checkHTTP3(); err != nil {
+		return err
+	}
+
+	if err := b.checkPrivateAddresses(); err != nil {
+		return err
+	}
+
+	if err := b.checkTransport(); err != nil {
+		return err
+	}
+
+	if err := b.checkURLPolicy(); err != nil {
+		return err
+	}
+
+	if err := b.applyStoredToken(); err != nil {
+		return err
+	}
+
+	restore, err := b.useStream()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	if err := b.createOutputDir(); err != nil {
+		return err
+	}
+
+	// With a file output, the overwrite policy can be applied up front,
+	// unless a paused download of it is resumed.
+	if isDir, err := isDirectory(b.output); err == nil && !isDir && b.writer == nil && b.partial == nil {
+		if _, skip, err := b.checkOverwrite(b.output); skip || err != nil {
+			return err
+		}
+	}
+
+	client, release := b.httpClient()
+	defer release()
+
+	rawURL, restoreRequest, err := b.locateArtifact(client)
+	if err != nil {
+		return err
+	}
+	defer restoreRequest()
+
+	restoreDeadline := b.applySizeDeadline(client, rawURL)
+	defer restoreDeadline()
+
+	if len(b.mirrors) == 0 || b.extract != nil || b.poll != nil {
+		if err := b.execURL(client, rawURL); err != nil {
+			return err
+		}
+		return b.cleanupSource(client, rawURL)
+	}
+
+	if b.race {
+		return b.execRace(client)
+	}
+
+	var errs []error
+	for _, rawURL := range b.candidateURLs() {
+		err := b.execURL(client, rawURL)
+		if err == nil {
+			return b.cleanupSource(client, rawURL)
+		}
+		var writeErr *partialWriteError
+		if errors.Is(err, ErrNotModified) || errors.Is(err, ErrTooLarge) || errors.Is(err, ErrDeclined) || errors.As(err, &writeErr) {
+			return err
+		}
+		if b.ctx.Err() != nil || !b.canRewindBody() {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", rawURL, err))
+	}
+	return fmt.Errorf("all mirrors failed: %w", errors.Join(errs...))
+}
+
+// execURL downloads rawURL, retrying transient failures.
+func (b *Builder) execURL(client *http.Client, rawURL string) error {
+	b.progress = Progress{
+		URL:         rawURL,
+		TotalBytes:  -1,
+		MaxAttempts: b.retries + 1,
+	}
+
+	for attempt := 0; ; attempt++ {
+		b.progress.Attempt = attempt + 1
+		b.progress.NextRetry = time.Time{}
+
+		b.retryIfCalled = false
+		err := b.tracedAttempt(client, rawURL)
+		if err == nil || attempt >= b.retries || !b.shouldRetry(err, attempt+1) {
+			return err
+		}
+
+		delay := b.retryDelay(err, attempt)
+		b.logDebug("retrying request", "url", redactURL(rawURL), "attempt", attempt+2, "delay", delay, "error", err)
+		b.recordRetry(rawURL, err)
+		b.progress.Attempt = attempt + 2
+		b.progress.LastError = err
+		b.progress.NextRetry = time.Now().Add(delay)
+		b.emitProgress()
+
+		if err := sleepContext(b.ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// attempt performs a single request and writes the response to the output.
+func (b *Builder) attempt(client *http.Client, rawURL string) error {
+	if err := b.rewindBody(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(b.ctx, b.method, rawURL, b.body)
 	if err != nil {
 		return err
 	}
+	b.setGetBody(req)
 
 	for key, value := range b.headers {
 		req.Header.Set(key, value)
 	}
 
-	client := &http.Client{
-		Timeout: b.timeout,
+	// Resume output left behind by a failed attempt, possibly on another mirror.
+	var offset int64
+	if b.partial != nil {
+		offset = b.resumeOffset()
+		if offset == 0 {
+			b.partial = nil
+		}
+	}
+	chunked := b.useChunks()
+	if chunked {
+		req.Header.Set("Range", b.chunkRange(offset))
+	} else if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
+	// A download paused by an earlier Exec is only resumed if the file has
+	// not changed since.
+	if offset > 0 && b.partial.stored {
+		if validator := b.partial.ifRange(); validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+	}
+	if b.onlyIfModified && b.partial == nil && b.writer == nil {
+		b.setConditionalHeaders(req, rawURL)
+	}
+
+	encodingRequested := b.requestEncoding(req)
+	upload := b.prepareBody(req)
+
+	req = b.traceEarlyHints(req)
+	b.logRequest(req)
+	b.recordRequest(req)
 	resp, err := client.Do(req)
+	upload.stop()
 	if err != nil {
+		if b.hasHeaderLimits() && isHeaderLimitError(err) {
+			return fmt.Errorf("%w: %w", ErrHeadersTooLarge, err)
+		}
 		return err
 	}
-	defer resp.Body.Close()
+	defer func() { resp.Body.Close() }()
+
+	if b.hasHeaderLimits() {
+		if err := b.checkHeaderLimits(resp); err != nil {
+			return err
+		}
+	}
+
+	if encodingRequested || b.autoDecompress && req.Header.Get("Range") == "" {
+		b.decodeResponse(resp)
+	}
+
+	b.recordResponse(resp)
+
+	if err := b.checkCached(resp); err != nil {
+		return err
+	}
+
+	if b.retryIf != nil && b.retryIfResponse(resp) {
+		return fmt.Errorf("%w: status %d", ErrRetryRequested, resp.StatusCode)
+	}
+
+	b.echAccepted = resp.TLS != nil && resp.TLS.ECHAccepted
+
+	if b.onlyIfModified && resp.StatusCode == http.StatusNotModified {
+		return ErrNotModified
+	}
 
 	if !b.ignoreStatusCode {
 		if resp.StatusCode > 399 {
-			return fmt.Errorf("received status code %d", resp.StatusCode)
+			return newStatusError(resp)
+		}
+	}
+
+	if err := b.checkRedirectResponse(resp); err != nil {
+		return err
+	}
+
+	resuming := b.partial != nil && b.canResume(resp, offset)
+	if b.partial != nil && !resuming {
+		b.partial = nil
+		if resp.StatusCode == http.StatusPartialContent {
+			// The mirror serves a different file; start over.
+			resp.Body.Close()
+			return b.attempt(client, rawURL)
+		}
+		offset = 0
+	}
+
+	if chunked && resp.StatusCode == http.StatusPartialContent {
+		if err := b.chainChunks(client, rawURL, resp); err != nil {
+			return err
 		}
 	}
 
@@ -295,20 +785,211 @@ func (b *Builder) Exec() error {
 		}
 	}
 
-	if isDir {
-		outputPath = filepath.Join(b.output, extractFilename(resp, b.url))
+	if err := b.checkResponse(resp, rawURL); err != nil {
+		return err
+	}
+
+	total := resp.ContentLength
+	if resuming {
+		total = b.partial.size
+	}
+	b.progress.BytesWritten = offset
+	b.progress.TotalBytes = total
+	b.progress.Segments = nil
+
+	if resp.ContentLength >= 0 {
+		if err := b.checkMaxSize(offset + resp.ContentLength); err != nil {
+			return err
+		}
+	}
+
+	if err := b.confirm(resp, total); err != nil {
+		return err
+	}
+
+	if b.writer != nil {
+		return b.copyToWriter(resp)
+	}
+
+	if resuming {
+		outputPath = b.partial.path
+	} else if isDir {
+		filename, err := b.extractFilename(resp, rawURL)
+		if err != nil {
+			return err
+		}
+		outputPath = filepath.Join(b.output, filename)
 	} else {
 		outputPath = b.output
 	}
 
-	out, err := os.Create(outputPath)
+	if !resuming && b.skipIfSameSize && resp.ContentLength >= 0 && sameSize(outputPath, resp.ContentLength) {
+		b.result.Output = outputPath
+		return ErrNotModified
+	}
+
+	if !resuming {
+		var skip bool
+		outputPath, skip, err = b.checkOverwrite(outputPath)
+		if skip || err != nil {
+			return err
+		}
+	}
+	b.result.Output = outputPath
+
+	writePath := b.writePath(outputPath)
+
+	var digests []io.Writer
+	h := b.newHash()
+	if h != nil {
+		digests = append(digests, h)
+	}
+	written := b.newReadBackHash()
+	if written != nil {
+		digests = append(digests, written)
+	}
+	var pw *pieceWriter
+	if b.pieces != nil {
+		pw = newPieceWriter(b.pieces)
+		digests = append(digests, pw)
+	}
+
+	var out *os.File
+	if resuming {
+		out, err = b.openPartial(offset, io.MultiWriter(digests...))
+	} else {
+		out, err = b.createFile(writePath)
+	}
 	if err != nil {
 		return err
 	}
-	defer out.Close()
 
-	_, err = io.Copy(out, resp.Body)
-	return err
+	b.state.start(writePath, total)
+	b.state.advance(offset)
+
+	segmented := b.canSegment(resp)
+	if segmented {
+		err = b.copySegments(client, rawURL, resp, out)
+	} else {
+		w := &progressWriter{w: io.MultiWriter(append([]io.Writer{out}, digests...)...), b: b}
+		var n int64
+		n, err = b.copyBody(w, b.limitReader(b.ctx, b.limitSize(&bodyReader{r: resp.Body}, offset)))
+		err = checkLength(n, resp.ContentLength, err)
+		if err != nil {
+			b.recordPartial(outputPath, total, resp.Header, offset+n, err)
+		}
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	b.partial = nil
+
+	// Segments are written out of order, so they are hashed once complete.
+	if segmented && len(digests) > 0 {
+		if err := copyFile(writePath, io.MultiWriter(digests...)); err != nil {
+			return err
+		}
+	}
+
+	if pw != nil {
+		bad, err := pw.result()
+		if err == nil && len(bad) > 0 {
+			err = b.repairPieces(client, rawURL, writePath, bad, rangeValidator(resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")))
+			if err == nil && h != nil {
+				err = hashFile(writePath, h)
+			}
+			if err == nil && written != nil {
+				err = hashFile(writePath, written)
+			}
+		}
+		if err != nil {
+			removeIncomplete(writePath)
+			return err
+		}
+	}
+
+	if h != nil {
+		if err := b.verifyHash(h); err != nil {
+			removeIncomplete(writePath)
+			return err
+		}
+	}
+
+	if b.verifyReadBack {
+		if err := b.readBack(writePath, written); err != nil {
+			removeIncomplete(writePath)
+			return err
+		}
+	}
+
+	if writePath != outputPath {
+		if err := os.Rename(writePath, finalPath(outputPath)); err != nil {
+			return err
+		}
+		b.state.rename(outputPath)
+	}
+
+	if b.renameTemplate != nil {
+		var skip bool
+		if outputPath, skip, err = b.renameOutput(outputPath, resp); err != nil {
+			return err
+		}
+		b.result.Output = outputPath
+		b.state.rename(outputPath)
+		if skip {
+			return nil
+		}
+	}
+
+	if b.preserveModTime {
+		if err := applyModTime(outputPath, resp); err != nil {
+			return err
+		}
+	}
+
+	if b.onlyIfModified {
+		return saveValidators(outputPath, resp)
+	}
+
+	return nil
+}
+
+// setGetBody lets transports resend a body that can be rewound but has no
+// GetBody, such as a file, for example to answer an authentication challenge.
+func (b *Builder) setGetBody(req *http.Request) {
+	if req.GetBody != nil || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	if _, ok := b.body.(io.Seeker); !ok {
+		return
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		if err := b.rewindBody(); err != nil {
+			return nil, err
+		}
+		return io.NopCloser(b.body), nil
+	}
+}
+
+// canRewindBody reports whether the request body, if any, can be resent.
+func (b *Builder) canRewindBody() bool {
+	if b.body == nil {
+		return true
+	}
+	_, ok := b.body.(io.Seeker)
+	return ok
+}
+
+// rewindBody seeks the request body back to its start so it can be resent.
+func (b *Builder) rewindBody() error {
+	if seeker, ok := b.body.(io.Seeker); ok {
+		_, err := seeker.Seek(0, io.SeekStart)
+		return err
+	}
+	return nil
 }
 
 func isValidMethod(method string) bool {
@@ -329,19 +1010,51 @@ func isDirectory(path string) (bool, error) {
 }
 
 func isValidURL(u string) bool {
+	if isFileURL(u) || isDataURL(u) {
+		return true
+	}
 	parsedURL, err := url.ParseRequestURI(u)
-	return err == nil && parsedURL.Scheme != "" && parsedURL.Host != ""
+	return err == nil && parsedURL.Scheme != "" && parsedURL.Scheme != "file" && parsedURL.Host != ""
 }
 
-func extractFilename(resp *http.Response, url string) string {
+// extractFilename determines the name of the downloaded file from the
+// Content-Disposition header, falling back to the last segment of the URL.
+func (b *Builder) extractFilename(resp *http.Response, rawURL string) (string, error) {
 	contentDisposition := resp.Header.Get("Content-Disposition")
 	if contentDisposition != "" {
-		parts := strings.Split(contentDisposition, "filename=")
-		if len(parts) > 1 {
-			filename := strings.Trim(parts[1], "\"")
-			return filename
+		if filename, ok := dispositionFilename(contentDisposition); ok {
+			sanitized := sanitizeFilename(filename)
+			if sanitized != "" {
+				if sanitized != filename {
+					if err := b.warn(WarnFilenameSanitized, "filename %q sanitized to %q", filename, sanitized); err != nil {
+						return "", err
+					}
+				}
+				return sanitized, nil
+			}
+			if err := b.warn(WarnFilenameSanitized, "filename %q is unusable, falling back to URL", filename); err != nil {
+				return "", err
+			}
 		}
 	}
 
-	return filepath.Base(url)
+	return filepath.Base(rawURL), nil
+}
+
+// sanitizeFilename strips any directory components and control characters
+// from a server-provided filename. It returns an empty string if nothing
+// usable remains.
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = path.Base(name)
+	if name == "." || name == ".." || name == "/" {
+		return ""
+	}
+	return name
 }
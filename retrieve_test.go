@@ -3,8 +3,14 @@ package retrieve_test
 import (
 	"context"
 	"encoding/json"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -72,6 +78,113 @@ func TestSetContext(t *testing.T) {
 	assert.Equal(t, ctx, b.GetContext())
 }
 
+func TestSetClient(t *testing.T) {
+	client := &http.Client{}
+	b := retrieve.New("http://example.com").SetClient(client)
+	assert.Same(t, client, b.GetClient())
+}
+
+func TestExec_CustomClient(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Client")))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	var used bool
+	client := &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			used = true
+			r.Header.Set("X-Client", "custom")
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+
+	output := filepath.Join(t.TempDir(), "out.txt")
+	err := retrieve.New(server.URL).SetClient(client).SetOutput(output).Exec()
+	assert.NoError(t, err)
+	assert.True(t, used)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "custom", string(data))
+}
+
+func TestExec_ReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data"))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	for range 3 {
+		_, err := retrieve.New(server.URL).ExecBytes()
+		assert.NoError(t, err)
+	}
+	assert.EqualValues(t, 1, conns.Load())
+}
+
+func TestExec_DefaultClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Client")))
+	}))
+	defer server.Close()
+
+	saved := retrieve.DefaultClient
+	defer func() { retrieve.DefaultClient = saved }()
+	retrieve.DefaultClient = &http.Client{
+		Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.Header.Set("X-Client", "default")
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+
+	data, err := retrieve.New(server.URL).ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "default", string(data))
+
+	data, err = retrieve.New(server.URL).SetClient(&http.Client{}).ExecBytes()
+	assert.NoError(t, err)
+	assert.Empty(t, string(data))
+}
+
+func TestExec_CustomTransport(t *testing.T) {
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("mocked")),
+			Request:    r,
+		}, nil
+	})
+
+	client := &http.Client{}
+	output := filepath.Join(t.TempDir(), "out.txt")
+	b := retrieve.New("http://example.com/file").
+		SetClient(client).
+		SetTransport(transport).
+		SetOutput(output)
+	assert.NotNil(t, b.GetTransport())
+	assert.NoError(t, b.Exec())
+	assert.Nil(t, client.Transport)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "mocked", string(data))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
 func TestExec(t *testing.T) {
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -86,6 +199,24 @@ func TestExec(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestExec_OutputDirectory(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	dir := t.TempDir()
+	result, err := retrieve.New(server.URL + "/file.txt").SetOutput(dir).ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "file.txt"), result.Output)
+
+	data, err := os.ReadFile(result.Output)
+	assert.NoError(t, err)
+	assert.Equal(t, "success", string(data))
+}
+
 func TestExec_InvalidURL(t *testing.T) {
 	b := retrieve.New(":://invalid-url")
 	err := b.Exec()
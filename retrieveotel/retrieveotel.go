@@ -0,0 +1,109 @@
+// Package retrieveotel traces retrieve requests with OpenTelemetry.
+//
+// A Tracer starts a client span for each attempt of a request, with the
+// HTTP semantic convention attributes, and links each retry to the attempt
+// it repeats:
+//
+//	retrieve.New(url).WithTracing(retrieveotel.NewTracer(tp)).Exec()
+//
+// The span is in the context of the request, so the spans of an
+// instrumented transport, such as otelhttp, are its children.
+package retrieveotel
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/ciathefed/retrieve"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name of the spans.
+const ScopeName = "github.com/ciathefed/retrieve/retrieveotel"
+
+// Tracer implements retrieve.Tracer with OpenTelemetry.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer returns a Tracer creating spans with tp, or with the global
+// TracerProvider if tp is nil.
+func NewTracer(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(ScopeName)}
+}
+
+// StartAttempt implements retrieve.Tracer.
+func (t *Tracer) StartAttempt(ctx context.Context, attempt retrieve.Attempt) (context.Context, func(retrieve.Attempt)) {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(attempt.Method),
+		semconv.URLFull(attempt.URL),
+	}
+	if u, err := url.Parse(attempt.URL); err == nil {
+		attrs = append(attrs, semconv.ServerAddress(u.Hostname()))
+		port, err := strconv.Atoi(u.Port())
+		if err != nil {
+			port = defaultPort(u.Scheme)
+		}
+		if port > 0 {
+			attrs = append(attrs, semconv.ServerPort(port))
+		}
+	}
+	if attempt.Number > 1 {
+		attrs = append(attrs, semconv.HTTPRequestResendCount(attempt.Number-1))
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	}
+	if attempt.Previous != nil {
+		if link := trace.LinkFromContext(attempt.Previous); link.SpanContext.IsValid() {
+			opts = append(opts, trace.WithLinks(link))
+		}
+	}
+	ctx, span := t.tracer.Start(ctx, attempt.Method, opts...)
+
+	return ctx, func(attempt retrieve.Attempt) {
+		defer span.End()
+		if attempt.StatusCode != 0 {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(attempt.StatusCode))
+		}
+		span.SetAttributes(attribute.Int64("retrieve.bytes_written", attempt.BytesWritten))
+		switch {
+		case attempt.StatusCode >= 400:
+			span.SetAttributes(semconv.ErrorTypeKey.String(strconv.Itoa(attempt.StatusCode)))
+		case attempt.Err != nil:
+			span.SetAttributes(semconv.ErrorTypeKey.String(fmt.Sprintf("%T", attempt.Err)))
+		}
+		if attempt.Err != nil {
+			span.RecordError(attempt.Err)
+			span.SetStatus(codes.Error, attempt.Err.Error())
+		} else if attempt.StatusCode >= 400 {
+			span.SetStatus(codes.Error, "")
+		}
+	}
+}
+
+// defaultPort returns the port used for scheme when a URL has none, or 0
+// if it is unknown.
+func defaultPort(scheme string) int {
+	switch scheme {
+	case "http":
+		return 80
+	case "https":
+		return 443
+	case "ftp", "ftps":
+		return 21
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+package retrieveotel_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+	"github.com/ciathefed/retrieve/retrieveotel"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracer(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+	host, portText, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portText)
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	_, err := retrieve.New(server.URL+"/file").
+		WithTracing(retrieveotel.NewTracer(tp)).
+		SetRetries(1).
+		SetRetryBackoff(0, 0).
+		ExecBytes()
+	assert.NoError(t, err)
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	first, retry := spans[0], spans[1]
+
+	assert.Equal(t, "GET", first.Name())
+	assert.Equal(t, trace.SpanKindClient, first.SpanKind())
+	assert.Equal(t, retrieveotel.ScopeName, first.InstrumentationScope().Name)
+	attrs := attributes(first)
+	assert.Equal(t, "GET", attrs["http.request.method"].AsString())
+	assert.Equal(t, server.URL+"/file", attrs["url.full"].AsString())
+	assert.Equal(t, host, attrs["server.address"].AsString())
+	assert.EqualValues(t, port, attrs["server.port"].AsInt64())
+	assert.EqualValues(t, http.StatusServiceUnavailable, attrs["http.response.status_code"].AsInt64())
+	assert.Equal(t, "503", attrs["error.type"].AsString())
+	assert.Equal(t, codes.Error, first.Status().Code)
+	assert.NotContains(t, attrs, attribute.Key("http.request.resend_count"))
+
+	attrs = attributes(retry)
+	assert.EqualValues(t, http.StatusOK, attrs["http.response.status_code"].AsInt64())
+	assert.EqualValues(t, 1, attrs["http.request.resend_count"].AsInt64())
+	assert.EqualValues(t, len("content"), attrs["retrieve.bytes_written"].AsInt64())
+	assert.NotContains(t, attrs, attribute.Key("error.type"))
+	assert.Equal(t, codes.Unset, retry.Status().Code)
+	if assert.Len(t, retry.Links(), 1) {
+		assert.Equal(t, first.SpanContext().SpanID(), retry.Links()[0].SpanContext.SpanID())
+	}
+}
+
+func TestTracer_ConnectionError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	err := retrieve.New("http://127.0.0.1:1/file").WithTracing(retrieveotel.NewTracer(tp)).Exec()
+	assert.Error(t, err)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		attrs := attributes(spans[0])
+		assert.NotContains(t, attrs, attribute.Key("http.response.status_code"))
+		assert.NotEmpty(t, attrs["error.type"].AsString())
+		assert.Equal(t, codes.Error, spans[0].Status().Code)
+		assert.Len(t, spans[0].Events(), 1)
+	}
+}
+
+func TestTracer_Parent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+
+	_, err := retrieve.New(server.URL).SetContext(ctx).WithTracing(retrieveotel.NewTracer(tp)).ExecBytes()
+	parent.End()
+	assert.NoError(t, err)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	}
+}
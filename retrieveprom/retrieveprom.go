@@ -0,0 +1,102 @@
+// Package retrieveprom exports the metrics of retrieve downloads to
+// Prometheus:
+//
+//	metrics := retrieveprom.NewMetrics()
+//	prometheus.MustRegister(metrics)
+//	retrieve.SetDefaultMetrics(metrics)
+//
+// The metrics are labeled by host, so they suit downloads from a bounded
+// set of hosts.
+package retrieveprom
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements retrieve.Metrics and prometheus.Collector. It
+// collects:
+//
+//   - retrieve_requests_total{method,host}: requests sent, including retries
+//   - retrieve_retries_total{host}: retries
+//   - retrieve_failures_total{host}: downloads that failed
+//   - retrieve_download_duration_seconds{host}: duration of downloads
+//   - retrieve_download_bytes{host}: bytes written by downloads
+//
+// Downloads that failed with retrieve.ErrNotModified count as successful.
+type Metrics struct {
+	requests *prometheus.CounterVec
+	retries  *prometheus.CounterVec
+	failures *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	bytes    *prometheus.HistogramVec
+}
+
+// NewMetrics returns Metrics to register with a prometheus.Registerer.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retrieve_requests_total",
+			Help: "Requests sent, including retries.",
+		}, []string{"method", "host"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retrieve_retries_total",
+			Help: "Requests retried after a transient failure.",
+		}, []string{"host"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "retrieve_failures_total",
+			Help: "Downloads that failed.",
+		}, []string{"host"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "retrieve_download_duration_seconds",
+			Help:    "Duration of downloads, including retries.",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 16),
+		}, []string{"host"}),
+		bytes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "retrieve_download_bytes",
+			Help:    "Bytes written by downloads.",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 12),
+		}, []string{"host"}),
+	}
+}
+
+// Request implements retrieve.Metrics.
+func (m *Metrics) Request(method, host string) {
+	m.requests.WithLabelValues(method, host).Inc()
+}
+
+// Retry implements retrieve.Metrics.
+func (m *Metrics) Retry(host string, err error) {
+	m.retries.WithLabelValues(host).Inc()
+}
+
+// Done implements retrieve.Metrics.
+func (m *Metrics) Done(host string, elapsed time.Duration, bytes int64, err error) {
+	if err != nil && !errors.Is(err, retrieve.ErrNotModified) {
+		m.failures.WithLabelValues(host).Inc()
+	}
+	m.duration.WithLabelValues(host).Observe(elapsed.Seconds())
+	m.bytes.WithLabelValues(host).Observe(float64(bytes))
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.retries.Describe(ch)
+	m.failures.Describe(ch)
+	m.duration.Describe(ch)
+	m.bytes.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.retries.Collect(ch)
+	m.failures.Collect(ch)
+	m.duration.Collect(ch)
+	m.bytes.Collect(ch)
+}
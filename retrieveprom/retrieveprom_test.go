@@ -0,0 +1,70 @@
+package retrieveprom_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+	"github.com/ciathefed/retrieve/retrieveprom"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case requests.Add(1) == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("content"))
+		}
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	host := u.Hostname()
+
+	metrics := retrieveprom.NewMetrics()
+	registry := prometheus.NewPedanticRegistry()
+	assert.NoError(t, registry.Register(metrics))
+
+	_, err := retrieve.New(server.URL+"/file").SetMetrics(metrics).SetRetries(1).SetRetryBackoff(0, 0).ExecBytes()
+	assert.NoError(t, err)
+	_, err = retrieve.New(server.URL + "/missing").SetMetrics(metrics).ExecBytes()
+	assert.Error(t, err)
+
+	expected := `
+# HELP retrieve_requests_total Requests sent, including retries.
+# TYPE retrieve_requests_total counter
+retrieve_requests_total{host="HOST",method="GET"} 3
+# HELP retrieve_retries_total Requests retried after a transient failure.
+# TYPE retrieve_retries_total counter
+retrieve_retries_total{host="HOST"} 1
+# HELP retrieve_failures_total Downloads that failed.
+# TYPE retrieve_failures_total counter
+retrieve_failures_total{host="HOST"} 1
+`
+	err = testutil.GatherAndCompare(registry, strings.NewReader(strings.ReplaceAll(expected, "HOST", host)),
+		"retrieve_requests_total", "retrieve_retries_total", "retrieve_failures_total")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics, "retrieve_download_duration_seconds", "retrieve_download_bytes"))
+	lint, err := testutil.GatherAndLint(registry)
+	assert.NoError(t, err)
+	assert.Empty(t, lint)
+}
+
+func TestMetrics_NotModified(t *testing.T) {
+	metrics := retrieveprom.NewMetrics()
+	metrics.Done("example.com", 0, 0, retrieve.ErrNotModified)
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics, "retrieve_failures_total"))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics, "retrieve_download_bytes"))
+}
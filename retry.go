@@ -0,0 +1,247 @@
+package retrieve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRetryRequested is returned when the retries run out while the
+// callback set with RetryIf still asks for another attempt.
+var ErrRetryRequested = errors.New("retry requested")
+
+const (
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultRetryMaxBackoff = 30 * time.Second
+	defaultMaxRetryAfter   = time.Minute
+)
+
+// SetRetries sets how many times a failed request is retried.
+//
+// Network errors, 5xx responses and 429 responses are considered transient
+// and are retried with jittered exponential backoff, or after the delay
+// asked for by a Retry-After header; see RetryOnStatus and
+// SetMaxRetryAfter. A value of 0 disables retries.
+func (b *Builder) SetRetries(n int) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if n < 0 {
+		n = 0
+	}
+	b.retries = n
+	return b
+}
+
+// GetRetries returns the number of retries set for the request.
+func (b *Builder) GetRetries() int {
+	return b.retries
+}
+
+// SetRetryBackoff configures the exponential backoff between retries.
+//
+// The delay starts at base and doubles after each attempt, never exceeding maxBackoff.
+func (b *Builder) SetRetryBackoff(base, maxBackoff time.Duration) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.retryBackoff = base
+	b.retryMaxBackoff = maxBackoff
+	return b
+}
+
+// GetRetryBackoff returns the base and maximum backoff durations.
+func (b *Builder) GetRetryBackoff() (time.Duration, time.Duration) {
+	return b.retryBackoff, b.retryMaxBackoff
+}
+
+// RetryOnStatus sets the status codes of the responses that are retried,
+// such as RetryOnStatus(429, 502, 503), instead of 429 and every 5xx.
+// Without arguments, no status code is retried.
+func (b *Builder) RetryOnStatus(statusCodes ...int) *Builder {
+	if b.err != nil {
+		return b
+	}
+	for _, code := range statusCodes {
+		if code < 400 || code > 599 {
+			b.err = fmt.Errorf("invalid retry status code: %d", code)
+			return b
+		}
+	}
+	b.retryStatuses = append([]int{}, statusCodes...)
+	return b
+}
+
+// GetRetryOnStatus returns the status codes that are retried, or nil if
+// the default of 429 and every 5xx applies.
+func (b *Builder) GetRetryOnStatus() []int {
+	return b.retryStatuses
+}
+
+// SetMaxRetryAfter caps the delay taken from a Retry-After header, given
+// in seconds or as an HTTP date, before a retry. Longer delays are cut to
+// d. It defaults to one minute.
+func (b *Builder) SetMaxRetryAfter(d time.Duration) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if d < 0 {
+		b.err = fmt.Errorf("invalid maximum Retry-After delay: %v", d)
+		return b
+	}
+	b.maxRetryAfter = d
+	return b
+}
+
+// GetMaxRetryAfter returns the cap on the delay taken from a Retry-After header.
+func (b *Builder) GetMaxRetryAfter() time.Duration {
+	return b.maxRetryAfter
+}
+
+// RetryIf adds a callback deciding whether an attempt is retried, for
+// transient conditions only the API knows about, such as a 200 response
+// with a {"status": "pending"} body. An attempt is retried if either fn or
+// the built-in rules of SetRetries consider it transient, up to the number
+// of retries set.
+//
+// fn is called once per attempt, numbered from 1: with the response as
+// soon as its headers arrive and a nil error, or with a nil response and
+// the error if the attempt failed before that. fn may read the response
+// body; what it reads is buffered and still written to the output unless
+// the attempt is retried. Exec fails with ErrRetryRequested if fn still
+// asks for a retry after the last attempt.
+func (b *Builder) RetryIf(fn func(resp *http.Response, err error, attempt int) bool) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.retryIf = fn
+	return b
+}
+
+// retryIfResponse asks the RetryIf callback whether to retry resp, letting
+// it read the body without losing what it read.
+func (b *Builder) retryIfResponse(resp *http.Response) bool {
+	b.retryIfCalled = true
+	body := resp.Body
+	var read bytes.Buffer
+	resp.Body = &replayBody{Reader: io.TeeReader(body, &read), Closer: body}
+	retry := b.retryIf(resp, nil, b.progress.Attempt)
+	resp.Body = &replayBody{Reader: io.MultiReader(&read, body), Closer: body}
+	return retry
+}
+
+// replayBody is a response body whose reads come from Reader.
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// shouldRetry reports whether the failed attempt should be retried.
+func (b *Builder) shouldRetry(err error, attempt int) bool {
+	if !b.canRewindBody() {
+		return false
+	}
+	if b.isRetryable(err) {
+		return true
+	}
+	if b.retryIf == nil || b.retryIfCalled || b.ctx.Err() != nil {
+		return false
+	}
+	return b.retryIf(nil, err, attempt)
+}
+
+// retryDelay returns the delay to wait after the given attempt failed with
+// err: the one asked for by the server, if any, or the backoff.
+func (b *Builder) retryDelay(err error, attempt int) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return min(statusErr.RetryAfter, b.maxRetryAfter)
+	}
+	return b.backoff(attempt)
+}
+
+// retryAfter returns the delay of the Retry-After header of resp, given in
+// seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(time.Until(t), 0), true
+}
+
+// backoff returns the jittered delay to wait after the given attempt.
+func (b *Builder) backoff(attempt int) time.Duration {
+	delay := b.retryMaxBackoff
+	if attempt < 32 {
+		if d := b.retryBackoff << attempt; d > 0 && d < delay {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// isRetryable reports whether err is a transient failure worth retrying.
+func (b *Builder) isRetryable(err error) bool {
+	if b.ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ErrRetryRequested) {
+		return true
+	}
+
+	var policyErr *redirectPolicyError
+	var reauthErr *reauthError
+	var writeErr *partialWriteError
+	if errors.As(err, &policyErr) || errors.As(err, &reauthErr) || errors.As(err, &writeErr) ||
+		errors.Is(err, ErrDecompressionLimit) || errors.Is(err, ErrHeadersTooLarge) ||
+		errors.Is(err, ErrMemoryBudget) || errors.Is(err, ErrBlockedAddress) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		if b.retryStatuses != nil {
+			return slices.Contains(b.retryStatuses, statusErr.StatusCode)
+		}
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var urlErr *url.Error
+	var readErr *readError
+	return errors.As(err, &urlErr) || errors.As(err, &readErr)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
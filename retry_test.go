@@ -0,0 +1,261 @@
+package retrieve_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetRetries(t *testing.T) {
+	b := retrieve.New("http://example.com").
+		SetRetries(3).
+		SetRetryBackoff(time.Second, 10*time.Second)
+	assert.Equal(t, 3, b.GetRetries())
+
+	base, maxBackoff := b.GetRetryBackoff()
+	assert.Equal(t, time.Second, base)
+	assert.Equal(t, 10*time.Second, maxBackoff)
+}
+
+func TestExec_RetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte("success"))
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.txt")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetRetries(3).
+		SetRetryBackoff(time.Millisecond, 5*time.Millisecond).
+		Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "success", string(data))
+}
+
+func TestExec_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		SetOutput(t.TempDir()).
+		SetRetries(3).
+		SetRetryBackoff(time.Millisecond, 5*time.Millisecond).
+		Exec()
+
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestExec_RetriesExhausted(t *testing.T) {
+	var calls atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		SetOutput(t.TempDir()).
+		SetRetries(2).
+		SetRetryBackoff(time.Millisecond, 5*time.Millisecond).
+		Exec()
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+// newPendingServer answers {"status":"pending"} until the given call, and
+// {"status":"done"} from then on.
+func newPendingServer(readyAt int32, calls *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < readyAt {
+			w.Write([]byte(`{"status":"pending"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"done"}`))
+	}))
+}
+
+func isPending(resp *http.Response, err error, attempt int) bool {
+	if resp == nil {
+		return false
+	}
+	var body struct{ Status string }
+	json.NewDecoder(resp.Body).Decode(&body)
+	return body.Status == "pending"
+}
+
+func TestRetryIf(t *testing.T) {
+	var calls atomic.Int32
+	server := newPendingServer(3, &calls)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "status.json")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetRetries(5).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		RetryIf(isPending).
+		Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// The body read by the callback is still written.
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, `{"status":"done"}`, string(data))
+}
+
+func TestRetryIf_Exhausted(t *testing.T) {
+	var calls atomic.Int32
+	server := newPendingServer(100, &calls)
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "status.json")).
+		SetRetries(2).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		RetryIf(isPending).
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrRetryRequested)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestRetryIf_Error(t *testing.T) {
+	// Too many redirects is not retried by default; the callback sees the
+	// error and asks for one retry.
+	server := newRedirectServer(nil)
+	defer server.Close()
+
+	var attempts []int
+	err := retrieve.New(server.URL+"/3").
+		SetOutput(filepath.Join(t.TempDir(), "out")).
+		SetMaxRedirects(1).
+		SetRetries(3).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		RetryIf(func(resp *http.Response, err error, attempt int) bool {
+			assert.Nil(t, resp)
+			assert.ErrorIs(t, err, retrieve.ErrTooManyRedirects)
+			attempts = append(attempts, attempt)
+			return attempt < 2
+		}).
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrTooManyRedirects)
+	assert.Equal(t, []int{1, 2}, attempts)
+}
+
+// newFlakyServer answers the first n requests with status and the given
+// Retry-After header, if any, and then "ok".
+func newFlakyServer(t *testing.T, n int32, status int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= n {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRetryOnStatus(t *testing.T) {
+	assert.Nil(t, retrieve.New("http://example.com").GetRetryOnStatus())
+	assert.Equal(t, []int{409, 503}, retrieve.New("http://example.com").RetryOnStatus(409, 503).GetRetryOnStatus())
+
+	err := retrieve.New("http://example.com").RetryOnStatus(200).Exec()
+	assert.ErrorContains(t, err, "invalid retry status code")
+
+	server, requests := newFlakyServer(t, 1, http.StatusConflict, "")
+	data, err := retrieve.New(server.URL).SetRetries(1).SetRetryBackoff(time.Millisecond, time.Millisecond).
+		RetryOnStatus(http.StatusConflict).ExecString()
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", data)
+	assert.Equal(t, int32(2), requests.Load())
+
+	server, requests = newFlakyServer(t, 1, http.StatusInternalServerError, "")
+	_, err = retrieve.New(server.URL).SetRetries(1).SetRetryBackoff(time.Millisecond, time.Millisecond).
+		RetryOnStatus(http.StatusConflict).ExecString()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Minute, retrieve.New("http://example.com").GetMaxRetryAfter())
+
+	tests := []struct {
+		name       string
+		retryAfter func() string
+		maxDelay   time.Duration
+		minElapsed time.Duration
+	}{
+		{"seconds", func() string { return "1" }, time.Minute, 900 * time.Millisecond},
+		{"date", func() string {
+			return time.Now().Add(2 * time.Second).UTC().Format(http.TimeFormat)
+		}, time.Minute, 900 * time.Millisecond},
+		{"capped", func() string { return "3600" }, 100 * time.Millisecond, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newFlakyServer(t, 1, http.StatusServiceUnavailable, tt.retryAfter())
+
+			start := time.Now()
+			data, err := retrieve.New(server.URL).
+				SetRetries(1).
+				SetRetryBackoff(time.Millisecond, time.Millisecond).
+				SetMaxRetryAfter(tt.maxDelay).
+				ExecString()
+			assert.NoError(t, err)
+			assert.Equal(t, "ok", data)
+			elapsed := time.Since(start)
+			assert.GreaterOrEqual(t, elapsed, tt.minElapsed)
+			assert.Less(t, elapsed, 3*time.Second)
+		})
+	}
+}
+
+func TestRetryAfter_StatusError(t *testing.T) {
+	server, _ := newFlakyServer(t, 1, http.StatusTooManyRequests, "120")
+
+	_, err := retrieve.New(server.URL).ExecString()
+	var statusErr *retrieve.StatusError
+	if assert.ErrorAs(t, err, &statusErr) {
+		assert.Equal(t, 2*time.Minute, statusErr.RetryAfter)
+	}
+}
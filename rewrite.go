@@ -0,0 +1,127 @@
+package retrieve
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// RewriteRulesEnv is the environment variable naming a file of rewrite
+// rules, as read by LoadRewriteRules, loaded on the first request unless
+// SetRewriteRules or LoadRewriteRules is called first.
+const RewriteRulesEnv = "RETRIEVE_REWRITE_RULES"
+
+// RewriteRule rewrites the URLs matching Pattern, a regular expression, to
+// Replacement, in which $1 or ${name} stand for submatches as with
+// regexp.Regexp.Expand. For example, to send every PyPI download to an
+// internal mirror:
+//
+//	{"pattern": "^https://files\\.pythonhosted\\.org/", "replacement": "https://pypi.internal/files/"}
+type RewriteRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+type compiledRule struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+var rewrites struct {
+	mu     sync.RWMutex
+	rules  []compiledRule
+	loaded bool
+	err    error
+}
+
+// SetRewriteRules replaces the rules rewriting the URL and mirrors of every
+// Builder, before Resolvers are applied, so platform teams can enforce
+// internal mirrors for every program using the package. Only the first
+// rule matching a URL is applied. An empty list removes the rules.
+func SetRewriteRules(rules []RewriteRule) error {
+	compiled := make([]compiledRule, len(rules))
+	for i, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid rewrite rule %d: %w", i, err)
+		}
+		compiled[i] = compiledRule{re: re, replacement: rule.Replacement}
+	}
+
+	rewrites.mu.Lock()
+	defer rewrites.mu.Unlock()
+	rewrites.rules = compiled
+	rewrites.loaded = true
+	rewrites.err = nil
+	return nil
+}
+
+// LoadRewriteRules sets the rewrite rules from a JSON file holding an array
+// of RewriteRule objects.
+func LoadRewriteRules(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("loading rewrite rules: %w", err)
+	}
+	var rules []RewriteRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("loading rewrite rules from %s: %w", path, err)
+	}
+	return SetRewriteRules(rules)
+}
+
+// rewriteRules returns the rewrite rules, loading them from the file named
+// by RewriteRulesEnv the first time if none were set.
+func rewriteRules() ([]compiledRule, error) {
+	rewrites.mu.RLock()
+	rules, loaded, err := rewrites.rules, rewrites.loaded, rewrites.err
+	rewrites.mu.RUnlock()
+	if loaded {
+		return rules, err
+	}
+
+	path := os.Getenv(RewriteRulesEnv)
+	if path == "" {
+		err = SetRewriteRules(nil)
+	} else {
+		err = LoadRewriteRules(path)
+	}
+	if err != nil {
+		rewrites.mu.Lock()
+		rewrites.loaded = true
+		rewrites.err = err
+		rewrites.mu.Unlock()
+		return nil, err
+	}
+	return rewriteRules()
+}
+
+// rewrite applies the first rule matching rawURL.
+func rewrite(rules []compiledRule, rawURL string) string {
+	for _, rule := range rules {
+		if m := rule.re.FindStringSubmatchIndex(rawURL); m != nil {
+			dst := rule.re.ExpandString(nil, rule.replacement, rawURL, m)
+			return rawURL[:m[0]] + string(dst) + rawURL[m[1]:]
+		}
+	}
+	return rawURL
+}
+
+// rewriteURLs applies the rewrite rules to the URL and mirrors, and returns
+// a function that restores them.
+func (b *Builder) rewriteURLs() (func(), error) {
+	rules, err := rewriteRules()
+	if err != nil || len(rules) == 0 {
+		return func() {}, err
+	}
+	rawURL, mirrors := b.url, b.mirrors
+	b.url = rewrite(rules, b.url)
+	b.mirrors = slices.Clone(mirrors)
+	for i, mirror := range b.mirrors {
+		b.mirrors[i] = rewrite(rules, mirror)
+	}
+	return func() { b.url, b.mirrors = rawURL, mirrors }, nil
+}
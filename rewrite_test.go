@@ -0,0 +1,76 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetRewriteRules(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("mirrored " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	err := retrieve.SetRewriteRules([]retrieve.RewriteRule{
+		{Pattern: `^https://pypi\.example\.test/(\w+)/`, Replacement: server.URL + "/pypi/$1/"},
+		{Pattern: `^https://pypi\.example\.test/`, Replacement: "http://unused.invalid/"},
+	})
+	assert.NoError(t, err)
+	defer retrieve.SetRewriteRules(nil)
+
+	b := retrieve.New("https://pypi.example.test/simple/requests")
+	data, err := b.ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "mirrored /pypi/simple/requests", string(data))
+	assert.Equal(t, "https://pypi.example.test/simple/requests", b.GetUrl())
+}
+
+func TestSetRewriteRules_Mirrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from mirror"))
+	}))
+	defer server.Close()
+
+	assert.NoError(t, retrieve.SetRewriteRules([]retrieve.RewriteRule{
+		{Pattern: `^https://mirror\.example\.test`, Replacement: server.URL},
+	}))
+	defer retrieve.SetRewriteRules(nil)
+
+	mirrors := []string{"https://mirror.example.test/file"}
+	data, err := retrieve.New("http://127.0.0.1:1/file").SetMirrors(mirrors).ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "from mirror", string(data))
+	assert.Equal(t, []string{"https://mirror.example.test/file"}, mirrors)
+}
+
+func TestSetRewriteRules_Invalid(t *testing.T) {
+	err := retrieve.SetRewriteRules([]retrieve.RewriteRule{{Pattern: "(", Replacement: "x"}})
+	assert.ErrorContains(t, err, "invalid rewrite rule 0")
+}
+
+func TestLoadRewriteRules(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "rewrite.json")
+	os.WriteFile(path, []byte(`[{"pattern": "^https://files\\.example\\.test", "replacement": "`+server.URL+`/internal"}]`), 0o644)
+	assert.NoError(t, retrieve.LoadRewriteRules(path))
+	defer retrieve.SetRewriteRules(nil)
+
+	data, err := retrieve.New("https://files.example.test/archive.tar.gz").ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "/internal/archive.tar.gz", string(data))
+
+	assert.ErrorContains(t, retrieve.LoadRewriteRules(filepath.Join(t.TempDir(), "missing.json")), "loading rewrite rules")
+	os.WriteFile(path, []byte(`{`), 0o644)
+	assert.ErrorContains(t, retrieve.LoadRewriteRules(path), "loading rewrite rules from")
+}
@@ -0,0 +1,97 @@
+package retrieve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+var schemes struct {
+	mu         sync.RWMutex
+	transports map[string]http.RoundTripper
+}
+
+// RegisterScheme registers rt to download the URLs of scheme, such as
+// "sftp", for every Builder, so other protocols go through the same
+// output, progress, retry and checksum pipeline as HTTP. The sftp
+// sub-package registers itself this way.
+//
+// rt receives GET and HEAD requests and answers with HTTP status codes. It
+// should honor the Range header and set Content-Length, so downloads can
+// be resumed. Registering a nil RoundTripper removes the one registered
+// for scheme.
+//
+// It panics if scheme is empty or handled by the package itself, such as
+// http or file.
+func RegisterScheme(scheme string, rt http.RoundTripper) {
+	scheme = strings.ToLower(scheme)
+	switch scheme {
+	case "", "http", "https", "file", "data", "ftp", "ftps":
+		panic("retrieve: cannot register scheme " + scheme)
+	}
+
+	schemes.mu.Lock()
+	defer schemes.mu.Unlock()
+	if rt == nil {
+		delete(schemes.transports, scheme)
+		return
+	}
+	if schemes.transports == nil {
+		schemes.transports = make(map[string]http.RoundTripper)
+	}
+	schemes.transports[scheme] = rt
+}
+
+// registeredScheme returns the RoundTripper registered for scheme, if any.
+func registeredScheme(scheme string) (http.RoundTripper, bool) {
+	schemes.mu.RLock()
+	defer schemes.mu.RUnlock()
+	rt, ok := schemes.transports[strings.ToLower(scheme)]
+	return rt, ok
+}
+
+// isRegisteredURL reports whether the scheme of rawURL is registered with
+// RegisterScheme.
+func isRegisteredURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	_, ok := registeredScheme(u.Scheme)
+	return ok
+}
+
+type sshKeyContextKey struct{}
+
+// SetSSHKey sets the private key, in PEM format, to log in with to
+// servers reached over SSH, such as with sftp:// URLs. A password in the
+// URL is tried as well. Keys protected by a passphrase are not supported.
+func (b *Builder) SetSSHKey(keyFile string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		b.err = fmt.Errorf("failed to load SSH key: %v", err)
+		return b
+	}
+	b.sshKey = key
+	return b
+}
+
+// GetSSHKey returns the SSH private key set with SetSSHKey.
+func (b *Builder) GetSSHKey() []byte {
+	return b.sshKey
+}
+
+// SSHKeyFromContext returns the SSH private key set with SetSSHKey for the
+// request ctx belongs to. It is meant for RoundTrippers registered with
+// RegisterScheme.
+func SSHKeyFromContext(ctx context.Context) ([]byte, bool) {
+	key, ok := ctx.Value(sshKeyContextKey{}).([]byte)
+	return key, ok
+}
@@ -0,0 +1,60 @@
+package retrieve_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterScheme(t *testing.T) {
+	var gotKey []byte
+	var gotMethod string
+	retrieve.RegisterScheme("mem", roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		gotKey, _ = retrieve.SSHKeyFromContext(req.Context())
+		gotMethod = req.Method
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        make(http.Header),
+			Body:          io.NopCloser(strings.NewReader("from " + req.URL.Host)),
+			ContentLength: int64(len("from " + req.URL.Host)),
+			Request:       req,
+		}, nil
+	}))
+	defer retrieve.RegisterScheme("mem", nil)
+
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("PRIVATE KEY"), 0o600))
+
+	b := retrieve.New("mem://store/file.txt").SetSSHKey(keyFile)
+	assert.Equal(t, []byte("PRIVATE KEY"), b.GetSSHKey())
+	data, err := b.ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "from store", string(data))
+	assert.Equal(t, []byte("PRIVATE KEY"), gotKey)
+	assert.Equal(t, http.MethodGet, gotMethod)
+
+	retrieve.RegisterScheme("mem", nil)
+	_, err = retrieve.New("mem://store/file.txt").ExecBytes()
+	assert.Error(t, err)
+}
+
+func TestRegisterScheme_BuiltIn(t *testing.T) {
+	rt := roundTripperFunc(func(*http.Request) (*http.Response, error) { return nil, nil })
+	assert.Panics(t, func() { retrieve.RegisterScheme("HTTPS", rt) })
+	assert.Panics(t, func() { retrieve.RegisterScheme("file", rt) })
+	assert.Panics(t, func() { retrieve.RegisterScheme("", rt) })
+}
+
+func TestSetSSHKey_Missing(t *testing.T) {
+	err := retrieve.New("sftp://example.com/file").
+		SetSSHKey(filepath.Join(t.TempDir(), "missing")).
+		Exec()
+	assert.ErrorContains(t, err, "failed to load SSH key")
+}
@@ -0,0 +1,177 @@
+package retrieve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SetSegments splits the download into n byte ranges that are fetched
+// concurrently over separate connections.
+//
+// Segments are only used for GET requests whose response has a known length
+// and advertises "Accept-Ranges: bytes"; other downloads use a single
+// connection. Checksums and piece hashes are verified once all segments finish.
+func (b *Builder) SetSegments(n int) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if n < 1 {
+		b.err = fmt.Errorf("invalid segment count: %d", n)
+		return b
+	}
+	b.segments = n
+	return b
+}
+
+// GetSegments returns the number of segments the download is split into.
+func (b *Builder) GetSegments() int {
+	return b.segments
+}
+
+// SegmentProgress describes one segment of a segmented download.
+type SegmentProgress struct {
+	// Index is the 0-based position of the segment in the file.
+	Index int
+
+	// Start and End are the byte range of the segment, End exclusive.
+	Start, End int64
+
+	// BytesWritten is the number of bytes of the segment written so far.
+	BytesWritten int64
+
+	// BytesPerSecond is the average throughput of the segment since it started.
+	BytesPerSecond float64
+}
+
+// canSegment reports whether resp can be downloaded in segments.
+func (b *Builder) canSegment(resp *http.Response) bool {
+	return b.segments > 1 &&
+		strings.EqualFold(b.method, http.MethodGet) &&
+		resp.StatusCode == http.StatusOK &&
+		!b.fromCache(resp) &&
+		resp.ContentLength >= int64(b.segments) &&
+		strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes")
+}
+
+// copySegments writes resp to out in concurrent segments. The first segment
+// is read from resp itself and the rest are fetched with Range requests.
+func (b *Builder) copySegments(client *http.Client, rawURL string, resp *http.Response, out *os.File) error {
+	ctx, cancel := context.WithCancel(b.ctx)
+	defer cancel()
+
+	tracker := newSegmentTracker(b, resp.ContentLength)
+	validator := rangeValidator(resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"))
+	errs := make([]error, len(tracker.segments))
+
+	var wg sync.WaitGroup
+	for i, seg := range tracker.segments {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := resp.Body
+			if i > 0 {
+				var err error
+				body, err = b.openRange(ctx, client, rawURL, seg.Start, seg.End-1, validator)
+				if err != nil {
+					errs[i] = err
+					cancel()
+					return
+				}
+				defer body.Close()
+			}
+
+			w := &segmentWriter{w: io.NewOffsetWriter(out, seg.Start), t: tracker, index: i}
+			r := io.LimitReader(&bodyReader{r: body}, seg.End-seg.Start)
+			n, err := b.copyBody(w, b.limitReader(ctx, r))
+			err = checkLength(n, seg.End-seg.Start, err)
+			if err != nil {
+				errs[i] = err
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Report the first error that did not stem from another segment failing.
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// segmentTracker aggregates the progress of concurrent segments and reports
+// it to the builder's progress callback.
+type segmentTracker struct {
+	mu       sync.Mutex
+	b        *Builder
+	started  time.Time
+	segments []SegmentProgress
+	prefix   int64 // length of the leading bytes written without gaps
+}
+
+func newSegmentTracker(b *Builder, size int64) *segmentTracker {
+	t := &segmentTracker{b: b, started: time.Now()}
+	n := int64(b.segments)
+	for i := range n {
+		t.segments = append(t.segments, SegmentProgress{
+			Index: int(i),
+			Start: size * i / n,
+			End:   size * (i + 1) / n,
+		})
+	}
+	b.progress.Segments = slices.Clone(t.segments)
+	return t
+}
+
+// advance records that n bytes of segment i were written.
+func (t *segmentTracker) advance(i int, n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seg := &t.segments[i]
+	seg.BytesWritten += n
+	if elapsed := time.Since(t.started).Seconds(); elapsed > 0 {
+		seg.BytesPerSecond = float64(seg.BytesWritten) / elapsed
+	}
+
+	t.b.progress.BytesWritten += n
+	t.b.progress.Segments = slices.Clone(t.segments)
+	t.b.emitProgress()
+
+	// Handler clients read the output sequentially, so only the leading
+	// bytes without gaps are reported to them.
+	var prefix int64
+	for _, seg := range t.segments {
+		prefix += seg.BytesWritten
+		if seg.Start+seg.BytesWritten < seg.End {
+			break
+		}
+	}
+	if prefix > t.prefix {
+		t.b.state.advance(prefix - t.prefix)
+		t.prefix = prefix
+	}
+}
+
+// segmentWriter writes a segment at its offset in the output and reports progress.
+type segmentWriter struct {
+	w     io.Writer
+	t     *segmentTracker
+	index int
+}
+
+func (sw *segmentWriter) Write(p []byte) (int, error) {
+	n, err := sw.w.Write(p)
+	sw.t.advance(sw.index, int64(n))
+	return n, err
+}
@@ -0,0 +1,149 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetSegments(t *testing.T) {
+	assert.Equal(t, 1, retrieve.New("http://example.com").GetSegments())
+	assert.Equal(t, 4, retrieve.New("http://example.com").SetSegments(4).GetSegments())
+	assert.Error(t, retrieve.New("http://example.com").SetSegments(0).Exec())
+}
+
+func TestExec_Segments(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+
+	var ranges atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			ranges.Add(1)
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	var last retrieve.Progress
+	sum := sha256.Sum256(data)
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetSegments(4).
+		VerifyChecksum("sha256", hex.EncodeToString(sum[:])).
+		OnProgress(func(p retrieve.Progress) { last = p }).
+		Exec()
+	assert.NoError(t, err)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+	assert.Equal(t, int32(3), ranges.Load())
+	assert.Equal(t, int64(len(data)), last.BytesWritten)
+	if assert.Len(t, last.Segments, 4) {
+		var end int64
+		for i, seg := range last.Segments {
+			assert.Equal(t, i, seg.Index)
+			assert.Equal(t, end, seg.Start)
+			assert.Equal(t, seg.End-seg.Start, seg.BytesWritten)
+			end = seg.End
+		}
+		assert.Equal(t, int64(len(data)), end)
+	}
+}
+
+func TestExec_SegmentsUnsupported(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Range"))
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	var last retrieve.Progress
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetSegments(4).
+		OnProgress(func(p retrieve.Progress) { last = p }).
+		Exec()
+	assert.NoError(t, err)
+	assert.Nil(t, last.Segments)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+}
+
+func TestExec_SegmentFailure(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out.bin")).
+		SetSegments(2).
+		Exec()
+	assert.ErrorContains(t, err, "range requests")
+}
+
+func TestExec_SegmentFileChanged(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag.Load().(string))
+		if r.Header.Get("Range") == "" {
+			// The file changes once the first response is sent.
+			etag.Store(`"v2"`)
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetSegments(2).
+		Exec()
+	assert.ErrorContains(t, err, "file changed on the server")
+	assert.NoFileExists(t, output)
+}
+
+func TestExec_SegmentContentRangeMismatch(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "" {
+			http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+			return
+		}
+		// Ignore the requested range and send the start of the file.
+		w.Header().Set("Content-Range", "bytes 0-499/1000")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[:500])
+	}))
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetSegments(2).
+		Exec()
+	assert.ErrorContains(t, err, "unexpected Content-Range")
+	assert.NoFileExists(t, output)
+}
@@ -0,0 +1,258 @@
+// Package sftp adds sftp:// URLs to retrieve. Importing it registers a
+// RoundTripper for the scheme, so SFTP downloads go through the same
+// output, progress, resume and checksum pipeline as HTTP:
+//
+//	import _ "github.com/ciathefed/retrieve/sftp"
+//
+//	err := retrieve.New("sftp://deploy@build.internal/releases/app.tar.gz").
+//		SetSSHKey("/home/deploy/.ssh/id_ed25519").
+//		Exec()
+//
+// It logs in with the key set with SetSSHKey and the password of the URL,
+// if any, and checks host keys against ~/.ssh/known_hosts unless another
+// Transport is registered. It lives in its own package to keep the core
+// package free of third-party dependencies.
+package sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ciathefed/retrieve"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func init() {
+	retrieve.RegisterScheme("sftp", &Transport{})
+}
+
+// Transport downloads sftp:// URLs. Register a customized Transport with
+// retrieve.RegisterScheme to replace the default one.
+type Transport struct {
+	// HostKeyCallback verifies the host keys of servers. If nil, they are
+	// checked against ~/.ssh/known_hosts.
+	HostKeyCallback ssh.HostKeyCallback
+
+	// Dial opens the connection to the server. If nil, a net.Dialer is used.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// RoundTrip downloads the file of req.URL, honoring a Range header with a
+// single range. Missing files are reported as 404 Not Found and denied
+// access as 403 Forbidden.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	client, err := t.connect(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := serve(client, req)
+	if err != nil || resp.Body == http.NoBody {
+		client.Close()
+	}
+	return resp, err
+}
+
+// client is an SFTP session and its SSH connection.
+type client struct {
+	*sftp.Client
+	conn *ssh.Client
+}
+
+func (c *client) Close() error {
+	err := c.Client.Close()
+	c.conn.Close()
+	return err
+}
+
+func (t *Transport) connect(req *http.Request) (*client, error) {
+	config, err := t.clientConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr = net.JoinHostPort(req.URL.Hostname(), "22")
+	}
+
+	dial := t.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(req.Context(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(req.Context(), func() { conn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	stop()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	sshClient := ssh.NewClient(c, chans, reqs)
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+	return &client{Client: sftpClient, conn: sshClient}, nil
+}
+
+func (t *Transport) clientConfig(req *http.Request) (*ssh.ClientConfig, error) {
+	config := &ssh.ClientConfig{HostKeyCallback: t.HostKeyCallback}
+	if config.HostKeyCallback == nil {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		callback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+		if err != nil {
+			return nil, fmt.Errorf("sftp: loading known hosts: %w", err)
+		}
+		config.HostKeyCallback = callback
+	}
+
+	if req.URL.User != nil {
+		config.User = req.URL.User.Username()
+	}
+	if config.User == "" {
+		u, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		config.User = u.Username
+	}
+
+	if key, ok := retrieve.SSHKeyFromContext(req.Context()); ok {
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("sftp: parsing SSH key: %w", err)
+		}
+		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+	}
+	if password, ok := req.URL.User.Password(); ok {
+		config.Auth = append(config.Auth, ssh.Password(password))
+	}
+	return config, nil
+}
+
+// serve opens the file requested by req.
+func serve(c *client, req *http.Request) (*http.Response, error) {
+	p := req.URL.Path
+	if p == "" {
+		p = "/"
+	}
+	info, err := c.Stat(p)
+	if err != nil {
+		return errorResponse(req, err)
+	}
+	if info.IsDir() {
+		return response(req, http.StatusNotFound), nil
+	}
+
+	size := info.Size()
+	resp := response(req, http.StatusOK)
+	resp.Header.Set("Accept-Ranges", "bytes")
+	resp.Header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	resp.ContentLength = size
+
+	start, end, partial := parseRange(req.Header.Get("Range"), size)
+	if req.Method == http.MethodHead {
+		return resp, nil
+	}
+	if partial {
+		resp.StatusCode = http.StatusPartialContent
+		resp.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		resp.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		resp.ContentLength = end + 1 - start
+	}
+
+	f, err := c.Open(p)
+	if err != nil {
+		return errorResponse(req, err)
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	resp.Body = &body{Reader: io.LimitReader(f, resp.ContentLength), f: f, c: c}
+	return resp, nil
+}
+
+// errorResponse translates err to an HTTP response, or returns it.
+func errorResponse(req *http.Request, err error) (*http.Response, error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return response(req, http.StatusNotFound), nil
+	case errors.Is(err, fs.ErrPermission):
+		return response(req, http.StatusForbidden), nil
+	}
+	return nil, err
+}
+
+// response returns a response to req with the given status and no body.
+func response(req *http.Request, code int) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode: code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// parseRange parses a Range header with a single range, returning the
+// first and last byte to send and whether the response is partial.
+func parseRange(header string, size int64) (start, end int64, partial bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") || size == 0 {
+		return 0, size - 1, false
+	}
+	first, last, _ := strings.Cut(strings.TrimSpace(spec), "-")
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, size - 1, false
+		}
+		return max(size-n, 0), size - 1, true
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, size - 1, false
+	}
+	end = size - 1
+	if last != "" {
+		if n, err := strconv.ParseInt(last, 10, 64); err == nil && n >= start {
+			end = min(n, size-1)
+		}
+	}
+	return start, end, true
+}
+
+// body closes the file and the session once the download ends.
+type body struct {
+	io.Reader
+	f *sftp.File
+	c *client
+}
+
+func (b *body) Close() error {
+	err := b.f.Close()
+	b.c.Close()
+	return err
+}
@@ -0,0 +1,159 @@
+package sftp_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+	retrievesftp "github.com/ciathefed/retrieve/sftp"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newServer starts an SFTP server serving the local file system to the
+// user alice with the password s3cret, and returns its address and host key.
+func newServer(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "alice" && string(password) == "s3cret" {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, config)
+		}
+	}()
+	return ln.Addr().String(), signer.PublicKey()
+}
+
+func serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "subsystem" || string(req.Payload[4:]) != "sftp" {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+				server, err := sftp.NewServer(channel)
+				if err != nil {
+					return
+				}
+				server.Serve()
+				server.Close()
+				return
+			}
+		}()
+	}
+}
+
+// register installs rt for sftp:// URLs until the test ends.
+func register(t *testing.T, rt *retrievesftp.Transport) {
+	retrieve.RegisterScheme("sftp", rt)
+	t.Cleanup(func() { retrieve.RegisterScheme("sftp", &retrievesftp.Transport{}) })
+}
+
+func TestTransport(t *testing.T) {
+	addr, hostKey := newServer(t)
+	register(t, &retrievesftp.Transport{HostKeyCallback: ssh.FixedHostKey(hostKey)})
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "release.tar.gz")
+	if err := os.WriteFile(src, []byte("sftp content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "download.tar.gz")
+	result, err := retrieve.New("sftp://alice:s3cret@" + addr + filepath.ToSlash(src)).
+		SetOutput(output).
+		ExecWithResult()
+	assert.NoError(t, err)
+	assert.EqualValues(t, len("sftp content"), result.BytesWritten)
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "sftp content", string(data))
+}
+
+func TestTransport_NotFound(t *testing.T) {
+	addr, hostKey := newServer(t)
+	register(t, &retrievesftp.Transport{HostKeyCallback: ssh.FixedHostKey(hostKey)})
+
+	_, err := retrieve.New("sftp://alice:s3cret@" + addr + filepath.ToSlash(filepath.Join(t.TempDir(), "missing"))).
+		ExecBytes()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
+
+func TestTransport_HostKeyMismatch(t *testing.T) {
+	addr, _ := newServer(t)
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ssh.NewPublicKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	register(t, &retrievesftp.Transport{HostKeyCallback: ssh.FixedHostKey(otherKey)})
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "secret.txt")
+	if err := os.WriteFile(src, []byte("private"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "out.txt")
+	err = retrieve.New("sftp://alice:s3cret@" + addr + filepath.ToSlash(src)).
+		SetOutput(output).
+		Exec()
+	assert.ErrorContains(t, err, "host key mismatch")
+	assert.NoFileExists(t, output)
+}
@@ -0,0 +1,103 @@
+package retrieve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ErrInterrupted is returned for Batch items that did not complete because
+// the batch was interrupted by a signal registered with HandleSignals.
+var ErrInterrupted = errors.New("batch interrupted")
+
+// ShutdownMode controls how a Batch reacts to a signal registered with HandleSignals.
+type ShutdownMode int
+
+const (
+	// ShutdownPause cancels in-flight downloads and skips the rest, keeping
+	// partially written files so the batch can be resumed later, even by
+	// another process. The ETag or Last-Modified of each partial download
+	// and the number of bytes written are stored in a sidecar file next to
+	// the output (with a ".resume" suffix), and the next Exec of the same
+	// item resumes it if an If-Range request shows the file is unchanged.
+	// Downloads without an ETag or Last-Modified restart from scratch.
+	ShutdownPause ShutdownMode = iota
+
+	// ShutdownDrain lets in-flight downloads finish but starts no new ones.
+	ShutdownDrain
+
+	// ShutdownAbort cancels in-flight downloads and removes their partially written files.
+	ShutdownAbort
+)
+
+// HandleSignals makes Exec shut down gracefully according to mode when
+// one of the given signals is received. If no signals are given, SIGINT
+// and SIGTERM are handled.
+//
+// Items that do not complete fail with ErrInterrupted. The signals are only
+// handled while Exec runs and only once: a second signal gets the default
+// behavior, which usually terminates the program.
+func (b *Batch) HandleSignals(mode ShutdownMode, signals ...os.Signal) *Batch {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	b.signals = signals
+	b.shutdownMode = mode
+	return b
+}
+
+// shutdownContexts returns the context that stops new items from starting,
+// the context that cancels in-flight items, a function reporting whether a
+// signal was received, and a function releasing the signal handler.
+func (b *Batch) shutdownContexts(ctx context.Context) (context.Context, context.Context, func() bool, func()) {
+	if len(b.signals) == 0 {
+		return ctx, ctx, func() bool { return false }, func() {}
+	}
+
+	sigCtx, stop := signal.NotifyContext(ctx, b.signals...)
+	// Restore the default behavior after the first signal so that a second
+	// one can force the program to exit.
+	stopAfter := context.AfterFunc(sigCtx, stop)
+	release := func() {
+		stopAfter()
+		stop()
+	}
+	interrupted := func() bool {
+		return sigCtx.Err() != nil && ctx.Err() == nil
+	}
+
+	if b.shutdownMode == ShutdownDrain {
+		return sigCtx, ctx, interrupted, release
+	}
+	return sigCtx, sigCtx, interrupted, release
+}
+
+// interrupt marks the incomplete results of the items at the given indices
+// as interrupted and, in ShutdownPause mode, stores the state of their
+// partial downloads or, in ShutdownAbort mode, removes their partially
+// written files.
+func (b *Batch) interrupt(items []int) {
+	for _, i := range items {
+		result := &b.results[i]
+		if !errors.Is(result.Err, context.Canceled) {
+			continue
+		}
+		result.Err = fmt.Errorf("%w: %w", ErrInterrupted, result.Err)
+
+		switch b.shutdownMode {
+		case ShutdownPause:
+			result.Builder.savePartial()
+		case ShutdownAbort:
+			state := result.Builder.state
+			state.mu.Lock()
+			path := state.path
+			state.mu.Unlock()
+			if path != "" {
+				removeIncomplete(path)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch_HandleSignals(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        retrieve.ShutdownMode
+		firstFails  bool
+		partialKept bool
+	}{
+		{name: "pause", mode: retrieve.ShutdownPause, firstFails: true, partialKept: true},
+		{name: "drain", mode: retrieve.ShutdownDrain, firstFails: false, partialKept: true},
+		{name: "abort", mode: retrieve.ShutdownAbort, firstFails: true, partialKept: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "10")
+				w.Write([]byte("01234"))
+				w.(http.Flusher).Flush()
+				select {
+				case <-release:
+				case <-r.Context().Done():
+					return
+				}
+				w.Write([]byte("56789"))
+			}))
+			defer server.Close()
+
+			dir := t.TempDir()
+			first := filepath.Join(dir, "first")
+			second := filepath.Join(dir, "second")
+
+			batch := retrieve.NewBatch().
+				SetWorkers(1).
+				HandleSignals(tt.mode, os.Interrupt).
+				AddURL(server.URL+"/first", first).
+				AddURL(server.URL+"/second", second)
+
+			go func() {
+				for !isExist(first + ".part") {
+					runtime.Gosched()
+				}
+				p, _ := os.FindProcess(os.Getpid())
+				p.Signal(os.Interrupt)
+				if tt.mode == retrieve.ShutdownDrain {
+					// Give the signal time to be delivered before the
+					// in-flight download is allowed to finish.
+					time.Sleep(50 * time.Millisecond)
+					close(release)
+				}
+			}()
+
+			results := batch.Exec()
+			if tt.mode != retrieve.ShutdownDrain {
+				close(release)
+			}
+
+			if tt.firstFails {
+				assert.ErrorIs(t, results[0].Err, retrieve.ErrInterrupted)
+			} else {
+				assert.NoError(t, results[0].Err)
+			}
+			assert.ErrorIs(t, results[1].Err, retrieve.ErrInterrupted)
+			if tt.firstFails {
+				assert.Equal(t, tt.partialKept, isExist(first+".part"))
+				assert.False(t, isExist(first))
+			} else {
+				assert.True(t, isExist(first))
+			}
+			assert.False(t, isExist(second))
+			assert.False(t, isExist(second+".part"))
+		})
+	}
+}
+
+func isExist(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestBatch_HandleSignalsPauseResume(t *testing.T) {
+	data := []byte("0123456789")
+	var mu sync.Mutex
+	var requests []http.Header
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Header.Clone())
+		first := len(requests) == 1
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		if !first {
+			http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+			return
+		}
+		w.Header().Set("Content-Length", "10")
+		w.Write(data[:5])
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	output := filepath.Join(t.TempDir(), "file")
+	go func() {
+		for {
+			info, err := os.Stat(output + ".part")
+			if err == nil && info.Size() == 5 {
+				break
+			}
+			runtime.Gosched()
+		}
+		p, _ := os.FindProcess(os.Getpid())
+		p.Signal(os.Interrupt)
+	}()
+	results := retrieve.NewBatch().
+		HandleSignals(retrieve.ShutdownPause, os.Interrupt).
+		AddURL(server.URL, output).
+		Exec()
+	assert.ErrorIs(t, results[0].Err, retrieve.ErrInterrupted)
+	assert.True(t, isExist(output+".resume"))
+
+	// A fresh Builder, as in a restarted process, resumes the download.
+	err := retrieve.New(server.URL).SetOutput(output).Exec()
+	assert.NoError(t, err)
+
+	got, _ := os.ReadFile(output)
+	assert.Equal(t, data, got)
+	assert.False(t, isExist(output+".part"))
+	assert.False(t, isExist(output+".resume"))
+	if assert.Len(t, requests, 2) {
+		assert.Equal(t, "bytes=5-", requests[1].Get("Range"))
+		assert.Equal(t, `"v1"`, requests[1].Get("If-Range"))
+	}
+}
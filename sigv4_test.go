@@ -0,0 +1,98 @@
+package retrieve_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAWSV4Signer_Sign(t *testing.T) {
+	// Test vectors from the AWS Signature Version 4 test suite.
+	tests := []struct {
+		name      string
+		url       string
+		signature string
+	}{
+		{
+			name:      "get-vanilla",
+			url:       "https://example.amazonaws.com/",
+			signature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:      "get-vanilla-query-order-key-case",
+			url:       "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			signature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+
+	signer := &retrieve.AWSV4Signer{
+		Region:      "us-east-1",
+		Service:     "service",
+		Credentials: retrieve.StaticAWSCredentials("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", ""),
+	}
+	signingTime := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			assert.NoError(t, err)
+			assert.NoError(t, signer.Sign(req, nil, signingTime))
+
+			assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+			assert.Equal(t,
+				"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature="+tt.signature,
+				req.Header.Get("Authorization"))
+		})
+	}
+}
+
+func TestExec_SignAWSV4(t *testing.T) {
+	var calls atomic.Int32
+	var dates []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/"))
+		assert.Contains(t, auth, "/eu-west-1/s3/aws4_request")
+		assert.Contains(t, auth, "x-amz-content-sha256")
+		assert.Equal(t, "token", r.Header.Get("X-Amz-Security-Token"))
+		dates = append(dates, r.Header.Get("X-Amz-Date"))
+
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("object"))
+	}))
+	defer server.Close()
+
+	err := retrieve.New(server.URL+"/bucket/key.txt").
+		SignAWSV4("eu-west-1", "s3", retrieve.StaticAWSCredentials("AKID", "secret", "token")).
+		SetRetries(1).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		SetOutput(filepath.Join(t.TempDir(), "key.txt")).
+		Exec()
+	assert.NoError(t, err)
+	assert.Len(t, dates, 2)
+}
+
+func TestEnvAWSCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	_, err := retrieve.EnvAWSCredentials().Retrieve(context.Background())
+	assert.Error(t, err)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	creds, err := retrieve.EnvAWSCredentials().Retrieve(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "AKID", creds.AccessKeyID)
+}
@@ -0,0 +1,177 @@
+package retrieve
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+)
+
+// BatchRecord is the recorded size and timing of one item of a Batch run.
+// Records can be saved as JSON and replayed with BatchSimulation.
+type BatchRecord struct {
+	URL      string        `json:"url"`
+	Bytes    int64         `json:"bytes"`
+	Duration time.Duration `json:"duration"`
+}
+
+// RecordBatch returns the records of the results of Batch.Exec, failed
+// items included since they took time too.
+func RecordBatch(results []BatchResult) []BatchRecord {
+	records := make([]BatchRecord, len(results))
+	for i, r := range results {
+		records[i] = BatchRecord{Bytes: r.BytesWritten, Duration: r.Duration}
+		if r.Builder != nil {
+			records[i].URL = r.Builder.url
+		}
+	}
+	return records
+}
+
+// BatchSimulation estimates how long a Batch would take under different
+// settings by replaying the records of an earlier run, without network
+// access. It is meant for capacity planning, such as choosing the number
+// of workers and rate limits of a nightly sync.
+//
+// Each item is assumed to download no faster than its recorded average
+// speed, and items without content to take their recorded time. Items
+// start in the order of the records.
+type BatchSimulation struct {
+	// Workers is the number of concurrent downloads, as set with
+	// Batch.SetWorkers. It defaults to 4.
+	Workers int
+
+	// RateLimit caps the speed of each item in bytes per second, as set
+	// with SetRateLimit. Zero means unlimited.
+	RateLimit int64
+
+	// Bandwidth is the speed of the link shared by every item, in bytes
+	// per second. Zero means unlimited.
+	Bandwidth int64
+}
+
+// SimulationResult is the outcome of a BatchSimulation.
+type SimulationResult struct {
+	// Elapsed is the estimated time the whole batch takes.
+	Elapsed time.Duration
+
+	// Finished holds the estimated time at which each item completes,
+	// from the start of the batch, in the order of the records.
+	Finished []time.Duration
+
+	// BytesPerSecond is the average throughput of the batch.
+	BytesPerSecond float64
+}
+
+// simItem is an item being replayed. Items without content wait until
+// deadline; the others transfer remaining bytes at up to limit per second.
+type simItem struct {
+	index     int
+	remaining float64
+	limit     float64
+	deadline  float64
+	rate      float64
+}
+
+// Run replays records under the simulation's settings.
+func (s BatchSimulation) Run(records []BatchRecord) SimulationResult {
+	workers := s.Workers
+	if workers < 1 {
+		workers = defaultBatchWorkers
+	}
+	result := SimulationResult{Finished: make([]time.Duration, len(records))}
+
+	var now float64
+	var total int64
+	next := 0
+	var active []*simItem
+	for next < len(records) || len(active) > 0 {
+		for ; next < len(records) && len(active) < workers; next++ {
+			active = append(active, s.start(next, records[next], now))
+			total += max(records[next].Bytes, 0)
+		}
+
+		s.allocate(active)
+		step := math.Inf(1)
+		for _, item := range active {
+			if item.remaining > 0 {
+				step = min(step, item.remaining/item.rate)
+			} else {
+				step = min(step, item.deadline-now)
+			}
+		}
+		step = max(step, 0)
+
+		now += step
+		active = slices.DeleteFunc(active, func(item *simItem) bool {
+			if item.remaining > 0 {
+				item.remaining -= item.rate * step
+				// Allow for rounding errors.
+				if item.remaining > 1e-6 {
+					return false
+				}
+			} else if item.deadline-now > 1e-9 {
+				return false
+			}
+			result.Finished[item.index] = simDuration(now)
+			return true
+		})
+	}
+
+	result.Elapsed = simDuration(now)
+	if now > 0 {
+		result.BytesPerSecond = float64(total) / now
+	}
+	return result
+}
+
+// start returns the item replaying record from now.
+func (s BatchSimulation) start(index int, record BatchRecord, now float64) *simItem {
+	item := &simItem{index: index, limit: math.Inf(1)}
+	seconds := record.Duration.Seconds()
+	if record.Bytes <= 0 {
+		item.deadline = now + seconds
+		return item
+	}
+	item.remaining = float64(record.Bytes)
+	if seconds > 0 {
+		item.limit = item.remaining / seconds
+	}
+	if s.RateLimit > 0 {
+		item.limit = min(item.limit, float64(s.RateLimit))
+	}
+	return item
+}
+
+// allocate shares the bandwidth between the active items fairly: each item
+// gets an equal share, and what slower items cannot use goes to the rest.
+func (s BatchSimulation) allocate(active []*simItem) {
+	var downloading []*simItem
+	for _, item := range active {
+		if item.remaining > 0 {
+			downloading = append(downloading, item)
+		}
+	}
+	if s.Bandwidth <= 0 {
+		for _, item := range downloading {
+			item.rate = item.limit
+		}
+		return
+	}
+	slices.SortFunc(downloading, func(a, b *simItem) int {
+		return cmp.Compare(a.limit, b.limit)
+	})
+	left := float64(s.Bandwidth)
+	for i, item := range downloading {
+		item.rate = min(item.limit, left/float64(len(downloading)-i))
+		left -= item.rate
+	}
+}
+
+// simDuration converts simulated seconds to a Duration.
+func simDuration(seconds float64) time.Duration {
+	if math.IsInf(seconds, 0) || seconds > math.MaxInt64/float64(time.Second) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,76 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchSimulation(t *testing.T) {
+	records := make([]retrieve.BatchRecord, 4)
+	for i := range records {
+		records[i] = retrieve.BatchRecord{Bytes: 100, Duration: time.Second}
+	}
+
+	tests := []struct {
+		name string
+		sim  retrieve.BatchSimulation
+		want time.Duration
+	}{
+		{"sequential", retrieve.BatchSimulation{Workers: 1}, 4 * time.Second},
+		{"concurrent", retrieve.BatchSimulation{Workers: 4}, time.Second},
+		{"shared bandwidth", retrieve.BatchSimulation{Workers: 4, Bandwidth: 200}, 2 * time.Second},
+		{"rate limited", retrieve.BatchSimulation{Workers: 2, RateLimit: 50}, 4 * time.Second},
+		{"default workers", retrieve.BatchSimulation{}, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.sim.Run(records)
+			assert.InDelta(t, tt.want, result.Elapsed, float64(time.Millisecond))
+			assert.InDelta(t, 400/tt.want.Seconds(), result.BytesPerSecond, 0.01)
+		})
+	}
+}
+
+func TestBatchSimulation_UnevenItems(t *testing.T) {
+	records := []retrieve.BatchRecord{
+		{Bytes: 100, Duration: 4 * time.Second}, // 25 B/s at most
+		{Bytes: 300, Duration: time.Second},
+		{Bytes: 0, Duration: 500 * time.Millisecond},
+	}
+	result := retrieve.BatchSimulation{Workers: 2, Bandwidth: 100}.Run(records)
+
+	// The slow item keeps 25 B/s and the fast one gets the other 75 B/s.
+	assert.InDelta(t, 4*time.Second, result.Finished[0], float64(time.Millisecond))
+	assert.InDelta(t, 4*time.Second, result.Finished[1], float64(time.Millisecond))
+	// The empty item only starts once a worker is free.
+	assert.InDelta(t, 4500*time.Millisecond, result.Finished[2], float64(time.Millisecond))
+	assert.Equal(t, result.Finished[2], result.Elapsed)
+}
+
+func TestRecordBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	results := retrieve.NewBatch().
+		AddURL(server.URL+"/a", filepath.Join(dir, "a")).
+		AddURL(server.URL+"/b", filepath.Join(dir, "b")).
+		Exec()
+
+	records := retrieve.RecordBatch(results)
+	assert.Len(t, records, 2)
+	for i, record := range records {
+		assert.Equal(t, server.URL+[]string{"/a", "/b"}[i], record.URL)
+		assert.EqualValues(t, 10, record.Bytes)
+		assert.Equal(t, results[i].Duration, record.Duration)
+	}
+}
@@ -0,0 +1,128 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTimeGate is the Memento TimeGate of the Internet Archive's Wayback
+// Machine, used by AsOf unless SetTimeGate is called.
+const DefaultTimeGate = "https://web.archive.org/web/"
+
+// ErrNoSnapshot is returned by AsOf downloads when the archive has no
+// snapshot of the URL.
+var ErrNoSnapshot = errors.New("no archived snapshot")
+
+// AsOf downloads the archived snapshot of the URL closest to t instead of
+// the live URL, for reproducible pipelines whose sources change or
+// disappear. The snapshot is found with the Memento protocol (RFC 7089),
+// through the Wayback Machine or the TimeGate set with SetTimeGate.
+//
+// Snapshots of Wayback Machine style archives are downloaded as originally
+// captured, without the archive's rewriting. The Result reports the URL of
+// the snapshot, and its Header the Memento-Datetime it was captured at.
+// Mirrors are not affected.
+func (b *Builder) AsOf(t time.Time) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.asOf = t
+	return b
+}
+
+// GetAsOf returns the time set with AsOf, or the zero time.
+func (b *Builder) GetAsOf() time.Time {
+	return b.asOf
+}
+
+// SetTimeGate sets the Memento TimeGate used by AsOf, as the prefix the URL
+// is appended to, such as "https://archive.example.org/web/".
+func (b *Builder) SetTimeGate(base string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		b.err = fmt.Errorf("invalid TimeGate: %s", base)
+		return b
+	}
+	b.timeGate = base
+	return b
+}
+
+// GetTimeGate returns the Memento TimeGate used by AsOf.
+func (b *Builder) GetTimeGate() string {
+	if b.timeGate == "" {
+		return DefaultTimeGate
+	}
+	return b.timeGate
+}
+
+// resolveSnapshot replaces the URL with the one of its snapshot closest to
+// the time set with AsOf, asking the TimeGate.
+func (b *Builder) resolveSnapshot() error {
+	if b.asOf.IsZero() {
+		return nil
+	}
+	if err := b.findSnapshot(); err != nil {
+		return fmt.Errorf("finding snapshot of %s: %w", b.url, err)
+	}
+	return nil
+}
+
+func (b *Builder) findSnapshot() error {
+	client, release := b.httpClient()
+	defer release()
+	gate := *client
+	gate.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	timeGate := b.GetTimeGate()
+	req, err := http.NewRequestWithContext(b.ctx, http.MethodGet, timeGate+b.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept-Datetime", b.asOf.UTC().Format(http.TimeFormat))
+	resp, err := gate.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	var memento string
+	switch {
+	case resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") != "":
+		location, err := req.URL.Parse(resp.Header.Get("Location"))
+		if err != nil {
+			return err
+		}
+		memento = location.String()
+	case resp.StatusCode == http.StatusOK && resp.Header.Get("Memento-Datetime") != "":
+		memento = req.URL.String()
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNoSnapshot
+	default:
+		return newStatusError(resp)
+	}
+	b.url = originalMemento(timeGate, memento)
+	return nil
+}
+
+// originalMemento returns the URL of the memento as originally captured
+// for Wayback Machine style archives, whose memento URLs are the TimeGate
+// followed by a 14-digit timestamp and the URL, by adding the "id_" flag
+// to the timestamp. Other URLs are returned as is.
+func originalMemento(timeGate, memento string) string {
+	rest, ok := strings.CutPrefix(memento, timeGate)
+	if !ok {
+		return memento
+	}
+	timestamp, original, ok := strings.Cut(rest, "/")
+	if !ok || len(timestamp) != 14 || strings.Trim(timestamp, "0123456789") != "" {
+		return memento
+	}
+	return timeGate + timestamp + "id_/" + original
+}
@@ -0,0 +1,70 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTimeGate serves snapshots of http://example.com/data.csv like the
+// Wayback Machine, redirecting to the snapshot from 2020 for earlier dates.
+func newTimeGate(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/web/http://example.com/data.csv":
+			asOf, err := http.ParseTime(r.Header.Get("Accept-Datetime"))
+			assert.NoError(t, err)
+			if asOf.Year() < 2019 {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Location", "/web/20200102030405/http://example.com/data.csv")
+			w.WriteHeader(http.StatusFound)
+		case "/web/20200102030405id_/http://example.com/data.csv":
+			w.Header().Set("Memento-Datetime", "Thu, 02 Jan 2020 03:04:05 GMT")
+			w.Write([]byte("a,b\n1,2\n"))
+		default:
+			w.Write([]byte("rewritten by the archive"))
+		}
+	}))
+}
+
+func TestAsOf(t *testing.T) {
+	gate := newTimeGate(t)
+	defer gate.Close()
+
+	asOf := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	b := retrieve.New("http://example.com/data.csv").AsOf(asOf).SetTimeGate(gate.URL + "/web/")
+	assert.Equal(t, asOf, b.GetAsOf())
+	assert.Equal(t, gate.URL+"/web/", b.GetTimeGate())
+
+	var data strings.Builder
+	result, err := b.SetWriter(&data).ExecWithResult()
+	assert.NoError(t, err)
+	assert.Equal(t, "a,b\n1,2\n", data.String())
+	assert.Equal(t, gate.URL+"/web/20200102030405id_/http://example.com/data.csv", result.URL)
+	assert.Equal(t, "Thu, 02 Jan 2020 03:04:05 GMT", result.Header.Get("Memento-Datetime"))
+	assert.Equal(t, "http://example.com/data.csv", b.GetUrl())
+}
+
+func TestAsOf_NoSnapshot(t *testing.T) {
+	gate := newTimeGate(t)
+	defer gate.Close()
+
+	_, err := retrieve.New("http://example.com/data.csv").
+		AsOf(time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)).
+		SetTimeGate(gate.URL + "/web/").
+		ExecBytes()
+	assert.ErrorIs(t, err, retrieve.ErrNoSnapshot)
+}
+
+func TestSetTimeGate(t *testing.T) {
+	assert.Equal(t, retrieve.DefaultTimeGate, retrieve.New("http://example.com").GetTimeGate())
+	assert.ErrorContains(t, retrieve.New("http://example.com").SetTimeGate("archive.org").Exec(), "invalid TimeGate")
+}
@@ -0,0 +1,122 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrBlockedAddress is returned when BlockPrivateAddresses rejects a URL
+// or a connection.
+var ErrBlockedAddress = errors.New("address blocked")
+
+// blockedPrefixes are the ranges rejected by BlockPrivateAddresses besides
+// the loopback, private, link-local, multicast and unspecified ones known
+// to netip.Addr.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT, including 100.100.100.200
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which can reach any IPv4 address
+}
+
+// BlockPrivateAddresses rejects URLs, redirects and connections to
+// loopback, private (RFC 1918 and unique local), link-local, carrier-grade
+// NAT and other special-purpose addresses, which include cloud metadata
+// services such as 169.254.169.254. Services that download user-supplied
+// URLs need it to avoid server-side request forgery.
+//
+// Addresses are checked when connecting, after host names are resolved, so
+// a host name cannot resolve to a blocked address. Only http, https, ftp,
+// ftps and data URLs are allowed. Requests fail with ErrBlockedAddress and
+// are not retried.
+//
+// Connections to a proxy are checked too. The host names of URLs sent
+// through a proxy are resolved by the proxy, so only IP addresses in those
+// URLs are checked. It cannot be combined with SetTransport or HTTP/3,
+// which dial on their own.
+func (b *Builder) BlockPrivateAddresses() *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.blockPrivate = true
+	return b
+}
+
+// IsBlockPrivateAddresses returns whether private addresses are blocked.
+func (b *Builder) IsBlockPrivateAddresses() bool {
+	return b.blockPrivate
+}
+
+// checkPrivateAddresses checks the URL and mirrors when private addresses
+// are blocked, and that the connections can be checked.
+func (b *Builder) checkPrivateAddresses() error {
+	if !b.blockPrivate {
+		return nil
+	}
+	if _, ok := b.baseClient().Transport.(*http.Transport); b.transport != nil || b.http3 ||
+		!ok && b.baseClient().Transport != nil {
+		return errors.New("BlockPrivateAddresses requires the standard transport")
+	}
+	for _, rawURL := range append([]string{b.url}, b.mirrors...) {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		if err := checkAllowedURL(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAllowedURL rejects u if its scheme is not allowed or its host is a
+// blocked IP address.
+func checkAllowedURL(u *url.URL) error {
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ftp", "ftps", "data":
+	default:
+		return fmt.Errorf("%w: %s URLs are not allowed", ErrBlockedAddress, u.Scheme)
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(u.Hostname(), "[]")); err == nil && isBlockedAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+	}
+	return nil
+}
+
+// controlBlocked is a net.Dialer Control function rejecting connections to
+// blocked addresses.
+func controlBlocked(network, address string, _ syscall.RawConn) error {
+	if !strings.HasPrefix(network, "tcp") && !strings.HasPrefix(network, "udp") {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+	if isBlockedAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// isBlockedAddr reports whether addr is blocked by BlockPrivateAddresses.
+func isBlockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsMulticast() ||
+		addr.IsUnspecified() || !addr.IsGlobalUnicast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
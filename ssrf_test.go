@@ -0,0 +1,81 @@
+package retrieve_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	b := retrieve.New(server.URL).BlockPrivateAddresses().SetRetries(2)
+	assert.True(t, b.IsBlockPrivateAddresses())
+	_, err := b.ExecBytes()
+	assert.ErrorIs(t, err, retrieve.ErrBlockedAddress)
+
+	// Host names are checked once resolved.
+	_, err = retrieve.New("http://localhost:" + port).BlockPrivateAddresses().ExecBytes()
+	assert.ErrorIs(t, err, retrieve.ErrBlockedAddress)
+	_, err = retrieve.New("http://files.example.com:"+port).
+		ResolveTo("files.example.com", "10.0.0.1", "127.0.0.1").
+		BlockPrivateAddresses().
+		ExecBytes()
+	assert.ErrorIs(t, err, retrieve.ErrBlockedAddress)
+
+	assert.Zero(t, hits.Load())
+}
+
+func TestBlockPrivateAddresses_URLs(t *testing.T) {
+	for _, rawURL := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[fd00:ec2::254]/latest/meta-data/",
+		"http://100.100.100.200/latest/meta-data/",
+		"http://192.168.1.1/",
+		"http://172.16.0.1/",
+		"http://0.0.0.0/",
+		"http://[::1]/",
+		"http://[::ffff:127.0.0.1]/",
+		"http://[fe80::1]/",
+		"file:///etc/passwd",
+	} {
+		err := retrieve.New(rawURL).BlockPrivateAddresses().SetOutput(filepath.Join(t.TempDir(), "out")).Exec()
+		assert.ErrorIs(t, err, retrieve.ErrBlockedAddress, rawURL)
+	}
+
+	err := retrieve.New("http://93.184.216.34/").
+		SetMirrors([]string{"http://10.1.2.3/"}).
+		BlockPrivateAddresses().
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrBlockedAddress)
+
+	data, err := retrieve.New("data:,public").BlockPrivateAddresses().ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "public", string(data))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = retrieve.New("http://93.184.216.34/").SetContext(ctx).BlockPrivateAddresses().ExecBytes()
+	assert.NotErrorIs(t, err, retrieve.ErrBlockedAddress)
+}
+
+func TestBlockPrivateAddresses_CustomTransport(t *testing.T) {
+	err := retrieve.New("http://93.184.216.34/").
+		SetTransport(http.DefaultTransport).
+		BlockPrivateAddresses().
+		Exec()
+	assert.ErrorContains(t, err, "requires the standard transport")
+}
@@ -0,0 +1,13 @@
+package retrieve
+
+// stdoutPath is the output path that writes the response to standard output.
+const stdoutPath = "-"
+
+// ToStdout writes the response to standard output, so it can be piped into
+// another process. It is the same as SetOutput("-").
+//
+// Like SetWriter, options that operate on the output file do not apply, and
+// a failed download is only retried if nothing was written yet.
+func (b *Builder) ToStdout() *Builder {
+	return b.SetOutput(stdoutPath)
+}
@@ -0,0 +1,68 @@
+package retrieve_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// captureStdout redirects os.Stdout while fn runs and returns what was written.
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestToStdout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("piped"))
+	}))
+	defer server.Close()
+
+	for _, b := range []*retrieve.Builder{
+		retrieve.New(server.URL).ToStdout(),
+		retrieve.New(server.URL).SetOutput("-"),
+	} {
+		var err error
+		out := captureStdout(t, func() { err = b.Exec() })
+		assert.NoError(t, err)
+		assert.Equal(t, "piped", out)
+		assert.Equal(t, "-", b.GetOutput())
+		assert.Nil(t, b.GetWriter())
+		assert.NoFileExists(t, "-")
+	}
+}
+
+func TestToStdout_ExecBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("in memory"))
+	}))
+	defer server.Close()
+
+	var data []byte
+	var err error
+	out := captureStdout(t, func() { data, err = retrieve.New(server.URL).ToStdout().ExecBytes() })
+	assert.NoError(t, err)
+	assert.Equal(t, "in memory", string(data))
+	assert.Empty(t, out)
+}
@@ -0,0 +1,163 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStalled is returned when no data of a response body arrives within
+// the timeout set with SetIdleReadTimeout.
+var ErrStalled = errors.New("download stalled")
+
+// SetConnectTimeout limits how long establishing a connection may take.
+// It defaults to 30 seconds.
+//
+// Setting any of SetConnectTimeout, SetTLSHandshakeTimeout,
+// SetResponseHeaderTimeout, SetIdleReadTimeout, SetAttemptTimeout,
+// SetTotalDeadline or DeadlineFromSize removes the default overall timeout,
+// which would cut off large downloads, unless SetTimeout is called too.
+func (b *Builder) SetConnectTimeout(d time.Duration) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if d <= 0 {
+		b.err = fmt.Errorf("invalid connect timeout: %v", d)
+		return b
+	}
+	b.connectTimeout = d
+	return b
+}
+
+// GetConnectTimeout returns the connect timeout, or 0 if the default applies.
+func (b *Builder) GetConnectTimeout() time.Duration {
+	return b.connectTimeout
+}
+
+// SetTLSHandshakeTimeout limits how long the TLS handshake may take. See
+// SetConnectTimeout.
+func (b *Builder) SetTLSHandshakeTimeout(d time.Duration) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if d <= 0 {
+		b.err = fmt.Errorf("invalid TLS handshake timeout: %v", d)
+		return b
+	}
+	b.tlsHandshakeTimeout = d
+	return b
+}
+
+// GetTLSHandshakeTimeout returns the TLS handshake timeout, or 0 if the
+// transport's default applies.
+func (b *Builder) GetTLSHandshakeTimeout() time.Duration {
+	return b.tlsHandshakeTimeout
+}
+
+// SetResponseHeaderTimeout limits how long to wait for the response
+// headers once the request has been sent. See SetConnectTimeout.
+func (b *Builder) SetResponseHeaderTimeout(d time.Duration) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if d <= 0 {
+		b.err = fmt.Errorf("invalid response header timeout: %v", d)
+		return b
+	}
+	b.responseHeaderTimeout = d
+	return b
+}
+
+// GetResponseHeaderTimeout returns the response header timeout, or 0 if
+// there is none.
+func (b *Builder) GetResponseHeaderTimeout() time.Duration {
+	return b.responseHeaderTimeout
+}
+
+// SetIdleReadTimeout fails a download with ErrStalled when no data of the
+// response body arrives for d, however long the download takes overall.
+// Stalled downloads are retried like other transient failures, resuming
+// where possible. See SetConnectTimeout.
+func (b *Builder) SetIdleReadTimeout(d time.Duration) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if d <= 0 {
+		b.err = fmt.Errorf("invalid idle read timeout: %v", d)
+		return b
+	}
+	b.idleReadTimeout = d
+	return b
+}
+
+// GetIdleReadTimeout returns the idle read timeout, or 0 if there is none.
+func (b *Builder) GetIdleReadTimeout() time.Duration {
+	return b.idleReadTimeout
+}
+
+// hasFineTimeouts reports whether any timeout other than the overall one is set.
+func (b *Builder) hasFineTimeouts() bool {
+	return b.connectTimeout > 0 || b.tlsHandshakeTimeout > 0 || b.responseHeaderTimeout > 0 || b.idleReadTimeout > 0 ||
+		b.attemptTimeout > 0 || b.totalDeadline > 0 || b.minBytesPerSec > 0
+}
+
+// clientTimeout returns the overall timeout of the client. The default
+// gives way to the finer timeouts.
+func (b *Builder) clientTimeout() time.Duration {
+	if !b.timeoutSet && b.hasFineTimeouts() {
+		return 0
+	}
+	return b.timeout
+}
+
+// stallTransport wraps response bodies to fail reads that stall.
+type stallTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *stallTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = newStallBody(resp.Body, t.timeout)
+	return resp, nil
+}
+
+// stallBody closes the body when a read waits longer than the timeout,
+// which unblocks the read.
+type stallBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+func newStallBody(body io.ReadCloser, timeout time.Duration) *stallBody {
+	sb := &stallBody{ReadCloser: body, timeout: timeout}
+	sb.timer = time.AfterFunc(timeout, func() {
+		sb.stalled.Store(true)
+		body.Close()
+	})
+	sb.timer.Stop()
+	return sb
+}
+
+func (sb *stallBody) Read(p []byte) (int, error) {
+	sb.timer.Reset(sb.timeout)
+	n, err := sb.ReadCloser.Read(p)
+	sb.timer.Stop()
+	if sb.stalled.Load() {
+		return n, fmt.Errorf("%w: no data for %v", ErrStalled, sb.timeout)
+	}
+	return n, err
+}
+
+func (sb *stallBody) Close() error {
+	sb.timer.Stop()
+	return sb.ReadCloser.Close()
+}
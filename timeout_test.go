@@ -0,0 +1,119 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhaseTimeouts(t *testing.T) {
+	b := retrieve.New("http://example.com")
+	assert.Equal(t, time.Duration(0), b.GetConnectTimeout())
+	assert.Equal(t, time.Duration(0), b.GetIdleReadTimeout())
+
+	b.SetConnectTimeout(time.Second).
+		SetTLSHandshakeTimeout(2 * time.Second).
+		SetResponseHeaderTimeout(3 * time.Second).
+		SetIdleReadTimeout(4 * time.Second)
+	assert.Equal(t, time.Second, b.GetConnectTimeout())
+	assert.Equal(t, 2*time.Second, b.GetTLSHandshakeTimeout())
+	assert.Equal(t, 3*time.Second, b.GetResponseHeaderTimeout())
+	assert.Equal(t, 4*time.Second, b.GetIdleReadTimeout())
+
+	err := retrieve.New("http://example.com").SetIdleReadTimeout(0).Exec()
+	assert.ErrorContains(t, err, "invalid idle read timeout")
+}
+
+func TestSetResponseHeaderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	err := retrieve.New(server.URL).SetWriter(io.Discard).SetResponseHeaderTimeout(50 * time.Millisecond).Exec()
+	assert.ErrorContains(t, err, "timeout awaiting response headers")
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+// newStallingServer sends the first part of "hello world" and stalls the
+// first time, and serves the rest as a Range response when asked.
+func newStallingServer(t *testing.T) *httptest.Server {
+	const content = "hello world"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") == "bytes=5-" {
+			w.Header().Set("Content-Range", "bytes 5-10/11")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(content[5:]))
+			return
+		}
+		w.Header().Set("Content-Length", "11")
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Write([]byte(content[:5]))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSetIdleReadTimeout(t *testing.T) {
+	server := newStallingServer(t)
+
+	var buf bytes.Buffer
+	err := retrieve.New(server.URL).SetWriter(&buf).SetIdleReadTimeout(50 * time.Millisecond).Exec()
+	assert.ErrorIs(t, err, retrieve.ErrStalled)
+	assert.Equal(t, "hello", buf.String())
+}
+
+func TestSetIdleReadTimeout_Resume(t *testing.T) {
+	server := newStallingServer(t)
+
+	output := filepath.Join(t.TempDir(), "file")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetIdleReadTimeout(50*time.Millisecond).
+		SetRetries(1).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		Exec()
+	assert.NoError(t, err)
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
+
+func TestSetIdleReadTimeout_SlowDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for range 10 {
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	// A steady download is not stalled, but an overall timeout set
+	// explicitly still applies.
+	data, err := retrieve.New(server.URL).SetIdleReadTimeout(100 * time.Millisecond).ExecString()
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 10), data)
+
+	_, err = retrieve.New(server.URL).SetIdleReadTimeout(100 * time.Millisecond).SetTimeout(50 * time.Millisecond).ExecString()
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package retrieve
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+// SetTLSConfig sets the TLS configuration used for HTTPS requests.
+//
+// Options such as InsecureSkipVerify, SetRootCAs and SetClientCert are
+// applied on top of a copy of config.
+func (b *Builder) SetTLSConfig(config *tls.Config) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.tlsConfig = config
+	return b
+}
+
+// GetTLSConfig returns the TLS configuration set for the request, if any.
+func (b *Builder) GetTLSConfig() *tls.Config {
+	return b.tlsConfig
+}
+
+// InsecureSkipVerify disables verification of the server's certificate chain and host name.
+//
+// This makes the connection vulnerable to machine-in-the-middle attacks and
+// should only be used for testing.
+func (b *Builder) InsecureSkipVerify() *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.insecureSkipVerify = true
+	return b
+}
+
+// IsInsecureSkipVerify returns whether server certificate verification is disabled.
+func (b *Builder) IsInsecureSkipVerify() bool {
+	return b.insecureSkipVerify
+}
+
+// SetRootCAs sets the certificate authorities used to verify the server,
+// for example a private enterprise CA.
+func (b *Builder) SetRootCAs(pool *x509.CertPool) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.rootCAs = pool
+	return b
+}
+
+// GetRootCAs returns the certificate authorities set for the request, if any.
+func (b *Builder) GetRootCAs() *x509.CertPool {
+	return b.rootCAs
+}
+
+// SetClientCert loads a PEM-encoded certificate and private key from the
+// given files and presents them to the server for mutual TLS.
+func (b *Builder) SetClientCert(certFile, keyFile string) *Builder {
+	if b.err != nil {
+		return b
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		b.err = fmt.Errorf("failed to load client certificate: %v", err)
+		return b
+	}
+	b.clientCerts = append(b.clientCerts, cert)
+	return b
+}
+
+// GetClientCerts returns the client certificates set for the request.
+func (b *Builder) GetClientCerts() []tls.Certificate {
+	return b.clientCerts
+}
+
+// NewTLSSessionCache returns a TLS session cache holding up to capacity
+// sessions, keyed by server name. Share it between Builders with
+// SetTLSSessionCache to resume TLS sessions across downloads from the same host.
+func NewTLSSessionCache(capacity int) tls.ClientSessionCache {
+	return tls.NewLRUClientSessionCache(capacity)
+}
+
+// SetTLSSessionCache sets the cache used to resume TLS sessions, cutting
+// handshake latency when downloading many files from the same host.
+func (b *Builder) SetTLSSessionCache(cache tls.ClientSessionCache) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.tlsSessionCache = cache
+	return b
+}
+
+// GetTLSSessionCache returns the TLS session cache set for the request, if any.
+func (b *Builder) GetTLSSessionCache() tls.ClientSessionCache {
+	return b.tlsSessionCache
+}
+
+// SetECHConfigList enables Encrypted Client Hello using the given
+// ECHConfigList, as published in the server's DNS HTTPS record.
+//
+// This is experimental. If the server rejects ECH, the request fails
+// rather than falling back to a plaintext Client Hello. Use ECHAccepted
+// to check whether ECH was used.
+func (b *Builder) SetECHConfigList(configList []byte) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.echConfigList = configList
+	return b
+}
+
+// GetECHConfigList returns the ECHConfigList set for the request, if any.
+func (b *Builder) GetECHConfigList() []byte {
+	return b.echConfigList
+}
+
+// ECHAccepted reports whether the server accepted Encrypted Client Hello
+// during the most recent call to Exec.
+func (b *Builder) ECHAccepted() bool {
+	return b.echAccepted
+}
+
+// needsTLSConfig reports whether any TLS option is set.
+func (b *Builder) needsTLSConfig() bool {
+	return b.tlsConfig != nil ||
+		b.insecureSkipVerify ||
+		b.rootCAs != nil ||
+		len(b.clientCerts) > 0 ||
+		b.tlsSessionCache != nil ||
+		b.echConfigList != nil
+}
+
+// buildTLSConfig returns a copy of base with the builder's TLS options applied.
+// A configuration set with SetTLSConfig takes precedence over base.
+func (b *Builder) buildTLSConfig(base *tls.Config) *tls.Config {
+	if b.tlsConfig != nil {
+		base = b.tlsConfig
+	}
+	var config *tls.Config
+	if base != nil {
+		config = base.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+	if b.insecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+	if b.rootCAs != nil {
+		config.RootCAs = b.rootCAs
+	}
+	if len(b.clientCerts) > 0 {
+		config.Certificates = append(config.Certificates, b.clientCerts...)
+	}
+	if b.tlsSessionCache != nil {
+		config.ClientSessionCache = b.tlsSessionCache
+	}
+	if b.echConfigList != nil {
+		config.EncryptedClientHelloConfigList = b.echConfigList
+		config.MinVersion = tls.VersionTLS13
+	}
+	return config
+}
@@ -0,0 +1,197 @@
+package retrieve_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingSessionCache struct {
+	tls.ClientSessionCache
+	hits atomic.Int32
+}
+
+func (c *countingSessionCache) Get(key string) (*tls.ClientSessionState, bool) {
+	session, ok := c.ClientSessionCache.Get(key)
+	if ok {
+		c.hits.Add(1)
+	}
+	return session, ok
+}
+
+func TestSetTLSSessionCache(t *testing.T) {
+	var resumed atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS.DidResume {
+			resumed.Add(1)
+		}
+		w.Write([]byte("success"))
+	}))
+	server.StartTLS()
+	defer server.Close()
+
+	cache := &countingSessionCache{ClientSessionCache: retrieve.NewTLSSessionCache(8)}
+	for range 2 {
+		err := retrieve.New(server.URL).
+			SetClient(server.Client()).
+			SetTLSSessionCache(cache).
+			SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+			Exec()
+		assert.NoError(t, err)
+	}
+
+	assert.Positive(t, cache.hits.Load())
+	assert.Equal(t, int32(1), resumed.Load())
+}
+
+func TestSetECHConfigList(t *testing.T) {
+	b := retrieve.New("https://example.com").SetECHConfigList([]byte{0x00, 0x01})
+	assert.Equal(t, []byte{0x00, 0x01}, b.GetECHConfigList())
+}
+
+func TestExec_ECHNotAccepted(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success"))
+	}))
+	defer server.Close()
+
+	b := retrieve.New(server.URL).
+		SetClient(server.Client()).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt"))
+	assert.NoError(t, b.Exec())
+	assert.False(t, b.ECHAccepted())
+
+	err := retrieve.New(server.URL).
+		SetClient(server.Client()).
+		SetECHConfigList([]byte{0x00, 0x01}).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		Exec()
+	assert.Error(t, err)
+}
+
+func TestExec_InsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success"))
+	}))
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		Exec()
+	assert.Error(t, err)
+
+	b := retrieve.New(server.URL).
+		InsecureSkipVerify().
+		SetOutput(filepath.Join(t.TempDir(), "out.txt"))
+	assert.True(t, b.IsInsecureSkipVerify())
+	assert.NoError(t, b.Exec())
+}
+
+func TestExec_SetRootCAs(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success"))
+	}))
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	err := retrieve.New(server.URL).
+		SetRootCAs(pool).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		Exec()
+	assert.NoError(t, err)
+}
+
+func TestExec_SetTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("success"))
+	}))
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	config := &tls.Config{RootCAs: pool}
+	b := retrieve.New(server.URL).
+		SetTLSConfig(config).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt"))
+	assert.Same(t, config, b.GetTLSConfig())
+	assert.NoError(t, b.Exec())
+}
+
+func TestExec_SetClientCert(t *testing.T) {
+	certFile, keyFile := writeClientCert(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		InsecureSkipVerify().
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		Exec()
+	assert.Error(t, err)
+
+	output := filepath.Join(t.TempDir(), "out.txt")
+	b := retrieve.New(server.URL).
+		InsecureSkipVerify().
+		SetClientCert(certFile, keyFile).
+		SetOutput(output)
+	assert.Len(t, b.GetClientCerts(), 1)
+	assert.NoError(t, b.Exec())
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "retrieve-client", string(data))
+}
+
+func TestSetClientCert_MissingFile(t *testing.T) {
+	err := retrieve.New("https://example.com").SetClientCert("missing.pem", "missing.key").Exec()
+	assert.Error(t, err)
+}
+
+func writeClientCert(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "retrieve-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client.key")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
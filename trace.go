@@ -0,0 +1,84 @@
+package retrieve
+
+import (
+	"context"
+	"net/http"
+)
+
+// Attempt describes an attempt of a request to a Tracer.
+type Attempt struct {
+	// Method and URL are those of the request, before redirects.
+	Method string
+	URL    string
+
+	// Number is the number of the attempt, starting at 1 for each URL.
+	Number int
+
+	// Previous is the context returned by the Tracer for the previous
+	// attempt of the same Exec, such as on another mirror, or nil for the
+	// first, so retries can be linked to the attempts they repeat.
+	Previous context.Context
+
+	// StatusCode, BytesWritten and Err describe the outcome of the
+	// attempt, once it ended. StatusCode is 0 if no response was received.
+	StatusCode   int
+	BytesWritten int64
+	Err          error
+}
+
+// Tracer traces the attempts of a request, as set with WithTracing. The
+// retrieveotel package implements it with OpenTelemetry.
+type Tracer interface {
+	// StartAttempt is called before each attempt with the builder's context
+	// and returns the context the attempt runs with, usually holding a new
+	// span, and a function called with the outcome of the attempt.
+	StartAttempt(ctx context.Context, attempt Attempt) (context.Context, func(Attempt))
+}
+
+// WithTracing traces each attempt of the request with tracer, for example
+// with OpenTelemetry:
+//
+//	New(url).WithTracing(retrieveotel.NewTracer(tp))
+func (b *Builder) WithTracing(tracer Tracer) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.tracer = tracer
+	return b
+}
+
+// GetTracer returns the Tracer set with WithTracing, if any.
+func (b *Builder) GetTracer() Tracer {
+	return b.tracer
+}
+
+// tracedAttempt performs an attempt, traced with the Tracer if one is set.
+func (b *Builder) tracedAttempt(client *http.Client, rawURL string) error {
+	if b.tracer == nil {
+		return b.timedAttempt(client, rawURL)
+	}
+	attempt := Attempt{
+		Method:   b.method,
+		URL:      redactURL(rawURL),
+		Number:   b.progress.Attempt,
+		Previous: b.traceCtx,
+	}
+	ctx, end := b.tracer.StartAttempt(b.ctx, attempt)
+	b.traceCtx = ctx
+
+	// Clear the result so a response from an earlier attempt is not
+	// reported for this one, and restore it if none is received.
+	last := b.result
+	b.result = Result{}
+	err := b.withContext(ctx, func() error {
+		return b.timedAttempt(client, rawURL)
+	})
+	attempt.StatusCode = b.result.StatusCode
+	if b.result.StatusCode == 0 {
+		b.result = last
+	}
+	attempt.BytesWritten = b.progress.BytesWritten
+	attempt.Err = err
+	end(attempt)
+	return err
+}
@@ -0,0 +1,103 @@
+package retrieve_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type attemptKey struct{}
+
+// recordingTracer records the attempts it traces and tags their contexts
+// with their number.
+type recordingTracer struct {
+	started []retrieve.Attempt
+	ended   []retrieve.Attempt
+	seen    []any
+}
+
+func (t *recordingTracer) StartAttempt(ctx context.Context, attempt retrieve.Attempt) (context.Context, func(retrieve.Attempt)) {
+	t.started = append(t.started, attempt)
+	return context.WithValue(ctx, attemptKey{}, attempt.Number), func(attempt retrieve.Attempt) {
+		t.ended = append(t.ended, attempt)
+	}
+}
+
+func TestWithTracing(t *testing.T) {
+	tracer := &recordingTracer{}
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	b := retrieve.New(server.URL+"/file").
+		WithTracing(tracer).
+		SetRetries(1).
+		SetRetryBackoff(0, 0).
+		SetTransport(&contextTransport{base: http.DefaultTransport, tracer: tracer})
+	assert.Same(t, tracer, b.GetTracer())
+	_, err := b.ExecBytes()
+	assert.NoError(t, err)
+
+	if assert.Len(t, tracer.started, 2) && assert.Len(t, tracer.ended, 2) {
+		assert.Equal(t, http.MethodGet, tracer.started[0].Method)
+		assert.Equal(t, server.URL+"/file", tracer.started[0].URL)
+		assert.Equal(t, 1, tracer.started[0].Number)
+		assert.Nil(t, tracer.started[0].Previous)
+		assert.Equal(t, 2, tracer.started[1].Number)
+		assert.Equal(t, 1, tracer.started[1].Previous.Value(attemptKey{}))
+
+		assert.Equal(t, http.StatusBadGateway, tracer.ended[0].StatusCode)
+		assert.Error(t, tracer.ended[0].Err)
+		assert.Equal(t, http.StatusOK, tracer.ended[1].StatusCode)
+		assert.EqualValues(t, len("content"), tracer.ended[1].BytesWritten)
+		assert.NoError(t, tracer.ended[1].Err)
+	}
+	assert.Equal(t, []any{1, 2}, tracer.seen)
+}
+
+// contextTransport records the attempt number in the context of each request.
+type contextTransport struct {
+	base   http.RoundTripper
+	tracer *recordingTracer
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.tracer.seen = append(t.tracer.seen, req.Context().Value(attemptKey{}))
+	return t.base.RoundTrip(req)
+}
+
+func TestWithTracing_NoResponse(t *testing.T) {
+	var requests atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		server.CloseClientConnections()
+	}))
+	defer server.Close()
+
+	tracer := &recordingTracer{}
+	result, err := retrieve.New(server.URL).WithTracing(tracer).SetRetries(1).SetRetryBackoff(0, 0).
+		SetWriter(io.Discard).ExecWithResult()
+	assert.Error(t, err)
+	if assert.Len(t, tracer.ended, 2) {
+		assert.Equal(t, http.StatusServiceUnavailable, tracer.ended[0].StatusCode)
+		assert.Equal(t, 0, tracer.ended[1].StatusCode)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+}
@@ -0,0 +1,226 @@
+package retrieve
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// DefaultClient is the client used by every Builder without SetClient.
+// Sharing it lets sequential downloads reuse keep-alive connections
+// instead of paying for a new TCP and TLS handshake for each file. It may
+// be replaced or reconfigured before use; the timeout set with SetTimeout
+// applies instead of its Timeout.
+//
+// Its transport is a clone of http.DefaultTransport that keeps more idle
+// connections to each host, for segmented downloads.
+var DefaultClient = &http.Client{Transport: newDefaultTransport()}
+
+// defaultMaxIdleConnsPerHost is the number of idle connections to a host
+// the transport of DefaultClient keeps.
+const defaultMaxIdleConnsPerHost = 16
+
+func newDefaultTransport() http.RoundTripper {
+	t, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return http.DefaultTransport
+	}
+	t = t.Clone()
+	t.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	return t
+}
+
+// baseClient returns the client set with SetClient, or DefaultClient.
+func (b *Builder) baseClient() *http.Client {
+	if b.client != nil {
+		return b.client
+	}
+	return DefaultClient
+}
+
+// httpClient returns the client used to execute the request and a function
+// that releases any resources allocated for it.
+func (b *Builder) httpClient() (*http.Client, func()) {
+	base := b.baseClient()
+	transport, release := b.roundTripper()
+	if b.usesOtherSchemes() {
+		if transport == nil {
+			transport = http.DefaultTransport
+			if base.Transport != nil {
+				transport = base.Transport
+			}
+		}
+		transport = b.newSchemeTransport(transport)
+	}
+	if b.hasMiddleware() {
+		if transport == nil {
+			transport = http.DefaultTransport
+			if base.Transport != nil {
+				transport = base.Transport
+			}
+		}
+		transport = b.wrapTransport(transport)
+	}
+	if b.client != nil && transport == nil && !b.hasRedirectPolicy() {
+		return b.client, release
+	}
+	client := *base
+	if b.client == nil {
+		client.Timeout = b.clientTimeout()
+	}
+	if transport != nil {
+		client.Transport = transport
+	}
+	if b.hasRedirectPolicy() {
+		client.CheckRedirect = b.checkRedirect(base.CheckRedirect)
+	}
+	return &client, release
+}
+
+// roundTripper returns the transport used to execute the request, or nil if
+// the client's own transport should be used.
+//
+// When options that require a dedicated transport are set, the client's
+// transport (or http.DefaultTransport) is cloned and configured, and the
+// returned release function closes its idle connections.
+func (b *Builder) roundTripper() (http.RoundTripper, func()) {
+	if b.transport != nil {
+		return b.transport, func() {}
+	}
+	if b.http3 {
+		return b.http3RoundTripper()
+	}
+	if !b.needsTransport() {
+		return nil, func() {}
+	}
+
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if client := b.baseClient(); client.Transport != nil {
+		base, ok = client.Transport.(*http.Transport)
+	}
+	if !ok {
+		return nil, func() {}
+	}
+
+	t := base.Clone()
+	b.configureTransport(t)
+	return t, t.CloseIdleConnections
+}
+
+// checkTransport rejects options that configure the transport when it
+// cannot be configured: when SetTransport is used or the client's
+// transport is not an *http.Transport.
+func (b *Builder) checkTransport() error {
+	if !b.needsTransport() || b.transport == nil && b.http3 {
+		return nil
+	}
+	if _, ok := b.baseClient().Transport.(*http.Transport); b.transport != nil ||
+		!ok && b.baseClient().Transport != nil {
+		return errors.New("proxy, TLS, dialer, timeout and header limit options require the standard transport")
+	}
+	return nil
+}
+
+// needsTransport reports whether any option requires a dedicated transport.
+func (b *Builder) needsTransport() bool {
+	return b.proxy != nil ||
+		b.needsTLSConfig() ||
+		b.needsDialer() ||
+		b.maxHeaderBytes > 0 ||
+		b.tlsHandshakeTimeout > 0 ||
+		b.responseHeaderTimeout > 0 ||
+		b.http2 != nil
+}
+
+// configureTransport applies the builder's options to t.
+func (b *Builder) configureTransport(t *http.Transport) {
+	if b.proxy != nil {
+		t.Proxy = b.proxy
+	}
+	if b.needsDialer() {
+		t.DialContext = b.dialContext
+	}
+	if b.unixSocket != "" {
+		t.Proxy = nil
+	}
+	if b.needsTLSConfig() {
+		t.TLSClientConfig = b.buildTLSConfig(t.TLSClientConfig)
+	}
+	if b.maxHeaderBytes > 0 {
+		t.MaxResponseHeaderBytes = b.maxHeaderBytes
+	}
+	if b.tlsHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = b.tlsHandshakeTimeout
+	}
+	if b.responseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = b.responseHeaderTimeout
+	}
+	if b.http2 != nil {
+		b.configureHTTP2(t)
+	}
+}
+
+// hasMiddleware reports whether any option wraps the transport.
+func (b *Builder) hasMiddleware() bool {
+	return b.digestAuth != nil ||
+		b.awsSigner != nil ||
+		b.reauth != nil ||
+		b.responseCache != nil ||
+		b.idleReadTimeout > 0 ||
+		b.auditLog != nil
+}
+
+// wrapTransport wraps rt with the builder's request middleware, such as
+// authentication. The cache is applied last so cached responses skip the rest.
+func (b *Builder) wrapTransport(rt http.RoundTripper) http.RoundTripper {
+	if b.auditLog != nil {
+		rt = &auditTransport{base: rt, b: b}
+	}
+	if b.digestAuth != nil {
+		rt = &digestTransport{base: rt, creds: b.digestAuth}
+	}
+	if b.awsSigner != nil {
+		rt = &awsV4Transport{base: rt, signer: b.awsSigner}
+	}
+	if b.reauth != nil {
+		rt = &reauthTransport{base: rt, state: b.reauth}
+	}
+	if b.idleReadTimeout > 0 {
+		rt = &stallTransport{base: rt, timeout: b.idleReadTimeout}
+	}
+	if b.responseCache != nil {
+		rt = b.responseCache.Transport(rt)
+	}
+	return rt
+}
+
+// readRequestBody returns a copy of the request body so the request can be resent.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		defer req.Body.Close()
+		return io.ReadAll(req.Body)
+	}
+	req.Body.Close()
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// cloneRequest returns a copy of req with a fresh body.
+func cloneRequest(req *http.Request, body []byte) *http.Request {
+	clone := req.Clone(req.Context())
+	if body != nil {
+		clone.Body = io.NopCloser(bytes.NewReader(body))
+		clone.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	return clone
+}
@@ -0,0 +1,53 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_TransportOptionsRequireStandardTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	custom := roundTripperFunc(http.DefaultTransport.RoundTrip)
+	tests := []struct {
+		name string
+		b    *retrieve.Builder
+	}{
+		{
+			name: "SetTransport",
+			b:    retrieve.New(server.URL).SetTransport(custom).SetProxy("http://proxy.invalid:8080"),
+		},
+		{
+			name: "SetClient",
+			b:    retrieve.New(server.URL).SetClient(&http.Client{Transport: custom}).InsecureSkipVerify(),
+		},
+		{
+			name: "timeout",
+			b:    retrieve.New(server.URL).SetTransport(custom).SetConnectTimeout(time.Second),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.b.ExecBytes()
+			assert.ErrorContains(t, err, "require the standard transport")
+		})
+	}
+
+	// Without such options, custom transports are used as they are.
+	data, err := retrieve.New(server.URL).SetTransport(custom).ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(data))
+
+	data, err = retrieve.New(server.URL).SetClient(&http.Client{Transport: custom}).ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", string(data))
+}
@@ -0,0 +1,66 @@
+package retrieve
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrTruncated is returned when a response body ends before its
+// Content-Length. Truncated downloads are retried, resuming where possible.
+var ErrTruncated = errors.New("download truncated")
+
+// TruncatedError reports a response body shorter than its Content-Length.
+type TruncatedError struct {
+	Received int64 // bytes of the body received
+	Expected int64 // the Content-Length
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("%s: received %d of %d bytes", ErrTruncated, e.Received, e.Expected)
+}
+
+func (e *TruncatedError) Is(target error) bool {
+	return target == ErrTruncated
+}
+
+// KeepTruncated sets whether the partially written file of a download that
+// fails with ErrTruncated is kept, so a later Exec can resume it. It is
+// kept by default; otherwise it is removed once retries are exhausted.
+func (b *Builder) KeepTruncated(keep bool) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.removeTruncated = !keep
+	return b
+}
+
+// IsKeepTruncated returns whether the file of a truncated download is kept.
+func (b *Builder) IsKeepTruncated() bool {
+	return !b.removeTruncated
+}
+
+// checkLength turns a copy of n bytes from a body of contentLength bytes
+// (-1 if unknown) that ended early into a TruncatedError. err is the
+// error of the copy.
+func checkLength(n, contentLength int64, err error) error {
+	if contentLength < 0 || n >= contentLength {
+		return err
+	}
+	if err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &readError{err: &TruncatedError{Received: n, Expected: contentLength}}
+	}
+	return err
+}
+
+// discardIncomplete removes the output of a truncated download, unless it
+// is to be kept, or of one that exceeded the maximum size.
+func (b *Builder) discardIncomplete(err error) {
+	if b.writer != nil || b.result.Output == "" {
+		return
+	}
+	if errors.Is(err, ErrTooLarge) || b.removeTruncated && errors.Is(err, ErrTruncated) {
+		removeIncomplete(b.writePath(b.result.Output))
+		b.partial = nil
+	}
+}
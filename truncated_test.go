@@ -0,0 +1,93 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// shortTransport answers every request with a body shorter than its
+// Content-Length that ends without an error.
+type shortTransport struct{}
+
+func (shortTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Length": {"100"}},
+		ContentLength: 100,
+		Body:          io.NopCloser(strings.NewReader("only forty bytes of the hundred promised")),
+		Request:       req,
+	}, nil
+}
+
+func TestKeepTruncated(t *testing.T) {
+	assert.True(t, retrieve.New("http://example.com").IsKeepTruncated())
+	assert.False(t, retrieve.New("http://example.com").KeepTruncated(false).IsKeepTruncated())
+}
+
+func TestExec_TruncatedSilently(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New("http://example.com/file").
+		SetOutput(output).
+		SetTransport(shortTransport{}).
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrTruncated)
+
+	var truncErr *retrieve.TruncatedError
+	if assert.True(t, errors.As(err, &truncErr)) {
+		assert.Equal(t, int64(40), truncErr.Received)
+		assert.Equal(t, int64(100), truncErr.Expected)
+	}
+
+	// The partial file is kept by default.
+	assert.FileExists(t, output+".part")
+	assert.NoFileExists(t, output)
+}
+
+func TestExec_TruncatedConnection(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 10000)
+	server := newDyingServer(data, 4000, "")
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "out.bin")
+	err := retrieve.New(server.URL).SetOutput(output).Exec()
+	assert.ErrorIs(t, err, retrieve.ErrTruncated)
+	assert.FileExists(t, output+".part")
+}
+
+func TestExec_TruncatedRemoved(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 10000)
+	server := newDyingServer(data, 4000, "")
+	defer server.Close()
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "out.bin")
+	err := retrieve.New(server.URL).
+		SetOutput(output).
+		SetRetries(1).
+		SetRetryBackoff(0, 0).
+		KeepTruncated(false).
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrTruncated)
+
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+func TestExec_TruncatedWriter(t *testing.T) {
+	var buf bytes.Buffer
+	err := retrieve.New("http://example.com/file").
+		SetWriter(&buf).
+		SetTransport(shortTransport{}).
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrTruncated)
+}
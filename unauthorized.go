@@ -0,0 +1,133 @@
+package retrieve
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Challenge describes a 401 Unauthorized response passed to an OnUnauthorized hook.
+type Challenge struct {
+	// URL is the URL of the rejected request.
+	URL string
+
+	// Scheme is the authentication scheme of the first challenge, such as "Bearer" or "Basic".
+	Scheme string
+
+	// Params holds the auth-params of the first challenge, such as "realm",
+	// "service" and "scope", keyed in lowercase.
+	Params map[string]string
+
+	// Header holds the raw WWW-Authenticate header values.
+	Header []string
+}
+
+// OnUnauthorized registers a hook called when the server responds with
+// 401 Unauthorized, so the application can obtain fresh credentials, for
+// example through an OAuth device flow or a registry token exchange.
+//
+// The hook returns a new Authorization header value, such as "Bearer <token>",
+// and the rejected request is retried once with it. The value is also sent
+// with every later request made by the Builder to the host that issued the
+// challenge, but not to other hosts, such as the target of a cross-host
+// redirect. If the hook returns an error, Exec fails with it.
+func (b *Builder) OnUnauthorized(fn func(ctx context.Context, c Challenge) (string, error)) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.reauth = &reauthState{fn: fn}
+	return b
+}
+
+// reauthState holds the hook, the latest authorization it returned and the
+// host it applies to.
+type reauthState struct {
+	fn func(ctx context.Context, c Challenge) (string, error)
+
+	mu   sync.Mutex
+	host string
+	auth string
+}
+
+// current returns the authorization to send to host, if any.
+func (s *reauthState) current(host string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !strings.EqualFold(s.host, host) {
+		return ""
+	}
+	return s.auth
+}
+
+func (s *reauthState) set(host, auth string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.host = host
+	s.auth = auth
+}
+
+// reauthError marks an error returned by the OnUnauthorized hook so that it is not retried.
+type reauthError struct {
+	err error
+}
+
+func (e *reauthError) Error() string {
+	return "failed to refresh authorization: " + e.err.Error()
+}
+
+func (e *reauthError) Unwrap() error {
+	return e.err
+}
+
+// reauthTransport calls the OnUnauthorized hook on 401 responses and
+// retries the request once with the authorization it returns.
+type reauthTransport struct {
+	base  http.RoundTripper
+	state *reauthState
+}
+
+func (t *reauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	req = cloneRequest(req, body)
+	if auth := t.state.current(req.URL.Host); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	auth, err := t.state.fn(req.Context(), newChallenge(req, resp))
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, &reauthError{err: err}
+	}
+	t.state.set(req.URL.Host, auth)
+
+	retry := cloneRequest(req, body)
+	retry.Header.Set("Authorization", auth)
+	return t.base.RoundTrip(retry)
+}
+
+// newChallenge describes the 401 response resp to req.
+func newChallenge(req *http.Request, resp *http.Response) Challenge {
+	c := Challenge{
+		URL:    req.URL.String(),
+		Params: make(map[string]string),
+		Header: resp.Header.Values("WWW-Authenticate"),
+	}
+	if len(c.Header) > 0 {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(c.Header[0]), " ")
+		c.Scheme = scheme
+		c.Params = parseAuthParams(rest)
+	}
+	return c
+}
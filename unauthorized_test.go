@@ -0,0 +1,130 @@
+package retrieve_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnUnauthorized(t *testing.T) {
+	token := "fresh"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="https://auth.example.com/token",service="registry",scope="repository:app:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("secret"))
+	}))
+	defer server.Close()
+
+	var challenges []retrieve.Challenge
+	output := filepath.Join(t.TempDir(), "out")
+	b := retrieve.New(server.URL).
+		SetOutput(output).
+		SetHeader("Authorization", "Bearer stale").
+		OnUnauthorized(func(ctx context.Context, c retrieve.Challenge) (string, error) {
+			challenges = append(challenges, c)
+			return "Bearer " + token, nil
+		})
+
+	assert.NoError(t, b.Exec())
+	data, _ := os.ReadFile(output)
+	assert.Equal(t, "secret", string(data))
+
+	if assert.Len(t, challenges, 1) {
+		c := challenges[0]
+		assert.Equal(t, server.URL, c.URL)
+		assert.Equal(t, "Bearer", c.Scheme)
+		assert.Equal(t, "https://auth.example.com/token", c.Params["realm"])
+		assert.Equal(t, "registry", c.Params["service"])
+		assert.Equal(t, "repository:app:pull", c.Params["scope"])
+	}
+
+	// The refreshed authorization is reused without calling the hook again.
+	assert.NoError(t, b.Exec())
+	assert.Len(t, challenges, 1)
+}
+
+func TestOnUnauthorized_CrossHostRedirect(t *testing.T) {
+	var cdnAuth []string
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cdnAuth = append(cdnAuth, r.Header.Get("Authorization"))
+		w.Write([]byte("blob"))
+	}))
+	defer cdn.Close()
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="https://auth.example.com/token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, cdn.URL+"/blob", http.StatusTemporaryRedirect)
+	}))
+	defer registry.Close()
+
+	b := retrieve.New(registry.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out")).
+		OnUnauthorized(func(ctx context.Context, c retrieve.Challenge) (string, error) {
+			return "Bearer fresh", nil
+		})
+
+	// The refreshed authorization is not sent to the redirect target, on
+	// the first download or on later ones.
+	assert.NoError(t, b.Exec())
+	assert.NoError(t, b.Exec())
+	assert.Equal(t, []string{"", ""}, cdnAuth)
+}
+
+func TestOnUnauthorized_RetriesOnce(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	calls := 0
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out")).
+		OnUnauthorized(func(ctx context.Context, c retrieve.Challenge) (string, error) {
+			calls++
+			return "Bearer wrong", nil
+		}).
+		Exec()
+
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 2, requests)
+}
+
+func TestOnUnauthorized_HookError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	errDenied := errors.New("login denied")
+	calls := 0
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out")).
+		SetRetries(3).
+		OnUnauthorized(func(ctx context.Context, c retrieve.Challenge) (string, error) {
+			calls++
+			return "", errDenied
+		}).
+		Exec()
+	assert.ErrorIs(t, err, errDenied)
+	assert.Equal(t, 1, calls)
+}
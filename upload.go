@@ -0,0 +1,104 @@
+package retrieve
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// prepareBody completes the request body set with SetBody: a file is sent
+// with its size as the Content-Length and left open so it can be sent
+// again on retry, and reads are reported as upload progress.
+func (b *Builder) prepareBody(req *http.Request) *uploadTracker {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	if _, ok := b.body.(io.Closer); ok {
+		req.Body = io.NopCloser(b.body)
+	}
+	if f, ok := b.body.(*os.File); ok && req.ContentLength == 0 {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			if pos, err := f.Seek(0, io.SeekCurrent); err == nil {
+				req.ContentLength = info.Size() - pos
+			}
+		}
+	}
+
+	b.progress.BytesSent = 0
+	b.progress.TotalBytesToSend = req.ContentLength
+	if req.ContentLength == 0 {
+		b.progress.TotalBytesToSend = -1
+	}
+	if b.onProgress == nil {
+		return nil
+	}
+
+	t := &uploadTracker{b: b}
+	req.Body = t.wrap(req.Body)
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			t.reset()
+			return t.wrap(body), nil
+		}
+	}
+	return t
+}
+
+// uploadTracker reports the bytes of a request body read by the transport.
+// The transport may keep reading after the response arrives, so reports
+// stop once the response is being handled.
+type uploadTracker struct {
+	mu   sync.Mutex
+	b    *Builder
+	done bool
+}
+
+func (t *uploadTracker) wrap(body io.ReadCloser) io.ReadCloser {
+	return &uploadReader{ReadCloser: body, t: t}
+}
+
+func (t *uploadTracker) add(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.done {
+		t.b.progress.BytesSent += int64(n)
+		t.b.emitProgress()
+	}
+}
+
+func (t *uploadTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.done {
+		t.b.progress.BytesSent = 0
+	}
+}
+
+// stop ends progress reports. It is safe to call on a nil tracker.
+func (t *uploadTracker) stop() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.done = true
+	t.mu.Unlock()
+}
+
+type uploadReader struct {
+	io.ReadCloser
+	t *uploadTracker
+}
+
+func (ur *uploadReader) Read(p []byte) (int, error) {
+	n, err := ur.ReadCloser.Read(p)
+	if n > 0 {
+		ur.t.add(n)
+	}
+	return n, err
+}
@@ -0,0 +1,157 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_UploadProgress(t *testing.T) {
+	payload := bytes.Repeat([]byte("u"), 256<<10)
+	var received []byte
+	var contentLength int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		received, _ = io.ReadAll(r.Body)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var mu sync.Mutex
+	var events []retrieve.Progress
+	_, err := retrieve.New(server.URL).
+		SetMethod(http.MethodPost).
+		SetBody(payload).
+		OnProgress(func(p retrieve.Progress) {
+			mu.Lock()
+			events = append(events, p)
+			mu.Unlock()
+		}).
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, payload, received)
+	assert.Equal(t, int64(len(payload)), contentLength)
+
+	mu.Lock()
+	defer mu.Unlock()
+	var last retrieve.Progress
+	for _, p := range events {
+		if p.BytesSent > 0 {
+			assert.GreaterOrEqual(t, p.BytesSent, last.BytesSent)
+			last = p
+		}
+	}
+	assert.Equal(t, int64(len(payload)), last.BytesSent)
+	assert.Equal(t, int64(len(payload)), last.TotalBytesToSend)
+	assert.Equal(t, float64(100), last.UploadPercent())
+}
+
+func TestExec_UploadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upload.bin")
+	payload := bytes.Repeat([]byte("file"), 10000)
+	assert.NoError(t, os.WriteFile(path, payload, 0o644))
+
+	var requests atomic.Int32
+	var contentLength int64
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		contentLength, received = r.ContentLength, body
+	}))
+	defer server.Close()
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var last retrieve.Progress
+	_, err = retrieve.New(server.URL).
+		SetMethod(http.MethodPut).
+		SetBody(f).
+		SetRetries(1).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		OnProgress(func(p retrieve.Progress) { last = p }).
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, int64(len(payload)), contentLength)
+	assert.Equal(t, payload, received)
+	assert.Equal(t, int64(len(payload)), last.BytesSent)
+
+	// The file is left open for the caller.
+	_, err = f.Seek(0, io.SeekStart)
+	assert.NoError(t, err)
+}
+
+func TestExec_UploadUnknownSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	var last retrieve.Progress
+	_, err := retrieve.New(server.URL).
+		SetMethod(http.MethodPost).
+		SetBody(io.MultiReader(strings.NewReader("abc"), strings.NewReader("def"))).
+		OnProgress(func(p retrieve.Progress) { last = p }).
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), last.BytesSent)
+	assert.Equal(t, int64(-1), last.TotalBytesToSend)
+	assert.Equal(t, float64(-1), last.UploadPercent())
+}
+
+func TestExec_UploadStreamNotRetried(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	// The stream is used up by the first attempt, so it is not retried
+	// with an empty body.
+	_, err := retrieve.New(server.URL).
+		SetMethod(http.MethodPost).
+		SetBody(io.MultiReader(strings.NewReader("payload"))).
+		SetRetries(2).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		ExecBytes()
+	var statusErr *retrieve.StatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	assert.Equal(t, []string{"payload"}, bodies)
+
+	// A seekable body is rewound and resent.
+	bodies = nil
+	data, err := retrieve.New(server.URL).
+		SetMethod(http.MethodPost).
+		SetBody(strings.NewReader("payload")).
+		SetRetries(2).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "payload", string(data))
+	assert.Equal(t, []string{"payload", "payload"}, bodies)
+}
@@ -0,0 +1,53 @@
+package retrieve
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// SetURLPolicy sets a function that approves every URL the request goes
+// to: the URL and its mirrors before anything is sent, and the target of
+// every redirect. Returning an error stops Exec with that error, without
+// retrying, so embedders can enforce a domain allowlist in one place:
+//
+//	SetURLPolicy(func(u *url.URL) error {
+//		if u.Hostname() != "downloads.example.com" {
+//			return errors.New("host not allowed")
+//		}
+//		return nil
+//	})
+//
+// URLs are checked after Resolvers and AsOf have translated them.
+func (b *Builder) SetURLPolicy(policy func(u *url.URL) error) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.urlPolicy = policy
+	return b
+}
+
+// checkURLPolicy checks the URL and mirrors with the policy set with
+// SetURLPolicy.
+func (b *Builder) checkURLPolicy() error {
+	if b.urlPolicy == nil {
+		return nil
+	}
+	for _, rawURL := range append([]string{b.url}, b.mirrors...) {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		if err := b.applyURLPolicy(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyURLPolicy checks u with the policy set with SetURLPolicy.
+func (b *Builder) applyURLPolicy(u *url.URL) error {
+	if err := b.urlPolicy(u); err != nil {
+		return fmt.Errorf("%s rejected by URL policy: %w", u.Redacted(), err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package retrieve_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errNotAllowed = errors.New("path not allowed")
+
+func allowPrefix(prefix string, checked *[]string) func(*url.URL) error {
+	return func(u *url.URL) error {
+		*checked = append(*checked, u.Path)
+		if !strings.HasPrefix(u.Path, prefix) {
+			return errNotAllowed
+		}
+		return nil
+	}
+}
+
+func TestSetURLPolicy(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/public/old":
+			http.Redirect(w, r, "/public/new", http.StatusFound)
+		case "/public/escape":
+			http.Redirect(w, r, "/private/secret", http.StatusFound)
+		default:
+			w.Write([]byte(r.URL.Path))
+		}
+	}))
+	defer server.Close()
+
+	var checked []string
+	data, err := retrieve.New(server.URL + "/public/old").SetURLPolicy(allowPrefix("/public/", &checked)).ExecBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, "/public/new", string(data))
+	assert.Equal(t, []string{"/public/old", "/public/new"}, checked)
+
+	checked = nil
+	_, err = retrieve.New(server.URL + "/public/escape").
+		SetURLPolicy(allowPrefix("/public/", &checked)).
+		SetRetries(2).
+		ExecBytes()
+	assert.ErrorIs(t, err, errNotAllowed)
+	assert.ErrorContains(t, err, "/private/secret rejected by URL policy")
+	assert.Equal(t, []string{"/public/escape", "/private/secret"}, checked)
+
+	hits.Store(0)
+	_, err = retrieve.New(server.URL + "/private/secret").SetURLPolicy(allowPrefix("/public/", &checked)).ExecBytes()
+	assert.ErrorIs(t, err, errNotAllowed)
+	_, err = retrieve.New(server.URL + "/public/file").
+		SetMirrors([]string{server.URL + "/private/file"}).
+		SetURLPolicy(allowPrefix("/public/", &checked)).
+		ExecBytes()
+	assert.ErrorIs(t, err, errNotAllowed)
+	assert.Zero(t, hits.Load())
+}
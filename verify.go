@@ -0,0 +1,109 @@
+package retrieve
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// Integrity describes the expected content of a file, as checked by Verify.
+// Only the fields that are set are checked.
+type Integrity struct {
+	// Size is the expected size of the file in bytes, if positive.
+	Size int64
+
+	// Algorithm is the hash algorithm of Checksum and Pieces: "md5",
+	// "sha256" or "sha512".
+	Algorithm string
+
+	// Checksum is the hex-encoded digest of the whole file.
+	Checksum string
+
+	// PieceSize and Pieces are the size of each piece and the hex-encoded
+	// digest of every piece, as with VerifyPieces.
+	PieceSize int64
+	Pieces    []string
+}
+
+// Verify checks the file at path against expected, reading it once. It
+// lets programs re-validate files downloaded earlier, such as at startup,
+// with the same checks Exec applies while downloading.
+//
+// Verify returns an error wrapping ErrChecksumMismatch if the file does not
+// match, naming the bad pieces if Pieces is set.
+func Verify(path string, expected Integrity) error {
+	if err := verify(path, expected); err != nil {
+		return fmt.Errorf("verifying %s: %w", path, err)
+	}
+	return nil
+}
+
+func verify(path string, expected Integrity) error {
+	algo := strings.ToLower(expected.Algorithm)
+	checksum := strings.ToLower(strings.TrimSpace(expected.Checksum))
+	if checksum != "" || len(expected.Pieces) > 0 {
+		if _, ok := hashFuncs[algo]; !ok {
+			return fmt.Errorf("unsupported checksum algorithm: %s", expected.Algorithm)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if expected.Size > 0 {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() != expected.Size {
+			return fmt.Errorf("%w: size is %d bytes, expected %d", ErrChecksumMismatch, info.Size(), expected.Size)
+		}
+	}
+
+	var writers []io.Writer
+	var h hash.Hash
+	if checksum != "" {
+		h = hashFuncs[algo]()
+		writers = append(writers, h)
+	}
+	var pw *pieceWriter
+	if len(expected.Pieces) > 0 {
+		if expected.PieceSize <= 0 {
+			return fmt.Errorf("invalid piece size: %d", expected.PieceSize)
+		}
+		pieces := &pieceSet{algo: algo, size: expected.PieceSize}
+		for _, p := range expected.Pieces {
+			pieces.hashes = append(pieces.hashes, strings.ToLower(strings.TrimSpace(p)))
+		}
+		pw = newPieceWriter(pieces)
+		writers = append(writers, pw)
+	}
+	if len(writers) == 0 {
+		return nil
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return err
+	}
+
+	if pw != nil {
+		bad, err := pw.result()
+		if err != nil {
+			return err
+		}
+		if len(bad) > 0 {
+			return fmt.Errorf("%w: pieces %v", ErrChecksumMismatch, bad)
+		}
+	}
+	if h != nil {
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != checksum {
+			return fmt.Errorf("%w: expected %s %s, got %s", ErrChecksumMismatch, algo, checksum, actual)
+		}
+	}
+	return nil
+}
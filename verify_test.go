@@ -0,0 +1,60 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	path := filepath.Join(t.TempDir(), "file.bin")
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+	sum := sha256.Sum256(data)
+
+	expected := retrieve.Integrity{
+		Size:      int64(len(data)),
+		Algorithm: "SHA256",
+		Checksum:  hex.EncodeToString(sum[:]),
+		PieceSize: 64,
+		Pieces:    pieceHashes(data, 64),
+	}
+	assert.NoError(t, retrieve.Verify(path, expected))
+	assert.NoError(t, retrieve.Verify(path, retrieve.Integrity{}))
+
+	corrupted := bytes.Clone(data)
+	corrupted[200] = 'x'
+	assert.NoError(t, os.WriteFile(path, corrupted, 0o644))
+	err := retrieve.Verify(path, expected)
+	assert.ErrorIs(t, err, retrieve.ErrChecksumMismatch)
+	assert.ErrorContains(t, err, "pieces [3]")
+
+	expected.Pieces = nil
+	assert.ErrorIs(t, retrieve.Verify(path, expected), retrieve.ErrChecksumMismatch)
+}
+
+func TestVerify_Size(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	assert.NoError(t, os.WriteFile(path, []byte("short"), 0o644))
+
+	err := retrieve.Verify(path, retrieve.Integrity{Size: 10})
+	assert.ErrorIs(t, err, retrieve.ErrChecksumMismatch)
+	assert.ErrorContains(t, err, path)
+}
+
+func TestVerify_Errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	assert.ErrorIs(t, retrieve.Verify(path, retrieve.Integrity{}), fs.ErrNotExist)
+
+	assert.NoError(t, os.WriteFile(path, []byte("data"), 0o644))
+	assert.ErrorContains(t, retrieve.Verify(path, retrieve.Integrity{Algorithm: "crc32", Checksum: "00"}), "unsupported checksum algorithm")
+	assert.ErrorContains(t, retrieve.Verify(path, retrieve.Integrity{Algorithm: "sha256", Pieces: []string{"00"}}), "invalid piece size")
+}
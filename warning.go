@@ -0,0 +1,157 @@
+package retrieve
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// WarningCode identifies the kind of condition reported by a Warning.
+type WarningCode string
+
+const (
+	// WarnFilenameSanitized is reported when the server-provided filename
+	// had to be altered before it could be used as an output path.
+	WarnFilenameSanitized WarningCode = "filename_sanitized"
+
+	// WarnContentTypeMismatch is reported when the response Content-Type
+	// does not match the media types requested in the Accept header.
+	WarnContentTypeMismatch WarningCode = "content_type_mismatch"
+
+	// WarnProtocolDowngrade is reported when an HTTPS request was redirected to plain HTTP.
+	WarnProtocolDowngrade WarningCode = "protocol_downgrade"
+
+	// WarnMissingContentLength is reported when the response does not declare its size.
+	WarnMissingContentLength WarningCode = "missing_content_length"
+)
+
+// defaultStrictWarnings are the warnings promoted to errors by Strict when no codes are given.
+var defaultStrictWarnings = []WarningCode{
+	WarnFilenameSanitized,
+	WarnContentTypeMismatch,
+	WarnProtocolDowngrade,
+	WarnMissingContentLength,
+}
+
+// Warning describes a condition that did not fail the download but may be worth surfacing.
+type Warning struct {
+	Code    WarningCode
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.Code, w.Message)
+}
+
+// WarningError is returned by Exec when a warning is promoted to an error by Strict.
+type WarningError struct {
+	Warning Warning
+}
+
+func (e *WarningError) Error() string {
+	return fmt.Sprintf("strict mode: %s", e.Warning)
+}
+
+// OnWarning registers a callback invoked for every warning raised during Exec.
+func (b *Builder) OnWarning(fn func(Warning)) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.onWarning = fn
+	return b
+}
+
+// GetWarnings returns the warnings raised by the most recent call to Exec.
+func (b *Builder) GetWarnings() []Warning {
+	return b.warnings
+}
+
+// Strict turns the given warnings into hard failures.
+//
+// If no codes are given, filename sanitization, content-type mismatches,
+// protocol downgrades and missing Content-Length are all treated as errors.
+func (b *Builder) Strict(codes ...WarningCode) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if len(codes) == 0 {
+		codes = defaultStrictWarnings
+	}
+	b.strict = make(map[WarningCode]bool, len(codes))
+	for _, code := range codes {
+		b.strict[code] = true
+	}
+	return b
+}
+
+// IsStrict reports whether the given warning is promoted to an error.
+func (b *Builder) IsStrict(code WarningCode) bool {
+	return b.strict[code]
+}
+
+// warn records a warning and forwards it to the registered callback.
+// It returns a *WarningError if the warning is promoted to an error by Strict.
+//
+// Identical warnings raised by later attempts of the same Exec are dropped.
+func (b *Builder) warn(code WarningCode, format string, args ...any) error {
+	w := Warning{Code: code, Message: fmt.Sprintf(format, args...)}
+	if !slices.Contains(b.warnings, w) {
+		b.warnings = append(b.warnings, w)
+		if b.onWarning != nil {
+			b.onWarning(w)
+		}
+	}
+	if b.strict[code] {
+		return &WarningError{Warning: w}
+	}
+	return nil
+}
+
+// checkResponse raises warnings for suspicious but non-fatal response properties.
+func (b *Builder) checkResponse(resp *http.Response, rawURL string) error {
+	if resp.Request != nil && resp.Request.URL.Scheme == "http" && strings.HasPrefix(strings.ToLower(rawURL), "https:") {
+		if err := b.warn(WarnProtocolDowngrade, "redirected from HTTPS to %s", resp.Request.URL.Redacted()); err != nil {
+			return err
+		}
+	}
+
+	if accept := b.headers["Accept"]; accept != "" {
+		contentType := resp.Header.Get("Content-Type")
+		if !acceptsMediaType(accept, contentType) {
+			if err := b.warn(WarnContentTypeMismatch, "received %q, expected %q", contentType, accept); err != nil {
+				return err
+			}
+		}
+	}
+
+	if resp.ContentLength < 0 {
+		if err := b.warn(WarnMissingContentLength, "response does not declare a Content-Length"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// acceptsMediaType reports whether contentType matches any media range in accept.
+func acceptsMediaType(accept, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	for _, part := range strings.Split(accept, ",") {
+		rng, _, _ := strings.Cut(part, ";")
+		rng = strings.ToLower(strings.TrimSpace(rng))
+		switch {
+		case rng == "*/*", rng == mediaType:
+			return true
+		case strings.HasSuffix(rng, "/*") && strings.TrimSuffix(rng, "/*") == typ:
+			return true
+		}
+	}
+	return false
+}
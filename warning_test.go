@@ -0,0 +1,131 @@
+package retrieve_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExec_WarnsOnSanitizedFilename(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="../../etc/passwd"`)
+		w.Write([]byte("success"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	var received []retrieve.Warning
+	dir := t.TempDir()
+	b := retrieve.New(server.URL).
+		SetOutput(dir).
+		OnWarning(func(w retrieve.Warning) {
+			received = append(received, w)
+		})
+	err := b.Exec()
+	assert.NoError(t, err)
+
+	warnings := b.GetWarnings()
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, retrieve.WarnFilenameSanitized, warnings[0].Code)
+	assert.Equal(t, warnings, received)
+
+	_, err = os.Stat(filepath.Join(dir, "passwd"))
+	assert.NoError(t, err)
+}
+
+func TestExec_NoWarnings(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="file.txt"`)
+		w.Write([]byte("success"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	b := retrieve.New(server.URL).SetOutput(t.TempDir())
+	err := b.Exec()
+	assert.NoError(t, err)
+	assert.Empty(t, b.GetWarnings())
+}
+
+func TestStrict(t *testing.T) {
+	b := retrieve.New("http://example.com").Strict()
+	assert.True(t, b.IsStrict(retrieve.WarnFilenameSanitized))
+	assert.True(t, b.IsStrict(retrieve.WarnMissingContentLength))
+
+	b = retrieve.New("http://example.com").Strict(retrieve.WarnProtocolDowngrade)
+	assert.True(t, b.IsStrict(retrieve.WarnProtocolDowngrade))
+	assert.False(t, b.IsStrict(retrieve.WarnFilenameSanitized))
+}
+
+func TestExec_StrictSanitizedFilename(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="../../etc/passwd"`)
+		w.Write([]byte("success"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	dir := t.TempDir()
+	err := retrieve.New(server.URL).
+		SetOutput(dir).
+		Strict().
+		Exec()
+
+	var warnErr *retrieve.WarningError
+	assert.ErrorAs(t, err, &warnErr)
+	assert.Equal(t, retrieve.WarnFilenameSanitized, warnErr.Warning.Code)
+
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+func TestExec_WarnsOnContentTypeMismatch(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html></html>"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	b := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out.json")).
+		SetHeader("Accept", "application/json")
+	err := b.Exec()
+	assert.NoError(t, err)
+
+	codes := []retrieve.WarningCode{}
+	for _, w := range b.GetWarnings() {
+		codes = append(codes, w.Code)
+	}
+	assert.Contains(t, codes, retrieve.WarnContentTypeMismatch)
+
+	err = b.Strict(retrieve.WarnContentTypeMismatch).Exec()
+	assert.Error(t, err)
+}
+
+func TestExec_StrictMissingContentLength(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("chunk"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("chunk"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	err := retrieve.New(server.URL).
+		SetOutput(filepath.Join(t.TempDir(), "out.txt")).
+		Strict(retrieve.WarnMissingContentLength).
+		Exec()
+	assert.Error(t, err)
+}
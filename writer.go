@@ -0,0 +1,89 @@
+package retrieve
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// SetWriter streams the response into w instead of a file, for example a
+// buffer, a pipe or an upload to another service. It takes precedence over
+// SetOutput, and options that operate on the output file, such as Atomic,
+// OnlyIfModified and SetOverwritePolicy, do not apply.
+//
+// Since data written to w cannot be taken back, a failed download is only
+// retried or moved to a mirror if nothing was written yet. Checksums are
+// verified as the data streams; a mismatch is reported once the whole
+// response has been written.
+func (b *Builder) SetWriter(w io.Writer) *Builder {
+	if b.err != nil {
+		return b
+	}
+	b.writer = w
+	return b
+}
+
+// GetWriter returns the writer set with SetWriter, if any.
+func (b *Builder) GetWriter() io.Writer {
+	return b.writer
+}
+
+// partialWriteError marks a failure after data was written to the writer
+// set with SetWriter, so that it is not retried.
+type partialWriteError struct {
+	err error
+}
+
+func (e *partialWriteError) Error() string {
+	return e.err.Error()
+}
+
+func (e *partialWriteError) Unwrap() error {
+	return e.err
+}
+
+// copyToWriter writes the body of resp to the builder's writer, verifying
+// any checksum or piece hashes as it streams.
+func (b *Builder) copyToWriter(resp *http.Response) error {
+	if lb, ok := b.writer.(*limitedBuffer); ok {
+		if err := lb.checkSize(resp.ContentLength); err != nil {
+			return err
+		}
+		lb.memory = b.memory
+	}
+
+	writers := []io.Writer{b.writer}
+	h := b.newHash()
+	if h != nil {
+		writers = append(writers, h)
+	}
+	var pw *pieceWriter
+	if b.pieces != nil {
+		pw = newPieceWriter(b.pieces)
+		writers = append(writers, pw)
+	}
+
+	w := &progressWriter{w: io.MultiWriter(writers...), b: b}
+	n, err := b.copyBody(w, b.limitReader(b.ctx, b.limitSize(&bodyReader{r: resp.Body}, 0)))
+	err = checkLength(n, resp.ContentLength, err)
+	if err != nil {
+		if n > 0 {
+			return &partialWriteError{err: err}
+		}
+		return err
+	}
+
+	if pw != nil {
+		bad, err := pw.result()
+		if err != nil {
+			return err
+		}
+		if len(bad) > 0 {
+			return fmt.Errorf("%w: pieces %v", ErrChecksumMismatch, bad)
+		}
+	}
+	if h != nil {
+		return b.verifyHash(h)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package retrieve_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/retrieve"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetWriter(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("streamed"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	sum := sha256.Sum256([]byte("streamed"))
+	b := retrieve.New(server.URL).
+		SetOutput(dir).
+		SetWriter(&buf).
+		SetRetries(1).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		VerifyChecksum("sha256", hex.EncodeToString(sum[:]))
+	assert.Equal(t, &buf, b.GetWriter())
+
+	assert.NoError(t, b.Exec())
+	assert.Equal(t, "streamed", buf.String())
+
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+func TestSetWriter_ChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("streamed"))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	err := retrieve.New(server.URL).
+		SetWriter(&buf).
+		VerifyChecksum("sha256", "00").
+		Exec()
+	assert.ErrorIs(t, err, retrieve.ErrChecksumMismatch)
+}
+
+func TestSetWriter_NoRetryAfterWrite(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte("01234"))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	err := retrieve.New(server.URL).
+		SetMirrors([]string{server.URL}).
+		SetWriter(&buf).
+		SetRetries(3).
+		SetRetryBackoff(time.Millisecond, time.Millisecond).
+		Exec()
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+	assert.Equal(t, "01234", buf.String())
+}